@@ -7,6 +7,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/jgabor/spela/internal/config"
+	"github.com/jgabor/spela/internal/dll"
 )
 
 var ConfigCmd = &cobra.Command{
@@ -63,6 +64,17 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		cfg.ShaderCache = value
 	case "check_updates":
 		cfg.CheckUpdates = value == "true" || value == "1"
+	case "auto_rollback":
+		cfg.AutoRollback = value == "true" || value == "1"
+	case "apply_system_changes":
+		cfg.ApplySystemChanges = value == "true" || value == "1"
+	case "dll_signature_policy":
+		switch dll.SignaturePolicy(value) {
+		case dll.SignaturePolicyEnforce, dll.SignaturePolicyWarn, dll.SignaturePolicyOff:
+			cfg.DLLSignaturePolicy = value
+		default:
+			return fmt.Errorf("invalid dll_signature_policy: %s (expected enforce, warn or off)", value)
+		}
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
@@ -2,10 +2,13 @@ package commands
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/tui"
 )
 
 var CPUCmd = &cobra.Command{
@@ -34,10 +37,60 @@ var cpuSMTCmd = &cobra.Command{
 	RunE:  runCPUSMT,
 }
 
+var cpuTopologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "Show CPU topology and what affinity presets select",
+	Args:  cobra.NoArgs,
+	RunE:  runCPUTopology,
+}
+
+var cpuSCXCmd = &cobra.Command{
+	Use:   "scx",
+	Short: "Manage sched_ext schedulers",
+	Long:  "List, inspect and switch sched_ext schedulers through scx_loader, or scx.service when scx_loader is not available.",
+}
+
+var cpuSCXListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available schedulers",
+	Args:  cobra.NoArgs,
+	RunE:  runCPUSCXList,
+}
+
+var cpuSCXStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the running scheduler",
+	Args:  cobra.NoArgs,
+	RunE:  runCPUSCXStatus,
+}
+
+var cpuSCXSwitchCmd = &cobra.Command{
+	Use:   "switch <scheduler|none>",
+	Short: "Switch to a scheduler, or stop it with none",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCPUSCXSwitch,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		schedulers, _ := cpu.GetSchedulers()
+		return append(schedulers, profile.SchedulerNone), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+var scxMode string
+
 func init() {
 	CPUCmd.AddCommand(cpuInfoCmd)
 	CPUCmd.AddCommand(cpuGovernorCmd)
 	CPUCmd.AddCommand(cpuSMTCmd)
+	CPUCmd.AddCommand(cpuTopologyCmd)
+	CPUCmd.AddCommand(cpuSCXCmd)
+
+	cpuSCXSwitchCmd.Flags().StringVar(&scxMode, "mode", "", "Scheduler mode: auto, gaming, powersave, lowlatency or server (scx_loader only)")
+	cpuSCXCmd.AddCommand(cpuSCXListCmd)
+	cpuSCXCmd.AddCommand(cpuSCXStatusCmd)
+	cpuSCXCmd.AddCommand(cpuSCXSwitchCmd)
 }
 
 func runCPUInfo(cmd *cobra.Command, args []string) error {
@@ -52,8 +105,9 @@ func runCPUInfo(cmd *cobra.Command, args []string) error {
 	fmt.Printf("SMT:      %s\n", info["smt"])
 
 	if cpu.SCXIsAvailable() {
-		active, _ := cpu.SCXStatus()
-		fmt.Printf("SCX:      %v\n", active)
+		if status, err := cpu.SCXStatus(); err == nil {
+			fmt.Printf("SCX:      %s\n", status)
+		}
 	}
 
 	return nil
@@ -103,3 +157,103 @@ func runCPUSMT(cmd *cobra.Command, args []string) error {
 	fmt.Printf("SMT %s\n", status)
 	return nil
 }
+
+func runCPUTopology(cmd *cobra.Command, args []string) error {
+	topology, err := cpu.ReadTopology()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-5s %-5s %-8s %-9s %-10s %-12s %s\n", "CPU", "Core", "Package", "Siblings", "Max MHz", "L3", "Type")
+	for _, c := range topology.CPUs {
+		l3 := "-"
+		if len(c.L3) > 0 {
+			l3 = fmt.Sprintf("%s (%d MiB)", cpu.FormatCPUList(c.L3), c.L3Size/1024)
+		}
+		coreType := string(c.Type)
+		if coreType == "" {
+			coreType = "-"
+		}
+		fmt.Printf("%-5d %-5d %-8d %-9s %-10d %-12s %s\n", c.ID, c.Core, c.Package, cpu.FormatCPUList(c.Siblings), c.MaxFreq/1000, l3, coreType)
+	}
+
+	fmt.Println()
+	fmt.Println("Affinity presets:")
+	for _, preset := range cpu.AffinityPresets {
+		cpus, err := topology.Resolve(preset)
+		if err != nil {
+			fmt.Printf("  %-18s %s\n", preset, tui.CLIDim(err.Error()))
+			continue
+		}
+		fmt.Printf("  %-18s %s\n", preset, cpu.FormatCPUList(cpus))
+	}
+	return nil
+}
+
+func runCPUSCXList(cmd *cobra.Command, args []string) error {
+	schedulers, err := cpu.GetSchedulers()
+	if err != nil {
+		return fmt.Errorf("failed to list schedulers: %w", err)
+	}
+	if len(schedulers) == 0 {
+		fmt.Println("No sched_ext schedulers found.")
+		return nil
+	}
+
+	var current string
+	if status, err := cpu.SCXStatus(); err == nil && status.Running {
+		current = status.Scheduler
+	}
+	for _, scheduler := range schedulers {
+		if scheduler == current {
+			fmt.Printf("%s %s\n", tui.CLIPrimary(scheduler), tui.CLISuccess("(running)"))
+			continue
+		}
+		fmt.Println(scheduler)
+	}
+	return nil
+}
+
+func runCPUSCXStatus(cmd *cobra.Command, args []string) error {
+	if !cpu.SCXIsAvailable() {
+		return fmt.Errorf("neither scx_loader nor scx.service is available")
+	}
+	status, err := cpu.SCXStatus()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Scheduler: %s\n", status)
+	fmt.Printf("Backend:   %s\n", status.Backend)
+	if status.Backend == cpu.SCXBackendService && !status.Running && status.Scheduler != "" {
+		fmt.Printf("Configured: %s\n", status.Scheduler)
+	}
+	return nil
+}
+
+func runCPUSCXSwitch(cmd *cobra.Command, args []string) error {
+	mode := cpu.SCXMode(scxMode)
+	if mode != "" && !slices.Contains(cpu.SCXModes, mode) {
+		return fmt.Errorf("unknown scheduler mode: %s", mode)
+	}
+	if !cpu.SCXIsAvailable() {
+		return fmt.Errorf("neither scx_loader nor scx.service is available")
+	}
+
+	if args[0] == profile.SchedulerNone {
+		if err := cpu.SCXStop(); err != nil {
+			return fmt.Errorf("failed to stop scheduler (may need root): %w", err)
+		}
+		fmt.Println("sched_ext scheduler stopped")
+		return nil
+	}
+
+	scheduler := cpu.SCXSchedulerName(args[0])
+	if err := cpu.SCXStart(scheduler, mode); err != nil {
+		return fmt.Errorf("failed to switch scheduler (may need root): %w", err)
+	}
+	if mode != "" && cpu.SCXBackendInUse() == cpu.SCXBackendService {
+		fmt.Println(tui.CLIDim("Modes need scx_loader; ignoring --mode"))
+	}
+	fmt.Printf("Switched to %s\n", scheduler)
+	return nil
+}
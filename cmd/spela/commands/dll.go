@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -37,6 +38,26 @@ var dllUpdateCmd = &cobra.Command{
 	RunE:  runDLLUpdate,
 }
 
+var dllImportType string
+
+var dllImportCmd = &cobra.Command{
+	Use:   "import <path>",
+	Short: "Import a local DLL into the cache",
+	Long:  "Copy a DLL from disk into the DLL cache and make it available as an update source alongside the remote manifest.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDLLImport,
+}
+
+var dllHarvestDryRun bool
+
+var dllHarvestCmd = &cobra.Command{
+	Use:   "harvest",
+	Short: "Copy the newest DLLs found in installed games into the cache",
+	Long:  "Find the highest version of each DLL type across scanned games and import it into the DLL cache, so it can be used to update other games offline.",
+	Args:  cobra.NoArgs,
+	RunE:  runDLLHarvest,
+}
+
 var dllRestoreCmd = &cobra.Command{
 	Use:   "restore <game>",
 	Short: "Restore original DLLs from backup",
@@ -49,6 +70,11 @@ func init() {
 	DLLCmd.AddCommand(dllCheckCmd)
 	DLLCmd.AddCommand(dllUpdateCmd)
 	DLLCmd.AddCommand(dllRestoreCmd)
+	DLLCmd.AddCommand(dllImportCmd)
+	DLLCmd.AddCommand(dllHarvestCmd)
+
+	dllHarvestCmd.Flags().BoolVar(&dllHarvestDryRun, "dry-run", false, "Show what would be harvested without copying")
+	dllImportCmd.Flags().StringVar(&dllImportType, "type", "", "DLL type (dlss, dlssg, dlssd, xess, fsr); inferred from the file name if omitted")
 }
 
 func runDLLList(cmd *cobra.Command, args []string) error {
@@ -87,6 +113,9 @@ func runDLLList(cmd *cobra.Command, args []string) error {
 				version = "unknown"
 			}
 			fmt.Printf("  %s: %s\n", d.Name, version)
+			if details := dllDetails(d); details != "" {
+				fmt.Printf("    %s\n", details)
+			}
 			fmt.Printf("    %s\n", d.Path)
 		}
 	}
@@ -94,6 +123,25 @@ func runDLLList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func dllDetails(d game.DetectedDLL) string {
+	var parts []string
+	if d.Company != "" {
+		parts = append(parts, d.Company)
+	}
+	if d.Arch != "" {
+		parts = append(parts, d.Arch)
+	}
+	if !d.Timestamp.IsZero() && d.Timestamp.Unix() > 0 {
+		parts = append(parts, "built "+d.Timestamp.Format("2006-01-02"))
+	}
+	if d.Signed {
+		parts = append(parts, "signed")
+	} else if d.Arch != "" {
+		parts = append(parts, "unsigned")
+	}
+	return strings.Join(parts, ", ")
+}
+
 func runDLLCheckUpdates(cmd *cobra.Command, _ []string) error {
 	manifest, err := dll.GetManifest(false, "")
 	if err != nil {
@@ -194,9 +242,14 @@ func runDLLUpdate(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	fmt.Printf("Downloading %s %s...\n", dllType, latest.Version)
-
+	// Cached copies still go through DownloadDLLWithProgress, which checks
+	// them against the manifest checksum before they are swapped in.
+	downloading := false
 	cachePath, err := dll.DownloadDLLWithProgress(latest, dllType, func(downloaded, total int64) {
+		if !downloading {
+			downloading = true
+			fmt.Printf("Downloading %s %s...\n", dllType, latest.Version)
+		}
 		if total > 0 {
 			percent := float64(downloaded) / float64(total) * 100
 			fmt.Printf("\rDownloading: %.1f%%", percent)
@@ -204,7 +257,9 @@ func runDLLUpdate(cmd *cobra.Command, args []string) error {
 			fmt.Printf("\rDownloading: %d bytes", downloaded)
 		}
 	})
-	fmt.Println()
+	if downloading {
+		fmt.Println()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to download DLL: %w", err)
 	}
@@ -255,3 +310,63 @@ func runDLLRestore(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Restored original DLLs for %s\n", g.Name)
 	return nil
 }
+
+func runDLLImport(cmd *cobra.Command, args []string) error {
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	dllType := game.DLLType(strings.ToLower(dllImportType))
+	entry, err := dll.ImportDLL(path, dllType, dll.SourceImport, "Imported from "+path)
+	if err != nil {
+		return fmt.Errorf("failed to import DLL: %w", err)
+	}
+
+	fmt.Printf("Imported %s %s\n", entry.Filename, entry.Version)
+	fmt.Printf("  SHA256: %s\n", entry.SHA256)
+	fmt.Printf("  Size:   %d bytes\n", entry.Size)
+
+	if sig, err := dll.VerifyDLLSignature(path, entry.Filename); err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	} else {
+		fmt.Printf("  Signed by %s\n", sig.Signer)
+	}
+
+	return nil
+}
+
+func runDLLHarvest(cmd *cobra.Command, _ []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	candidates := dll.FindHarvestCandidates(db)
+	if len(candidates) == 0 {
+		fmt.Println("No DLLs with known versions found. Run 'spela scan' first.")
+		return nil
+	}
+
+	for _, c := range candidates {
+		name := string(c.DLL.Type)
+		fmt.Printf("%s %s from %s (%d)\n", name, c.DLL.Version, c.Game.Name, c.Game.AppID)
+
+		if _, ok := dll.FindCachedDLL(name, c.DLL.Version); ok {
+			fmt.Println("  Already cached")
+			continue
+		}
+		if dllHarvestDryRun {
+			continue
+		}
+
+		entry, err := dll.HarvestDLL(c)
+		if err != nil {
+			fmt.Printf("  Failed: %v\n", err)
+			continue
+		}
+		fmt.Printf("  Cached %s (%d bytes)\n", entry.Filename, entry.Size)
+	}
+
+	return nil
+}
@@ -3,11 +3,14 @@ package commands
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/gpu"
 	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/tui"
 )
 
 var DLSSCmd = &cobra.Command{
@@ -44,7 +47,7 @@ func init() {
 	dlssSetCmd.Flags().StringVar(&dlssSetSRPreset, "sr-preset", "", "DLSS-SR preset (default, A, B, C, D, E, F, J, K, L, M)")
 	dlssSetCmd.Flags().StringVar(&dlssSetRRMode, "rr-mode", "", "DLSS-RR mode")
 	dlssSetCmd.Flags().StringVar(&dlssSetFGEnabled, "fg", "", "Frame generation (true/false)")
-	dlssSetCmd.Flags().IntVar(&dlssSetMultiFrame, "multi-frame", -1, "Multi-frame count (0-3)")
+	dlssSetCmd.Flags().IntVar(&dlssSetMultiFrame, "multi-frame", -1, fmt.Sprintf("Multi-frame count (0-%d, depending on the GPU)", gpu.GPUGenerationBlackwell.MaxMultiFrame()))
 	dlssSetCmd.Flags().BoolVar(&dlssSetIndicator, "indicator", false, "Enable DLSS indicator")
 
 	DLSSCmd.AddCommand(dlssShowCmd)
@@ -81,21 +84,21 @@ func runDLSSShow(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Super Resolution (SR):\n")
 	fmt.Printf("  Mode:     %s\n", p.DLSS.SRMode)
 	fmt.Printf("  Preset:   %s\n", p.DLSS.SRPreset)
-	fmt.Printf("  Override: %v\n", p.DLSS.SROverride)
+	fmt.Printf("  Override: %v\n", profile.IsTrue(p.DLSS.SROverride))
 
 	fmt.Printf("\nRay Reconstruction (RR):\n")
 	fmt.Printf("  Mode:     %s\n", p.DLSS.RRMode)
 	fmt.Printf("  Preset:   %s\n", p.DLSS.RRPreset)
-	fmt.Printf("  Override: %v\n", p.DLSS.RROverride)
+	fmt.Printf("  Override: %v\n", profile.IsTrue(p.DLSS.RROverride))
 
 	fmt.Printf("\nFrame Generation (FG):\n")
-	fmt.Printf("  Enabled:     %v\n", p.DLSS.FGEnabled)
-	fmt.Printf("  Multi-frame: %d\n", p.DLSS.MultiFrame)
-	fmt.Printf("  Override:    %v\n", p.DLSS.FGOverride)
+	fmt.Printf("  Enabled:     %v\n", profile.IsTrue(p.DLSS.FGEnabled))
+	fmt.Printf("  Multi-frame: %d\n", profile.IntValue(p.DLSS.MultiFrame))
+	fmt.Printf("  Override:    %v\n", profile.IsTrue(p.DLSS.FGOverride))
 
 	fmt.Printf("\nDebug:\n")
-	fmt.Printf("  Indicator:    %v\n", p.DLSS.Indicator)
-	fmt.Printf("  FG Indicator: %v\n", p.DLSS.FGIndicator)
+	fmt.Printf("  Indicator:    %v\n", profile.IsTrue(p.DLSS.Indicator))
+	fmt.Printf("  FG Indicator: %v\n", profile.IsTrue(p.DLSS.FGIndicator))
 
 	return nil
 }
@@ -129,36 +132,36 @@ func runDLSSSet(cmd *cobra.Command, args []string) error {
 
 	if dlssSetSRMode != "" {
 		p.DLSS.SRMode = profile.DLSSMode(dlssSetSRMode)
-		p.DLSS.SROverride = true
+		p.DLSS.SROverride = profile.Bool(true)
 		changed = true
 	}
 
 	if dlssSetSRPreset != "" {
 		p.DLSS.SRPreset = profile.DLSSPreset(dlssSetSRPreset)
-		p.DLSS.SROverride = true
+		p.DLSS.SROverride = profile.Bool(true)
 		changed = true
 	}
 
 	if dlssSetRRMode != "" {
 		p.DLSS.RRMode = profile.DLSSMode(dlssSetRRMode)
-		p.DLSS.RROverride = true
+		p.DLSS.RROverride = profile.Bool(true)
 		changed = true
 	}
 
 	if dlssSetFGEnabled != "" {
-		p.DLSS.FGEnabled = dlssSetFGEnabled == "true" || dlssSetFGEnabled == "1"
-		p.DLSS.FGOverride = true
+		p.DLSS.FGEnabled = profile.Bool(dlssSetFGEnabled == "true" || dlssSetFGEnabled == "1")
+		p.DLSS.FGOverride = profile.Bool(true)
 		changed = true
 	}
 
 	if dlssSetMultiFrame >= 0 {
-		p.DLSS.MultiFrame = dlssSetMultiFrame
-		p.DLSS.FGOverride = true
+		p.DLSS.MultiFrame = profile.Int(dlssSetMultiFrame)
+		p.DLSS.FGOverride = profile.Bool(true)
 		changed = true
 	}
 
 	if cmd.Flags().Changed("indicator") {
-		p.DLSS.Indicator = dlssSetIndicator
+		p.DLSS.Indicator = profile.Bool(dlssSetIndicator)
 		changed = true
 	}
 
@@ -167,10 +170,24 @@ func runDLSSSet(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	for _, issue := range p.Validate(gpu.GPUGenerationUnknown) {
+		if strings.HasPrefix(issue.Key, "dlss.") {
+			return fmt.Errorf("%s: %s", issue.Key, issue.Message)
+		}
+	}
+
 	if err := profile.Save(g.AppID, p); err != nil {
 		return err
 	}
 
 	fmt.Printf("Updated DLSS configuration for %s\n", g.Name)
+
+	// Invalid values were rejected above, so what is left depends on the GPU.
+	gen, _ := gpu.GetGPUGeneration()
+	for _, issue := range p.Validate(gen) {
+		if strings.HasPrefix(issue.Key, "dlss.") {
+			fmt.Printf("%s %s: %s\n", tui.CLIError("Warning:"), issue.Key, issue.Message)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jgabor/spela/internal/doctor"
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/session"
+	"github.com/jgabor/spela/internal/tui"
+)
+
+var (
+	doctorRollback bool
+	doctorJSON     bool
+)
+
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor <game>",
+	Short: "Find out why a game stopped working",
+	Long: `Look at the recorded sessions of a game and compare the ones that crashed or
+exited early with the last session that ran cleanly. Lists the profile and DLL
+changes made in between, and suggests what to try next.
+
+With --rollback, revert the game profile and DLLs to the revision and
+versions of the last clean session. Set auto_rollback in the config to do
+this after every failed launch.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDoctor,
+}
+
+func init() {
+	DoctorCmd.Flags().BoolVar(&doctorRollback, "rollback", false, "Roll back to the profile and DLLs of the last clean session")
+	DoctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Output in JSON format")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	report, err := doctor.Diagnose(g)
+	if err != nil {
+		return err
+	}
+
+	if doctorRollback {
+		if report.Rollback.Empty() {
+			return fmt.Errorf("nothing to roll back for %s", g.Name)
+		}
+		if err := doctor.RollBack(g, report.Rollback); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", tui.CLISuccess("Rolled back to session"), tui.CLIPrimary(fmt.Sprintf("%d: %s", report.Rollback.Session, report.Rollback.Describe())))
+		return nil
+	}
+
+	if doctorJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%s %s %s\n", tui.CLIDim("Game:"), tui.CLIPrimary(report.Game), tui.CLIDim(fmt.Sprintf("(%d)", report.AppID)))
+	if report.Last != nil {
+		fmt.Printf("%s %s\n", tui.CLIDim("Last session:"), doctorSession(report.Last))
+	}
+	if report.LastGood != nil && report.LastGood != report.Last {
+		fmt.Printf("%s %s\n", tui.CLIDim("Last clean session:"), doctorSession(report.LastGood))
+	}
+	if report.Failures > 0 {
+		fmt.Printf("%s %s\n", tui.CLIDim("Failures since:"), tui.CLIError(strconv.Itoa(report.Failures)))
+	}
+
+	if len(report.Changes) > 0 {
+		fmt.Println("\n" + tui.CLISecondary("Changes:"))
+		for _, c := range report.Changes {
+			fmt.Printf("  %s %s\n", tui.CLIDim("["+c.Kind+"]"), c.Description)
+		}
+	}
+
+	if len(report.Issues) > 0 {
+		fmt.Println("\n" + tui.CLISecondary("Profile issues:"))
+		for _, issue := range report.Issues {
+			fmt.Printf("  %s\n", issue)
+		}
+	}
+
+	if report.Healthy() && len(report.Suggestions) == 0 {
+		fmt.Println("\n" + tui.CLISuccess("No problems found"))
+		return nil
+	}
+
+	if len(report.Suggestions) > 0 {
+		fmt.Println("\n" + tui.CLISecondary("Suggestions:"))
+		for _, s := range report.Suggestions {
+			fmt.Printf("  - %s\n", s)
+		}
+	}
+	return nil
+}
+
+func doctorSession(s *session.Session) string {
+	return fmt.Sprintf("%s %s %s", tui.CLIPrimary(strconv.Itoa(s.Number)), sessionStatus(s), tui.CLIDim("("+s.Start.Local().Format("2006-01-02 15:04")+")"))
+}
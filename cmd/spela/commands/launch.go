@@ -6,13 +6,19 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/jgabor/spela/internal/config"
 	"github.com/jgabor/spela/internal/env"
 	"github.com/jgabor/spela/internal/game"
 	"github.com/jgabor/spela/internal/launcher"
 	"github.com/jgabor/spela/internal/profile"
 )
 
-var launchGameID uint64
+var (
+	launchGameID uint64
+	launchDryRun bool
+	launchJSON   bool
+	launchAllEnv bool
+)
 
 var LaunchCmd = &cobra.Command{
 	Use:   "launch <game>",
@@ -24,6 +30,9 @@ var LaunchCmd = &cobra.Command{
 
 func init() {
 	LaunchCmd.Flags().Uint64Var(&launchGameID, "game-id", 0, "Launch by Steam App ID")
+	LaunchCmd.Flags().BoolVar(&launchDryRun, "dry-run", false, "Show the environment, system changes and command without launching")
+	LaunchCmd.Flags().BoolVar(&launchJSON, "json", false, "Output the dry run in JSON format")
+	LaunchCmd.Flags().BoolVar(&launchAllEnv, "all-env", false, "Include every inherited variable in the dry run")
 }
 
 func runLaunch(cmd *cobra.Command, args []string) error {
@@ -46,32 +55,44 @@ func runLaunch(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("game not found")
 	}
 
-	p, err := profile.Load(g.AppID)
+	r, err := profile.Resolve(g.AppID)
 	if err != nil {
 		return fmt.Errorf("failed to load profile: %w", err)
 	}
 
-	restore := profile.NewRestorePoint()
-	restore.SaveAllProfileEnvVars()
+	var p *profile.Profile
+	if len(r.Layers) > 0 {
+		p = r.Profile
+	}
 
-	e := env.New()
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
 
-	var cleanups []func()
+	e := env.New()
 	if p != nil {
-		cleanups = p.Apply(e)
+		p.Apply(e)
 	}
 
 	l := launcher.New(g)
+	l.Profile = p
+	l.Resolution = r
 	l.Environment = e
-
-	l.OnCleanup(restore.Restore)
-	for _, cleanup := range cleanups {
-		l.OnCleanup(cleanup)
+	l.Hooks = cfg.Hooks
+	l.RecordSession = true
+	l.AutoRollback = cfg.AutoRollback
+	l.ApplySystemChanges = cfg.ApplySystemChanges
+
+	// A single argument names the game, which is then started through
+	// Steam; anything more is the command to run.
+	var launchArgs []string
+	if launchGameID == 0 && len(args) > 1 {
+		launchArgs = args
 	}
 
-	launchArgs := args
-	if launchGameID != 0 || len(args) == 1 {
-		launchArgs = []string{"steam", fmt.Sprintf("steam://rungameid/%d", g.AppID)}
+	if launchDryRun {
+		return PrintLaunchPlan(l.Plan(launchArgs, launchAllEnv), launchJSON)
 	}
 
 	if p != nil {
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/session"
 	"github.com/jgabor/spela/internal/tui"
 )
 
@@ -44,8 +45,21 @@ func runList(cmd *cobra.Command, args []string) error {
 		return games[i].Name < games[j].Name
 	})
 
+	playtimes, err := session.Playtimes()
+	if err != nil {
+		return fmt.Errorf("failed to load sessions: %w", err)
+	}
+
 	if listJSON {
-		data, err := json.MarshalIndent(games, "", "  ")
+		type listedGame struct {
+			*game.Game
+			PlaytimeSeconds int64 `json:"playtime_seconds"`
+		}
+		listed := make([]listedGame, len(games))
+		for i, g := range games {
+			listed[i] = listedGame{Game: g, PlaytimeSeconds: int64(playtimes[g.AppID].Seconds())}
+		}
+		data, err := json.MarshalIndent(listed, "", "  ")
 		if err != nil {
 			return err
 		}
@@ -54,8 +68,13 @@ func runList(cmd *cobra.Command, args []string) error {
 	}
 
 	for _, g := range games {
+		var playtime string
+		if d := playtimes[g.AppID]; d > 0 {
+			playtime = " " + tui.CLIAccent(session.FormatDuration(d))
+		}
+
 		if listWithDLLs && len(g.DLLs) > 0 {
-			fmt.Printf("%s %s\n", tui.CLIPrimary(g.Name), tui.CLIDim(fmt.Sprintf("(%d)", g.AppID)))
+			fmt.Printf("%s %s%s\n", tui.CLIPrimary(g.Name), tui.CLIDim(fmt.Sprintf("(%d)", g.AppID)), playtime)
 			for _, d := range g.DLLs {
 				version := d.Version
 				if version == "" {
@@ -64,7 +83,7 @@ func runList(cmd *cobra.Command, args []string) error {
 				fmt.Printf("  %s: %s\n", tui.CLISecondary(d.Name), tui.CLIAccent(version))
 			}
 		} else {
-			fmt.Printf("%s %s\n", tui.CLIPrimary(g.Name), tui.CLIDim(fmt.Sprintf("(%d)", g.AppID)))
+			fmt.Printf("%s %s%s\n", tui.CLIPrimary(g.Name), tui.CLIDim(fmt.Sprintf("(%d)", g.AppID)), playtime)
 		}
 	}
 
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/session"
+	"github.com/jgabor/spela/internal/tui"
+)
+
+var (
+	logsSession int
+	logsFile    string
+	logsList    bool
+	logsPath    bool
+)
+
+var LogsCmd = &cobra.Command{
+	Use:   "logs <game>",
+	Short: "Show the logs of a game session",
+	Long: `Print the captured output of a game's latest session that has logs, or of
+the session given with --session. Use --list to see every log file in the
+session directory, such as Proton and DXVK logs, and --file to print one.
+
+Logs are written when the game's profile sets logging.capture or
+logging.debug.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLogs,
+}
+
+func init() {
+	LogsCmd.Flags().IntVar(&logsSession, "session", 0, "Session number (default: latest with logs)")
+	LogsCmd.Flags().StringVar(&logsFile, "file", session.LogName, "Log file to print")
+	LogsCmd.Flags().BoolVar(&logsList, "list", false, "List the log files of the session")
+	LogsCmd.Flags().BoolVar(&logsPath, "path", false, "Print the session directory")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	sessions, err := session.List(g.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	s, err := logsSessionFor(sessions)
+	if err != nil {
+		return err
+	}
+
+	if logsPath {
+		fmt.Println(s.Dir())
+		return nil
+	}
+
+	logs, err := s.Logs()
+	if err != nil {
+		return fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	if logsList {
+		if len(logs) == 0 {
+			fmt.Println(tui.CLIDim("No logs in this session"))
+			return nil
+		}
+		for _, name := range logs {
+			info, err := os.Stat(filepath.Join(s.Dir(), name))
+			if err != nil {
+				continue
+			}
+			fmt.Printf("%s %s\n", tui.CLIPrimary(name), tui.CLIDim(fmt.Sprintf("(%d bytes)", info.Size())))
+		}
+		return nil
+	}
+
+	if !slices.Contains(logs, logsFile) {
+		return fmt.Errorf("session %d has no %s; use --list to see its logs", s.Number, logsFile)
+	}
+
+	// The rotated part of a log comes first.
+	for _, name := range []string{logsFile + ".1", logsFile} {
+		if err := printLog(filepath.Join(s.Dir(), name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logsSessionFor picks the session given with --session, or the latest one
+// that has logs.
+func logsSessionFor(sessions []*session.Session) (*session.Session, error) {
+	if logsSession != 0 {
+		i := slices.IndexFunc(sessions, func(s *session.Session) bool { return s.Number == logsSession })
+		if i < 0 {
+			return nil, fmt.Errorf("session not found: %d", logsSession)
+		}
+		return sessions[i], nil
+	}
+
+	for _, s := range slices.Backward(sessions) {
+		if logs, err := s.Logs(); err == nil && len(logs) > 0 {
+			return s, nil
+		}
+	}
+	return nil, errors.New("no session has logs; set logging.capture or logging.debug in the profile")
+}
+
+func printLog(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		return fmt.Errorf("failed to read log: %w", err)
+	}
+	return nil
+}
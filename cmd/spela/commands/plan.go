@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jgabor/spela/internal/launcher"
+	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/session"
+	"github.com/jgabor/spela/internal/tui"
+)
+
+// PrintLaunchPlan prints what a launch would do, as text or JSON.
+func PrintLaunchPlan(plan *launcher.Plan, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if plan.Game != "" {
+		fmt.Printf("%s %s\n", tui.CLIPrimary(plan.Game), tui.CLIDim(fmt.Sprintf("(%d)", plan.AppID)))
+	} else {
+		fmt.Println(tui.CLIDim("Unknown game"))
+	}
+	if len(plan.ProfileLayers) == 0 {
+		fmt.Printf("%s %s\n", tui.CLIDim("Profile:"), "none")
+	} else {
+		fmt.Printf("%s %s\n", tui.CLIDim("Profile:"), strings.Join(plan.ProfileLayers, " -> "))
+	}
+	switch plan.GameMode {
+	case profile.GameModeMethodDBus:
+		fmt.Printf("%s %s\n", tui.CLIDim("GameMode:"), "registered over D-Bus")
+	case profile.GameModeMethodWrapper:
+		fmt.Printf("%s %s\n", tui.CLIDim("GameMode:"), "through gamemoderun")
+	}
+
+	if c := plan.Conditions; c != nil {
+		fmt.Printf("\n%s\n", tui.CLISecondary("Conditions"))
+		resolutions := strings.Join(c.Context.Resolutions, ", ")
+		fmt.Printf("  %s\n", tui.CLIDim(fmt.Sprintf("power %s, resolution %s, session %s, hostname %s",
+			valueOrUnknown(c.Context.Power), valueOrUnknown(resolutions), valueOrUnknown(c.Context.Session), valueOrUnknown(c.Context.Hostname))))
+		if len(c.Matched) == 0 {
+			fmt.Println(tui.CLIDim("  (no when block matched)"))
+		}
+		for _, m := range c.Matched {
+			source := strings.Join(m.Settings, ", ")
+			if m.Layer != "" {
+				source += " from " + m.Layer
+			}
+			fmt.Printf("  %s %s %s\n", tui.CLIAccent("when"), m.Conditions, tui.CLIDim("["+source+"]"))
+		}
+	}
+
+	fmt.Printf("\n%s\n", tui.CLISecondary("Environment"))
+	if len(plan.Env) == 0 {
+		fmt.Println(tui.CLIDim("  (none)"))
+	}
+	for _, v := range plan.Env {
+		if v.Unset {
+			fmt.Printf("  %s %s %s\n", tui.CLIPrimary(v.Key), tui.CLIError("(unset)"), tui.CLIDim("["+describeEnvSource(v)+"]"))
+			continue
+		}
+		fmt.Printf("  %s=%s %s\n", tui.CLIPrimary(v.Key), v.Value, tui.CLIDim("["+describeEnvSource(v)+"]"))
+	}
+
+	fmt.Printf("\n%s\n", tui.CLISecondary("System changes"))
+	if len(plan.SystemChanges) == 0 {
+		fmt.Println(tui.CLIDim("  (none)"))
+	}
+	for _, c := range plan.SystemChanges {
+		source := c.Setting
+		if c.Layer != "" {
+			source += " from " + c.Layer
+		}
+		switch {
+		case c.Deferred:
+			fmt.Printf("  %s %s %s\n", tui.CLIDim(c.Description), tui.CLIDim("["+source+"]"), tui.CLIAccent("left to GameMode ("+c.Conflict+")"))
+			continue
+		case c.Conflict != "":
+			source += ", conflicts with GameMode " + c.Conflict
+		}
+		fmt.Printf("  %s %s\n", c.Description, tui.CLIDim("["+source+"]"))
+	}
+
+	if len(plan.Process) > 0 {
+		fmt.Printf("\n%s\n", tui.CLISecondary("Game process"))
+		for _, c := range plan.Process {
+			source := c.Setting
+			if c.Layer != "" {
+				source += " from " + c.Layer
+			}
+			fmt.Printf("  %s %s\n", c.Description, tui.CLIDim("["+source+"]"))
+		}
+	}
+
+	fmt.Printf("\n%s\n", tui.CLISecondary("Hooks"))
+	if len(plan.Hooks) == 0 {
+		fmt.Println(tui.CLIDim("  (none)"))
+	}
+	for _, h := range plan.Hooks {
+		details := fmt.Sprintf("timeout %s, on failure %s, from %s", h.Timeout, h.OnFailure, h.Source)
+		fmt.Printf("  %s %s %s\n", tui.CLIAccent(h.Event), h.Command, tui.CLIDim("["+details+"]"))
+	}
+
+	if lg := plan.Logging; lg != nil {
+		fmt.Printf("\n%s\n", tui.CLISecondary("Logging"))
+		fmt.Printf("  %s %s\n", tui.CLIDim("Session directory:"), filepath.Join(lg.Dir, "<session>"))
+		if lg.Capture {
+			fmt.Printf("  %s %s\n", tui.CLIDim("Game output:"), fmt.Sprintf("%s (rotated at %d MB)", session.LogName, lg.MaxSize>>20))
+		}
+		for _, v := range lg.Debug {
+			fmt.Printf("  %s %s\n", tui.CLIPrimary(v), tui.CLIDim("[logging.debug]"))
+		}
+		fmt.Printf("  %s %d\n", tui.CLIDim("Sessions keeping logs:"), lg.Keep)
+	}
+
+	if len(plan.Warnings) > 0 {
+		fmt.Printf("\n%s\n", tui.CLISecondary("Warnings"))
+		for _, w := range plan.Warnings {
+			fmt.Printf("  %s\n", tui.CLIError(w))
+		}
+	}
+
+	fmt.Printf("\n%s\n", tui.CLISecondary("Command"))
+	fmt.Printf("  %s\n", profile.FormatCommandLine(plan.Command))
+	return nil
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+func describeEnvSource(v launcher.PlannedEnvVar) string {
+	var source string
+	switch v.Source {
+	case launcher.SourceProfile:
+		source = "profile"
+		if v.Setting != "" {
+			source += " " + v.Setting
+		}
+		if v.Layer != "" {
+			source += " from " + v.Layer
+		}
+	case launcher.SourceWrapper:
+		source = "wrapper argument"
+	default:
+		source = v.Source
+	}
+	if v.Overrides != nil {
+		source += fmt.Sprintf(", overrides inherited %q", *v.Overrides)
+	}
+	return source
+}
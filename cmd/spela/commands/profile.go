@@ -2,12 +2,19 @@ package commands
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/jgabor/spela/internal/dll"
 	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/gpu"
 	"github.com/jgabor/spela/internal/profile"
 	"github.com/jgabor/spela/internal/tui"
 )
@@ -38,6 +45,113 @@ var profileShowCmd = &cobra.Command{
 	RunE:  runProfileShow,
 }
 
+var profileShowResolved bool
+
+var profileCreatePreset string
+
+var profileValidateAll bool
+
+var profileValidateCmd = &cobra.Command{
+	Use:   "validate [game]",
+	Short: "Check profiles for errors",
+	Long:  "Check a game's profile, or the default profile when no game is given, for unknown keys, invalid values and settings the GPU cannot use.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runProfileValidate,
+}
+
+var profilePresetsCmd = &cobra.Command{
+	Use:   "presets",
+	Short: "List available presets",
+	RunE:  runProfilePresets,
+}
+
+var profileApplyPresetCmd = &cobra.Command{
+	Use:   "apply-preset <game> <preset>",
+	Short: "Apply a preset to a game's profile",
+	Long:  "Apply a named preset to a game's profile, creating the profile if needed. Built-in presets adapt to the detected GPU generation.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runProfileApplyPreset,
+}
+
+var (
+	profileExportAll      bool
+	profileExportOutput   string
+	profileExportPinDLLs  bool
+	profileImportConflict string
+	profileImportAllow    bool
+)
+
+var profileExportCmd = &cobra.Command{
+	Use:   "export [game]",
+	Short: "Export profiles to a shareable bundle",
+	Long:  "Export a game's profile, or every profile with --all, to a portable bundle. Machine-specific settings such as the shader cache path are left out.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runProfileExport,
+}
+
+var profileImportCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Import profiles from a bundle",
+	Long:  "Import profiles from a bundle. Hooks, wrappers and environment variables that load libraries, Vulkan layers or Wine DLLs in the bundle run on this machine at the next launch, so they are listed first and only imported with --allow-hooks.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProfileImport,
+}
+
+var profileHistoryCmd = &cobra.Command{
+	Use:   "history <game>",
+	Short: "List saved revisions of a game's profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProfileHistory,
+}
+
+var profileDiffCmd = &cobra.Command{
+	Use:   "diff <game> [rev] [rev]",
+	Short: "Show what changed between profile revisions",
+	Long:  "Compare two revisions of a game's profile. With one revision, compare it to the current profile; with none, compare the previous revision to the current profile.",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  runProfileDiff,
+}
+
+var profileRevertCmd = &cobra.Command{
+	Use:   "revert <game> <rev>",
+	Short: "Restore a game's profile to an earlier revision",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runProfileRevert,
+}
+
+var profileSetCmd = &cobra.Command{
+	Use:               "set <game> <key> <value>",
+	Short:             "Set a profile setting",
+	Long:              "Set a single setting in a game's profile by its key path, for example gpu.clock_offset or dlss.sr_mode. Setting preset applies the preset's settings, like apply-preset. Run 'spela profile keys' for the full list.",
+	Args:              cobra.ExactArgs(3),
+	RunE:              runProfileSet,
+	ValidArgsFunction: completeProfileKeyArgs,
+}
+
+var profileGetCmd = &cobra.Command{
+	Use:               "get <game> <key>",
+	Short:             "Print the effective value of a profile setting",
+	Args:              cobra.ExactArgs(2),
+	RunE:              runProfileGet,
+	ValidArgsFunction: completeProfileKeyArgs,
+}
+
+var profileUnsetCmd = &cobra.Command{
+	Use:               "unset <game> <key>",
+	Short:             "Remove a setting from a game's profile",
+	Long:              "Remove a setting from a game's profile so that it is inherited from the default profile and base profiles again.",
+	Args:              cobra.ExactArgs(2),
+	RunE:              runProfileUnset,
+	ValidArgsFunction: completeProfileKeyArgs,
+}
+
+var profileKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "List profile setting keys",
+	Args:  cobra.NoArgs,
+	RunE:  runProfileKeys,
+}
+
 var profileDeleteCmd = &cobra.Command{
 	Use:   "delete <game>",
 	Short: "Delete a game's profile",
@@ -50,6 +164,32 @@ func init() {
 	ProfileCmd.AddCommand(profileCreateCmd)
 	ProfileCmd.AddCommand(profileShowCmd)
 	ProfileCmd.AddCommand(profileDeleteCmd)
+	ProfileCmd.AddCommand(profilePresetsCmd)
+	ProfileCmd.AddCommand(profileApplyPresetCmd)
+	ProfileCmd.AddCommand(profileValidateCmd)
+	ProfileCmd.AddCommand(profileExportCmd)
+	ProfileCmd.AddCommand(profileImportCmd)
+	ProfileCmd.AddCommand(profileHistoryCmd)
+	ProfileCmd.AddCommand(profileDiffCmd)
+	ProfileCmd.AddCommand(profileRevertCmd)
+	ProfileCmd.AddCommand(profileSetCmd)
+	ProfileCmd.AddCommand(profileGetCmd)
+	ProfileCmd.AddCommand(profileUnsetCmd)
+	ProfileCmd.AddCommand(profileKeysCmd)
+
+	profileExportCmd.Flags().BoolVar(&profileExportAll, "all", false, "Export the default profile and every game profile")
+	profileExportCmd.Flags().StringVarP(&profileExportOutput, "output", "o", "", "Bundle file to write")
+	profileExportCmd.Flags().BoolVar(&profileExportPinDLLs, "pin-dlls", false, "Record the installed DLL versions with each profile")
+	_ = profileExportCmd.MarkFlagRequired("output")
+
+	profileImportCmd.Flags().StringVar(&profileImportConflict, "on-conflict", string(profile.ConflictSkip), "What to do with existing profiles: skip, overwrite or merge")
+	profileImportCmd.Flags().BoolVar(&profileImportAllow, "allow-hooks", false, "Import profiles that run hooks, wrappers or load code through environment variables")
+
+	profileValidateCmd.Flags().BoolVar(&profileValidateAll, "all", false, "Validate every profile, base profile and preset")
+
+	profileCreateCmd.Flags().StringVar(&profileCreatePreset, "preset", "", "Preset to start from")
+
+	profileShowCmd.Flags().BoolVar(&profileShowResolved, "resolved", false, "Show the effective settings and the layer each value comes from")
 }
 
 func runProfileList(cmd *cobra.Command, args []string) error {
@@ -105,6 +245,17 @@ func runProfileCreate(cmd *cobra.Command, args []string) error {
 
 	p := &profile.Profile{Name: g.Name}
 
+	if profileCreatePreset != "" {
+		preset, err := profile.GetPreset(profileCreatePreset)
+		if err != nil {
+			return err
+		}
+		gen, _ := gpu.GetGPUGeneration()
+		if err := p.ApplyPreset(preset, gen); err != nil {
+			return err
+		}
+	}
+
 	if err := profile.Save(g.AppID, p); err != nil {
 		return fmt.Errorf("failed to save profile: %w", err)
 	}
@@ -130,6 +281,10 @@ func runProfileShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("game not found: %s", args[0])
 	}
 
+	if profileShowResolved {
+		return printResolvedProfile(g)
+	}
+
 	p, err := profile.Load(g.AppID)
 	if err != nil {
 		return err
@@ -170,3 +325,659 @@ func runProfileDelete(cmd *cobra.Command, args []string) error {
 	fmt.Printf("%s %s\n", tui.CLISuccess("Deleted profile for"), tui.CLIPrimary(g.Name))
 	return nil
 }
+
+func printResolvedProfile(g *game.Game) error {
+	r, err := profile.Resolve(g.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve profile: %w", err)
+	}
+
+	layers := append([]string{profile.LayerBuiltin}, r.Layers...)
+	fmt.Printf("%s %s\n", tui.CLIPrimary(g.Name), tui.CLIDim(fmt.Sprintf("(%d)", g.AppID)))
+	fmt.Printf("%s %s\n\n", tui.CLIDim("Layers:"), strings.Join(layers, " -> "))
+
+	for _, f := range r.Fields() {
+		value := f.Value
+		if value == "" {
+			value = "(default)"
+		}
+		if f.Source == profile.LayerBuiltin {
+			fmt.Printf("%s = %s\n", f.Key, tui.CLIDim(value))
+			continue
+		}
+		fmt.Printf("%s = %s %s\n", tui.CLIPrimary(f.Key), tui.CLISecondary(value), tui.CLIDim("["+f.Source+"]"))
+	}
+
+	return nil
+}
+
+func runProfilePresets(cmd *cobra.Command, args []string) error {
+	presets, err := profile.ListPresets()
+	if err != nil {
+		return err
+	}
+
+	for _, preset := range presets {
+		kind := "user"
+		if preset.Builtin {
+			kind = "built-in"
+		}
+		fmt.Printf("%s %s\n", tui.CLIPrimary(preset.Name), tui.CLIDim("("+kind+")"))
+		fmt.Printf("  %s\n", preset.Description)
+	}
+
+	return nil
+}
+
+func runProfileApplyPreset(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	preset, err := profile.GetPreset(args[1])
+	if err != nil {
+		return err
+	}
+
+	p, err := profile.Load(g.AppID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		p = &profile.Profile{Name: g.Name}
+	}
+
+	gen, _ := gpu.GetGPUGeneration()
+	if err := p.ApplyPreset(preset, gen); err != nil {
+		return err
+	}
+
+	if err := profile.Save(g.AppID, p); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	fmt.Printf("%s %s %s %s\n", tui.CLISuccess("Applied preset"), tui.CLIAccent(preset.Name), tui.CLISuccess("to"), tui.CLIPrimary(g.Name))
+	if preset.Builtin {
+		fmt.Printf("%s\n", tui.CLIDim("Tuned for GPU: "+gen.String()))
+	}
+	return nil
+}
+
+func runProfileValidate(cmd *cobra.Command, args []string) error {
+	var paths []string
+	switch {
+	case profileValidateAll:
+		files, err := profile.Files()
+		if err != nil {
+			return err
+		}
+		paths = files
+	case len(args) == 1:
+		db, err := game.LoadDatabase()
+		if err != nil {
+			return fmt.Errorf("failed to load game database: %w", err)
+		}
+
+		var g *game.Game
+		if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+			g = db.GetGame(appID)
+		} else {
+			g = db.GetGameByName(args[0])
+		}
+
+		if g == nil {
+			return fmt.Errorf("game not found: %s", args[0])
+		}
+		if !profile.Exists(g.AppID) {
+			return fmt.Errorf("no profile for %s", g.Name)
+		}
+		paths = []string{profile.Path(g.AppID)}
+	default:
+		if _, err := os.Stat(profile.DefaultPath()); err != nil {
+			fmt.Println("No default profile found.")
+			return nil
+		}
+		paths = []string{profile.DefaultPath()}
+	}
+
+	if len(paths) == 0 {
+		fmt.Println("No profiles found.")
+		return nil
+	}
+
+	gen, _ := gpu.GetGPUGeneration()
+
+	invalid := 0
+	for _, path := range paths {
+		if err := profile.ValidateFile(path, gen); err != nil {
+			invalid++
+			fmt.Printf("%s %s\n", tui.CLIError("✗"), err)
+			continue
+		}
+		fmt.Printf("%s %s\n", tui.CLISuccess("✓"), path)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d profiles are invalid", invalid, len(paths))
+	}
+	return nil
+}
+
+func runProfileExport(cmd *cobra.Command, args []string) error {
+	if profileExportAll == (len(args) == 1) {
+		return fmt.Errorf("specify a game or --all")
+	}
+
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	b := profile.NewBundle(cmd.Root().Version)
+
+	addGame := func(appID uint64, p *profile.Profile) error {
+		name := p.Name
+		var dlls map[string]string
+		if g := db.GetGame(appID); g != nil {
+			name = g.Name
+			if profileExportPinDLLs {
+				dlls = pinnedDLLs(g)
+			}
+		}
+		return b.Add(appID, name, p, dlls)
+	}
+
+	if profileExportAll {
+		def, err := profile.LoadDefault()
+		if err != nil {
+			return err
+		}
+		if def != nil {
+			if err := b.SetDefault(def); err != nil {
+				return err
+			}
+		}
+
+		profiles, err := profile.List()
+		if err != nil {
+			return err
+		}
+		appIDs := make([]uint64, 0, len(profiles))
+		for appID := range profiles {
+			appIDs = append(appIDs, appID)
+		}
+		slices.Sort(appIDs)
+		for _, appID := range appIDs {
+			if err := addGame(appID, profiles[appID]); err != nil {
+				return err
+			}
+		}
+	} else {
+		var g *game.Game
+		if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+			g = db.GetGame(appID)
+		} else {
+			g = db.GetGameByName(args[0])
+		}
+
+		if g == nil {
+			return fmt.Errorf("game not found: %s", args[0])
+		}
+
+		p, err := profile.Load(g.AppID)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return fmt.Errorf("no profile for %s", g.Name)
+		}
+		if err := addGame(g.AppID, p); err != nil {
+			return err
+		}
+	}
+
+	if b.Default == nil && len(b.Profiles) == 0 {
+		fmt.Println("No profiles found.")
+		return nil
+	}
+
+	if err := profile.WriteBundle(profileExportOutput, b); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	fmt.Printf("%s %d profiles to %s\n", tui.CLISuccess("Exported"), len(b.Profiles), tui.CLIPrimary(profileExportOutput))
+	return nil
+}
+
+// pinnedDLLs returns the newest installed version of each DLL type in g.
+func pinnedDLLs(g *game.Game) map[string]string {
+	dlls := make(map[string]string)
+	for _, d := range g.DLLs {
+		if d.Version == "" {
+			continue
+		}
+		key := string(d.Type)
+		if current, ok := dlls[key]; !ok || dll.CompareVersions(d.Version, current) > 0 {
+			dlls[key] = d.Version
+		}
+	}
+	if len(dlls) == 0 {
+		return nil
+	}
+	return dlls
+}
+
+func runProfileImport(cmd *cobra.Command, args []string) error {
+	b, err := profile.ReadBundle(args[0])
+	if err != nil {
+		return err
+	}
+
+	commands, err := b.Commands()
+	if err != nil {
+		return err
+	}
+	if len(commands) > 0 {
+		fmt.Println(tui.CLIPrimary("The bundle runs these commands:"))
+		for _, c := range commands {
+			label := c.Target
+			if c.Game != "" {
+				label = c.Game
+			}
+			fmt.Printf("  %s %s %s\n", label, tui.CLIDim(c.Key+":"), c.Command)
+		}
+		if !profileImportAllow {
+			return errors.New("bundle contains commands; review them and import again with --allow-hooks")
+		}
+		fmt.Println()
+	}
+
+	results, err := b.Import(profile.ConflictMode(profileImportConflict))
+	for _, r := range results {
+		label := r.Target
+		if r.Game != "" {
+			label = r.Game + " " + tui.CLIDim("("+r.Target+")")
+		}
+		fmt.Printf("%s %s\n", tui.CLISecondary(r.Action+":"), label)
+	}
+	if err != nil {
+		return err
+	}
+
+	db, _ := game.LoadDatabase()
+	for _, entry := range b.Profiles {
+		var g *game.Game
+		if db != nil {
+			g = db.GetGame(entry.AppID)
+		}
+		if g == nil {
+			fmt.Printf("%s %s %s\n", tui.CLIDim("Note:"), entry.Game, tui.CLIDim("is not installed; the profile applies once it is"))
+			continue
+		}
+		installedDLLs := pinnedDLLs(g)
+		for _, dllType := range slices.Sorted(maps.Keys(entry.DLLs)) {
+			version := entry.DLLs[dllType]
+			installed := installedDLLs[dllType]
+			if installed == version {
+				continue
+			}
+			fmt.Printf("%s %s %s %s %s\n", tui.CLIDim("Note:"), g.Name, tui.CLIDim("was tuned with "+dllType), tui.CLIAccent(version), tui.CLIDim("(installed: "+installedOrNone(installed)+")"))
+		}
+	}
+
+	return nil
+}
+
+func installedOrNone(version string) string {
+	if version == "" {
+		return "none"
+	}
+	return version
+}
+
+func runProfileHistory(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	revisions, err := profile.History(g.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to read profile history: %w", err)
+	}
+	if len(revisions) == 0 {
+		fmt.Printf("No history for %s.\n", g.Name)
+		return nil
+	}
+
+	var previous *profile.Profile
+	lines := make([]string, 0, len(revisions))
+	for _, rev := range revisions {
+		p, err := profile.LoadRevision(g.AppID, rev.Number)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s %s %s", tui.CLIPrimary(fmt.Sprintf("%4d", rev.Number)), rev.Time.Local().Format("2006-01-02 15:04:05"), tui.CLIError(err.Error())))
+			continue
+		}
+
+		summary := describeChanges(profile.Diff(previous, p))
+		if previous == nil {
+			summary = "initial revision"
+		}
+		line := fmt.Sprintf("%s %s %s", tui.CLIPrimary(fmt.Sprintf("%4d", rev.Number)), rev.Time.Local().Format("2006-01-02 15:04:05"), tui.CLIDim(summary))
+		if rev.IsCurrent(g.AppID) {
+			line += " " + tui.CLISuccess("(current)")
+		}
+		lines = append(lines, line)
+		previous = p
+	}
+
+	fmt.Printf("%s %s\n\n", tui.CLIPrimary(g.Name), tui.CLIDim(fmt.Sprintf("(%d)", g.AppID)))
+	for i := len(lines) - 1; i >= 0; i-- {
+		fmt.Println(lines[i])
+	}
+	return nil
+}
+
+func describeChanges(changes []profile.FieldChange) string {
+	switch len(changes) {
+	case 0:
+		return "no changes"
+	case 1:
+		return changes[0].Key
+	case 2, 3:
+		keys := make([]string, len(changes))
+		for i, c := range changes {
+			keys[i] = c.Key
+		}
+		return strings.Join(keys, ", ")
+	default:
+		return fmt.Sprintf("%s and %d more", changes[0].Key, len(changes)-1)
+	}
+}
+
+func runProfileDiff(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	revs := make([]int, 0, 2)
+	for _, arg := range args[1:] {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid revision: %s", arg)
+		}
+		revs = append(revs, n)
+	}
+
+	newLabel := "current"
+	var oldProfile, newProfile *profile.Profile
+	switch len(revs) {
+	case 0:
+		revisions, err := profile.History(g.AppID)
+		if err != nil {
+			return fmt.Errorf("failed to read profile history: %w", err)
+		}
+		// The latest revision is normally the current profile, so compare
+		// against the one before it unless the file was edited by hand.
+		switch {
+		case len(revisions) > 0 && !revisions[len(revisions)-1].IsCurrent(g.AppID):
+			revs = append(revs, revisions[len(revisions)-1].Number)
+		case len(revisions) > 1:
+			revs = append(revs, revisions[len(revisions)-2].Number)
+		default:
+			return fmt.Errorf("no earlier revision of %s to compare with", g.Name)
+		}
+		fallthrough
+	case 1:
+		if newProfile, err = profile.Load(g.AppID); err != nil {
+			return err
+		}
+	case 2:
+		newLabel = fmt.Sprintf("revision %d", revs[1])
+		if newProfile, err = profile.LoadRevision(g.AppID, revs[1]); err != nil {
+			return err
+		}
+	}
+
+	oldLabel := fmt.Sprintf("revision %d", revs[0])
+	if oldProfile, err = profile.LoadRevision(g.AppID, revs[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s %s %s\n\n", tui.CLIPrimary(g.Name), tui.CLIDim(oldLabel), tui.CLIDim("->"), tui.CLIDim(newLabel))
+
+	changes := profile.Diff(oldProfile, newProfile)
+	if len(changes) == 0 {
+		fmt.Println("No changes.")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Printf("%s: %s %s %s\n", tui.CLIPrimary(c.Key), tui.CLIError(valueOrDefault(c.Old)), tui.CLIDim("->"), tui.CLISuccess(valueOrDefault(c.New)))
+	}
+	return nil
+}
+
+func valueOrDefault(value string) string {
+	if value == "" {
+		return "(default)"
+	}
+	return value
+}
+
+func runProfileRevert(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	rev, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid revision: %s", args[1])
+	}
+
+	if _, err := profile.Revert(g.AppID, rev); err != nil {
+		return fmt.Errorf("failed to revert profile: %w", err)
+	}
+
+	fmt.Printf("%s %s %s\n", tui.CLISuccess("Reverted profile for"), tui.CLIPrimary(g.Name), tui.CLISuccess(fmt.Sprintf("to revision %d", rev)))
+	return nil
+}
+
+func runProfileSet(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	p, err := profile.Load(g.AppID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		p = &profile.Profile{Name: g.Name}
+	}
+
+	key, value := args[1], args[2]
+	if err := p.Set(key, value); err != nil {
+		return err
+	}
+
+	if err := profile.Save(g.AppID, p); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	fmt.Printf("%s %s = %s %s %s\n", tui.CLISuccess("Set"), tui.CLIPrimary(key), tui.CLISecondary(value), tui.CLISuccess("for"), tui.CLIPrimary(g.Name))
+
+	gen, _ := gpu.GetGPUGeneration()
+	for _, issue := range p.Validate(gen) {
+		if issue.Key == key {
+			fmt.Printf("%s %s\n", tui.CLIError("Warning:"), issue.Message)
+		}
+	}
+	return nil
+}
+
+func runProfileGet(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	r, err := profile.Resolve(g.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve profile: %w", err)
+	}
+
+	value, err := r.Profile.Get(args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func runProfileUnset(cmd *cobra.Command, args []string) error {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	} else {
+		g = db.GetGameByName(args[0])
+	}
+
+	if g == nil {
+		return fmt.Errorf("game not found: %s", args[0])
+	}
+
+	p, err := profile.Load(g.AppID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("no profile for %s", g.Name)
+	}
+
+	if err := p.Unset(args[1]); err != nil {
+		return err
+	}
+
+	if err := profile.Save(g.AppID, p); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	fmt.Printf("%s %s %s %s\n", tui.CLISuccess("Unset"), tui.CLIPrimary(args[1]), tui.CLISuccess("for"), tui.CLIPrimary(g.Name))
+	return nil
+}
+
+func runProfileKeys(cmd *cobra.Command, args []string) error {
+	for _, key := range profile.Keys() {
+		if values := profile.KeyValues(key); len(values) > 0 {
+			fmt.Printf("%s %s\n", key, tui.CLIDim(strings.Join(values, "|")))
+			continue
+		}
+		fmt.Println(key)
+	}
+	return nil
+}
+
+// completeProfileKeyArgs completes <game> <key> [value] arguments.
+func completeProfileKeyArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeGameNames(toComplete), cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		return profile.Keys(), cobra.ShellCompDirectiveNoFileComp
+	case 2:
+		if cmd.Name() == "set" {
+			return profile.KeyValues(args[1]), cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeGameNames(toComplete string) []string {
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, g := range db.List() {
+		if strings.HasPrefix(strings.ToLower(g.Name), strings.ToLower(toComplete)) {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jgabor/spela/internal/state"
+	"github.com/jgabor/spela/internal/tui"
+)
+
+var (
+	restoreForce   bool
+	restoreDiscard bool
+)
+
+var RestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Revert system changes left by interrupted game sessions",
+	Long: `Revert the CPU and GPU settings recorded in the journals of game sessions
+whose spela process is gone, for example because it was killed. This also
+happens automatically the next time spela starts; use this command to retry
+reversals that failed then.
+
+Sessions that are still running are skipped unless --force is given.`,
+	Args: cobra.NoArgs,
+	RunE: runRestore,
+}
+
+func init() {
+	RestoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Also revert the changes of sessions that are still running")
+	RestoreCmd.Flags().BoolVar(&restoreDiscard, "discard", false, "Forget the pending reversals without applying them")
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	journals, err := state.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read session journals: %w", err)
+	}
+	if len(journals) == 0 {
+		fmt.Println(tui.CLIDim("Nothing to restore"))
+		return nil
+	}
+
+	var failed bool
+	for _, j := range journals {
+		fmt.Printf("%s %s\n", tui.CLIPrimary(journalName(j)), tui.CLIDim(fmt.Sprintf("(pid %d, started %s)", j.PID, j.Started.Local().Format("2006-01-02 15:04"))))
+		if j.Running() && !restoreForce {
+			fmt.Println(tui.CLIDim("  Still running, skipped (use --force to restore anyway)"))
+			continue
+		}
+
+		if restoreDiscard {
+			if err := j.Discard(); err != nil {
+				return fmt.Errorf("failed to discard journal: %w", err)
+			}
+			fmt.Println(tui.CLIDim("  Discarded"))
+			continue
+		}
+
+		pending := j.Pending()
+		for i := len(pending) - 1; i >= 0; i-- {
+			fmt.Printf("  %s\n", pending[i].Reversal)
+		}
+		if err := j.Replay(); err != nil {
+			failed = true
+			fmt.Printf("  %s\n", tui.CLIError(err.Error()))
+			continue
+		}
+		fmt.Printf("  %s\n", tui.CLISuccess("Restored"))
+	}
+
+	if failed {
+		return errors.New("some changes could not be reverted")
+	}
+	return nil
+}
+
+// RecoverStaleSessions reverts the system changes of game sessions whose
+// spela process died. It runs whenever spela starts. A journal is replayed
+// automatically only once; when that fails it is left for `spela restore`,
+// and later starts only remind of it.
+func RecoverStaleSessions() {
+	journals, err := state.Stale()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read session journals: %v\n", err)
+		return
+	}
+
+	var failed []string
+	for _, j := range journals {
+		if j.RecoveryFailed {
+			failed = append(failed, journalName(j))
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Reverting system changes left by an interrupted session of %s\n", journalName(j))
+		if err := j.Replay(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			if err := j.MarkRecoveryFailed(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to update session journal: %v\n", err)
+			}
+			failed = append(failed, journalName(j))
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "System changes of interrupted sessions of %s are still in place. Run 'spela restore' to revert them, or 'spela restore --discard' to give up.\n", strings.Join(failed, ", "))
+	}
+}
+
+func journalName(j *state.Journal) string {
+	if j.Game == "" {
+		return "unknown game"
+	}
+	return j.Game
+}
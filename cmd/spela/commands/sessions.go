@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/session"
+	"github.com/jgabor/spela/internal/tui"
+)
+
+var (
+	sessionsJSON  bool
+	sessionsLimit int
+)
+
+var SessionsCmd = &cobra.Command{
+	Use:   "sessions [game] [number]",
+	Short: "Show recorded game sessions",
+	Long: `List the recorded launches of a game, or of every game, newest first, with
+how long each ran and how it exited. Give a session number to see the
+profile, DLLs and environment it ran with.
+
+Sessions are recorded when spela runs the game itself, including as the
+Steam launch option (spela %command%).`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: runSessions,
+}
+
+func init() {
+	SessionsCmd.Flags().BoolVar(&sessionsJSON, "json", false, "Output in JSON format")
+	SessionsCmd.Flags().IntVarP(&sessionsLimit, "limit", "n", 20, "Show at most this many sessions (0 for all)")
+}
+
+func runSessions(cmd *cobra.Command, args []string) error {
+	sessions, err := loadSessions(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 {
+		number, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid session number: %s", args[1])
+		}
+		i := slices.IndexFunc(sessions, func(s *session.Session) bool { return s.Number == number })
+		if i < 0 {
+			return fmt.Errorf("session not found: %d", number)
+		}
+		return printSession(sessions[i])
+	}
+
+	total := session.Total(sessions)
+	slices.Reverse(sessions)
+	if sessionsLimit > 0 && len(sessions) > sessionsLimit {
+		sessions = sessions[:sessionsLimit]
+	}
+
+	if sessionsJSON {
+		data, err := json.MarshalIndent(sessions, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println(tui.CLIDim("No sessions recorded"))
+		return nil
+	}
+
+	for _, s := range sessions {
+		line := fmt.Sprintf("%4d  %s  %8s  ", s.Number, s.Start.Local().Format("2006-01-02 15:04"), session.FormatDuration(s.Duration()))
+		if len(args) == 0 {
+			line += s.Game + "  "
+		}
+		fmt.Printf("%s%s\n", tui.CLIPrimary(line), sessionStatus(s))
+	}
+	fmt.Printf("\n%s %s\n", tui.CLIDim("Total playtime:"), tui.CLIAccent(session.FormatDuration(total)))
+	return nil
+}
+
+// loadSessions returns the sessions of the game named in args, or of every
+// game when there is none.
+func loadSessions(args []string) ([]*session.Session, error) {
+	if len(args) == 0 {
+		sessions, err := session.All()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sessions: %w", err)
+		}
+		return sessions, nil
+	}
+
+	db, err := game.LoadDatabase()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load game database: %w", err)
+	}
+
+	var g *game.Game
+	if appID, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+		g = db.GetGame(appID)
+	}
+	if g == nil {
+		g = db.GetGameByName(args[0])
+	}
+	if g == nil {
+		return nil, fmt.Errorf("game not found: %s", args[0])
+	}
+
+	sessions, err := session.List(g.AppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func printSession(s *session.Session) error {
+	if sessionsJSON {
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%s  %s %s\n", tui.CLIDim("Game:"), tui.CLIPrimary(s.Game), tui.CLIDim(fmt.Sprintf("(%d)", s.AppID)))
+	fmt.Printf("%s %d\n", tui.CLIDim("Session:"), s.Number)
+	fmt.Printf("%s %s\n", tui.CLIDim("Started:"), s.Start.Local().Format("2006-01-02 15:04:05"))
+	if s.Finished() {
+		fmt.Printf("%s   %s\n", tui.CLIDim("Ended:"), s.End.Local().Format("2006-01-02 15:04:05"))
+		fmt.Printf("%s %s\n", tui.CLIDim("Duration:"), session.FormatDuration(s.Duration()))
+	}
+	fmt.Printf("%s  %s\n", tui.CLIDim("Status:"), sessionStatus(s))
+	if s.Error != "" {
+		fmt.Printf("%s   %s\n", tui.CLIDim("Error:"), s.Error)
+	}
+	if len(s.Command) > 0 {
+		fmt.Printf("%s %s\n", tui.CLIDim("Command:"), strings.Join(s.Command, " "))
+	}
+
+	if s.Profile.Hash != "" {
+		fmt.Printf("%s %s %s\n", tui.CLIDim("Profile:"), tui.CLIAccent(s.Profile.Hash), tui.CLIDim("("+strings.Join(s.Profile.Layers, ", ")+")"))
+	} else {
+		fmt.Printf("%s %s\n", tui.CLIDim("Profile:"), tui.CLIDim("none"))
+	}
+
+	if len(s.DLLs) > 0 {
+		fmt.Println("\n" + tui.CLISecondary("DLLs:"))
+		for _, d := range s.DLLs {
+			version := d.Version
+			if version == "" {
+				version = "unknown"
+			}
+			fmt.Printf("  %s: %s\n", tui.CLIPrimary(d.Name), tui.CLIAccent(version))
+		}
+	}
+
+	if len(s.Env) > 0 || len(s.Unset) > 0 {
+		fmt.Println("\n" + tui.CLISecondary("Environment:"))
+		for _, key := range slices.Sorted(maps.Keys(s.Env)) {
+			fmt.Printf("  %s=%s\n", tui.CLIPrimary(key), s.Env[key])
+		}
+		for _, key := range s.Unset {
+			fmt.Printf("  %s %s\n", tui.CLIPrimary(key), tui.CLIDim("(unset)"))
+		}
+	}
+	return nil
+}
+
+func sessionStatus(s *session.Session) string {
+	status := s.Status()
+	switch outcome := s.Result(); {
+	case outcome == session.OutcomeRunning:
+		return tui.CLIAccent(status)
+	case outcome == session.OutcomeClean:
+		return tui.CLISuccess(status)
+	case outcome.Bad():
+		return tui.CLIError(status)
+	}
+	return tui.CLIDim(status)
+}
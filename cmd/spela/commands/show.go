@@ -9,6 +9,7 @@ import (
 
 	"github.com/jgabor/spela/internal/game"
 	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/session"
 	"github.com/jgabor/spela/internal/tui"
 )
 
@@ -46,8 +47,18 @@ func runShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("game not found: %s", args[0])
 	}
 
+	sessions, err := session.List(g.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to load sessions: %w", err)
+	}
+	playtime := session.Total(sessions)
+
 	if showJSON {
-		data, err := json.MarshalIndent(g, "", "  ")
+		data, err := json.MarshalIndent(struct {
+			*game.Game
+			PlaytimeSeconds int64 `json:"playtime_seconds"`
+			Sessions        int   `json:"sessions"`
+		}{g, int64(playtime.Seconds()), len(sessions)}, "", "  ")
 		if err != nil {
 			return err
 		}
@@ -81,5 +92,11 @@ func runShow(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("\n%s %s\n", tui.CLIDim("Profile:"), profileStatus)
 
+	if len(sessions) > 0 {
+		last := sessions[len(sessions)-1]
+		fmt.Printf("%s %s %s\n", tui.CLIDim("Playtime:"), tui.CLIAccent(session.FormatDuration(playtime)),
+			tui.CLIDim(fmt.Sprintf("(%d sessions, last %s)", len(sessions), last.Start.Local().Format("2006-01-02 15:04"))))
+	}
+
 	return nil
 }
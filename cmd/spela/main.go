@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/jgabor/spela/cmd/spela/commands"
+	"github.com/jgabor/spela/internal/config"
 	"github.com/jgabor/spela/internal/env"
 	"github.com/jgabor/spela/internal/game"
 	"github.com/jgabor/spela/internal/launcher"
@@ -18,12 +19,17 @@ import (
 
 var version = "dev"
 
+const dryRunEnv = "SPELA_DRY_RUN"
+
 var rootCmd = &cobra.Command{
 	Use:     "spela [command]",
 	Short:   "Linux gaming optimization tool",
 	Long:    "Spela is a Linux gaming optimization tool that combines DLSS/DLL management with comprehensive gaming environment setup.",
 	Version: version,
 	RunE:    runRoot,
+	// Stale sessions are recovered whenever spela starts, whatever the
+	// command.
+	PersistentPreRun: recoverOnStart,
 }
 
 func init() {
@@ -40,6 +46,26 @@ func init() {
 	rootCmd.AddCommand(commands.TUICmd)
 	rootCmd.AddCommand(commands.GUICmd)
 	rootCmd.AddCommand(commands.DenylistCmd)
+	rootCmd.AddCommand(commands.RestoreCmd)
+	rootCmd.AddCommand(commands.SessionsCmd)
+	rootCmd.AddCommand(commands.LogsCmd)
+	rootCmd.AddCommand(commands.DoctorCmd)
+}
+
+// recoverOnStart reverts the system changes of interrupted sessions before a
+// command runs. restore deals with the journals itself, and shell
+// completion must stay quiet.
+func recoverOnStart(cmd *cobra.Command, _ []string) {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch {
+		case c == commands.RestoreCmd,
+			c.Name() == "completion",
+			c.Name() == cobra.ShellCompRequestCmd,
+			c.Name() == cobra.ShellCompNoDescRequestCmd:
+			return
+		}
+	}
+	commands.RecoverStaleSessions()
 }
 
 func runRoot(cmd *cobra.Command, args []string) error {
@@ -71,34 +97,55 @@ func runWrapperMode(args []string) error {
 	}
 
 	var p *profile.Profile
+	var r *profile.Resolution
 	if g != nil {
-		p, err = profile.Load(g.AppID)
+		r, err = profile.Resolve(g.AppID)
 		if err != nil {
 			return fmt.Errorf("failed to load profile: %w", err)
 		}
+		if len(r.Layers) > 0 {
+			p = r.Profile
+		}
 	}
 
-	restore := profile.NewRestorePoint()
-	restore.SaveAllProfileEnvVars()
+	// SPELA_DRY_RUN=1 (or =json) prints the launch plan instead of launching,
+	// whether it is set in the environment or passed as a wrapper argument.
+	dryRun := os.Getenv(dryRunEnv)
+	if value, ok := invocation.Environment[dryRunEnv]; ok {
+		dryRun = value
+		delete(invocation.Environment, dryRunEnv)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
 
 	e := env.New()
-	var cleanups []func()
 	if p != nil {
-		cleanups = p.Apply(e)
-	}
-	for key, value := range invocation.Environment {
-		e.Set(key, value)
+		p.Apply(e)
 	}
+	e.Annotate(launcher.OriginWrapper, func() {
+		for key, value := range invocation.Environment {
+			e.Set(key, value)
+		}
+	})
 
 	l := launcher.New(g)
 	l.Profile = p
+	l.Resolution = r
 	l.Environment = e
+	l.Hooks = cfg.Hooks
+	l.RecordSession = true
+	l.AutoRollback = cfg.AutoRollback
+	l.ApplySystemChanges = cfg.ApplySystemChanges
 
-	l.OnCleanup(restore.Restore)
-	for _, cleanup := range cleanups {
-		l.OnCleanup(cleanup)
+	if dryRun != "" && dryRun != "0" {
+		return commands.PrintLaunchPlan(l.Plan(invocation.Command, false), dryRun == "json")
 	}
 
+	commands.RecoverStaleSessions()
+
 	if p != nil {
 		fmt.Printf("Launching %s with profile...\n", g.Name)
 	} else if g != nil {
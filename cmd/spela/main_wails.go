@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/jgabor/spela/cmd/spela/commands"
 	"github.com/jgabor/spela/internal/gui"
 )
 
 func main() {
+	commands.RecoverStaleSessions()
 	if err := gui.Run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -7,6 +7,7 @@ import (
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/jgabor/spela/internal/hook"
 	"github.com/jgabor/spela/internal/xdg"
 )
 
@@ -40,11 +41,24 @@ type Config struct {
 	AutoRefreshManifest  bool   `yaml:"auto_refresh_manifest"`
 	ManifestRefreshHours int    `yaml:"manifest_refresh_hours"`
 	PreferredDLLSource   string `yaml:"preferred_dll_source,omitempty"`
+	DLLSignaturePolicy   string `yaml:"dll_signature_policy,omitempty"`
 
 	// Display
 	Theme              string `yaml:"theme,omitempty"`
 	CompactMode        bool   `yaml:"compact_mode"`
 	ConfirmDestructive bool   `yaml:"confirm_destructive"`
+
+	// AutoRollback restores the profile and DLLs of a game's last clean
+	// session after it crashes or exits early.
+	AutoRollback bool `yaml:"auto_rollback"`
+
+	// ApplySystemChanges lets launches change the profile's system
+	// settings, such as the CPU governor, SMT and GPU clocks, and revert
+	// them when the game exits.
+	ApplySystemChanges bool `yaml:"apply_system_changes"`
+
+	// Hooks run for every game, before the hooks of its profile.
+	Hooks hook.Settings `yaml:"hooks,omitempty"`
 }
 
 func Default() *Config {
@@ -60,6 +74,7 @@ func Default() *Config {
 		AutoRefreshManifest:  true,
 		ManifestRefreshHours: 24,
 		PreferredDLLSource:   "techpowerup",
+		DLLSignaturePolicy:   "enforce",
 
 		Theme:              "default",
 		CompactMode:        false,
@@ -105,5 +120,6 @@ func (c *Config) Clone() *Config {
 		clone.AdditionalLibraryPaths = make([]string, len(c.AdditionalLibraryPaths))
 		copy(clone.AdditionalLibraryPaths, c.AdditionalLibraryPaths)
 	}
+	clone.Hooks = c.Hooks.Clone()
 	return &clone
 }
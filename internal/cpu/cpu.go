@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -70,6 +71,52 @@ func SetSMT(enabled bool) error {
 	return os.WriteFile("/sys/devices/system/cpu/smt/control", []byte(value), 0o644)
 }
 
+// ParseCPUList parses a CPU list in the taskset/cpuset format, such as
+// "0-3,8,10-15:2", and returns the CPUs it names in ascending order.
+func ParseCPUList(list string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty entry in CPU list %q", list)
+		}
+
+		stride := 1
+		if rng, step, ok := strings.Cut(part, ":"); ok {
+			n, err := strconv.Atoi(step)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid stride in %q", part)
+			}
+			part, stride = rng, n
+		}
+
+		first, last := part, part
+		if lo, hi, ok := strings.Cut(part, "-"); ok {
+			first, last = lo, hi
+		}
+
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", first)
+		}
+		end, err := strconv.Atoi(last)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid CPU range %q", part)
+		}
+
+		for c := start; c <= end; c += stride {
+			seen[c] = true
+		}
+	}
+
+	cpus := make([]int, 0, len(seen))
+	for c := range seen {
+		cpus = append(cpus, c)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
 func LaunchWithAffinity(affinity string, args []string) *exec.Cmd {
 	tasksetArgs := append([]string{"-c", affinity}, args...)
 	return exec.Command("taskset", tasksetArgs...)
@@ -108,43 +155,6 @@ func GetCPUInfo() (map[string]string, error) {
 	return info, nil
 }
 
-func SCXIsAvailable() bool {
-	_, err := exec.LookPath("scx_loader")
-	return err == nil
-}
-
-func SCXStart(scheduler string) error {
-	cmd := exec.Command("systemctl", "start", "scx.service")
-	return cmd.Run()
-}
-
-func SCXStop() error {
-	cmd := exec.Command("systemctl", "stop", "scx.service")
-	return cmd.Run()
-}
-
-func SCXStatus() (bool, error) {
-	cmd := exec.Command("systemctl", "is-active", "scx.service")
-	err := cmd.Run()
-	return err == nil, nil
-}
-
-func GetSchedulers() ([]string, error) {
-	entries, err := filepath.Glob("/usr/bin/scx_*")
-	if err != nil {
-		return nil, err
-	}
-
-	var schedulers []string
-	for _, e := range entries {
-		name := filepath.Base(e)
-		if strings.HasPrefix(name, "scx_") {
-			schedulers = append(schedulers, strings.TrimPrefix(name, "scx_"))
-		}
-	}
-	return schedulers, nil
-}
-
 type CPUMetrics struct {
 	Frequencies      []int
 	AverageFrequency int
@@ -0,0 +1,38 @@
+package cpu
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{"0", []int{0}, false},
+		{"0-3", []int{0, 1, 2, 3}, false},
+		{"0-3,8,10-11", []int{0, 1, 2, 3, 8, 10, 11}, false},
+		{"0-7:2", []int{0, 2, 4, 6}, false},
+		{"4,0-1,1", []int{0, 1, 4}, false},
+		{"", nil, true},
+		{"0,", nil, true},
+		{"3-1", nil, true},
+		{"a-b", nil, true},
+		{"0-3:0", nil, true},
+		{"-1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCPUList(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCPUList(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("ParseCPUList(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package cpu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// IOClass is an I/O scheduling class, as set by ionice.
+type IOClass string
+
+const (
+	IOClassRealtime   IOClass = "realtime"
+	IOClassBestEffort IOClass = "best-effort"
+	IOClassIdle       IOClass = "idle"
+)
+
+// SchedPolicy is a Linux scheduling policy.
+type SchedPolicy string
+
+const (
+	SchedOther SchedPolicy = "other"
+	SchedBatch SchedPolicy = "batch"
+	SchedIdle  SchedPolicy = "idle"
+	SchedFIFO  SchedPolicy = "fifo"
+	SchedRR    SchedPolicy = "rr"
+)
+
+// Limits of the per-process scheduling settings.
+const (
+	MinNice          = -20
+	MaxNice          = 19
+	MaxIOPriority    = 7
+	MinSchedPriority = 1
+	MaxSchedPriority = 99
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var (
+	ioClasses = map[IOClass]int{
+		IOClassRealtime:   1,
+		IOClassBestEffort: 2,
+		IOClassIdle:       3,
+	}
+	schedPolicies = map[SchedPolicy]uint32{
+		SchedOther: unix.SCHED_NORMAL,
+		SchedBatch: unix.SCHED_BATCH,
+		SchedIdle:  unix.SCHED_IDLE,
+		SchedFIFO:  unix.SCHED_FIFO,
+		SchedRR:    unix.SCHED_RR,
+	}
+)
+
+// IsRealtime reports whether the policy takes a priority.
+func (p SchedPolicy) IsRealtime() bool {
+	return p == SchedFIFO || p == SchedRR
+}
+
+// SetNice sets the niceness of every thread of a process. Lowering it needs
+// CAP_SYS_NICE or a matching RLIMIT_NICE.
+func SetNice(pid, nice int) error {
+	return forEachThread(pid, func(tid int) error {
+		return unix.Setpriority(unix.PRIO_PROCESS, tid, nice)
+	})
+}
+
+// SetIOPriority sets the I/O scheduling class and level of every thread of
+// a process. The idle class has no levels.
+func SetIOPriority(pid int, class IOClass, level int) error {
+	c, ok := ioClasses[class]
+	if !ok {
+		return fmt.Errorf("unknown I/O class %q", class)
+	}
+	if class == IOClassIdle {
+		level = 0
+	}
+	prio := c<<ioprioClassShift | level
+	return forEachThread(pid, func(tid int) error {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// SetSchedPolicy sets the scheduling policy of every thread of a process,
+// keeping their niceness. priority only applies to the fifo and rr
+// policies.
+func SetSchedPolicy(pid int, policy SchedPolicy, priority int) error {
+	p, ok := schedPolicies[policy]
+	if !ok {
+		return fmt.Errorf("unknown scheduling policy %q", policy)
+	}
+	if !policy.IsRealtime() {
+		priority = 0
+	}
+	return forEachThread(pid, func(tid int) error {
+		attr, err := unix.SchedGetAttr(tid, 0)
+		if err != nil {
+			return err
+		}
+		attr.Policy = p
+		attr.Priority = uint32(priority)
+		return unix.SchedSetAttr(tid, attr, 0)
+	})
+}
+
+// forEachThread calls fn for every thread of a process and returns the first
+// error. Threads that exit in the meantime are skipped.
+func forEachThread(pid int, fn func(tid int) error) error {
+	entries, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "task"))
+	if err != nil {
+		return err
+	}
+	var first error
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if err := fn(tid); err != nil && !errors.Is(err, unix.ESRCH) && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,67 @@
+package cpu
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestProcessScheduling(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	pid := cmd.Process.Pid
+
+	if err := SetNice(pid, 10); err != nil {
+		t.Fatalf("SetNice() error = %v", err)
+	}
+	if err := SetSchedPolicy(pid, SchedBatch, 0); err != nil {
+		t.Fatalf("SetSchedPolicy() error = %v", err)
+	}
+	if err := SetIOPriority(pid, IOClassIdle, 0); err != nil {
+		t.Fatalf("SetIOPriority() error = %v", err)
+	}
+
+	attr, err := unix.SchedGetAttr(pid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attr.Policy != unix.SCHED_BATCH {
+		t.Errorf("policy = %d, want SCHED_BATCH", attr.Policy)
+	}
+	// Changing the policy must keep the niceness set before it.
+	if got := niceOf(t, pid); got != 10 {
+		t.Errorf("nice = %d, want 10", got)
+	}
+	if prio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0); errno != 0 || prio>>ioprioClassShift != 3 {
+		t.Errorf("ioprio = %#x (%v), want idle class", prio, errno)
+	}
+
+	if err := SetSchedPolicy(pid, "deadline", 0); err == nil {
+		t.Error("SetSchedPolicy() accepted an unknown policy")
+	}
+}
+
+func niceOf(t *testing.T, pid int) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	nice, err := strconv.Atoi(fields[16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nice
+}
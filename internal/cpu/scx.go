@@ -0,0 +1,360 @@
+package cpu
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// SCXMode is a scheduler mode of scx_loader. Schedulers that do not support
+// modes ignore it.
+type SCXMode string
+
+const (
+	SCXModeAuto       SCXMode = "auto"
+	SCXModeGaming     SCXMode = "gaming"
+	SCXModePowerSave  SCXMode = "powersave"
+	SCXModeLowLatency SCXMode = "lowlatency"
+	SCXModeServer     SCXMode = "server"
+)
+
+// SCXModes lists the scheduler modes in the order scx_loader numbers them.
+var SCXModes = []SCXMode{SCXModeAuto, SCXModeGaming, SCXModePowerSave, SCXModeLowLatency, SCXModeServer}
+
+// SCXBackend is how sched_ext schedulers are switched.
+type SCXBackend string
+
+const (
+	// SCXBackendLoader talks to scx_loader over D-Bus.
+	SCXBackendLoader SCXBackend = "scx_loader"
+	// SCXBackendService rewrites the scheduler in the configuration of
+	// scx.service and restarts it.
+	SCXBackendService SCXBackend = "scx.service"
+)
+
+const (
+	scxBusName       = "org.scx.Loader"
+	scxObjectPath    = "/org/scx/Loader"
+	scxInterfaceName = "org.scx.Loader"
+
+	scxService       = "scx.service"
+	scxSchedulerVar  = "SCX_SCHEDULER"
+	scxSchedulerBins = "/usr/bin/scx_*"
+)
+
+// scxServiceConfig is the environment file scx.service reads the scheduler
+// from.
+var scxServiceConfig = "/etc/default/scx"
+
+// SCXState is the sched_ext scheduler that is running.
+type SCXState struct {
+	Backend SCXBackend
+	// Scheduler is the running scheduler, such as "scx_lavd", or "" when
+	// none is. For scx.service it is the configured scheduler, which runs
+	// only when Running is set.
+	Scheduler string
+	Running   bool
+	// Mode is only known with scx_loader.
+	Mode SCXMode
+}
+
+func (s SCXState) String() string {
+	if !s.Running {
+		return "none"
+	}
+	if s.Mode != "" {
+		return fmt.Sprintf("%s (%s)", s.Scheduler, s.Mode)
+	}
+	return s.Scheduler
+}
+
+// SCXSchedulerName returns the full name of a scheduler, so that "lavd" and
+// "scx_lavd" name the same one.
+func SCXSchedulerName(name string) string {
+	if name == "" || strings.HasPrefix(name, "scx_") {
+		return name
+	}
+	return "scx_" + name
+}
+
+// SCXIsAvailable reports whether sched_ext schedulers can be switched, by
+// scx_loader or scx.service.
+func SCXIsAvailable() bool {
+	if scxLoaderAvailable() {
+		return true
+	}
+	_, err := os.Stat(scxServiceConfig)
+	return err == nil
+}
+
+// SCXBackendInUse returns the backend schedulers are switched with:
+// scx_loader when it is reachable, scx.service otherwise.
+func SCXBackendInUse() SCXBackend {
+	if scxLoaderAvailable() {
+		return SCXBackendLoader
+	}
+	return SCXBackendService
+}
+
+// SCXStatus returns the running scheduler.
+func SCXStatus() (SCXState, error) {
+	if SCXBackendInUse() == SCXBackendLoader {
+		return scxLoaderStatus()
+	}
+	return scxServiceStatus()
+}
+
+// SCXStart switches to scheduler in the given mode, starting it if no
+// scheduler is running. With scx.service the mode is ignored.
+func SCXStart(scheduler string, mode SCXMode) error {
+	scheduler = SCXSchedulerName(scheduler)
+	if scheduler == "" {
+		return errors.New("no scheduler given")
+	}
+	if SCXBackendInUse() == SCXBackendLoader {
+		return scxLoaderSwitch(scheduler, mode)
+	}
+	return scxServiceSwitch(scheduler, true)
+}
+
+// SCXStop stops the running scheduler, handing the CPUs back to the kernel's
+// own scheduler.
+func SCXStop() error {
+	if SCXBackendInUse() == SCXBackendLoader {
+		return scxLoaderSwitch("", "")
+	}
+	if err := exec.Command("systemctl", "stop", scxService).Run(); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", scxService, err)
+	}
+	return nil
+}
+
+// SCXRestoreLoader makes scx_loader run scheduler in mode, or stops it when
+// scheduler is "". It undoes a switch made with scx_loader.
+func SCXRestoreLoader(scheduler string, mode SCXMode) error {
+	return scxLoaderSwitch(SCXSchedulerName(scheduler), mode)
+}
+
+// SCXRestoreService sets the scheduler in the configuration of scx.service,
+// then restarts the service if running is set and stops it otherwise. It
+// undoes a switch made with scx.service.
+func SCXRestoreService(scheduler string, running bool) error {
+	return scxServiceSwitch(SCXSchedulerName(scheduler), running)
+}
+
+func scxServiceSwitch(scheduler string, running bool) error {
+	data, err := os.ReadFile(scxServiceConfig)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", scxServiceConfig, err)
+	}
+	active := scxServiceActive()
+	if scxConfiguredScheduler(data) == scheduler && active == running {
+		return nil
+	}
+	if scxConfiguredScheduler(data) != scheduler {
+		if err := os.WriteFile(scxServiceConfig, setSCXConfiguredScheduler(data, scheduler), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", scxServiceConfig, err)
+		}
+	}
+
+	action := "stop"
+	if running {
+		action = "restart"
+	}
+	if err := exec.Command("systemctl", action, scxService).Run(); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, scxService, err)
+	}
+	return nil
+}
+
+// GetSchedulers lists the schedulers that can be switched to.
+func GetSchedulers() ([]string, error) {
+	if SCXBackendInUse() == SCXBackendLoader {
+		if schedulers, err := scxLoaderSchedulers(); err == nil {
+			return schedulers, nil
+		}
+	}
+
+	entries, err := filepath.Glob(scxSchedulerBins)
+	if err != nil {
+		return nil, err
+	}
+	var schedulers []string
+	for _, e := range entries {
+		// scx_loader is installed next to the schedulers.
+		if name := filepath.Base(e); name != "scx_loader" {
+			schedulers = append(schedulers, name)
+		}
+	}
+	return schedulers, nil
+}
+
+func scxLoader() (*dbus.Conn, dbus.BusObject, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	return conn, conn.Object(scxBusName, scxObjectPath), nil
+}
+
+func scxLoaderAvailable() bool {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return false
+	}
+	defer func() { _ = conn.Close() }()
+
+	var running bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, scxBusName).Store(&running); err == nil && running {
+		return true
+	}
+	var activatable []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&activatable); err != nil {
+		return false
+	}
+	return slices.Contains(activatable, scxBusName)
+}
+
+func scxLoaderStatus() (SCXState, error) {
+	conn, obj, err := scxLoader()
+	if err != nil {
+		return SCXState{}, err
+	}
+	defer func() { _ = conn.Close() }()
+	return scxLoaderState(obj)
+}
+
+func scxLoaderState(obj dbus.BusObject) (SCXState, error) {
+	s := SCXState{Backend: SCXBackendLoader}
+	var scheduler string
+	if err := obj.StoreProperty(scxInterfaceName+".CurrentScheduler", &scheduler); err != nil {
+		return SCXState{}, fmt.Errorf("failed to read current scheduler: %w", err)
+	}
+	// scx_loader reports "unknown" when no scheduler runs.
+	if scheduler != "unknown" {
+		s.Scheduler, s.Running = scheduler, true
+
+		var mode uint32
+		if err := obj.StoreProperty(scxInterfaceName+".SchedulerMode", &mode); err == nil && int(mode) < len(SCXModes) {
+			s.Mode = SCXModes[mode]
+		}
+	}
+	return s, nil
+}
+
+// scxLoaderSwitch starts, switches to, or with an empty scheduler stops a
+// scheduler through scx_loader.
+func scxLoaderSwitch(scheduler string, mode SCXMode) error {
+	conn, obj, err := scxLoader()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	current, err := scxLoaderState(obj)
+	if err != nil {
+		return err
+	}
+
+	if scheduler == "" {
+		if !current.Running {
+			return nil
+		}
+		if err := obj.Call(scxInterfaceName+".StopScheduler", 0).Err; err != nil {
+			return fmt.Errorf("failed to stop scheduler: %w", err)
+		}
+		return nil
+	}
+
+	if mode == "" {
+		mode = SCXModeAuto
+	}
+	m := slices.Index(SCXModes, mode)
+	if m < 0 {
+		return fmt.Errorf("unknown scheduler mode %q", mode)
+	}
+	if current.Running && current.Scheduler == scheduler && current.Mode == mode {
+		return nil
+	}
+	method := "StartScheduler"
+	if current.Running {
+		method = "SwitchScheduler"
+	}
+	if err := obj.Call(scxInterfaceName+"."+method, 0, scheduler, uint32(m)).Err; err != nil {
+		return fmt.Errorf("failed to switch to %s: %w", scheduler, err)
+	}
+	return nil
+}
+
+func scxLoaderSchedulers() ([]string, error) {
+	conn, obj, err := scxLoader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	var schedulers []string
+	if err := obj.StoreProperty(scxInterfaceName+".SupportedSchedulers", &schedulers); err != nil {
+		return nil, fmt.Errorf("failed to list schedulers: %w", err)
+	}
+	return schedulers, nil
+}
+
+func scxServiceStatus() (SCXState, error) {
+	data, err := os.ReadFile(scxServiceConfig)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return SCXState{}, fmt.Errorf("failed to read %s: %w", scxServiceConfig, err)
+	}
+	return SCXState{
+		Backend:   SCXBackendService,
+		Scheduler: scxConfiguredScheduler(data),
+		Running:   scxServiceActive(),
+	}, nil
+}
+
+func scxServiceActive() bool {
+	return exec.Command("systemctl", "is-active", "--quiet", scxService).Run() == nil
+}
+
+// scxConfiguredScheduler returns the value of SCX_SCHEDULER in the
+// environment file of scx.service.
+func scxConfiguredScheduler(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var scheduler string
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if ok && strings.TrimSpace(key) == scxSchedulerVar {
+			scheduler = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return scheduler
+}
+
+// setSCXConfiguredScheduler sets SCX_SCHEDULER in the environment file of
+// scx.service, keeping the rest of it.
+func setSCXConfiguredScheduler(data []byte, scheduler string) []byte {
+	line := scxSchedulerVar + "=" + scheduler
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	found := false
+	for i, l := range lines {
+		key, _, ok := strings.Cut(strings.TrimSpace(l), "=")
+		if ok && strings.TrimSpace(key) == scxSchedulerVar {
+			lines[i], found = line, true
+		}
+	}
+	if !found {
+		if len(lines) == 1 && lines[0] == "" {
+			lines = lines[:0]
+		}
+		lines = append(lines, line)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
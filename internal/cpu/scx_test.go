@@ -0,0 +1,57 @@
+package cpu
+
+import "testing"
+
+func TestSCXServiceConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		scheduler string
+		current   string
+		want      string
+	}{
+		{
+			name:      "replace",
+			config:    "# scx.service\nSCX_SCHEDULER=scx_bpfland\nSCX_FLAGS='-k'\n",
+			scheduler: "scx_lavd",
+			current:   "scx_bpfland",
+			want:      "# scx.service\nSCX_SCHEDULER=scx_lavd\nSCX_FLAGS='-k'\n",
+		},
+		{
+			name:      "quoted",
+			config:    "SCX_SCHEDULER=\"scx_rusty\"",
+			scheduler: "scx_lavd",
+			current:   "scx_rusty",
+			want:      "SCX_SCHEDULER=scx_lavd\n",
+		},
+		{
+			name:      "append",
+			config:    "SCX_FLAGS=\n",
+			scheduler: "scx_lavd",
+			want:      "SCX_FLAGS=\nSCX_SCHEDULER=scx_lavd\n",
+		},
+		{
+			name:      "empty",
+			scheduler: "scx_lavd",
+			want:      "SCX_SCHEDULER=scx_lavd\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scxConfiguredScheduler([]byte(tt.config)); got != tt.current {
+				t.Errorf("scxConfiguredScheduler() = %q, want %q", got, tt.current)
+			}
+			if got := string(setSCXConfiguredScheduler([]byte(tt.config), tt.scheduler)); got != tt.want {
+				t.Errorf("setSCXConfiguredScheduler() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSCXSchedulerName(t *testing.T) {
+	for input, want := range map[string]string{"lavd": "scx_lavd", "scx_lavd": "scx_lavd", "": ""} {
+		if got := SCXSchedulerName(input); got != want {
+			t.Errorf("SCXSchedulerName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package cpu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AffinityPreset is a symbolic CPU affinity, resolved against the machine's
+// topology at launch.
+type AffinityPreset string
+
+const (
+	// AffinityPerformanceCores selects the P-cores of a hybrid CPU, or the
+	// cores that clock highest on CPUs that mix core types without
+	// advertising them.
+	AffinityPerformanceCores AffinityPreset = "performance-cores"
+	// AffinityVCacheCCD selects the CCD with the largest L3 cache, such as
+	// the 3D V-Cache CCD of an X3D CPU.
+	AffinityVCacheCCD AffinityPreset = "vcache-ccd"
+	// AffinityNoSMTSiblings selects one thread of every core.
+	AffinityNoSMTSiblings AffinityPreset = "no-smt-siblings"
+)
+
+// AffinityPresets lists the symbolic affinity values.
+var AffinityPresets = []AffinityPreset{AffinityPerformanceCores, AffinityVCacheCCD, AffinityNoSMTSiblings}
+
+// performanceFreqRatio is how close, in percent, the maximum frequency of a
+// core must be to the fastest one to count as a performance core. It keeps
+// preferred-core boost differences within one core type together.
+const performanceFreqRatio = 90
+
+// sysfsRoot is where topology is read from.
+const sysfsRoot = "/sys/devices"
+
+// CoreType is the kind of core of a hybrid CPU.
+type CoreType string
+
+const (
+	CoreTypePerformance CoreType = "performance"
+	CoreTypeEfficiency  CoreType = "efficiency"
+)
+
+// LogicalCPU describes one logical CPU.
+type LogicalCPU struct {
+	ID      int
+	Core    int
+	Package int
+	// Siblings lists the logical CPUs sharing the core, this one included.
+	Siblings []int
+	// MaxFreq is the maximum frequency in kHz, or 0 if unknown.
+	MaxFreq int
+	// L3 lists the logical CPUs sharing this CPU's L3 cache, and L3Size is
+	// its size in KiB.
+	L3     []int
+	L3Size int
+	// Type is set on hybrid CPUs only.
+	Type CoreType
+}
+
+// Topology is the layout of the online CPUs.
+type Topology struct {
+	CPUs []LogicalCPU
+}
+
+// ReadTopology reads the topology of the online CPUs from sysfs.
+func ReadTopology() (*Topology, error) {
+	return readTopology(sysfsRoot)
+}
+
+func readTopology(root string) (*Topology, error) {
+	cpuDir := filepath.Join(root, "system", "cpu")
+	ids, err := onlineCPUs(cpuDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CPU topology: %w", err)
+	}
+
+	types := make(map[int]CoreType)
+	for pmu, coreType := range map[string]CoreType{"cpu_core": CoreTypePerformance, "cpu_atom": CoreTypeEfficiency} {
+		cpus, err := readCPUList(filepath.Join(root, pmu, "cpus"))
+		if err != nil {
+			continue
+		}
+		for _, id := range cpus {
+			types[id] = coreType
+		}
+	}
+
+	t := &Topology{}
+	for _, id := range ids {
+		dir := filepath.Join(cpuDir, "cpu"+strconv.Itoa(id))
+		c := LogicalCPU{ID: id, Type: types[id]}
+		if c.Core, err = readInt(filepath.Join(dir, "topology", "core_id")); err != nil {
+			return nil, fmt.Errorf("failed to read topology of cpu%d: %w", id, err)
+		}
+		c.Package, _ = readInt(filepath.Join(dir, "topology", "physical_package_id"))
+		if c.Siblings, err = readCPUList(filepath.Join(dir, "topology", "thread_siblings_list")); err != nil {
+			c.Siblings = []int{id}
+		}
+		c.MaxFreq, _ = readInt(filepath.Join(dir, "cpufreq", "cpuinfo_max_freq"))
+		c.L3, _ = readCPUList(filepath.Join(dir, "cache", "index3", "shared_cpu_list"))
+		c.L3Size, _ = readCacheSize(filepath.Join(dir, "cache", "index3", "size"))
+		t.CPUs = append(t.CPUs, c)
+	}
+	return t, nil
+}
+
+// onlineCPUs returns the online CPUs, or every CPU directory when the online
+// list is missing.
+func onlineCPUs(cpuDir string) ([]int, error) {
+	if ids, err := readCPUList(filepath.Join(cpuDir, "online")); err == nil {
+		return ids, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(cpuDir, "cpu[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, m := range matches {
+		if id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(m), "cpu")); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no CPUs found")
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// IsHybrid reports whether the CPU has both performance and efficiency
+// cores.
+func (t *Topology) IsHybrid() bool {
+	var p, e bool
+	for _, c := range t.CPUs {
+		p = p || c.Type == CoreTypePerformance
+		e = e || c.Type == CoreTypeEfficiency
+	}
+	return p && e
+}
+
+// Resolve returns the CPUs a symbolic affinity selects, in ascending order.
+func (t *Topology) Resolve(preset AffinityPreset) ([]int, error) {
+	var cpus []int
+	switch preset {
+	case AffinityPerformanceCores:
+		cpus = t.performanceCores()
+	case AffinityVCacheCCD:
+		var err error
+		if cpus, err = t.vcacheCCD(); err != nil {
+			return nil, err
+		}
+	case AffinityNoSMTSiblings:
+		for _, c := range t.CPUs {
+			if len(c.Siblings) == 0 || slices.Min(c.Siblings) == c.ID {
+				cpus = append(cpus, c.ID)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown affinity preset %q", preset)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no CPUs match %s", preset)
+	}
+	slices.Sort(cpus)
+	return cpus, nil
+}
+
+func (t *Topology) performanceCores() []int {
+	var cpus []int
+	if t.IsHybrid() {
+		for _, c := range t.CPUs {
+			if c.Type == CoreTypePerformance {
+				cpus = append(cpus, c.ID)
+			}
+		}
+		return cpus
+	}
+
+	var fastest int
+	for _, c := range t.CPUs {
+		fastest = max(fastest, c.MaxFreq)
+	}
+	for _, c := range t.CPUs {
+		if c.MaxFreq*100 >= fastest*performanceFreqRatio {
+			cpus = append(cpus, c.ID)
+		}
+	}
+	return cpus
+}
+
+func (t *Topology) vcacheCCD() ([]int, error) {
+	groups := make(map[string][]int)
+	sizes := make(map[string]int)
+	var largest string
+	for _, c := range t.CPUs {
+		if len(c.L3) == 0 {
+			continue
+		}
+		group := FormatCPUList(c.L3)
+		groups[group], sizes[group] = c.L3, c.L3Size
+		if largest == "" || c.L3Size > sizes[largest] {
+			largest = group
+		}
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("no L3 cache information")
+	}
+	for group, size := range sizes {
+		if group != largest && size == sizes[largest] {
+			return nil, errors.New("no CCD has a larger L3 cache than the others")
+		}
+	}
+	return slices.Clone(groups[largest]), nil
+}
+
+// ResolveAffinity turns an affinity setting into a CPU list for taskset.
+// CPU lists are returned as they are; symbolic values are resolved against
+// the topology read from sysfs.
+func ResolveAffinity(affinity string) (string, error) {
+	preset := AffinityPreset(affinity)
+	if !slices.Contains(AffinityPresets, preset) {
+		return affinity, nil
+	}
+	t, err := ReadTopology()
+	if err != nil {
+		return "", err
+	}
+	cpus, err := t.Resolve(preset)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve affinity %s: %w", preset, err)
+	}
+	return FormatCPUList(cpus), nil
+}
+
+// FormatCPUList formats CPUs as a list in the taskset/cpuset format,
+// collapsing consecutive CPUs into ranges.
+func FormatCPUList(cpus []int) string {
+	sorted := slices.Clone(cpus)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func readCPUList(path string) ([]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCPUList(strings.TrimSpace(string(data)))
+}
+
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// readCacheSize reads a cache size such as "32768K" in KiB.
+func readCacheSize(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	size := strings.TrimSpace(string(data))
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(size, "K"):
+		size = strings.TrimSuffix(size, "K")
+	case strings.HasSuffix(size, "M"):
+		size, multiplier = strings.TrimSuffix(size, "M"), 1024
+	}
+	n, err := strconv.Atoi(size)
+	return n * multiplier, err
+}
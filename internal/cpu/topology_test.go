@@ -0,0 +1,161 @@
+package cpu
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// fakeCPU is a logical CPU of a fake sysfs tree.
+type fakeCPU struct {
+	core     int
+	siblings string
+	maxFreq  int
+	l3       string
+	l3Size   string
+}
+
+// writeSysfs writes a fake /sys/devices tree for cpus, with the given hybrid
+// PMU CPU lists if not empty, and returns its root.
+func writeSysfs(t *testing.T, cpus []fakeCPU, coreCPUs, atomCPUs string) string {
+	t.Helper()
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("system/cpu/online", "0-"+strconv.Itoa(len(cpus)-1))
+	for i, c := range cpus {
+		dir := "system/cpu/cpu" + strconv.Itoa(i)
+		write(dir+"/topology/core_id", strconv.Itoa(c.core))
+		write(dir+"/topology/physical_package_id", "0")
+		write(dir+"/topology/thread_siblings_list", c.siblings)
+		write(dir+"/cpufreq/cpuinfo_max_freq", strconv.Itoa(c.maxFreq))
+		write(dir+"/cache/index3/shared_cpu_list", c.l3)
+		write(dir+"/cache/index3/size", c.l3Size)
+	}
+	if coreCPUs != "" {
+		write("cpu_core/cpus", coreCPUs)
+		write("cpu_atom/cpus", atomCPUs)
+	}
+	return root
+}
+
+func TestResolveHybrid(t *testing.T) {
+	// Two P-cores with SMT and two E-cores, all sharing one L3.
+	cpus := []fakeCPU{
+		{core: 0, siblings: "0-1", maxFreq: 5400000, l3: "0-5", l3Size: "30720K"},
+		{core: 0, siblings: "0-1", maxFreq: 5400000, l3: "0-5", l3Size: "30720K"},
+		{core: 4, siblings: "2-3", maxFreq: 5600000, l3: "0-5", l3Size: "30720K"},
+		{core: 4, siblings: "2-3", maxFreq: 5600000, l3: "0-5", l3Size: "30720K"},
+		{core: 8, siblings: "4", maxFreq: 4300000, l3: "0-5", l3Size: "30720K"},
+		{core: 9, siblings: "5", maxFreq: 4300000, l3: "0-5", l3Size: "30720K"},
+	}
+	topology, err := readTopology(writeSysfs(t, cpus, "0-3", "4-5"))
+	if err != nil {
+		t.Fatalf("readTopology() error = %v", err)
+	}
+	if !topology.IsHybrid() {
+		t.Error("IsHybrid() = false, want true")
+	}
+
+	tests := []struct {
+		preset AffinityPreset
+		want   []int
+	}{
+		{AffinityPerformanceCores, []int{0, 1, 2, 3}},
+		{AffinityNoSMTSiblings, []int{0, 2, 4, 5}},
+		{AffinityVCacheCCD, []int{0, 1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		got, err := topology.Resolve(tt.preset)
+		if err != nil {
+			t.Errorf("Resolve(%s) error = %v", tt.preset, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Resolve(%s) = %v, want %v", tt.preset, got, tt.want)
+		}
+	}
+}
+
+func TestResolveX3D(t *testing.T) {
+	// Two CCDs of two cores with SMT; the first has the stacked cache and the
+	// second clocks slightly higher.
+	var cpus []fakeCPU
+	for i := range 8 {
+		core := i % 4
+		c := fakeCPU{core: core, siblings: FormatCPUList([]int{core, core + 4}), maxFreq: 5250000, l3: "0-1,4-5", l3Size: "98304K"}
+		if core >= 2 {
+			c.maxFreq, c.l3, c.l3Size = 5750000, "2-3,6-7", "32M"
+		}
+		cpus = append(cpus, c)
+	}
+	topology, err := readTopology(writeSysfs(t, cpus, "", ""))
+	if err != nil {
+		t.Fatalf("readTopology() error = %v", err)
+	}
+	if topology.IsHybrid() {
+		t.Error("IsHybrid() = true, want false")
+	}
+
+	tests := []struct {
+		preset AffinityPreset
+		want   []int
+	}{
+		{AffinityVCacheCCD, []int{0, 1, 4, 5}},
+		{AffinityNoSMTSiblings, []int{0, 1, 2, 3}},
+		{AffinityPerformanceCores, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+	}
+	for _, tt := range tests {
+		got, err := topology.Resolve(tt.preset)
+		if err != nil {
+			t.Errorf("Resolve(%s) error = %v", tt.preset, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Resolve(%s) = %v, want %v", tt.preset, got, tt.want)
+		}
+	}
+}
+
+func TestResolveVCacheCCDWithoutVCache(t *testing.T) {
+	cpus := []fakeCPU{
+		{core: 0, siblings: "0", maxFreq: 5700000, l3: "0", l3Size: "32768K"},
+		{core: 1, siblings: "1", maxFreq: 5700000, l3: "1", l3Size: "32768K"},
+	}
+	topology, err := readTopology(writeSysfs(t, cpus, "", ""))
+	if err != nil {
+		t.Fatalf("readTopology() error = %v", err)
+	}
+	if _, err := topology.Resolve(AffinityVCacheCCD); err == nil {
+		t.Error("Resolve(vcache-ccd) error = nil, want error for equal L3 caches")
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	tests := []struct {
+		cpus []int
+		want string
+	}{
+		{nil, ""},
+		{[]int{3}, "3"},
+		{[]int{0, 1, 2, 3}, "0-3"},
+		{[]int{6, 0, 1, 4, 5, 1}, "0-1,4-6"},
+		{[]int{0, 2, 4}, "0,2,4"},
+	}
+	for _, tt := range tests {
+		if got := FormatCPUList(tt.cpus); got != tt.want {
+			t.Errorf("FormatCPUList(%v) = %q, want %q", tt.cpus, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Package display reports the modes the connected displays are currently
+// driven at.
+package display
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// driDir is where the DRM device nodes are.
+const driDir = "/dev/dri"
+
+var modePattern = regexp.MustCompile(`^[0-9]+x[0-9]+$`)
+
+// Resolutions returns the current mode of every active display as
+// WIDTHxHEIGHT, sorted and without duplicates. The modes are read from the
+// kernel's CRTCs, which hold whatever the compositor set rather than the
+// panel's native mode. When no DRM device offers modesetting, as with the
+// NVIDIA driver without nvidia-drm.modeset, xrandr is asked instead.
+func Resolutions() []string {
+	resolutions := drmResolutions(driDir)
+	if len(resolutions) == 0 {
+		resolutions = xrandrResolutions()
+	}
+	slices.Sort(resolutions)
+	return slices.Compact(resolutions)
+}
+
+// Layouts of the DRM mode-setting ioctl arguments from drm_mode.h.
+type drmModeCardRes struct {
+	FBIDPtr         uint64
+	CRTCIDPtr       uint64
+	ConnectorIDPtr  uint64
+	EncoderIDPtr    uint64
+	CountFBs        uint32
+	CountCRTCs      uint32
+	CountConnectors uint32
+	CountEncoders   uint32
+	MinWidth        uint32
+	MaxWidth        uint32
+	MinHeight       uint32
+	MaxHeight       uint32
+}
+
+type drmModeModeInfo struct {
+	Clock      uint32
+	HDisplay   uint16
+	HSyncStart uint16
+	HSyncEnd   uint16
+	HTotal     uint16
+	HSkew      uint16
+	VDisplay   uint16
+	VSyncStart uint16
+	VSyncEnd   uint16
+	VTotal     uint16
+	VScan      uint16
+	VRefresh   uint32
+	Flags      uint32
+	Type       uint32
+	Name       [32]byte
+}
+
+type drmModeCRTC struct {
+	SetConnectorsPtr uint64
+	CountConnectors  uint32
+	CRTCID           uint32
+	FBID             uint32
+	X                uint32
+	Y                uint32
+	GammaSize        uint32
+	ModeValid        uint32
+	Mode             drmModeModeInfo
+}
+
+// drmIOWR builds the request number of a read-write DRM ioctl.
+func drmIOWR(nr, size uintptr) uintptr {
+	const (
+		iocRead  = 2
+		iocWrite = 1
+		drmBase  = 'd'
+	)
+	return (iocRead|iocWrite)<<30 | size<<16 | drmBase<<8 | nr
+}
+
+var (
+	ioctlModeGetResources = drmIOWR(0xa0, unsafe.Sizeof(drmModeCardRes{}))
+	ioctlModeGetCRTC      = drmIOWR(0xa1, unsafe.Sizeof(drmModeCRTC{}))
+)
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// drmResolutions returns the mode of every CRTC that is scanning out, on
+// every DRM card in dir. Cards that cannot be opened or do not support
+// modesetting are skipped.
+func drmResolutions(dir string) []string {
+	cards, _ := filepath.Glob(filepath.Join(dir, "card[0-9]*"))
+
+	var resolutions []string
+	for _, card := range cards {
+		f, err := os.OpenFile(card, os.O_RDWR, 0)
+		if err != nil {
+			continue
+		}
+		modes, _ := crtcModes(f.Fd())
+		_ = f.Close()
+		for _, mode := range modes {
+			resolutions = append(resolutions, fmt.Sprintf("%dx%d", mode.HDisplay, mode.VDisplay))
+		}
+	}
+	return resolutions
+}
+
+// crtcModes returns the modes of the active CRTCs of the card open as fd.
+func crtcModes(fd uintptr) ([]drmModeModeInfo, error) {
+	var res drmModeCardRes
+	if err := ioctl(fd, ioctlModeGetResources, unsafe.Pointer(&res)); err != nil {
+		return nil, err
+	}
+	if res.CountCRTCs == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint32, res.CountCRTCs)
+	res = drmModeCardRes{CountCRTCs: uint32(len(ids)), CRTCIDPtr: uint64(uintptr(unsafe.Pointer(&ids[0])))}
+	err := ioctl(fd, ioctlModeGetResources, unsafe.Pointer(&res))
+	runtime.KeepAlive(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var modes []drmModeModeInfo
+	for _, id := range ids[:min(int(res.CountCRTCs), len(ids))] {
+		crtc := drmModeCRTC{CRTCID: id}
+		if err := ioctl(fd, ioctlModeGetCRTC, unsafe.Pointer(&crtc)); err != nil {
+			continue
+		}
+		if crtc.ModeValid != 0 && crtc.Mode.HDisplay != 0 && crtc.Mode.VDisplay != 0 {
+			modes = append(modes, crtc.Mode)
+		}
+	}
+	return modes, nil
+}
+
+func xrandrResolutions() []string {
+	out, err := exec.Command("xrandr", "--current").Output()
+	if err != nil {
+		return nil
+	}
+	return parseXrandr(out)
+}
+
+// parseXrandr returns the current modes from `xrandr --current`, which marks
+// the rate each output runs at with '*'.
+func parseXrandr(out []byte) []string {
+	var resolutions []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !modePattern.MatchString(fields[0]) {
+			continue
+		}
+		if slices.ContainsFunc(fields[1:], func(rate string) bool { return strings.Contains(rate, "*") }) {
+			resolutions = append(resolutions, fields[0])
+		}
+	}
+	return resolutions
+}
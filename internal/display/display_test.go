@@ -0,0 +1,43 @@
+package display
+
+import (
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+func TestDRMLayouts(t *testing.T) {
+	// Sizes of the structs in drm_mode.h; the ioctl numbers encode them.
+	if got := unsafe.Sizeof(drmModeCardRes{}); got != 64 {
+		t.Errorf("drm_mode_card_res is %d bytes, want 64", got)
+	}
+	if got := unsafe.Sizeof(drmModeCRTC{}); got != 104 {
+		t.Errorf("drm_mode_crtc is %d bytes, want 104", got)
+	}
+	if ioctlModeGetResources != 0xc04064a0 || ioctlModeGetCRTC != 0xc06864a1 {
+		t.Errorf("ioctl numbers = %#x, %#x", ioctlModeGetResources, ioctlModeGetCRTC)
+	}
+}
+
+func TestParseXrandr(t *testing.T) {
+	out := []byte(`Screen 0: minimum 8 x 8, current 4480 x 1440, maximum 32767 x 32767
+DP-0 connected primary 2560x1440+1920+0 (normal left inverted right x axis y axis) 597mm x 336mm
+   3840x2160     60.00 +  30.00
+   2560x1440    143.97*   59.95
+   1920x1080     60.00
+HDMI-0 connected 1920x1080+0+0 (normal left inverted right x axis y axis) 527mm x 296mm
+   1920x1080     60.00*+  50.00
+   1280x720      60.00
+HDMI-1 disconnected (normal left inverted right x axis y axis)
+   1920x1080i    60.00*
+`)
+	if got, want := parseXrandr(out), []string{"2560x1440", "1920x1080"}; !slices.Equal(got, want) {
+		t.Errorf("parseXrandr() = %v, want %v", got, want)
+	}
+}
+
+func TestResolutionsWithoutDRM(t *testing.T) {
+	if got := drmResolutions(t.TempDir()); len(got) != 0 {
+		t.Errorf("drmResolutions() of an empty directory = %v", got)
+	}
+}
@@ -0,0 +1,658 @@
+package dll
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"embed"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jgabor/spela/internal/config"
+	"github.com/jgabor/spela/internal/game"
+)
+
+type SignaturePolicy string
+
+const (
+	SignaturePolicyEnforce SignaturePolicy = "enforce"
+	SignaturePolicyWarn    SignaturePolicy = "warn"
+	SignaturePolicyOff     SignaturePolicy = "off"
+)
+
+var (
+	ErrNotSigned        = errors.New("DLL is not signed")
+	ErrSignatureInvalid = errors.New("DLL signature is invalid")
+	ErrUntrustedSigner  = errors.New("DLL signer is not trusted")
+)
+
+// Vendor organizations accepted as signers, keyed by DLL type.
+var dllVendors = map[game.DLLType][]string{
+	game.DLLTypeDLSS:  {"NVIDIA Corporation"},
+	game.DLLTypeDLSSG: {"NVIDIA Corporation"},
+	game.DLLTypeDLSSD: {"NVIDIA Corporation"},
+	game.DLLTypeXeSS:  {"Intel Corporation"},
+	game.DLLTypeFSR:   {"Advanced Micro Devices, Inc.", "Advanced Micro Devices Inc."},
+}
+
+//go:embed roots/*.pem
+var rootsFS embed.FS
+
+var vendorRoots = sync.OnceValue(func() *x509.CertPool {
+	pool := x509.NewCertPool()
+	entries, _ := rootsFS.ReadDir("roots")
+	for _, entry := range entries {
+		data, err := rootsFS.ReadFile("roots/" + entry.Name())
+		if err == nil {
+			pool.AppendCertsFromPEM(data)
+		}
+	}
+	return pool
+})
+
+// The bundled DigiCert and USERTrust roots also anchor the timestamping CAs
+// the vendors countersign with. Timestamp certificates must still carry the
+// timestamping key usage, so a code signing certificate cannot vouch for its
+// own signing time.
+var timestampRoots = vendorRoots
+
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirectData  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidContentType      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidCounterSignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidRFC3161Timestamp = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	oidTSTInfo          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidDigestSHA1       = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+const (
+	winCertRevision2       = 0x0200
+	winCertTypePKCSSigned  = 0x0002
+	winCertificateHeaderSz = 8
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerial           issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+// tstInfo holds the leading fields of an RFC 3161 TSTInfo; the optional
+// trailing fields are not needed.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint digestInfo
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+// SignatureInfo describes a verified Authenticode signature. SignedAt is the
+// time vouched for by a trusted timestamp authority, or zero without one.
+type SignatureInfo struct {
+	Signer   string
+	Vendor   string
+	Issuer   string
+	Digest   string
+	SignedAt time.Time
+}
+
+// VerifyDLLSignature validates the Authenticode signature of the DLL at path
+// against the bundled vendor roots. The signer must belong to the vendor that
+// ships dllName; unknown names accept any supported vendor.
+func VerifyDLLSignature(path, dllName string) (*SignatureInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var vendors []string
+	if dllType, ok := knownDLLs[strings.ToLower(dllName)]; ok {
+		vendors = dllVendors[dllType]
+	} else {
+		for _, v := range dllVendors {
+			vendors = append(vendors, v...)
+		}
+	}
+
+	return verifyAuthenticode(data, vendorRoots(), timestampRoots(), vendors)
+}
+
+// CheckSignaturePolicy verifies the DLL at path according to the configured
+// dll_signature_policy. In warn mode failures are logged and nil is returned.
+func CheckSignaturePolicy(path, dllName string) error {
+	policy := SignaturePolicyEnforce
+	if cfg, err := config.Load(); err == nil && cfg.DLLSignaturePolicy != "" {
+		policy = SignaturePolicy(cfg.DLLSignaturePolicy)
+	}
+
+	if policy == SignaturePolicyOff {
+		return nil
+	}
+
+	_, err := VerifyDLLSignature(path, dllName)
+	if err == nil {
+		return nil
+	}
+
+	if policy == SignaturePolicyWarn {
+		slog.Warn("DLL signature verification failed", "dll", dllName, "path", path, "error", err)
+		return nil
+	}
+
+	return fmt.Errorf("refusing to install %s: %w", dllName, err)
+}
+
+func verifyAuthenticode(data []byte, roots, tsaRoots *x509.CertPool, vendors []string) (*SignatureInfo, error) {
+	f, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	dir, ok := dataDirectory(f, securityDirectoryEntry)
+	if !ok || dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, ErrNotSigned
+	}
+
+	end := uint64(dir.VirtualAddress) + uint64(dir.Size)
+	if end > uint64(len(data)) || dir.Size < winCertificateHeaderSz {
+		return nil, fmt.Errorf("%w: certificate table out of range", ErrSignatureInvalid)
+	}
+
+	table := data[dir.VirtualAddress:end]
+	length := binary.LittleEndian.Uint32(table)
+	revision := binary.LittleEndian.Uint16(table[4:])
+	certType := binary.LittleEndian.Uint16(table[6:])
+	if certType != winCertTypePKCSSigned || revision != winCertRevision2 {
+		return nil, fmt.Errorf("%w: unsupported certificate type 0x%04x", ErrSignatureInvalid, certType)
+	}
+	if length < winCertificateHeaderSz || length > dir.Size {
+		return nil, fmt.Errorf("%w: malformed certificate entry", ErrSignatureInvalid)
+	}
+
+	sd, err := parseSignedData(table[winCertificateHeaderSz:length])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	if !sd.ContentInfo.ContentType.Equal(oidSpcIndirectData) {
+		return nil, fmt.Errorf("%w: not Authenticode content", ErrSignatureInvalid)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("%w: expected one signer, got %d", ErrSignatureInvalid, len(sd.SignerInfos))
+	}
+
+	var spc spcIndirectDataContent
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &spc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	hash, err := digestHash(spc.MessageDigest.Algorithm.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	imageDigest, err := authenticodeDigest(data, f, hash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if !bytes.Equal(imageDigest, spc.MessageDigest.Digest) {
+		return nil, fmt.Errorf("%w: file digest does not match signature", ErrSignatureInvalid)
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	signer := sd.SignerInfos[0]
+	leaf := findSignerCertificate(certs, signer.IssuerAndSerial)
+	if leaf == nil {
+		return nil, fmt.Errorf("%w: signer certificate missing", ErrSignatureInvalid)
+	}
+
+	signerHash, err := digestHash(signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	// The signed content is the SpcIndirectDataContent value without its
+	// outer SEQUENCE tag and length.
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	signedBytes := content.Bytes
+	if len(signer.AuthenticatedAttributes.Bytes) > 0 {
+		attrs, err := parseAttributes(signer.AuthenticatedAttributes.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+
+		var messageDigest []byte
+		if raw, ok := attrs[oidMessageDigest.String()]; ok {
+			_, _ = asn1.Unmarshal(raw, &messageDigest)
+		}
+		h := signerHash.New()
+		h.Write(content.Bytes)
+		if !bytes.Equal(h.Sum(nil), messageDigest) {
+			return nil, fmt.Errorf("%w: content digest mismatch", ErrSignatureInvalid)
+		}
+
+		// RFC 5652 requires the content type among the signed attributes, so
+		// that the signature cannot be moved to other content.
+		var contentType asn1.ObjectIdentifier
+		if raw, ok := attrs[oidContentType.String()]; ok {
+			_, _ = asn1.Unmarshal(raw, &contentType)
+		}
+		if !contentType.Equal(oidSpcIndirectData) {
+			return nil, fmt.Errorf("%w: content type attribute missing or not SpcIndirectDataContent", ErrSignatureInvalid)
+		}
+
+		// Authenticated attributes are signed as an explicit SET OF.
+		signedBytes = append([]byte{0x31}, signer.AuthenticatedAttributes.FullBytes[1:]...)
+	}
+
+	if err := checkSignature(leaf.PublicKey, signerHash, signedBytes, signer.EncryptedDigest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	// Vendor DLLs are routinely used long after their signing certificate
+	// expires, so the chain is validated at the time a timestamp authority
+	// vouches for. The signer's own signingTime attribute can be backdated
+	// and is never trusted; without a verified timestamp the chain has to be
+	// valid today.
+	signedAt, err := verifiedTimestamp(signer, certs, tsaRoots)
+	if err != nil {
+		slog.Debug("ignoring DLL timestamp", "error", err)
+	}
+	verifyAt := signedAt
+	if verifyAt.IsZero() {
+		verifyAt = time.Now()
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		if c != leaf {
+			intermediates.AddCert(c)
+		}
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   verifyAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUntrustedSigner, err)
+	}
+
+	vendor := matchVendor(leaf, vendors)
+	if vendor == "" {
+		return nil, fmt.Errorf("%w: signed by %q", ErrUntrustedSigner, leaf.Subject.CommonName)
+	}
+
+	return &SignatureInfo{
+		Signer:   leaf.Subject.CommonName,
+		Vendor:   vendor,
+		Issuer:   leaf.Issuer.CommonName,
+		Digest:   hash.String(),
+		SignedAt: signedAt,
+	}, nil
+}
+
+func parseSignedData(der []byte) (*signedData, error) {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("content is not PKCS#7 signed data")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, err
+	}
+	return &sd, nil
+}
+
+// parseAttributes returns the first value of each attribute keyed by OID.
+func parseAttributes(data []byte) (map[string][]byte, error) {
+	attrs := make(map[string][]byte)
+	for len(data) > 0 {
+		var attr attribute
+		rest, err := asn1.Unmarshal(data, &attr)
+		if err != nil {
+			return nil, err
+		}
+		var value asn1.RawValue
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &value); err == nil {
+			attrs[attr.Type.String()] = value.FullBytes
+		}
+		data = rest
+	}
+	return attrs, nil
+}
+
+// verifiedTimestamp returns the signing time asserted by a timestamp
+// authority over signer, either as an RFC 3161 token or as a legacy PKCS #9
+// countersignature. It returns the zero time when the signature carries no
+// timestamp.
+func verifiedTimestamp(signer signerInfo, certs []*x509.Certificate, tsaRoots *x509.CertPool) (time.Time, error) {
+	if len(signer.UnauthenticatedAttributes.Bytes) == 0 {
+		return time.Time{}, nil
+	}
+
+	attrs, err := parseAttributes(signer.UnauthenticatedAttributes.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if raw, ok := attrs[oidRFC3161Timestamp.String()]; ok {
+		return verifyTimestampToken(raw, signer.EncryptedDigest, tsaRoots)
+	}
+	if raw, ok := attrs[oidCounterSignature.String()]; ok {
+		return verifyCounterSignature(raw, signer.EncryptedDigest, certs, tsaRoots)
+	}
+	return time.Time{}, nil
+}
+
+// verifyCounterSignature checks a PKCS #9 countersignature over the signer's
+// encrypted digest and returns its signing time.
+func verifyCounterSignature(der, signature []byte, certs []*x509.Certificate, tsaRoots *x509.CertPool) (time.Time, error) {
+	var counter signerInfo
+	if _, err := asn1.Unmarshal(der, &counter); err != nil {
+		return time.Time{}, fmt.Errorf("countersignature: %w", err)
+	}
+
+	tsa := findSignerCertificate(certs, counter.IssuerAndSerial)
+	if tsa == nil {
+		return time.Time{}, fmt.Errorf("countersignature certificate missing")
+	}
+
+	attrs, err := verifySignerAttributes(counter, tsa, signature)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("countersignature: %w", err)
+	}
+
+	var signedAt time.Time
+	raw, ok := attrs[oidSigningTime.String()]
+	if !ok {
+		return time.Time{}, fmt.Errorf("countersignature has no signing time")
+	}
+	if _, err := asn1.Unmarshal(raw, &signedAt); err != nil {
+		return time.Time{}, fmt.Errorf("countersignature: %w", err)
+	}
+
+	if err := verifyTimestampAuthority(tsa, certs, tsaRoots, signedAt); err != nil {
+		return time.Time{}, err
+	}
+	return signedAt, nil
+}
+
+// verifyTimestampToken checks an RFC 3161 timestamp token whose message
+// imprint covers the signer's encrypted digest and returns its genTime.
+func verifyTimestampToken(der, signature []byte, tsaRoots *x509.CertPool) (time.Time, error) {
+	sd, err := parseSignedData(der)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp token: %w", err)
+	}
+	if !sd.ContentInfo.ContentType.Equal(oidTSTInfo) {
+		return time.Time{}, fmt.Errorf("timestamp token has no TSTInfo")
+	}
+	if len(sd.SignerInfos) != 1 {
+		return time.Time{}, fmt.Errorf("timestamp token has %d signers", len(sd.SignerInfos))
+	}
+
+	var content []byte
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return time.Time{}, fmt.Errorf("timestamp token: %w", err)
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(content, &info); err != nil {
+		return time.Time{}, fmt.Errorf("timestamp token: %w", err)
+	}
+
+	imprintHash, err := digestHash(info.MessageImprint.Algorithm.Algorithm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp token: %w", err)
+	}
+	h := imprintHash.New()
+	h.Write(signature)
+	if !bytes.Equal(h.Sum(nil), info.MessageImprint.Digest) {
+		return time.Time{}, fmt.Errorf("timestamp token does not cover the signature")
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp token: %w", err)
+	}
+	tsa := findSignerCertificate(certs, sd.SignerInfos[0].IssuerAndSerial)
+	if tsa == nil {
+		return time.Time{}, fmt.Errorf("timestamp token certificate missing")
+	}
+	if _, err := verifySignerAttributes(sd.SignerInfos[0], tsa, content); err != nil {
+		return time.Time{}, fmt.Errorf("timestamp token: %w", err)
+	}
+
+	if err := verifyTimestampAuthority(tsa, certs, tsaRoots, info.GenTime); err != nil {
+		return time.Time{}, err
+	}
+	return info.GenTime, nil
+}
+
+// verifySignerAttributes checks that si signs content through its
+// authenticated attributes and returns those attributes.
+func verifySignerAttributes(si signerInfo, cert *x509.Certificate, content []byte) (map[string][]byte, error) {
+	if len(si.AuthenticatedAttributes.Bytes) == 0 {
+		return nil, fmt.Errorf("missing authenticated attributes")
+	}
+
+	hash, err := digestHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := parseAttributes(si.AuthenticatedAttributes.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var messageDigest []byte
+	if raw, ok := attrs[oidMessageDigest.String()]; ok {
+		_, _ = asn1.Unmarshal(raw, &messageDigest)
+	}
+	h := hash.New()
+	h.Write(content)
+	if !bytes.Equal(h.Sum(nil), messageDigest) {
+		return nil, fmt.Errorf("message digest mismatch")
+	}
+
+	signed := append([]byte{0x31}, si.AuthenticatedAttributes.FullBytes[1:]...)
+	if err := checkSignature(cert.PublicKey, hash, signed, si.EncryptedDigest); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// verifyTimestampAuthority checks that tsa chains to a trusted timestamping
+// root at the time it asserts. Timestamps from the future are rejected so
+// they cannot move verification past a certificate's expiry.
+func verifyTimestampAuthority(tsa *x509.Certificate, certs []*x509.Certificate, roots *x509.CertPool, at time.Time) error {
+	if at.After(time.Now()) {
+		return fmt.Errorf("timestamp %s is in the future", at.Format(time.RFC3339))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		if c != tsa {
+			intermediates.AddCert(c)
+		}
+	}
+
+	if _, err := tsa.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}); err != nil {
+		return fmt.Errorf("untrusted timestamp authority: %w", err)
+	}
+	return nil
+}
+
+func findSignerCertificate(certs []*x509.Certificate, id issuerAndSerial) *x509.Certificate {
+	for _, c := range certs {
+		if c.SerialNumber.Cmp(id.SerialNumber) == 0 && bytes.Equal(c.RawIssuer, id.Issuer.FullBytes) {
+			return c
+		}
+	}
+	return nil
+}
+
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidDigestSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidDigestSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidDigestSHA512):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported digest algorithm %s", oid)
+	}
+}
+
+// checkSignature verifies sig directly against the public key rather than
+// through x509, which rejects SHA-1 signatures still found on older DLLs.
+func checkSignature(pub crypto.PublicKey, hash crypto.Hash, signed, sig []byte) error {
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, sig) {
+			return fmt.Errorf("ECDSA verification failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// authenticodeDigest hashes the PE image as specified by Authenticode:
+// everything except the checksum, the certificate table directory entry and
+// the certificate table itself.
+func authenticodeDigest(data []byte, f *pe.File, hash crypto.Hash) ([]byte, error) {
+	peOffset := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	optStart := peOffset + 4 + binary.Size(pe.FileHeader{})
+
+	checksumOffset := optStart + 64
+	var certDirOffset int
+	switch f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		certDirOffset = optStart + 96 + securityDirectoryEntry*8
+	case *pe.OptionalHeader64:
+		certDirOffset = optStart + 112 + securityDirectoryEntry*8
+	default:
+		return nil, fmt.Errorf("missing optional header")
+	}
+	if certDirOffset+8 > len(data) {
+		return nil, fmt.Errorf("truncated header")
+	}
+
+	certStart, certEnd := len(data), len(data)
+	if dir, ok := dataDirectory(f, securityDirectoryEntry); ok && dir.Size != 0 {
+		certStart = int(dir.VirtualAddress)
+		certEnd = certStart + int(dir.Size)
+	}
+	if certStart > len(data) || certEnd > len(data) || certStart < certDirOffset+8 {
+		return nil, fmt.Errorf("certificate table out of range")
+	}
+
+	h := hash.New()
+	h.Write(data[:checksumOffset])
+	h.Write(data[checksumOffset+4 : certDirOffset])
+	h.Write(data[certDirOffset+8 : certStart])
+	h.Write(data[certEnd:])
+	return h.Sum(nil), nil
+}
+
+func matchVendor(cert *x509.Certificate, vendors []string) string {
+	names := append([]string{cert.Subject.CommonName}, cert.Subject.Organization...)
+	for _, vendor := range vendors {
+		for _, name := range names {
+			if strings.EqualFold(strings.TrimSpace(name), vendor) {
+				return vendor
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,393 @@
+package dll
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+
+type testSigner struct {
+	root    *x509.Certificate
+	leaf    *x509.Certificate
+	leafKey *rsa.PrivateKey
+	tsa     *x509.Certificate
+	tsaKey  *rsa.PrivateKey
+
+	// contentType replaces SpcIndirectDataContent in the signed contentType
+	// attribute; noContentType leaves the attribute out.
+	contentType   asn1.ObjectIdentifier
+	noContentType bool
+}
+
+// newTestSigner creates a root CA, a code signing leaf valid until notAfter
+// and a timestamp authority that countersigns under the same root.
+func newTestSigner(t *testing.T, organization string, notAfter time.Time) *testSigner {
+	t.Helper()
+
+	rootKey := mustGenerateKey(t)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	root := mustCreateCertificate(t, rootTemplate, rootTemplate, rootKey, rootKey)
+
+	leafKey := mustGenerateKey(t)
+	leaf := mustCreateCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: organization, Organization: []string{organization}},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, root, leafKey, rootKey)
+
+	tsaKey := mustGenerateKey(t)
+	tsa := mustCreateCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(43),
+		Subject:      pkix.Name{CommonName: "Test Timestamp Authority"},
+		NotBefore:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, root, tsaKey, rootKey)
+
+	return &testSigner{root: root, leaf: leaf, leafKey: leafKey, tsa: tsa, tsaKey: tsaKey}
+}
+
+func mustGenerateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustCreateCertificate(t *testing.T, template, parent *x509.Certificate, key, parentKey *rsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func (s *testSigner) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.root)
+	return pool
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func explicit0(data []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: data}
+}
+
+func asn1Set(data []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: data}
+}
+
+type testTimestamp int
+
+const (
+	noTimestamp testTimestamp = iota
+	counterSignature
+	rfc3161Token
+)
+
+var testSHA256 = pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256, Parameters: asn1.NullRawValue}
+
+// signAttributes builds a signerInfo for cert whose authenticated attributes
+// carry the digest of content plus extra.
+func signAttributes(t *testing.T, cert *x509.Certificate, key *rsa.PrivateKey, content, extra []byte) signerInfo {
+	t.Helper()
+
+	digest := sha256.Sum256(content)
+	attrs := append(append([]byte{}, extra...), mustMarshal(t, attribute{Type: oidMessageDigest, Values: asn1Set(mustMarshal(t, digest[:]))})...)
+
+	signedAttrs := sha256.Sum256(mustMarshal(t, asn1Set(attrs)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, signedAttrs[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signerInfo{
+		Version: 1,
+		IssuerAndSerial: issuerAndSerial{
+			Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+			SerialNumber: cert.SerialNumber,
+		},
+		DigestAlgorithm:           testSHA256,
+		AuthenticatedAttributes:   explicit0(attrs),
+		DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+		EncryptedDigest:           signature,
+	}
+}
+
+func signingTimeAttribute(t *testing.T, at time.Time) []byte {
+	return mustMarshal(t, attribute{Type: oidSigningTime, Values: asn1Set(mustMarshal(t, at))})
+}
+
+// timestamp has the signer's TSA vouch for signature at signedAt and returns
+// the unauthenticated attribute carrying it.
+func (s *testSigner) timestamp(t *testing.T, kind testTimestamp, signature []byte, signedAt time.Time) []byte {
+	t.Helper()
+
+	if kind == counterSignature {
+		counter := signAttributes(t, s.tsa, s.tsaKey, signature, signingTimeAttribute(t, signedAt))
+		return mustMarshal(t, attribute{Type: oidCounterSignature, Values: asn1Set(mustMarshal(t, counter))})
+	}
+
+	imprint := sha256.Sum256(signature)
+	tst := mustMarshal(t, tstInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: digestInfo{Algorithm: testSHA256, Digest: imprint[:]},
+		SerialNumber:   big.NewInt(7),
+		GenTime:        signedAt,
+	})
+	token := mustMarshal(t, signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{testSHA256},
+		ContentInfo:      contentInfo{ContentType: oidTSTInfo, Content: explicit0(mustMarshal(t, tst))},
+		Certificates:     explicit0(s.tsa.Raw),
+		SignerInfos:      []signerInfo{signAttributes(t, s.tsa, s.tsaKey, tst, nil)},
+	})
+	return mustMarshal(t, attribute{
+		Type:   oidRFC3161Timestamp,
+		Values: asn1Set(mustMarshal(t, contentInfo{ContentType: oidSignedData, Content: explicit0(token)})),
+	})
+}
+
+// sign produces an Authenticode-signed copy of the unsigned PE image. The
+// signature always claims signedAt as its signingTime; kind selects whether
+// a timestamp authority backs that time.
+func (s *testSigner) sign(t *testing.T, opts testPE, signedAt time.Time, kind testTimestamp) []byte {
+	t.Helper()
+
+	unsigned := buildTestPE(t, opts)
+	f, err := pe.NewFile(bytes.NewReader(unsigned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := authenticodeDigest(unsigned, f, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spc := mustMarshal(t, spcIndirectDataContent{
+		Data: asn1.RawValue{FullBytes: mustMarshal(t, struct {
+			Type asn1.ObjectIdentifier
+		}{asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}})},
+		MessageDigest: digestInfo{Algorithm: testSHA256, Digest: digest},
+	})
+
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(spc, &content); err != nil {
+		t.Fatal(err)
+	}
+
+	contentType := oidSpcIndirectData
+	if s.contentType != nil {
+		contentType = s.contentType
+	}
+	var extra []byte
+	if !s.noContentType {
+		extra = mustMarshal(t, attribute{Type: oidContentType, Values: asn1Set(mustMarshal(t, contentType))})
+	}
+	extra = append(extra, signingTimeAttribute(t, signedAt)...)
+	signer := signAttributes(t, s.leaf, s.leafKey, content.Bytes, extra)
+	if kind != noTimestamp {
+		signer.UnauthenticatedAttributes = asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1,
+			IsCompound: true,
+			Bytes:      s.timestamp(t, kind, signer.EncryptedDigest, signedAt),
+		}
+	}
+
+	certs := append(append([]byte{}, s.leaf.Raw...), s.root.Raw...)
+	if kind == counterSignature {
+		certs = append(certs, s.tsa.Raw...)
+	}
+
+	sd := mustMarshal(t, signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{testSHA256},
+		ContentInfo:      contentInfo{ContentType: oidSpcIndirectData, Content: explicit0(spc)},
+		Certificates:     explicit0(certs),
+		SignerInfos:      []signerInfo{signer},
+	})
+	pkcs7 := mustMarshal(t, contentInfo{ContentType: oidSignedData, Content: explicit0(sd)})
+
+	certificate := make([]byte, winCertificateHeaderSz, winCertificateHeaderSz+len(pkcs7)+8)
+	certificate = append(certificate, pkcs7...)
+	for len(certificate)%8 != 0 {
+		certificate = append(certificate, 0)
+	}
+	binary.LittleEndian.PutUint32(certificate, uint32(len(certificate)))
+	binary.LittleEndian.PutUint16(certificate[4:], winCertRevision2)
+	binary.LittleEndian.PutUint16(certificate[6:], winCertTypePKCSSigned)
+
+	opts.certificate = certificate
+	return buildTestPE(t, opts)
+}
+
+func TestVerifyAuthenticode(t *testing.T) {
+	// The leaf expired long ago, so only a trusted timestamp keeps it valid.
+	signer := newTestSigner(t, "NVIDIA Corporation", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	opts := testPE{
+		machine:     pe.IMAGE_FILE_MACHINE_AMD64,
+		fileVersion: [4]uint16{310, 5, 0, 0},
+		strings:     [][2]string{{"CompanyName", "NVIDIA Corporation"}},
+	}
+	signedAt := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	signed := signer.sign(t, opts, signedAt, counterSignature)
+	nvidia := dllVendors["dlss"]
+
+	for name, kind := range map[string]testTimestamp{
+		"countersignature": counterSignature,
+		"rfc3161 token":    rfc3161Token,
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := signer.sign(t, opts, signedAt, kind)
+			info, err := verifyAuthenticode(data, signer.roots(), signer.roots(), nvidia)
+			if err != nil {
+				t.Fatalf("verifyAuthenticode: %v", err)
+			}
+			if info.Vendor != "NVIDIA Corporation" {
+				t.Errorf("Vendor = %q", info.Vendor)
+			}
+			if !info.SignedAt.Equal(signedAt) {
+				t.Errorf("SignedAt = %v, want %v", info.SignedAt, signedAt)
+			}
+		})
+	}
+
+	t.Run("current certificate without timestamp", func(t *testing.T) {
+		current := newTestSigner(t, "NVIDIA Corporation", time.Now().AddDate(1, 0, 0))
+		info, err := verifyAuthenticode(current.sign(t, opts, signedAt, noTimestamp), current.roots(), current.roots(), nvidia)
+		if err != nil {
+			t.Fatalf("verifyAuthenticode: %v", err)
+		}
+		if !info.SignedAt.IsZero() {
+			t.Errorf("SignedAt = %v, want zero without a timestamp", info.SignedAt)
+		}
+	})
+
+	t.Run("expired certificate without timestamp", func(t *testing.T) {
+		unstamped := signer.sign(t, opts, signedAt, noTimestamp)
+		if _, err := verifyAuthenticode(unstamped, signer.roots(), signer.roots(), nvidia); !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("got %v, want ErrUntrustedSigner", err)
+		}
+	})
+
+	t.Run("untrusted timestamp authority", func(t *testing.T) {
+		if _, err := verifyAuthenticode(signed, signer.roots(), x509.NewCertPool(), nvidia); !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("got %v, want ErrUntrustedSigner", err)
+		}
+	})
+
+	t.Run("self-timestamped", func(t *testing.T) {
+		self := *signer
+		self.tsa, self.tsaKey = signer.leaf, signer.leafKey
+		data := self.sign(t, opts, signedAt, counterSignature)
+		if _, err := verifyAuthenticode(data, signer.roots(), signer.roots(), nvidia); !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("got %v, want ErrUntrustedSigner", err)
+		}
+	})
+
+	t.Run("future timestamp", func(t *testing.T) {
+		data := signer.sign(t, opts, time.Now().AddDate(1, 0, 0), rfc3161Token)
+		if _, err := verifyAuthenticode(data, signer.roots(), signer.roots(), nvidia); !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("got %v, want ErrUntrustedSigner", err)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := append([]byte{}, signed...)
+		tampered[testRsrcOffset+100] ^= 0xff
+		if _, err := verifyAuthenticode(tampered, signer.roots(), signer.roots(), nvidia); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("got %v, want ErrSignatureInvalid", err)
+		}
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		wrong := *signer
+		wrong.contentType = oidSignedData
+		data := wrong.sign(t, opts, signedAt, counterSignature)
+		if _, err := verifyAuthenticode(data, signer.roots(), signer.roots(), nvidia); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("got %v, want ErrSignatureInvalid", err)
+		}
+	})
+
+	t.Run("missing content type", func(t *testing.T) {
+		missing := *signer
+		missing.noContentType = true
+		data := missing.sign(t, opts, signedAt, counterSignature)
+		if _, err := verifyAuthenticode(data, signer.roots(), signer.roots(), nvidia); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("got %v, want ErrSignatureInvalid", err)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		if _, err := verifyAuthenticode(buildTestPE(t, opts), signer.roots(), signer.roots(), nvidia); !errors.Is(err, ErrNotSigned) {
+			t.Errorf("got %v, want ErrNotSigned", err)
+		}
+	})
+
+	t.Run("untrusted root", func(t *testing.T) {
+		if _, err := verifyAuthenticode(signed, x509.NewCertPool(), signer.roots(), nvidia); !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("got %v, want ErrUntrustedSigner", err)
+		}
+	})
+
+	t.Run("wrong vendor", func(t *testing.T) {
+		if _, err := verifyAuthenticode(signed, signer.roots(), signer.roots(), dllVendors["xess"]); !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("got %v, want ErrUntrustedSigner", err)
+		}
+	})
+}
+
+func TestVendorRootsParse(t *testing.T) {
+	entries, err := rootsFS.ReadDir("roots")
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("no vendor roots embedded: %v", err)
+	}
+	for _, entry := range entries {
+		data, _ := rootsFS.ReadFile("roots/" + entry.Name())
+		if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			t.Errorf("%s: no certificates parsed", entry.Name())
+		}
+	}
+}
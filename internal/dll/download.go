@@ -3,8 +3,11 @@ package dll
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -20,18 +23,67 @@ func GetDLLCachePath(name, version string) string {
 	return xdg.CachePath(filepath.Join("dlls", name, version+".dll"))
 }
 
+// GetLocalDLLCachePath returns where an imported DLL is cached. Imports are
+// keyed by checksum so they never share a file with a manifest download of
+// the same version.
+func GetLocalDLLCachePath(name, sha256 string) string {
+	return xdg.CachePath(filepath.Join("dlls", name, "local", sha256+".dll"))
+}
+
 func IsCached(name, version string) bool {
 	cachePath := GetDLLCachePath(name, version)
 	_, err := os.Stat(cachePath)
 	return err == nil
 }
 
+// FindCachedDLL returns the cached copy of version, whether it was
+// downloaded from the manifest or imported.
+func FindCachedDLL(name, version string) (string, bool) {
+	if IsCached(name, version) {
+		return GetDLLCachePath(name, version), true
+	}
+
+	local, err := LoadLocalManifest()
+	if err != nil {
+		return "", false
+	}
+	entry := local.GetDLLVersion(name, version)
+	if entry == nil {
+		return "", false
+	}
+	cachePath := GetLocalDLLCachePath(name, entry.SHA256)
+	if _, err := os.Stat(cachePath); err != nil {
+		return "", false
+	}
+	return cachePath, true
+}
+
 func DownloadDLL(dll *DLL, dllName string) (string, error) {
 	return DownloadDLLWithProgress(dll, dllName, nil)
 }
 
+// DownloadDLLWithProgress returns the cached copy of dll, downloading it
+// first if needed. Imported DLLs have no URL and can only come from the
+// cache. Cached copies are only used if they match the recorded checksum.
 func DownloadDLLWithProgress(dll *DLL, dllName string, progress ProgressCallback) (string, error) {
+	if dll.URL == "" {
+		return localDLL(dll, dllName)
+	}
+
 	cachePath := GetDLLCachePath(dllName, dll.Version)
+	if IsCached(dllName, dll.Version) {
+		if dll.SHA256 == "" {
+			return cachePath, nil
+		}
+		cachedHash, _, err := hashFile(cachePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read cached DLL: %w", err)
+		}
+		if cachedHash == dll.SHA256 {
+			return cachePath, nil
+		}
+		slog.Warn("cached DLL does not match the manifest, downloading it again", "dll", dllName, "version", dll.Version)
+	}
 
 	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
 		return "", fmt.Errorf("failed to create cache directory: %w", err)
@@ -100,6 +152,22 @@ func (pw *progressWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// localDLL returns the cached copy of an imported DLL.
+func localDLL(dll *DLL, dllName string) (string, error) {
+	cachePath := GetLocalDLLCachePath(dllName, dll.SHA256)
+	cachedHash, _, err := hashFile(cachePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%s %s is a local DLL and is no longer in the cache", dllName, dll.Version)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read cached DLL: %w", err)
+	}
+	if cachedHash != dll.SHA256 {
+		return "", fmt.Errorf("cached %s %s does not match its recorded checksum", dllName, dll.Version)
+	}
+	return cachePath, nil
+}
+
 func GetOrDownloadDLL(manifest *Manifest, dllName, version string) (string, error) {
 	var dll *DLL
 
@@ -113,10 +181,6 @@ func GetOrDownloadDLL(manifest *Manifest, dllName, version string) (string, erro
 		return "", fmt.Errorf("DLL not found: %s %s", dllName, version)
 	}
 
-	if IsCached(dllName, dll.Version) {
-		return GetDLLCachePath(dllName, dll.Version), nil
-	}
-
 	return DownloadDLL(dll, dllName)
 }
 
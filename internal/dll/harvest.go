@@ -0,0 +1,60 @@
+package dll
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jgabor/spela/internal/game"
+)
+
+const SourceHarvest = "harvest"
+
+// HarvestCandidate is the newest copy of a DLL type found in the scanned
+// library, together with the game it was found in.
+type HarvestCandidate struct {
+	Game *game.Game
+	DLL  game.DetectedDLL
+}
+
+// FindHarvestCandidates returns the highest version of each DLL type across
+// all games in db. DLLs that no longer exist on disk are skipped. Ties are
+// broken by the lowest app ID so the result is stable between runs.
+func FindHarvestCandidates(db *game.Database) []HarvestCandidate {
+	games := make([]*game.Game, 0, len(db.Games))
+	for _, g := range db.Games {
+		games = append(games, g)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].AppID < games[j].AppID })
+
+	best := make(map[game.DLLType]HarvestCandidate)
+	for _, g := range games {
+		for _, d := range g.DLLs {
+			if d.Version == "" {
+				continue
+			}
+			if current, ok := best[d.Type]; ok && CompareVersions(d.Version, current.DLL.Version) <= 0 {
+				continue
+			}
+			if _, err := os.Stat(d.Path); err != nil {
+				continue
+			}
+			best[d.Type] = HarvestCandidate{Game: g, DLL: d}
+		}
+	}
+
+	candidates := make([]HarvestCandidate, 0, len(best))
+	for _, c := range best {
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].DLL.Type < candidates[j].DLL.Type })
+
+	return candidates
+}
+
+// HarvestDLL imports a candidate into the local cache, recording the game it
+// was taken from.
+func HarvestDLL(c HarvestCandidate) (*DLL, error) {
+	notes := fmt.Sprintf("Harvested from %s (%d)", c.Game.Name, c.Game.AppID)
+	return ImportDLL(c.DLL.Path, c.DLL.Type, SourceHarvest, notes)
+}
@@ -0,0 +1,56 @@
+package dll
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jgabor/spela/internal/game"
+)
+
+func TestFindHarvestCandidates(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	db := &game.Database{Games: map[uint64]*game.Game{
+		10: {AppID: 10, Name: "Old", DLLs: []game.DetectedDLL{
+			{Path: touch("old_dlss.dll"), Type: game.DLLTypeDLSS, Version: "3.5.0.0"},
+			{Path: touch("old_xess.dll"), Type: game.DLLTypeXeSS, Version: "1.3.0.0"},
+		}},
+		20: {AppID: 20, Name: "New", DLLs: []game.DetectedDLL{
+			{Path: touch("new_dlss.dll"), Type: game.DLLTypeDLSS, Version: "3.10.0.0"},
+			{Path: touch("tie_xess.dll"), Type: game.DLLTypeXeSS, Version: "1.3.0.0"},
+		}},
+		30: {AppID: 30, Name: "Gone", DLLs: []game.DetectedDLL{
+			{Path: filepath.Join(dir, "missing.dll"), Type: game.DLLTypeDLSS, Version: "9.0.0.0"},
+			{Path: touch("unknown.dll"), Type: game.DLLTypeFSR},
+		}},
+	}}
+
+	candidates := FindHarvestCandidates(db)
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(candidates))
+	}
+
+	tests := []struct {
+		dllType game.DLLType
+		version string
+		appID   uint64
+	}{
+		{game.DLLTypeDLSS, "3.10.0.0", 20},
+		{game.DLLTypeXeSS, "1.3.0.0", 10},
+	}
+	for i, tt := range tests {
+		c := candidates[i]
+		if c.DLL.Type != tt.dllType || c.DLL.Version != tt.version || c.Game.AppID != tt.appID {
+			t.Errorf("candidate %d = %s %s from %d, want %s %s from %d",
+				i, c.DLL.Type, c.DLL.Version, c.Game.AppID, tt.dllType, tt.version, tt.appID)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package dll
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/xdg"
+)
+
+// LocalManifestFile lives inside the DLL cache so that clearing the cache
+// also forgets the DLLs that were imported into it.
+const LocalManifestFile = "local.json"
+
+const SourceImport = "import"
+
+var defaultFilenames = map[game.DLLType]string{
+	game.DLLTypeDLSS:  "nvngx_dlss.dll",
+	game.DLLTypeDLSSG: "nvngx_dlssg.dll",
+	game.DLLTypeDLSSD: "nvngx_dlssd.dll",
+	game.DLLTypeXeSS:  "libxess.dll",
+	game.DLLTypeFSR:   "amd_fidelityfx_dx12.dll",
+}
+
+func localManifestPath() string {
+	return xdg.CachePath("dlls", LocalManifestFile)
+}
+
+// LoadLocalManifest returns the overlay manifest of locally imported DLLs.
+// A missing file yields an empty manifest.
+func LoadLocalManifest() (*Manifest, error) {
+	data, err := os.ReadFile(localManifestPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Manifest{DLLs: make(map[string][]DLL)}, nil
+		}
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.DLLs == nil {
+		manifest.DLLs = make(map[string][]DLL)
+	}
+
+	return &manifest, nil
+}
+
+func SaveLocalManifest(manifest *Manifest) error {
+	path := localManifestPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	manifest.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// AddDLL records entry under dllType, replacing any entry with the same
+// version and keeping the list ordered newest first.
+func (m *Manifest) AddDLL(dllType string, entry DLL) {
+	if m.DLLs == nil {
+		m.DLLs = make(map[string][]DLL)
+	}
+
+	entries := m.DLLs[dllType][:0:0]
+	for _, existing := range m.DLLs[dllType] {
+		if existing.Version != entry.Version {
+			entries = append(entries, existing)
+		}
+	}
+	entries = append(entries, entry)
+	sortNewestFirst(entries)
+	m.DLLs[dllType] = entries
+}
+
+// Merge returns a copy of m with the entries of overlay added. Versions that
+// already exist in m take precedence over the overlay.
+func (m *Manifest) Merge(overlay *Manifest) *Manifest {
+	merged := *m
+	merged.DLLs = make(map[string][]DLL, len(m.DLLs))
+	for name, entries := range m.DLLs {
+		merged.DLLs[name] = append([]DLL(nil), entries...)
+	}
+
+	if overlay == nil {
+		return &merged
+	}
+
+	for name, entries := range overlay.DLLs {
+		for _, entry := range entries {
+			if merged.GetDLLVersion(name, entry.Version) == nil {
+				merged.DLLs[name] = append(merged.DLLs[name], entry)
+			}
+		}
+		sortNewestFirst(merged.DLLs[name])
+	}
+
+	return &merged
+}
+
+func sortNewestFirst(entries []DLL) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return CompareVersions(entries[i].Version, entries[j].Version) > 0
+	})
+}
+
+// ImportDLL copies a DLL from disk into the cache, under its checksum rather
+// than its version, and records it in the local manifest. When dllType is
+// empty it is inferred from the file name.
+func ImportDLL(path string, dllType game.DLLType, source, notes string) (*DLL, error) {
+	base := strings.ToLower(filepath.Base(path))
+	if dllType == "" {
+		known, ok := knownDLLs[base]
+		if !ok {
+			return nil, fmt.Errorf("cannot determine DLL type of %s", filepath.Base(path))
+		}
+		dllType = known
+	}
+
+	filename, ok := defaultFilenames[dllType]
+	if !ok {
+		return nil, fmt.Errorf("unknown DLL type: %s", dllType)
+	}
+	if known, ok := knownDLLs[base]; ok && known == dllType {
+		filename = base
+	}
+
+	info, err := ReadPEInfo(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if info.FileVersion == "" {
+		return nil, fmt.Errorf("no version information in %s", path)
+	}
+
+	hash, size, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := string(dllType)
+	local, err := LoadLocalManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local manifest: %w", err)
+	}
+	if existing := local.GetDLLVersion(name, info.FileVersion); existing != nil && existing.SHA256 != hash {
+		return nil, fmt.Errorf("%s %s is already cached with different contents", name, info.FileVersion)
+	}
+
+	cachePath := GetLocalDLLCachePath(name, hash)
+	if _, err := os.Stat(cachePath); err != nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		tmpPath := cachePath + ".tmp"
+		if err := copyFile(path, tmpPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return nil, fmt.Errorf("failed to copy DLL into cache: %w", err)
+		}
+		if err := os.Rename(tmpPath, cachePath); err != nil {
+			_ = os.Remove(tmpPath)
+			return nil, fmt.Errorf("failed to move DLL to cache: %w", err)
+		}
+	}
+
+	entry := DLL{
+		Version:     info.FileVersion,
+		Filename:    filename,
+		SHA256:      hash,
+		Size:        size,
+		ReleaseDate: time.Now().UTC(),
+		Notes:       notes,
+		Source:      source,
+	}
+
+	local.AddDLL(name, entry)
+	if err := SaveLocalManifest(local); err != nil {
+		return nil, fmt.Errorf("failed to save local manifest: %w", err)
+	}
+
+	return &entry, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
@@ -0,0 +1,163 @@
+package dll
+
+import (
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/game"
+)
+
+func TestImportDLL(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	local, err := LoadLocalManifest()
+	if err != nil || len(local.DLLs) != 0 {
+		t.Fatalf("LoadLocalManifest() without file = %+v, %v, want empty manifest", local, err)
+	}
+
+	opts := testPE{machine: pe.IMAGE_FILE_MACHINE_AMD64, fileVersion: [4]uint16{310, 2, 1, 0}}
+	path := writeTestPE(t, "nvngx_dlss.dll", opts)
+	entry, err := ImportDLL(path, "", SourceImport, "from a game")
+	if err != nil {
+		t.Fatalf("ImportDLL() error = %v", err)
+	}
+	if entry.Version != "310.2.1" || entry.Filename != "nvngx_dlss.dll" || entry.Source != SourceImport || entry.URL != "" {
+		t.Errorf("ImportDLL() = %+v", entry)
+	}
+	if cachePath, ok := FindCachedDLL("dlss", "310.2.1"); !ok || cachePath != GetLocalDLLCachePath("dlss", entry.SHA256) {
+		t.Errorf("FindCachedDLL() of the import = %q, %v", cachePath, ok)
+	}
+	if IsCached("dlss", "310.2.1") {
+		t.Error("the import took the path of a manifest download")
+	}
+
+	local, err = LoadLocalManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := local.GetDLLVersion("dlss", "310.2.1"); got == nil || got.SHA256 != entry.SHA256 {
+		t.Errorf("local manifest entry = %+v, want %+v", got, entry)
+	}
+
+	// Importing the same file again is fine; different contents under the
+	// same version are not.
+	if _, err := ImportDLL(path, game.DLLTypeDLSS, SourceImport, ""); err != nil {
+		t.Errorf("re-importing the same DLL: %v", err)
+	}
+	opts.timestamp = 1700000000
+	other := writeTestPE(t, "nvngx_dlss.dll", opts)
+	if _, err := ImportDLL(other, "", SourceImport, ""); err == nil || !strings.Contains(err.Error(), "different contents") {
+		t.Errorf("importing different contents: error = %v", err)
+	}
+
+	if _, err := ImportDLL(writeTestPE(t, "unknown.dll", opts), "", SourceImport, ""); err == nil {
+		t.Error("ImportDLL() of an unknown file name without type succeeded")
+	}
+}
+
+func TestDownloadLocalDLL(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	path := writeTestPE(t, "nvngx_dlss.dll", testPE{machine: pe.IMAGE_FILE_MACHINE_AMD64, fileVersion: [4]uint16{310, 3, 0, 0}})
+	entry, err := ImportDLL(path, "", SourceImport, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath, err := DownloadDLL(entry, "dlss")
+	if err != nil || cachePath != GetLocalDLLCachePath("dlss", entry.SHA256) {
+		t.Errorf("DownloadDLL() of a cached local DLL = %q, %v", cachePath, err)
+	}
+
+	if err := os.WriteFile(cachePath, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DownloadDLL(entry, "dlss"); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("DownloadDLL() of a tampered local DLL: error = %v", err)
+	}
+
+	if err := os.Remove(cachePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DownloadDLL(entry, "dlss"); err == nil || !strings.Contains(err.Error(), "no longer in the cache") {
+		t.Errorf("DownloadDLL() of an evicted local DLL: error = %v", err)
+	}
+}
+
+func TestDownloadDLLReplacesMismatchedCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	path := writeTestPE(t, "nvngx_dlss.dll", testPE{machine: pe.IMAGE_FILE_MACHINE_AMD64, fileVersion: [4]uint16{310, 4, 0, 0}})
+	imported, err := ImportDLL(path, "", SourceImport, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	official := []byte("official build")
+	sum := sha256.Sum256(official)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(official)
+	}))
+	defer server.Close()
+
+	remote := &DLL{Version: "310.4", URL: server.URL, SHA256: hex.EncodeToString(sum[:])}
+	stale := GetDLLCachePath("dlss", remote.Version)
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath, err := DownloadDLL(remote, "dlss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(cachePath); string(data) != string(official) {
+		t.Error("a cached DLL that does not match the manifest was used")
+	}
+
+	if _, err := DownloadDLL(imported, "dlss"); err != nil {
+		t.Errorf("the manifest download replaced the import of the same version: %v", err)
+	}
+}
+
+func TestManifestAddAndMerge(t *testing.T) {
+	m := &Manifest{}
+	m.AddDLL("dlss", DLL{Version: "3.7.0", URL: "a"})
+	m.AddDLL("dlss", DLL{Version: "310.1.0", URL: "b"})
+	m.AddDLL("dlss", DLL{Version: "3.7.0", URL: "c"})
+
+	var versions []string
+	for _, d := range m.DLLs["dlss"] {
+		versions = append(versions, d.Version+"="+d.URL)
+	}
+	if got := strings.Join(versions, " "); got != "310.1.0=b 3.7.0=c" {
+		t.Errorf("AddDLL() entries = %s, want newest first with the replacement", got)
+	}
+
+	overlay := &Manifest{DLLs: map[string][]DLL{
+		"dlss": {{Version: "310.1.0", Source: SourceImport}, {Version: "310.4.0", Source: SourceImport}},
+		"xess": {{Version: "1.3.0", Source: SourceImport}},
+	}}
+	merged := m.Merge(overlay)
+	if got := merged.GetLatestDLL("dlss"); got == nil || got.Version != "310.4.0" {
+		t.Errorf("merged latest dlss = %+v, want 310.4.0 from the overlay", got)
+	}
+	if got := merged.GetDLLVersion("dlss", "310.1.0"); got == nil || got.URL != "b" {
+		t.Errorf("merged dlss 310.1.0 = %+v, want the upstream entry", got)
+	}
+	if merged.GetLatestDLL("xess") == nil {
+		t.Error("merged manifest is missing overlay-only xess")
+	}
+	if len(m.DLLs["dlss"]) != 2 {
+		t.Errorf("Merge() modified the base manifest: %+v", m.DLLs["dlss"])
+	}
+}
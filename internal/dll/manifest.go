@@ -35,6 +35,7 @@ type DLL struct {
 	Size        int64     `json:"size"`
 	ReleaseDate time.Time `json:"release_date"`
 	Notes       string    `json:"notes,omitempty"`
+	Source      string    `json:"source,omitempty"`
 }
 
 func LoadManifest() (*Manifest, error) {
@@ -110,7 +111,27 @@ func UpdateManifest(manifestURL string) (*Manifest, error) {
 	return manifest, nil
 }
 
+// GetManifest returns the remote manifest merged with locally imported DLLs.
+// If the remote manifest cannot be fetched, the local entries alone are
+// returned so that imported DLLs remain usable offline.
 func GetManifest(forceUpdate bool, manifestURL string) (*Manifest, error) {
+	local, localErr := LoadLocalManifest()
+
+	remote, err := getRemoteManifest(forceUpdate, manifestURL)
+	if err != nil {
+		if localErr == nil && len(local.ListDLLNames()) > 0 {
+			return local, nil
+		}
+		return nil, err
+	}
+
+	if localErr != nil {
+		return remote, nil
+	}
+	return remote.Merge(local), nil
+}
+
+func getRemoteManifest(forceUpdate bool, manifestURL string) (*Manifest, error) {
 	if !forceUpdate {
 		manifest, err := LoadManifest()
 		if err == nil && manifest != nil {
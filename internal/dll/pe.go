@@ -0,0 +1,361 @@
+package dll
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	ArchX86   = "x86"
+	ArchX64   = "x64"
+	ArchARM64 = "arm64"
+)
+
+const (
+	resourceDirectoryEntry = 2
+	securityDirectoryEntry = 4
+
+	resourceTypeVersion = 16
+
+	fixedFileInfoSignature = 0xFEEF04BD
+)
+
+var ErrArchMismatch = errors.New("DLL architecture mismatch")
+
+// PEInfo holds metadata read from a PE file's headers and version resource.
+type PEInfo struct {
+	FileVersion    string
+	ProductVersion string
+	CompanyName    string
+	ProductName    string
+	Description    string
+	Machine        uint16
+	Arch           string
+	Timestamp      time.Time
+	// Signed reports whether the file carries an Authenticode signature.
+	// The signature itself is not verified.
+	Signed bool
+}
+
+func ReadPEInfo(path string) (*PEInfo, error) {
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	info := &PEInfo{
+		Machine:   f.Machine,
+		Arch:      machineArch(f.Machine),
+		Timestamp: time.Unix(int64(f.TimeDateStamp), 0).UTC(),
+	}
+
+	if dir, ok := dataDirectory(f, securityDirectoryEntry); ok {
+		info.Signed = dir.VirtualAddress != 0 && dir.Size != 0
+	}
+
+	data, err := versionResource(f)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		parseVersionInfo(data, info)
+	}
+
+	return info, nil
+}
+
+func machineArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return ArchX86
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return ArchX64
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return ArchARM64
+	default:
+		return fmt.Sprintf("0x%04x", machine)
+	}
+}
+
+func dataDirectory(f *pe.File, index int) (pe.DataDirectory, bool) {
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if uint32(index) < oh.NumberOfRvaAndSizes {
+			return oh.DataDirectory[index], true
+		}
+	case *pe.OptionalHeader64:
+		if uint32(index) < oh.NumberOfRvaAndSizes {
+			return oh.DataDirectory[index], true
+		}
+	}
+	return pe.DataDirectory{}, false
+}
+
+func sectionForRVA(f *pe.File, rva uint32) *pe.Section {
+	for _, s := range f.Sections {
+		size := max(s.VirtualSize, s.Size)
+		if rva >= s.VirtualAddress && rva < s.VirtualAddress+size {
+			return s
+		}
+	}
+	return nil
+}
+
+// versionResource walks the resource directory tree (type, name, language)
+// and returns the raw bytes of the first RT_VERSION resource, or nil if the
+// file has none.
+func versionResource(f *pe.File) ([]byte, error) {
+	dir, ok := dataDirectory(f, resourceDirectoryEntry)
+	if !ok || dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+
+	section := sectionForRVA(f, dir.VirtualAddress)
+	if section == nil {
+		return nil, nil
+	}
+
+	sectionData, err := section.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	base := dir.VirtualAddress - section.VirtualAddress
+	if int(base) >= len(sectionData) {
+		return nil, nil
+	}
+	rsrc := sectionData[base:]
+
+	offset, isDir, ok := findResourceEntry(rsrc, 0, resourceTypeVersion)
+	for depth := 0; ok && isDir && depth < 2; depth++ {
+		offset, isDir, ok = firstResourceEntry(rsrc, offset)
+	}
+	if !ok || isDir || int(offset)+16 > len(rsrc) {
+		return nil, nil
+	}
+
+	dataRVA := binary.LittleEndian.Uint32(rsrc[offset:])
+	dataSize := binary.LittleEndian.Uint32(rsrc[offset+4:])
+
+	dataSection := sectionForRVA(f, dataRVA)
+	if dataSection == nil {
+		return nil, nil
+	}
+	if dataSection != section {
+		if sectionData, err = dataSection.Data(); err != nil {
+			return nil, err
+		}
+	}
+
+	start := uint64(dataRVA - dataSection.VirtualAddress)
+	end := start + uint64(dataSize)
+	if end > uint64(len(sectionData)) {
+		return nil, nil
+	}
+
+	return sectionData[start:end], nil
+}
+
+// resourceEntries returns the entries of the resource directory at offset as
+// (name, offset-to-data) pairs.
+func resourceEntries(rsrc []byte, offset uint32) [][2]uint32 {
+	if int(offset)+16 > len(rsrc) {
+		return nil
+	}
+	named := binary.LittleEndian.Uint16(rsrc[offset+12:])
+	ids := binary.LittleEndian.Uint16(rsrc[offset+14:])
+	count := int(named) + int(ids)
+
+	entries := make([][2]uint32, 0, count)
+	pos := int(offset) + 16
+	for i := 0; i < count && pos+8 <= len(rsrc); i++ {
+		entries = append(entries, [2]uint32{
+			binary.LittleEndian.Uint32(rsrc[pos:]),
+			binary.LittleEndian.Uint32(rsrc[pos+4:]),
+		})
+		pos += 8
+	}
+	return entries
+}
+
+func findResourceEntry(rsrc []byte, offset, id uint32) (uint32, bool, bool) {
+	for _, entry := range resourceEntries(rsrc, offset) {
+		if entry[0] == id {
+			return entry[1] &^ 0x80000000, entry[1]&0x80000000 != 0, true
+		}
+	}
+	return 0, false, false
+}
+
+func firstResourceEntry(rsrc []byte, offset uint32) (uint32, bool, bool) {
+	entries := resourceEntries(rsrc, offset)
+	if len(entries) == 0 {
+		return 0, false, false
+	}
+	return entries[0][1] &^ 0x80000000, entries[0][1]&0x80000000 != 0, true
+}
+
+type versionBlock struct {
+	key      string
+	value    []byte
+	text     bool
+	children []byte
+}
+
+// readVersionBlock decodes one VS_VERSIONINFO-style block (wLength,
+// wValueLength, wType, szKey, Value, Children) and returns it together with
+// the aligned number of bytes it occupies.
+func readVersionBlock(data []byte) (versionBlock, int, bool) {
+	if len(data) < 6 {
+		return versionBlock{}, 0, false
+	}
+
+	length := int(binary.LittleEndian.Uint16(data))
+	valueLength := int(binary.LittleEndian.Uint16(data[2:]))
+	text := binary.LittleEndian.Uint16(data[4:]) == 1
+	if length < 6 || length > len(data) {
+		return versionBlock{}, 0, false
+	}
+	block := data[:length]
+
+	key, pos := readUTF16(block, 6)
+	pos = align4(pos)
+
+	valueSize := valueLength
+	if text {
+		valueSize *= 2
+	}
+	if pos > length {
+		pos = length
+	}
+	valueSize = min(valueSize, length-pos)
+
+	b := versionBlock{
+		key:   key,
+		value: block[pos : pos+valueSize],
+		text:  text,
+	}
+
+	pos = align4(pos + valueSize)
+	if pos < length {
+		b.children = block[pos:]
+	}
+
+	return b, min(align4(length), len(data)), true
+}
+
+func eachVersionBlock(data []byte, fn func(versionBlock)) {
+	for len(data) > 0 {
+		b, n, ok := readVersionBlock(data)
+		if !ok {
+			return
+		}
+		fn(b)
+		data = data[n:]
+	}
+}
+
+func parseVersionInfo(data []byte, info *PEInfo) {
+	root, _, ok := readVersionBlock(data)
+	if !ok || root.key != "VS_VERSION_INFO" {
+		return
+	}
+
+	if len(root.value) >= 24 && binary.LittleEndian.Uint32(root.value) == fixedFileInfoSignature {
+		info.FileVersion = fixedVersion(
+			binary.LittleEndian.Uint32(root.value[8:]),
+			binary.LittleEndian.Uint32(root.value[12:]),
+		)
+		info.ProductVersion = fixedVersion(
+			binary.LittleEndian.Uint32(root.value[16:]),
+			binary.LittleEndian.Uint32(root.value[20:]),
+		)
+	}
+
+	eachVersionBlock(root.children, func(fileInfo versionBlock) {
+		if fileInfo.key != "StringFileInfo" {
+			return
+		}
+		eachVersionBlock(fileInfo.children, func(table versionBlock) {
+			eachVersionBlock(table.children, func(s versionBlock) {
+				value, _ := readUTF16(s.value, 0)
+				switch s.key {
+				case "CompanyName":
+					setIfEmpty(&info.CompanyName, value)
+				case "ProductName":
+					setIfEmpty(&info.ProductName, value)
+				case "FileDescription":
+					setIfEmpty(&info.Description, value)
+				}
+			})
+		})
+	})
+}
+
+func fixedVersion(ms, ls uint32) string {
+	major := uint16(ms >> 16)
+	minor := uint16(ms & 0xFFFF)
+	build := uint16(ls >> 16)
+	revision := uint16(ls & 0xFFFF)
+
+	if major == 0 && minor == 0 {
+		return ""
+	}
+
+	return formatVersion(major, minor, build, revision)
+}
+
+// readUTF16 reads a NUL-terminated UTF-16LE string starting at pos and
+// returns it with the position just past the terminator.
+func readUTF16(data []byte, pos int) (string, int) {
+	var units []uint16
+	for pos+1 < len(data) {
+		u := binary.LittleEndian.Uint16(data[pos:])
+		pos += 2
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units)), pos
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func setIfEmpty(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+// CheckArchCompatible returns ErrArchMismatch if the replacement DLL at src
+// targets a different machine type than the DLL it would replace. A missing
+// target is not an error.
+func CheckArchCompatible(src, target string) error {
+	if target == "" {
+		return nil
+	}
+
+	targetInfo, err := ReadPEInfo(target)
+	if err != nil {
+		return nil
+	}
+
+	srcInfo, err := ReadPEInfo(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+
+	if srcInfo.Machine != targetInfo.Machine {
+		return fmt.Errorf("%w: replacement is %s but game uses %s", ErrArchMismatch, srcInfo.Arch, targetInfo.Arch)
+	}
+
+	return nil
+}
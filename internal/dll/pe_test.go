@@ -0,0 +1,279 @@
+package dll
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+type testPE struct {
+	machine        uint16
+	timestamp      uint32
+	fileVersion    [4]uint16
+	productVersion [4]uint16
+	strings        [][2]string
+	certificate    []byte
+}
+
+const (
+	testRsrcRVA    = 0x1000
+	testRsrcOffset = 0x200
+)
+
+// buildTestPE assembles a minimal PE image with a single .rsrc section
+// holding a VS_VERSIONINFO resource, optionally followed by a certificate
+// table.
+func buildTestPE(t *testing.T, opts testPE) []byte {
+	t.Helper()
+
+	rsrc := buildTestResources(opts)
+	rsrcSize := align(uint32(len(rsrc)), 0x200)
+
+	is64 := opts.machine != pe.IMAGE_FILE_MACHINE_I386
+	optSize := uint16(binary.Size(pe.OptionalHeader32{}))
+	if is64 {
+		optSize = uint16(binary.Size(pe.OptionalHeader64{}))
+	}
+
+	var dirs [16]pe.DataDirectory
+	dirs[resourceDirectoryEntry] = pe.DataDirectory{VirtualAddress: testRsrcRVA, Size: uint32(len(rsrc))}
+	if opts.certificate != nil {
+		dirs[securityDirectoryEntry] = pe.DataDirectory{
+			VirtualAddress: testRsrcOffset + rsrcSize,
+			Size:           uint32(len(opts.certificate)),
+		}
+	}
+
+	var buf bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	buf.Write(dos)
+	buf.WriteString("PE\x00\x00")
+
+	write := func(v any) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(pe.FileHeader{
+		Machine:              opts.machine,
+		NumberOfSections:     1,
+		TimeDateStamp:        opts.timestamp,
+		SizeOfOptionalHeader: optSize,
+		Characteristics:      0x2022,
+	})
+
+	if is64 {
+		write(pe.OptionalHeader64{
+			Magic:               0x20b,
+			SectionAlignment:    0x1000,
+			FileAlignment:       0x200,
+			SizeOfImage:         testRsrcRVA + 0x1000,
+			SizeOfHeaders:       testRsrcOffset,
+			NumberOfRvaAndSizes: 16,
+			DataDirectory:       dirs,
+		})
+	} else {
+		write(pe.OptionalHeader32{
+			Magic:               0x10b,
+			SectionAlignment:    0x1000,
+			FileAlignment:       0x200,
+			SizeOfImage:         testRsrcRVA + 0x1000,
+			SizeOfHeaders:       testRsrcOffset,
+			NumberOfRvaAndSizes: 16,
+			DataDirectory:       dirs,
+		})
+	}
+
+	write(pe.SectionHeader32{
+		Name:             [8]uint8{'.', 'r', 's', 'r', 'c'},
+		VirtualSize:      uint32(len(rsrc)),
+		VirtualAddress:   testRsrcRVA,
+		SizeOfRawData:    rsrcSize,
+		PointerToRawData: testRsrcOffset,
+		Characteristics:  0x40000040,
+	})
+
+	buf.Write(make([]byte, testRsrcOffset-buf.Len()))
+	buf.Write(rsrc)
+	buf.Write(make([]byte, int(rsrcSize)-len(rsrc)))
+	buf.Write(opts.certificate)
+
+	return buf.Bytes()
+}
+
+func buildTestResources(opts testPE) []byte {
+	const dataEntryOffset = 72
+	const versionOffset = 88
+
+	var strs [][]byte
+	for _, kv := range opts.strings {
+		value := utf16Bytes(kv[1])
+		strs = append(strs, versionBlockBytes(kv[0], value, true, len(value)/2))
+	}
+	table := versionBlockBytes("040904b0", nil, true, 0, strs...)
+	stringFileInfo := versionBlockBytes("StringFileInfo", nil, true, 0, table)
+
+	fixed := make([]byte, 52)
+	binary.LittleEndian.PutUint32(fixed[0:], fixedFileInfoSignature)
+	binary.LittleEndian.PutUint32(fixed[4:], 0x10000)
+	binary.LittleEndian.PutUint32(fixed[8:], uint32(opts.fileVersion[0])<<16|uint32(opts.fileVersion[1]))
+	binary.LittleEndian.PutUint32(fixed[12:], uint32(opts.fileVersion[2])<<16|uint32(opts.fileVersion[3]))
+	binary.LittleEndian.PutUint32(fixed[16:], uint32(opts.productVersion[0])<<16|uint32(opts.productVersion[1]))
+	binary.LittleEndian.PutUint32(fixed[20:], uint32(opts.productVersion[2])<<16|uint32(opts.productVersion[3]))
+	versionInfo := versionBlockBytes("VS_VERSION_INFO", fixed, false, len(fixed), stringFileInfo)
+
+	rsrc := make([]byte, versionOffset)
+	putDir := func(offset int, id, target uint32) {
+		binary.LittleEndian.PutUint16(rsrc[offset+14:], 1)
+		binary.LittleEndian.PutUint32(rsrc[offset+16:], id)
+		binary.LittleEndian.PutUint32(rsrc[offset+20:], target)
+	}
+	putDir(0, resourceTypeVersion, 0x80000000|24)
+	putDir(24, 1, 0x80000000|48)
+	putDir(48, 0x409, dataEntryOffset)
+	binary.LittleEndian.PutUint32(rsrc[dataEntryOffset:], testRsrcRVA+versionOffset)
+	binary.LittleEndian.PutUint32(rsrc[dataEntryOffset+4:], uint32(len(versionInfo)))
+
+	return append(rsrc, versionInfo...)
+}
+
+func versionBlockBytes(key string, value []byte, text bool, valueLength int, children ...[]byte) []byte {
+	b := make([]byte, 6)
+	binary.LittleEndian.PutUint16(b[2:], uint16(valueLength))
+	if text {
+		binary.LittleEndian.PutUint16(b[4:], 1)
+	}
+	b = append(b, utf16Bytes(key)...)
+	b = pad4(b)
+	b = append(b, value...)
+	for _, child := range children {
+		b = pad4(b)
+		b = append(b, child...)
+	}
+	binary.LittleEndian.PutUint16(b, uint16(len(b)))
+	return b
+}
+
+func utf16Bytes(s string) []byte {
+	units := append(utf16.Encode([]rune(s)), 0)
+	b := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[i*2:], u)
+	}
+	return b
+}
+
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func align(n, to uint32) uint32 {
+	return (n + to - 1) &^ (to - 1)
+}
+
+func writeTestPE(t *testing.T, name string, opts testPE) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buildTestPE(t, opts), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadPEInfo(t *testing.T) {
+	path := writeTestPE(t, "nvngx_dlss.dll", testPE{
+		machine:        pe.IMAGE_FILE_MACHINE_AMD64,
+		timestamp:      1700000000,
+		fileVersion:    [4]uint16{310, 5, 0, 0},
+		productVersion: [4]uint16{310, 5, 0, 1},
+		strings: [][2]string{
+			{"CompanyName", "NVIDIA Corporation"},
+			{"ProductName", "NVIDIA DLSS"},
+			{"FileDescription", "NVIDIA DLSS Super Resolution"},
+		},
+	})
+
+	info, err := ReadPEInfo(path)
+	if err != nil {
+		t.Fatalf("ReadPEInfo: %v", err)
+	}
+
+	if info.FileVersion != "310.5" {
+		t.Errorf("FileVersion = %q, want 310.5", info.FileVersion)
+	}
+	if info.ProductVersion != "310.5.0.1" {
+		t.Errorf("ProductVersion = %q, want 310.5.0.1", info.ProductVersion)
+	}
+	if info.CompanyName != "NVIDIA Corporation" {
+		t.Errorf("CompanyName = %q", info.CompanyName)
+	}
+	if info.ProductName != "NVIDIA DLSS" {
+		t.Errorf("ProductName = %q", info.ProductName)
+	}
+	if info.Description != "NVIDIA DLSS Super Resolution" {
+		t.Errorf("Description = %q", info.Description)
+	}
+	if info.Arch != ArchX64 {
+		t.Errorf("Arch = %q, want %q", info.Arch, ArchX64)
+	}
+	if !info.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Timestamp = %v", info.Timestamp)
+	}
+	if info.Signed {
+		t.Error("Signed = true for file without certificate table")
+	}
+
+	version, err := GetDLLVersion(path)
+	if err != nil || version != "310.5" {
+		t.Errorf("GetDLLVersion = %q, %v", version, err)
+	}
+}
+
+func TestReadPEInfoSigned(t *testing.T) {
+	path := writeTestPE(t, "signed.dll", testPE{
+		machine:     pe.IMAGE_FILE_MACHINE_I386,
+		fileVersion: [4]uint16{1, 2, 3, 4},
+		certificate: make([]byte, 16),
+	})
+
+	info, err := ReadPEInfo(path)
+	if err != nil {
+		t.Fatalf("ReadPEInfo: %v", err)
+	}
+	if info.Arch != ArchX86 {
+		t.Errorf("Arch = %q, want %q", info.Arch, ArchX86)
+	}
+	if info.FileVersion != "1.2.3.4" {
+		t.Errorf("FileVersion = %q, want 1.2.3.4", info.FileVersion)
+	}
+	if !info.Signed {
+		t.Error("Signed = false for file with certificate table")
+	}
+}
+
+func TestCheckArchCompatible(t *testing.T) {
+	x64 := writeTestPE(t, "x64.dll", testPE{machine: pe.IMAGE_FILE_MACHINE_AMD64})
+	x86 := writeTestPE(t, "x86.dll", testPE{machine: pe.IMAGE_FILE_MACHINE_I386})
+
+	if err := CheckArchCompatible(x64, x64); err != nil {
+		t.Errorf("same arch: %v", err)
+	}
+	if err := CheckArchCompatible(x64, x86); !errors.Is(err, ErrArchMismatch) {
+		t.Errorf("x64 into x86: got %v, want ErrArchMismatch", err)
+	}
+	if err := CheckArchCompatible(x64, filepath.Join(t.TempDir(), "missing.dll")); err != nil {
+		t.Errorf("missing target: %v", err)
+	}
+}
@@ -136,6 +136,29 @@ func RestoreBackup(appID uint64) error {
 	return nil
 }
 
+// RestoreBackupFile restores a single DLL from the game's backup, leaving
+// the others as they are.
+func RestoreBackupFile(appID uint64, dllName string) error {
+	backup, err := LoadBackup(appID)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return fmt.Errorf("no backup found for app %d", appID)
+	}
+
+	for _, file := range backup.Files {
+		if file.DLLName != dllName {
+			continue
+		}
+		if err := copyFile(file.BackupPath, file.OriginalPath); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file.DLLName, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not in the backup", dllName)
+}
+
 func DeleteBackup(appID uint64) error {
 	backupDir := GetBackupDir(appID)
 	return os.RemoveAll(backupDir)
@@ -154,6 +177,14 @@ func SwapDLL(appID uint64, gameName string, dlls []GameDLL, dllName, cachePath s
 		return fmt.Errorf("DLL %s not found in game", dllName)
 	}
 
+	if err := CheckArchCompatible(cachePath, targetPath); err != nil {
+		return err
+	}
+
+	if err := CheckSignaturePolicy(cachePath, dllName); err != nil {
+		return err
+	}
+
 	if !BackupExists(appID) {
 		if _, err := CreateBackup(appID, gameName, dlls); err != nil {
 			return fmt.Errorf("failed to create backup before swap: %w", err)
@@ -180,8 +211,20 @@ func InstallDLL(appID uint64, gameName, installDir string, dlls []GameDLL, dllNa
 		}
 	}
 
+	archReference := targetPath
 	if targetPath == "" {
 		targetPath = filepath.Join(installDir, dllName)
+		if len(dlls) > 0 {
+			archReference = dlls[0].Path
+		}
+	}
+
+	if err := CheckArchCompatible(cachePath, archReference); err != nil {
+		return err
+	}
+
+	if err := CheckSignaturePolicy(cachePath, dllName); err != nil {
+		return err
 	}
 
 	if !BackupExists(appID) && len(dlls) > 0 {
@@ -0,0 +1,320 @@
+// Package doctor looks at the recorded sessions of a game to explain why it
+// stopped working, and can roll its profile and DLLs back to the last
+// combination that ran cleanly.
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jgabor/spela/internal/dll"
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/session"
+)
+
+// Change kinds.
+const (
+	ChangeProfile = "profile"
+	ChangeDLL     = "dll"
+)
+
+// Change is something that differs between the last clean session and the
+// failing one.
+type Change struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+}
+
+// DLLRollback puts a DLL back to the version of the last clean session.
+type DLLRollback struct {
+	Name   string       `json:"name"`
+	Type   game.DLLType `json:"type"`
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Path   string       `json:"path"`
+	Source string       `json:"source,omitempty"`
+}
+
+// DLL rollback sources.
+const (
+	SourceBackup = "backup"
+	SourceCache  = "cache"
+)
+
+// Rollback restores the profile revision and DLL versions of a clean
+// session.
+type Rollback struct {
+	// Session is the clean session being rolled back to.
+	Session int `json:"session"`
+	// ProfileRevision is the game profile revision to revert to, or 0 to
+	// leave the profile alone.
+	ProfileRevision int           `json:"profile_revision,omitempty"`
+	DLLs            []DLLRollback `json:"dlls,omitempty"`
+	// Unavailable lists the DLLs whose clean version is neither backed up
+	// nor cached, so they cannot be rolled back.
+	Unavailable []DLLRollback `json:"unavailable,omitempty"`
+}
+
+// Empty reports whether there is nothing to roll back.
+func (r *Rollback) Empty() bool {
+	return r == nil || (r.ProfileRevision == 0 && len(r.DLLs) == 0)
+}
+
+// Report is the diagnosis of a game.
+type Report struct {
+	Game  string `json:"game"`
+	AppID uint64 `json:"app_id"`
+	// Last is the latest finished session, and LastGood the latest clean
+	// one.
+	Last     *session.Session `json:"last,omitempty"`
+	LastGood *session.Session `json:"last_good,omitempty"`
+	// Failures counts the crashes, early exits and failed starts since the
+	// last clean session.
+	Failures    int       `json:"failures"`
+	Changes     []Change  `json:"changes"`
+	Issues      []string  `json:"issues,omitempty"`
+	Suggestions []string  `json:"suggestions"`
+	Rollback    *Rollback `json:"rollback,omitempty"`
+}
+
+// Healthy reports whether the latest session gave no reason for concern.
+func (r *Report) Healthy() bool {
+	return r.Failures == 0 && len(r.Issues) == 0
+}
+
+// Diagnose compares the failing sessions of g with its last clean one.
+func Diagnose(g *game.Game) (*Report, error) {
+	sessions, err := session.List(g.AppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	r := &Report{Game: g.Name, AppID: g.AppID, Changes: []Change{}, Suggestions: []string{}}
+
+	if resolved, err := profile.Resolve(g.AppID); err == nil {
+		for _, issue := range resolved.Profile.Validate(gpu.GPUGenerationUnknown) {
+			r.Issues = append(r.Issues, fmt.Sprintf("%s: %s", issue.Key, issue.Message))
+		}
+	}
+	if len(r.Issues) > 0 {
+		r.suggest("Fix the profile issues; 'spela profile validate %s' shows where they are", g.Name)
+	}
+
+	var failing *session.Session
+	for _, s := range slices.Backward(sessions) {
+		outcome := s.Result()
+		if outcome == session.OutcomeRunning || outcome == session.OutcomeInterrupted {
+			continue
+		}
+		if r.Last == nil {
+			r.Last = s
+		}
+		if outcome == session.OutcomeClean {
+			r.LastGood = s
+			break
+		}
+		if outcome.Bad() {
+			r.Failures++
+			if failing == nil {
+				failing = s
+			}
+		}
+	}
+
+	switch {
+	case r.Last == nil:
+		r.suggest("No finished sessions are recorded yet; launch the game with spela first")
+		return r, nil
+	case r.Failures == 0:
+		return r, nil
+	}
+
+	if r.LastGood == nil {
+		r.suggest("No clean session is recorded, so there is nothing to roll back to")
+		r.suggest("Try the original DLLs with 'spela dll restore %s', or remove settings from the profile one at a time", g.Name)
+		r.suggestLogs(failing)
+		return r, nil
+	}
+
+	r.Changes = changes(g.AppID, r.LastGood, failing)
+	rollback, err := rollbackTo(g, r.LastGood)
+	if err != nil {
+		return nil, err
+	}
+	if !rollback.Empty() {
+		r.Rollback = rollback
+	}
+
+	switch {
+	case len(r.Changes) == 0:
+		r.suggest("Nothing spela controls changed since session %d, which ran cleanly; the game or Proton may have been updated", r.LastGood.Number)
+		r.suggestLogs(failing)
+	case r.Rollback != nil:
+		r.suggest("Roll back to the profile and DLLs of session %d with 'spela doctor %s --rollback'", r.LastGood.Number, g.Name)
+	}
+	for _, d := range rollback.Unavailable {
+		r.suggest("%s %s is neither backed up nor cached; import it with 'spela dll import' to roll it back", d.Name, d.To)
+	}
+	if slices.ContainsFunc(r.Changes, func(c Change) bool { return c.Kind == ChangeProfile }) && rollback.ProfileRevision == 0 {
+		r.suggest("The profile change came from the default or a base profile; check 'spela profile show %s --resolved'", g.Name)
+	}
+	return r, nil
+}
+
+func (r *Report) suggest(format string, args ...any) {
+	r.Suggestions = append(r.Suggestions, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) suggestLogs(s *session.Session) {
+	if logs, err := s.Logs(); err == nil && len(logs) > 0 {
+		r.suggest("Check the logs of session %d with 'spela logs %s --session %d'", s.Number, r.Game, s.Number)
+		return
+	}
+	r.suggest("Set logging.capture and logging.debug in the profile to keep the game's output and Proton logs next time")
+}
+
+// changes lists what differs between a clean session and a failing one.
+func changes(appID uint64, good, bad *session.Session) []Change {
+	var changes []Change
+
+	if good.Profile.Hash != bad.Profile.Hash {
+		description := "Profile settings changed"
+		from, to := good.Profile.Revision, bad.Profile.Revision
+		if from != 0 && to != 0 && from != to {
+			description = fmt.Sprintf("Game profile changed from revision %d to %d", from, to)
+			if keys := changedKeys(appID, from, to); len(keys) > 0 {
+				description += ": " + strings.Join(keys, ", ")
+			}
+		}
+		changes = append(changes, Change{Kind: ChangeProfile, Description: description})
+	}
+
+	for _, d := range bad.DLLs {
+		i := slices.IndexFunc(good.DLLs, func(g session.DLL) bool { return g.Name == d.Name })
+		switch {
+		case i < 0:
+			changes = append(changes, Change{Kind: ChangeDLL, Description: fmt.Sprintf("%s %s was added", d.Name, d.Version)})
+		case good.DLLs[i].Version != d.Version:
+			changes = append(changes, Change{Kind: ChangeDLL, Description: fmt.Sprintf("%s changed from %s to %s", d.Name, versionOrUnknown(good.DLLs[i].Version), versionOrUnknown(d.Version))})
+		}
+	}
+	return changes
+}
+
+func changedKeys(appID uint64, from, to int) []string {
+	old, err := profile.LoadRevision(appID, from)
+	if err != nil {
+		return nil
+	}
+	current, err := profile.LoadRevision(appID, to)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, c := range profile.Diff(old, current) {
+		keys = append(keys, c.Key)
+	}
+	return keys
+}
+
+// rollbackTo works out how to get from the game's current profile and DLLs
+// back to those of a clean session.
+func rollbackTo(g *game.Game, good *session.Session) (*Rollback, error) {
+	rollback := &Rollback{Session: good.Number}
+
+	if good.Profile.Revision != 0 {
+		current, err := profile.CurrentRevision(g.AppID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read profile history: %w", err)
+		}
+		if current != good.Profile.Revision {
+			rollback.ProfileRevision = good.Profile.Revision
+		}
+	}
+
+	backup, err := dll.LoadBackup(g.AppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DLL backup: %w", err)
+	}
+	for _, d := range session.DLLs(g) {
+		i := slices.IndexFunc(good.DLLs, func(gd session.DLL) bool { return gd.Name == d.Name })
+		if i < 0 || good.DLLs[i].Version == "" || good.DLLs[i].Version == d.Version {
+			continue
+		}
+
+		target := good.DLLs[i].Version
+		rb := DLLRollback{Name: d.Name, Type: d.Type, From: d.Version, To: target, Path: d.Path}
+		switch {
+		case backup != nil && slices.ContainsFunc(backup.Files, func(f dll.BackedUpFile) bool { return f.DLLName == d.Name && f.Version == target }):
+			rb.Source = SourceBackup
+		case hasCachedDLL(string(d.Type), target):
+			rb.Source = SourceCache
+		default:
+			rollback.Unavailable = append(rollback.Unavailable, rb)
+			continue
+		}
+		rollback.DLLs = append(rollback.DLLs, rb)
+	}
+	return rollback, nil
+}
+
+func hasCachedDLL(name, version string) bool {
+	_, ok := dll.FindCachedDLL(name, version)
+	return ok
+}
+
+// RollBack applies a rollback to g.
+func RollBack(g *game.Game, rollback *Rollback) error {
+	var errs []error
+	if rollback.ProfileRevision != 0 {
+		if _, err := profile.Revert(g.AppID, rollback.ProfileRevision); err != nil {
+			errs = append(errs, fmt.Errorf("failed to revert profile: %w", err))
+		}
+	}
+
+	var gameDLLs []dll.GameDLL
+	for _, d := range g.DLLs {
+		gameDLLs = append(gameDLLs, dll.GameDLL{Name: d.Name, Path: d.Path, Version: d.Version})
+	}
+	for _, d := range rollback.DLLs {
+		var err error
+		switch d.Source {
+		case SourceBackup:
+			err = dll.RestoreBackupFile(g.AppID, d.Name)
+		case SourceCache:
+			cachePath, ok := dll.FindCachedDLL(string(d.Type), d.To)
+			if !ok {
+				err = fmt.Errorf("%s %s is no longer in the cache", d.Type, d.To)
+				break
+			}
+			err = dll.SwapDLL(g.AppID, g.Name, gameDLLs, d.Name, cachePath)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back %s: %w", d.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Describe summarises a rollback in one line.
+func (r *Rollback) Describe() string {
+	var parts []string
+	if r.ProfileRevision != 0 {
+		parts = append(parts, fmt.Sprintf("profile revision %d", r.ProfileRevision))
+	}
+	for _, d := range r.DLLs {
+		parts = append(parts, fmt.Sprintf("%s %s from the %s", d.Name, d.To, d.Source))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func versionOrUnknown(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
@@ -0,0 +1,87 @@
+package doctor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/session"
+)
+
+// recordSession writes a finished session of g that ran for d with the
+// given profile revision.
+func recordSession(t *testing.T, g *game.Game, revision, code int, d time.Duration) {
+	t.Helper()
+	s := session.New(g, []string{"game.exe"})
+	if err := s.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	s.Start = s.Start.Add(-d)
+	s.Profile = session.ProfileSnapshot{Hash: fmt.Sprintf("rev%d", revision), Revision: revision}
+	if err := s.Finish(code, "", false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiagnoseAndRollBack(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	g := &game.Game{AppID: 10, Name: "Foo"}
+
+	if err := profile.Save(10, &profile.Profile{DLSS: profile.DLSSSettings{SRMode: profile.DLSSModeQuality}}); err != nil {
+		t.Fatal(err)
+	}
+	recordSession(t, g, 1, 0, time.Hour)
+
+	if err := profile.Save(10, &profile.Profile{DLSS: profile.DLSSSettings{SRMode: profile.DLSSModePerformance}}); err != nil {
+		t.Fatal(err)
+	}
+	recordSession(t, g, 2, 1, time.Minute)
+	recordSession(t, g, 2, 0, time.Second)
+
+	report, err := Diagnose(g)
+	if err != nil {
+		t.Fatalf("Diagnose() error = %v", err)
+	}
+	if report.Failures != 2 || report.Last.Number != 3 || report.LastGood.Number != 1 {
+		t.Fatalf("report = %d failures, last %d, last good %d; want 2, 3, 1", report.Failures, report.Last.Number, report.LastGood.Number)
+	}
+	if len(report.Changes) != 1 || !strings.Contains(report.Changes[0].Description, "from revision 1 to 2: dlss.sr_mode") {
+		t.Errorf("Changes = %+v", report.Changes)
+	}
+	if report.Rollback.Empty() || report.Rollback.ProfileRevision != 1 {
+		t.Fatalf("Rollback = %+v, want profile revision 1", report.Rollback)
+	}
+
+	if err := RollBack(g, report.Rollback); err != nil {
+		t.Fatalf("RollBack() error = %v", err)
+	}
+	p, err := profile.Load(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.DLSS.SRMode != profile.DLSSModeQuality {
+		t.Errorf("sr_mode after rollback = %s, want quality", p.DLSS.SRMode)
+	}
+}
+
+func TestDiagnoseHealthy(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	g := &game.Game{AppID: 11, Name: "Bar"}
+	recordSession(t, g, 0, 1, time.Minute)
+	recordSession(t, g, 0, 0, time.Hour)
+
+	report, err := Diagnose(g)
+	if err != nil {
+		t.Fatalf("Diagnose() error = %v", err)
+	}
+	if !report.Healthy() || report.Rollback != nil {
+		t.Errorf("report = %+v, want healthy with nothing to roll back", report)
+	}
+}
@@ -1,52 +1,157 @@
 package env
 
 import (
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
 )
 
+// Environment is the environment of a process spela launches. It is
+// computed from an inherited base without touching the environment of the
+// spela process itself.
 type Environment struct {
-	vars map[string]string
+	base    []string
+	vars    map[string]string
+	order   []string
+	unset   map[string]bool
+	origins map[string]string
+	origin  string
 }
 
+// New returns an environment that inherits the variables of the spela
+// process.
 func New() *Environment {
+	return NewFrom(os.Environ())
+}
+
+// NewFrom returns an environment that inherits base, a list of KEY=value
+// entries.
+func NewFrom(base []string) *Environment {
 	return &Environment{
-		vars: make(map[string]string),
+		base:    slices.Clone(base),
+		vars:    make(map[string]string),
+		unset:   make(map[string]bool),
+		origins: make(map[string]string),
 	}
 }
 
+// Set sets key, overriding an inherited value. An empty value is set as
+// empty; use Unset to remove a variable.
 func (e *Environment) Set(key, value string) {
+	if _, ok := e.vars[key]; !ok {
+		e.order = append(e.order, key)
+	}
 	e.vars[key] = value
+	delete(e.unset, key)
+	e.origins[key] = e.origin
 }
 
-func (e *Environment) SetIf(key, value string, condition bool) {
-	if condition {
-		e.vars[key] = value
+// Unset removes key from the environment, including an inherited value.
+func (e *Environment) Unset(key string) {
+	if _, ok := e.vars[key]; ok {
+		e.order = slices.DeleteFunc(e.order, func(k string) bool { return k == key })
+		delete(e.vars, key)
 	}
+	e.unset[key] = true
+	e.origins[key] = e.origin
 }
 
-func (e *Environment) Get(key string) string {
-	return e.vars[key]
+// Lookup returns the value key will have: the value set here, or else the
+// inherited one.
+func (e *Environment) Lookup(key string) (string, bool) {
+	if e.unset[key] {
+		return "", false
+	}
+	if value, ok := e.vars[key]; ok {
+		return value, true
+	}
+	return e.Inherited(key)
 }
 
-func (e *Environment) All() map[string]string {
-	result := make(map[string]string, len(e.vars))
-	for k, v := range e.vars {
-		result[k] = v
+// Inherited returns the inherited value of key. If the base lists key more
+// than once the first value wins, as it does for getenv.
+func (e *Environment) Inherited(key string) (string, bool) {
+	for _, entry := range e.base {
+		if k, value, ok := strings.Cut(entry, "="); ok && k == key {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// InheritedAll returns every inherited variable.
+func (e *Environment) InheritedAll() map[string]string {
+	result := make(map[string]string, len(e.base))
+	for _, entry := range e.base {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			if _, seen := result[key]; !seen {
+				result[key] = value
+			}
+		}
 	}
 	return result
 }
 
-func (e *Environment) Apply() {
-	for k, v := range e.vars {
-		_ = os.Setenv(k, v)
+// Unsets lists the variables removed with Unset, sorted.
+func (e *Environment) Unsets() []string {
+	return slices.Sorted(maps.Keys(e.unset))
+}
+
+func (e *Environment) SetIf(key, value string, condition bool) {
+	if condition {
+		e.Set(key, value)
 	}
 }
 
+// Annotate records origin for every variable set while fn runs, so that
+// callers can later explain where a variable came from.
+func (e *Environment) Annotate(origin string, fn func()) {
+	previous := e.origin
+	e.origin = origin
+	defer func() { e.origin = previous }()
+	fn()
+}
+
+// Origin returns the origin recorded by Annotate when key was last set.
+func (e *Environment) Origin(key string) string {
+	return e.origins[key]
+}
+
+func (e *Environment) Get(key string) string {
+	return e.vars[key]
+}
+
+func (e *Environment) All() map[string]string {
+	return maps.Clone(e.vars)
+}
+
+// BuildEnv returns the environment for a child process. Inherited variables
+// keep their order, with the ones set here replacing their value in place
+// and the ones unset left out; new variables follow in the order they were
+// first set. Every key appears once.
 func (e *Environment) BuildEnv() []string {
-	env := os.Environ()
-	for k, v := range e.vars {
-		env = append(env, k+"="+v)
+	env := make([]string, 0, len(e.base)+len(e.vars))
+	seen := make(map[string]bool, len(e.base))
+	for _, entry := range e.base {
+		key, _, ok := strings.Cut(entry, "=")
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if e.unset[key] {
+			continue
+		}
+		if value, ok := e.vars[key]; ok {
+			entry = key + "=" + value
+		}
+		env = append(env, entry)
+	}
+	for _, key := range e.order {
+		if !seen[key] {
+			env = append(env, key+"="+e.vars[key])
+		}
 	}
 	return env
 }
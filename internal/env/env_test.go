@@ -0,0 +1,76 @@
+package env_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jgabor/spela/internal/env"
+)
+
+func TestBuildEnv(t *testing.T) {
+	e := env.NewFrom([]string{"HOME=/home/me", "EMPTY=", "DUP=first", "PATH=/bin", "DUP=second", "GONE=1"})
+	e.Set("NEW_B", "b")
+	e.Set("PATH", "/opt/bin:/bin")
+	e.Set("NEW_A", "a")
+	e.Set("NEW_B", "b2")
+	e.Unset("GONE")
+	e.Unset("NEVER_SET")
+
+	want := []string{"HOME=/home/me", "EMPTY=", "DUP=first", "PATH=/opt/bin:/bin", "NEW_B=b2", "NEW_A=a"}
+	if got := e.BuildEnv(); !slices.Equal(got, want) {
+		t.Errorf("BuildEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestEmptyIsNotUnset(t *testing.T) {
+	e := env.NewFrom([]string{"EMPTY=", "FULL=1"})
+
+	if value, ok := e.Lookup("EMPTY"); !ok || value != "" {
+		t.Errorf("Lookup(EMPTY) = %q, %v, want an empty inherited value", value, ok)
+	}
+	if _, ok := e.Lookup("MISSING"); ok {
+		t.Error("Lookup(MISSING) reported a value")
+	}
+
+	e.Set("FULL", "")
+	if value, ok := e.Lookup("FULL"); !ok || value != "" {
+		t.Errorf("Lookup(FULL) = %q, %v after setting it empty", value, ok)
+	}
+	if got, want := e.BuildEnv(), []string{"EMPTY=", "FULL="}; !slices.Equal(got, want) {
+		t.Errorf("BuildEnv() = %q, want %q", got, want)
+	}
+
+	e.Unset("EMPTY")
+	if _, ok := e.Lookup("EMPTY"); ok {
+		t.Error("Lookup(EMPTY) reported a value after Unset")
+	}
+	if got, want := e.BuildEnv(), []string{"FULL="}; !slices.Equal(got, want) {
+		t.Errorf("BuildEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestSetAfterUnset(t *testing.T) {
+	e := env.NewFrom([]string{"A=1"})
+	e.Set("B", "1")
+	e.Unset("A")
+	e.Unset("B")
+	e.Set("B", "2")
+	e.Set("A", "2")
+
+	if got, want := e.BuildEnv(), []string{"A=2", "B=2"}; !slices.Equal(got, want) {
+		t.Errorf("BuildEnv() = %q, want %q", got, want)
+	}
+	if len(e.Unsets()) != 0 {
+		t.Errorf("Unsets() = %v, want none", e.Unsets())
+	}
+}
+
+func TestBaseIsNotModified(t *testing.T) {
+	base := []string{"A=1"}
+	e := env.NewFrom(base)
+	e.Set("A", "2")
+	_ = e.BuildEnv()
+	if base[0] != "A=1" {
+		t.Errorf("base = %q, want it untouched", base)
+	}
+}
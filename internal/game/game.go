@@ -25,10 +25,15 @@ type Game struct {
 }
 
 type DetectedDLL struct {
-	Path    string  `yaml:"path"`
-	Name    string  `yaml:"name"`
-	Type    DLLType `yaml:"type"`
-	Version string  `yaml:"version,omitempty"`
+	Path           string    `yaml:"path"`
+	Name           string    `yaml:"name"`
+	Type           DLLType   `yaml:"type"`
+	Version        string    `yaml:"version,omitempty"`
+	ProductVersion string    `yaml:"product_version,omitempty"`
+	Company        string    `yaml:"company,omitempty"`
+	Arch           string    `yaml:"arch,omitempty"`
+	Timestamp      time.Time `yaml:"timestamp,omitempty"`
+	Signed         bool      `yaml:"signed,omitempty"`
 }
 
 func (g *Game) HasDLSS() bool {
@@ -0,0 +1,188 @@
+// Package gamemode runs games under Feral GameMode, either through
+// gamemoderun or by registering them with the daemon over D-Bus, and reads
+// the GameMode configuration to tell which system settings it will change.
+package gamemode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName       = "com.feralinteractive.GameMode"
+	objectPath    = "/com/feralinteractive/GameMode"
+	interfaceName = "com.feralinteractive.GameMode"
+)
+
+// Wrapper is the command that runs a game under GameMode.
+const Wrapper = "gamemoderun"
+
+// DefaultGovernor is the governor GameMode switches to when its
+// configuration does not set desiredgov.
+const DefaultGovernor = "performance"
+
+// IsInstalled reports whether gamemoderun is on the PATH.
+func IsInstalled() bool {
+	_, err := exec.LookPath(Wrapper)
+	return err == nil
+}
+
+// DaemonAvailable reports whether the GameMode daemon is running on the
+// session bus, or can be started by it.
+func DaemonAvailable() bool {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false
+	}
+	defer func() { _ = conn.Close() }()
+
+	var running bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, busName).Store(&running); err == nil && running {
+		return true
+	}
+	var activatable []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&activatable); err != nil {
+		return false
+	}
+	return slices.Contains(activatable, busName)
+}
+
+// Register asks the daemon to turn GameMode on for the process pid. GameMode
+// stays on until every registered process has exited or is unregistered.
+// The game is registered on behalf of this process, as it is not the caller.
+func Register(pid int) error {
+	return call("RegisterGameByPID", pid)
+}
+
+// Unregister tells the daemon that the process pid is done.
+func Unregister(pid int) error {
+	return call("UnregisterGameByPID", pid)
+}
+
+func call(method string, pid int) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var status int32
+	obj := conn.Object(busName, objectPath)
+	if err := obj.Call(interfaceName+"."+method, 0, int32(os.Getpid()), int32(pid)).Store(&status); err != nil {
+		return fmt.Errorf("GameMode %s failed: %w", method, err)
+	}
+	switch status {
+	case 0:
+		return nil
+	case -2:
+		return fmt.Errorf("GameMode rejected process %d", pid)
+	}
+	return fmt.Errorf("GameMode %s failed for process %d", method, pid)
+}
+
+// Config is the part of the GameMode configuration that overlaps with
+// settings spela changes itself.
+type Config struct {
+	// Files lists the configuration files that were read, in order.
+	Files []string
+	// DesiredGov is the governor GameMode sets while a game runs.
+	DesiredGov string
+	// ParkCores is GameMode's setting for taking CPU cores offline while a
+	// game runs: no, yes, or a list of cores.
+	ParkCores string
+}
+
+// ConfigPaths returns the GameMode configuration files in the order the
+// daemon reads them; later files override earlier ones.
+func ConfigPaths() []string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return []string{
+		"/usr/share/gamemode/gamemode.ini",
+		"/etc/gamemode.ini",
+		filepath.Join(configHome, "gamemode.ini"),
+	}
+}
+
+// LoadConfig reads the GameMode configuration files that exist.
+func LoadConfig() (*Config, error) {
+	return loadConfig(ConfigPaths())
+}
+
+func loadConfig(paths []string) (*Config, error) {
+	c := &Config{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read GameMode config: %w", err)
+		}
+		err = c.parse(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GameMode config %s: %w", path, err)
+		}
+		c.Files = append(c.Files, path)
+	}
+	return c, nil
+}
+
+func (c *Config) parse(r io.Reader) error {
+	var section string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch section + "." + key {
+		case "general.desiredgov":
+			c.DesiredGov = value
+		case "cpu.park_cores":
+			c.ParkCores = value
+		}
+	}
+	return scanner.Err()
+}
+
+// Governor returns the governor GameMode sets while a game runs.
+func (c *Config) Governor() string {
+	if c.DesiredGov == "" {
+		return DefaultGovernor
+	}
+	return c.DesiredGov
+}
+
+// ParksCores reports whether GameMode takes CPU cores offline while a game
+// runs.
+func (c *Config) ParksCores() bool {
+	switch strings.ToLower(c.ParkCores) {
+	case "", "no", "false", "off", "0":
+		return false
+	}
+	return true
+}
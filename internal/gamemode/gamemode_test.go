@@ -0,0 +1,58 @@
+package gamemode
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	system := filepath.Join(dir, "etc.ini")
+	user := filepath.Join(dir, "user.ini")
+	if err := os.WriteFile(system, []byte(`
+[general]
+; desiredgov=schedutil
+desiredgov=powersave
+renice=10
+
+[cpu]
+park_cores=yes
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(user, []byte(`
+[general]
+desiredgov = ondemand
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig([]string{system, filepath.Join(dir, "missing.ini"), user})
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if !slices.Equal(cfg.Files, []string{system, user}) {
+		t.Errorf("Files = %q", cfg.Files)
+	}
+	if got := cfg.Governor(); got != "ondemand" {
+		t.Errorf("Governor() = %q, want ondemand from the user config", got)
+	}
+	if !cfg.ParksCores() {
+		t.Error("ParksCores() = false, want true")
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Governor(); got != DefaultGovernor {
+		t.Errorf("Governor() = %q, want %q", got, DefaultGovernor)
+	}
+	if cfg.ParksCores() {
+		t.Error("ParksCores() = true, want false")
+	}
+}
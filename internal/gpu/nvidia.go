@@ -174,6 +174,23 @@ func (g GPUGeneration) SupportsFP8() bool {
 	return g >= GPUGenerationAdaLovelace
 }
 
+func (g GPUGeneration) SupportsFrameGeneration() bool {
+	return g >= GPUGenerationAdaLovelace
+}
+
+// MaxMultiFrame is the number of frames DLSS-FG can generate per rendered
+// frame, or 0 if frame generation is unsupported.
+func (g GPUGeneration) MaxMultiFrame() int {
+	switch {
+	case g >= GPUGenerationBlackwell:
+		return 3
+	case g.SupportsFrameGeneration():
+		return 1
+	default:
+		return 0
+	}
+}
+
 func (g GPUGeneration) String() string {
 	switch g {
 	case GPUGenerationTuring:
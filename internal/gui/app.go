@@ -17,6 +17,7 @@ import (
 	"github.com/jgabor/spela/internal/env"
 	"github.com/jgabor/spela/internal/game"
 	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/launcher"
 	"github.com/jgabor/spela/internal/profile"
 )
 
@@ -45,6 +46,7 @@ type ConfigInfo struct {
 	AutoRefreshManifest    bool     `json:"autoRefreshManifest"`
 	ManifestRefreshHours   int      `json:"manifestRefreshHours"`
 	PreferredDLLSource     string   `json:"preferredDLLSource"`
+	DLLSignaturePolicy     string   `json:"dllSignaturePolicy"`
 	Theme                  string   `json:"theme"`
 	CompactMode            bool     `json:"compactMode"`
 	ConfirmDestructive     bool     `json:"confirmDestructive"`
@@ -85,6 +87,7 @@ func configInfoFromConfig(cfg *config.Config) ConfigInfo {
 		AutoRefreshManifest:    cfg.AutoRefreshManifest,
 		ManifestRefreshHours:   cfg.ManifestRefreshHours,
 		PreferredDLLSource:     cfg.PreferredDLLSource,
+		DLLSignaturePolicy:     cfg.DLLSignaturePolicy,
 		Theme:                  cfg.Theme,
 		CompactMode:            cfg.CompactMode,
 		ConfirmDestructive:     cfg.ConfirmDestructive,
@@ -100,6 +103,10 @@ func applyConfigInfo(cfg *config.Config, info ConfigInfo) error {
 	if err != nil {
 		return err
 	}
+	signaturePolicy, err := parseSignaturePolicy(info.DLLSignaturePolicy)
+	if err != nil {
+		return err
+	}
 	theme, err := parseTheme(info.Theme)
 	if err != nil {
 		return err
@@ -118,6 +125,7 @@ func applyConfigInfo(cfg *config.Config, info ConfigInfo) error {
 	cfg.AutoRefreshManifest = info.AutoRefreshManifest
 	cfg.ManifestRefreshHours = info.ManifestRefreshHours
 	cfg.PreferredDLLSource = preferredDLLSource
+	cfg.DLLSignaturePolicy = signaturePolicy
 	cfg.Theme = theme
 	cfg.CompactMode = info.CompactMode
 	cfg.ConfirmDestructive = info.ConfirmDestructive
@@ -148,6 +156,15 @@ func parsePreferredDLLSource(source string) (string, error) {
 	}
 }
 
+func parseSignaturePolicy(policy string) (string, error) {
+	switch dll.SignaturePolicy(policy) {
+	case dll.SignaturePolicyEnforce, dll.SignaturePolicyWarn, dll.SignaturePolicyOff:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported signature policy: %s", policy)
+	}
+}
+
 func parseTheme(theme string) (string, error) {
 	switch theme {
 	case "default", "dark":
@@ -268,8 +285,10 @@ func (a *App) GetGame(appID uint64) *GameInfo {
 }
 
 type ProfileInfo struct {
+	Preset               string `json:"preset"`
 	SRMode               string `json:"srMode"`
 	SRPreset             string `json:"srPreset"`
+	SRModelPreset        string `json:"srModelPreset"`
 	SROverride           bool   `json:"srOverride"`
 	FGEnabled            bool   `json:"fgEnabled"`
 	FGOverride           bool   `json:"fgOverride"`
@@ -283,6 +302,30 @@ type ProfileInfo struct {
 	EnableNGXUpdater     bool   `json:"enableNgxUpdater"`
 	BackupOnLaunch       bool   `json:"backupOnLaunch"`
 	InheritedFromDefault bool   `json:"inheritedFromDefault"`
+	// Inherited maps the fields whose values come from the default profile
+	// or a base profile to that layer. They are shown for context; saving
+	// only writes the fields that were changed.
+	Inherited map[string]string `json:"inherited"`
+}
+
+// profileInfoKeys maps ProfileInfo fields to the profile settings they show.
+var profileInfoKeys = map[string]string{
+	"preset":               "preset",
+	"srMode":               "dlss.sr_mode",
+	"srPreset":             "dlss.sr_preset",
+	"srModelPreset":        "dlss.sr_model_preset",
+	"srOverride":           "dlss.sr_override",
+	"fgEnabled":            "dlss.fg_enabled",
+	"fgOverride":           "dlss.fg_override",
+	"multiFrame":           "dlss.multi_frame",
+	"indicator":            "dlss.indicator",
+	"shaderCache":          "gpu.shader_cache",
+	"threadedOptimization": "gpu.threaded_optimization",
+	"powerMizer":           "gpu.power_mizer",
+	"enableHdr":            "proton.enable_hdr",
+	"enableWayland":        "proton.enable_wayland",
+	"enableNgxUpdater":     "proton.enable_ngx_updater",
+	"backupOnLaunch":       "ludusavi.backup_on_launch",
 }
 
 func profileInfoFromProfile(p *profile.Profile, inheritedFromDefault bool) *ProfileInfo {
@@ -291,84 +334,172 @@ func profileInfoFromProfile(p *profile.Profile, inheritedFromDefault bool) *Prof
 	}
 
 	return &ProfileInfo{
+		Preset:               p.Preset,
 		SRMode:               string(p.DLSS.SRMode),
 		SRPreset:             string(p.DLSS.SRPreset),
-		SROverride:           p.DLSS.SROverride,
-		FGEnabled:            p.DLSS.FGEnabled,
-		FGOverride:           p.DLSS.FGOverride,
-		MultiFrame:           p.DLSS.MultiFrame,
-		Indicator:            p.DLSS.Indicator,
-		ShaderCache:          p.GPU.ShaderCache,
-		ThreadedOptimization: p.GPU.ThreadedOptimization,
+		SRModelPreset:        string(p.DLSS.SRModelPreset),
+		SROverride:           profile.IsTrue(p.DLSS.SROverride),
+		FGEnabled:            profile.IsTrue(p.DLSS.FGEnabled),
+		FGOverride:           profile.IsTrue(p.DLSS.FGOverride),
+		MultiFrame:           profile.IntValue(p.DLSS.MultiFrame),
+		Indicator:            profile.IsTrue(p.DLSS.Indicator),
+		ShaderCache:          profile.IsTrue(p.GPU.ShaderCache),
+		ThreadedOptimization: profile.IsTrue(p.GPU.ThreadedOptimization),
 		PowerMizer:           p.GPU.PowerMizer,
-		EnableHDR:            p.Proton.EnableHDR,
-		EnableWayland:        p.Proton.EnableWayland,
-		EnableNGXUpdater:     p.Proton.EnableNGXUpdater,
-		BackupOnLaunch:       p.Ludusavi.BackupOnLaunch,
+		EnableHDR:            profile.IsTrue(p.Proton.EnableHDR),
+		EnableWayland:        profile.IsTrue(p.Proton.EnableWayland),
+		EnableNGXUpdater:     profile.IsTrue(p.Proton.EnableNGXUpdater),
+		BackupOnLaunch:       profile.IsTrue(p.Ludusavi.BackupOnLaunch),
 		InheritedFromDefault: inheritedFromDefault,
 	}
 }
 
 func profileFromInfo(info ProfileInfo) *profile.Profile {
 	return &profile.Profile{
+		Preset: info.Preset,
 		DLSS: profile.DLSSSettings{
-			SRMode:     profile.DLSSMode(info.SRMode),
-			SRPreset:   profile.DLSSPreset(info.SRPreset),
-			SROverride: info.SROverride,
-			FGEnabled:  info.FGEnabled,
-			FGOverride: info.FGOverride,
-			MultiFrame: info.MultiFrame,
-			Indicator:  info.Indicator,
+			SRMode:        profile.DLSSMode(info.SRMode),
+			SRPreset:      profile.DLSSPreset(info.SRPreset),
+			SRModelPreset: profile.DLSSModelPreset(info.SRModelPreset),
+			SROverride:    profile.Bool(info.SROverride),
+			FGEnabled:     profile.Bool(info.FGEnabled),
+			FGOverride:    profile.Bool(info.FGOverride),
+			MultiFrame:    profile.Int(info.MultiFrame),
+			Indicator:     profile.Bool(info.Indicator),
 		},
 		GPU: profile.GPUSettings{
-			ShaderCache:          info.ShaderCache,
-			ThreadedOptimization: info.ThreadedOptimization,
+			ShaderCache:          profile.Bool(info.ShaderCache),
+			ThreadedOptimization: profile.Bool(info.ThreadedOptimization),
 			PowerMizer:           info.PowerMizer,
 		},
 		Proton: profile.ProtonSettings{
-			EnableHDR:        info.EnableHDR,
-			EnableWayland:    info.EnableWayland,
-			EnableNGXUpdater: info.EnableNGXUpdater,
+			EnableHDR:        profile.Bool(info.EnableHDR),
+			EnableWayland:    profile.Bool(info.EnableWayland),
+			EnableNGXUpdater: profile.Bool(info.EnableNGXUpdater),
 		},
 		Ludusavi: profile.LudusaviSettings{
-			BackupOnLaunch: info.BackupOnLaunch,
+			BackupOnLaunch: profile.Bool(info.BackupOnLaunch),
 		},
 	}
 }
 
+// profileInfoFromResolution returns the resolved profile as shown by the
+// GUI, with the fields that layer does not set marked as inherited.
+func profileInfoFromResolution(r *profile.Resolution, layer string, inheritedFromDefault bool) *ProfileInfo {
+	info := profileInfoFromProfile(r.Profile, inheritedFromDefault)
+	info.Inherited = make(map[string]string)
+	for field, key := range profileInfoKeys {
+		if source := r.Source(key); source != layer && source != profile.LayerBuiltin {
+			info.Inherited[field] = source
+		}
+	}
+	return info
+}
+
+// applyProfileInfo writes the fields changed in info to layer, the profile
+// file resolved was loaded from. Both sides are compared as the GUI shows
+// them, so a boolean that is unset and shown as false is not written as an
+// explicit false.
+func applyProfileInfo(layer, resolved *profile.Profile, info ProfileInfo) error {
+	shown := profileFromInfo(*profileInfoFromProfile(resolved, false))
+	return profile.ApplyEdits(layer, shown, profileFromInfo(info))
+}
+
 func (a *App) GetProfile(appID uint64) *ProfileInfo {
-	perGameProfile, err := profile.Load(appID)
-	if err != nil {
+	r, err := profile.Resolve(appID)
+	if err != nil || len(r.Layers) == 0 {
 		return nil
 	}
-	if perGameProfile != nil {
-		return profileInfoFromProfile(perGameProfile, false)
+	return profileInfoFromResolution(r, profile.GameLayer(appID), !profile.Exists(appID))
+}
+
+func (a *App) GetDefaultProfile() *ProfileInfo {
+	r, err := profile.ResolveDefault()
+	if err != nil || len(r.Layers) == 0 {
+		return nil
 	}
+	return profileInfoFromResolution(r, profile.LayerDefault, false)
+}
 
-	defaultProfile, err := profile.LoadDefault()
+// SaveProfile writes the fields changed in info to the game's own profile.
+// Fields left alone keep being inherited from the default and base
+// profiles.
+func (a *App) SaveProfile(appID uint64, info ProfileInfo) error {
+	r, err := profile.Resolve(appID)
 	if err != nil {
-		return nil
+		return err
 	}
-	if defaultProfile == nil {
-		return nil
+	layer, err := profile.Load(appID)
+	if err != nil {
+		return err
+	}
+	if layer == nil {
+		layer = &profile.Profile{}
+	}
+	if err := applyProfileInfo(layer, r.Profile, info); err != nil {
+		return err
 	}
-	return profileInfoFromProfile(defaultProfile, true)
+	return profile.Save(appID, layer)
 }
 
-func (a *App) GetDefaultProfile() *ProfileInfo {
-	p, err := profile.LoadDefault()
+// SaveDefaultProfile writes the fields changed in info to the default
+// profile.
+func (a *App) SaveDefaultProfile(info ProfileInfo) error {
+	r, err := profile.ResolveDefault()
 	if err != nil {
-		return nil
+		return err
+	}
+	layer, err := profile.LoadDefault()
+	if err != nil {
+		return err
+	}
+	if layer == nil {
+		layer = &profile.Profile{}
+	}
+	if err := applyProfileInfo(layer, r.Profile, info); err != nil {
+		return err
 	}
-	return profileInfoFromProfile(p, false)
+	return profile.SaveDefault(layer)
 }
 
-func (a *App) SaveProfile(appID uint64, info ProfileInfo) error {
-	return profile.Save(appID, profileFromInfo(info))
+type PresetInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Builtin     bool   `json:"builtin"`
 }
 
-func (a *App) SaveDefaultProfile(info ProfileInfo) error {
-	return profile.SaveDefault(profileFromInfo(info))
+func (a *App) ListPresets() []PresetInfo {
+	presets, err := profile.ListPresets()
+	if err != nil {
+		return nil
+	}
+
+	infos := make([]PresetInfo, 0, len(presets))
+	for _, preset := range presets {
+		infos = append(infos, PresetInfo{
+			Name:        preset.Name,
+			Description: preset.Description,
+			Builtin:     preset.Builtin,
+		})
+	}
+	return infos
+}
+
+// ApplyPreset returns info with the named preset applied, tuned to the
+// detected GPU. The result is not saved.
+func (a *App) ApplyPreset(info ProfileInfo, name string) (*ProfileInfo, error) {
+	preset, err := profile.GetPreset(name)
+	if err != nil {
+		return nil, err
+	}
+
+	gen, _ := gpu.GetGPUGeneration()
+	p := profileFromInfo(info)
+	if err := p.ApplyPreset(preset, gen); err != nil {
+		return nil, err
+	}
+
+	return profileInfoFromProfile(p, info.InheritedFromDefault), nil
 }
 
 type GPUInfo struct {
@@ -690,7 +821,8 @@ func (a *App) LaunchGame(appID uint64) error {
 		p.Apply(e)
 	}
 
-	cmd := exec.Command("steam", fmt.Sprintf("steam://rungameid/%d", appID))
+	steam := launcher.SteamCommand(appID, p)
+	cmd := exec.Command(steam[0], steam[1:]...)
 	e.ApplyToCmd(cmd)
 
 	if err := cmd.Start(); err != nil {
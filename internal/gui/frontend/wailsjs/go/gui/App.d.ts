@@ -2,6 +2,8 @@
 // This file is automatically generated. DO NOT EDIT
 import {gui} from '../models';
 
+export function ApplyPreset(arg1:gui.ProfileInfo,arg2:string):Promise<gui.ProfileInfo>;
+
 export function CheckDLLUpdates(arg1:number):Promise<Array<gui.DLLUpdateInfo>>;
 
 export function GetCPUInfo():Promise<gui.CPUInfo>;
@@ -32,6 +34,8 @@ export function ListDLLInstallTypes(arg1:number):Promise<Array<string>>;
 
 export function ListDLLVersions(arg1:string):Promise<Array<string>>;
 
+export function ListPresets():Promise<Array<gui.PresetInfo>>;
+
 export function RestoreDLLs(arg1:number):Promise<void>;
 
 export function SaveConfig(arg1:gui.ConfigInfo):Promise<void>;
@@ -2,6 +2,10 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function ApplyPreset(arg1, arg2) {
+  return window['go']['gui']['App']['ApplyPreset'](arg1, arg2);
+}
+
 export function CheckDLLUpdates(arg1) {
   return window['go']['gui']['App']['CheckDLLUpdates'](arg1);
 }
@@ -62,6 +66,10 @@ export function ListDLLVersions(arg1) {
   return window['go']['gui']['App']['ListDLLVersions'](arg1);
 }
 
+export function ListPresets() {
+  return window['go']['gui']['App']['ListPresets']();
+}
+
 export function RestoreDLLs(arg1) {
   return window['go']['gui']['App']['RestoreDLLs'](arg1);
 }
@@ -41,6 +41,7 @@ export namespace gui {
 	    autoRefreshManifest: boolean;
 	    manifestRefreshHours: number;
 	    preferredDLLSource: string;
+	    dllSignaturePolicy: string;
 	    theme: string;
 	    compactMode: boolean;
 	    confirmDestructive: boolean;
@@ -65,6 +66,7 @@ export namespace gui {
 	        this.autoRefreshManifest = source["autoRefreshManifest"];
 	        this.manifestRefreshHours = source["manifestRefreshHours"];
 	        this.preferredDLLSource = source["preferredDLLSource"];
+	        this.dllSignaturePolicy = source["dllSignaturePolicy"];
 	        this.theme = source["theme"];
 	        this.compactMode = source["compactMode"];
 	        this.confirmDestructive = source["confirmDestructive"];
@@ -174,9 +176,27 @@ export namespace gui {
 		    return a;
 		}
 	}
+	export class PresetInfo {
+	    name: string;
+	    description: string;
+	    builtin: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PresetInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.builtin = source["builtin"];
+	    }
+	}
 	export class ProfileInfo {
+	    preset: string;
 	    srMode: string;
 	    srPreset: string;
+	    srModelPreset: string;
 	    srOverride: boolean;
 	    fgEnabled: boolean;
 	    fgOverride: boolean;
@@ -190,6 +210,7 @@ export namespace gui {
 	    enableNgxUpdater: boolean;
 	    backupOnLaunch: boolean;
 	    inheritedFromDefault: boolean;
+	    inherited: {[key: string]: string};
 	
 	    static createFrom(source: any = {}) {
 	        return new ProfileInfo(source);
@@ -197,8 +218,10 @@ export namespace gui {
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.preset = source["preset"];
 	        this.srMode = source["srMode"];
 	        this.srPreset = source["srPreset"];
+	        this.srModelPreset = source["srModelPreset"];
 	        this.srOverride = source["srOverride"];
 	        this.fgEnabled = source["fgEnabled"];
 	        this.fgOverride = source["fgOverride"];
@@ -212,6 +235,7 @@ export namespace gui {
 	        this.enableNgxUpdater = source["enableNgxUpdater"];
 	        this.backupOnLaunch = source["backupOnLaunch"];
 	        this.inheritedFromDefault = source["inheritedFromDefault"];
+	        this.inherited = source["inherited"];
 	    }
 	}
 
@@ -0,0 +1,221 @@
+// Package hook runs user-defined commands before a game launches and after
+// it exits.
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Event string
+
+const (
+	EventPreLaunch Event = "pre_launch"
+	EventPostExit  Event = "post_exit"
+	EventOnCrash   Event = "on_crash"
+)
+
+// FailurePolicy decides what a failing hook does to the launch.
+type FailurePolicy string
+
+const (
+	// FailWarn logs the failure and carries on. It is the default.
+	FailWarn FailurePolicy = "warn"
+	// FailAbort stops the launch when a pre-launch hook fails. Hooks run
+	// after the game exits can only warn.
+	FailAbort FailurePolicy = "abort"
+)
+
+// DefaultTimeout is how long a hook may run when it sets no timeout.
+const DefaultTimeout = 30 * time.Second
+
+// Hook is a shell command run for an event. In YAML a plain string is the
+// command; a mapping can also set a timeout and a failure policy.
+type Hook struct {
+	Command   string        `yaml:"command"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+	OnFailure FailurePolicy `yaml:"on_failure,omitempty"`
+}
+
+type hookFields Hook
+
+var hookKeys = []string{"command", "timeout", "on_failure"}
+
+func (h *Hook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*h = Hook{Command: node.Value}
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a command or a mapping with %s", node.Line, strings.Join(hookKeys, ", "))
+	}
+
+	// Decoding a node does not inherit the strict mode of the outer
+	// decoder, so unknown keys are rejected here.
+	for i := 0; i < len(node.Content); i += 2 {
+		if key := node.Content[i]; !slices.Contains(hookKeys, key.Value) {
+			return fmt.Errorf("line %d: field %s not found in type hook.Hook", key.Line, key.Value)
+		}
+	}
+
+	var fields hookFields
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	*h = Hook(fields)
+	return nil
+}
+
+func (h Hook) MarshalYAML() (any, error) {
+	if h == (Hook{Command: h.Command}) {
+		return h.Command, nil
+	}
+	return hookFields(h), nil
+}
+
+func (h Hook) String() string {
+	return h.Command
+}
+
+// Validate reports the first problem with the hook's fields.
+func (h Hook) Validate() error {
+	switch {
+	case strings.TrimSpace(h.Command) == "":
+		return errors.New("command is empty")
+	case h.Timeout < 0:
+		return fmt.Errorf("timeout must not be negative, got %s", h.Timeout)
+	case h.OnFailure != "" && h.OnFailure != FailWarn && h.OnFailure != FailAbort:
+		return fmt.Errorf("invalid on_failure %q (expected %s or %s)", h.OnFailure, FailWarn, FailAbort)
+	}
+	return nil
+}
+
+// EffectiveTimeout returns the hook's timeout, or DefaultTimeout.
+func (h Hook) EffectiveTimeout() time.Duration {
+	if h.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return h.Timeout
+}
+
+// Aborts reports whether a failure of the hook should stop the launch.
+func (h Hook) Aborts() bool {
+	return h.OnFailure == FailAbort
+}
+
+// Settings holds the hooks for each event, run in order.
+type Settings struct {
+	PreLaunch []Hook `yaml:"pre_launch,omitempty"`
+	PostExit  []Hook `yaml:"post_exit,omitempty"`
+	OnCrash   []Hook `yaml:"on_crash,omitempty"`
+}
+
+// For returns the hooks for an event.
+func (s Settings) For(event Event) []Hook {
+	switch event {
+	case EventPreLaunch:
+		return s.PreLaunch
+	case EventPostExit:
+		return s.PostExit
+	case EventOnCrash:
+		return s.OnCrash
+	}
+	return nil
+}
+
+// Clone returns a copy that shares no slices with s.
+func (s Settings) Clone() Settings {
+	return Settings{
+		PreLaunch: slices.Clone(s.PreLaunch),
+		PostExit:  slices.Clone(s.PostExit),
+		OnCrash:   slices.Clone(s.OnCrash),
+	}
+}
+
+// Events lists the events in the order they happen.
+func Events() []Event {
+	return []Event{EventPreLaunch, EventPostExit, EventOnCrash}
+}
+
+// Context describes the game to hooks. It is passed to them as SPELA_APPID,
+// SPELA_GAME_NAME, SPELA_INSTALL_DIR and SPELA_PREFIX, along with
+// SPELA_HOOK naming the event and, after the game exits, SPELA_EXIT_CODE.
+type Context struct {
+	AppID      uint64
+	GameName   string
+	InstallDir string
+	Prefix     string
+	ExitCode   int
+}
+
+// Environ returns the variables a hook for event runs with: the current
+// environment plus the SPELA_* variables.
+func (c Context) Environ(event Event) []string {
+	var appID string
+	if c.AppID != 0 {
+		appID = strconv.FormatUint(c.AppID, 10)
+	}
+
+	vars := []string{
+		"SPELA_HOOK=" + string(event),
+		"SPELA_APPID=" + appID,
+		"SPELA_GAME_NAME=" + c.GameName,
+		"SPELA_INSTALL_DIR=" + c.InstallDir,
+		"SPELA_PREFIX=" + c.Prefix,
+	}
+	if event != EventPreLaunch {
+		vars = append(vars, "SPELA_EXIT_CODE="+strconv.Itoa(c.ExitCode))
+	}
+	return append(os.Environ(), vars...)
+}
+
+// Run runs h with sh -c and writes its combined output to output, each line
+// prefixed with the event. The hook and anything it started are killed when
+// it runs past its timeout.
+func Run(h Hook, event Event, c Context, output io.Writer) error {
+	timeout := h.EffectiveTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Env = c.Environ(event)
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	writePrefixed(output, "["+string(event)+"] ", buf.Bytes())
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+func writePrefixed(w io.Writer, prefix string, data []byte) {
+	if w == nil {
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fmt.Fprintf(w, "%s%s\n", prefix, scanner.Text())
+	}
+}
@@ -0,0 +1,99 @@
+package hook_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jgabor/spela/internal/hook"
+)
+
+func TestHookYAML(t *testing.T) {
+	var s hook.Settings
+	data := "pre_launch:\n  - echo hi\n  - command: ./backup.sh\n    timeout: 2m\n    on_failure: abort\n"
+	if err := yaml.Unmarshal([]byte(data), &s); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := []hook.Hook{
+		{Command: "echo hi"},
+		{Command: "./backup.sh", Timeout: 2 * time.Minute, OnFailure: hook.FailAbort},
+	}
+	if len(s.PreLaunch) != len(want) || s.PreLaunch[0] != want[0] || s.PreLaunch[1] != want[1] {
+		t.Fatalf("PreLaunch = %+v, want %+v", s.PreLaunch, want)
+	}
+
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var again hook.Settings
+	if err := yaml.Unmarshal(out, &again); err != nil {
+		t.Fatalf("Unmarshal() round trip error = %v", err)
+	}
+	if again.PreLaunch[0] != want[0] || again.PreLaunch[1] != want[1] {
+		t.Errorf("round trip = %+v, want %+v", again.PreLaunch, want)
+	}
+
+	if err := yaml.Unmarshal([]byte("pre_launch:\n  - command: x\n    timout: 1s\n"), &s); err == nil {
+		t.Error("Unmarshal() accepted an unknown key")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		hook    hook.Hook
+		wantErr bool
+	}{
+		{hook.Hook{Command: "true"}, false},
+		{hook.Hook{Command: "true", OnFailure: hook.FailAbort, Timeout: time.Second}, false},
+		{hook.Hook{Command: "  "}, true},
+		{hook.Hook{Command: "true", Timeout: -time.Second}, true},
+		{hook.Hook{Command: "true", OnFailure: "ignore"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.hook.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.hook, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := hook.Context{AppID: 42, GameName: "Foo", InstallDir: "/games/Foo", Prefix: "/prefix", ExitCode: 3}
+	h := hook.Hook{Command: `echo "$SPELA_HOOK $SPELA_APPID $SPELA_GAME_NAME $SPELA_INSTALL_DIR $SPELA_PREFIX $SPELA_EXIT_CODE"; echo err >&2`}
+
+	var out bytes.Buffer
+	if err := hook.Run(h, hook.EventPostExit, c, &out); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "[post_exit] post_exit 42 Foo /games/Foo /prefix 3\n[post_exit] err\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := hook.Run(h, hook.EventPreLaunch, c, &out); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "[pre_launch] pre_launch 42 Foo /games/Foo /prefix \n") {
+		t.Errorf("pre-launch output = %q, want no exit code", out.String())
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	if err := hook.Run(hook.Hook{Command: "exit 2"}, hook.EventPreLaunch, hook.Context{}, nil); err == nil {
+		t.Error("Run() error = nil for a failing command")
+	}
+
+	start := time.Now()
+	err := hook.Run(hook.Hook{Command: "sleep 10 & wait", Timeout: 100 * time.Millisecond}, hook.EventPreLaunch, hook.Context{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Run() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %s, want the hook killed at its timeout", elapsed)
+	}
+}
@@ -0,0 +1,108 @@
+package launcher
+
+import (
+	"log"
+
+	"github.com/jgabor/spela/internal/gamemode"
+	"github.com/jgabor/spela/internal/profile"
+)
+
+// systemChange is a system change of the profile and how it overlaps with
+// GameMode.
+type systemChange struct {
+	profile.SystemChange
+	// conflict describes the GameMode setting that changes the same thing,
+	// if any.
+	conflict string
+	// deferred is set when the change is left to GameMode.
+	deferred bool
+}
+
+// resolveGameMode works out how the game is put in GameMode: over D-Bus,
+// through gamemoderun, or not at all when GameMode is off, not installed,
+// or the game is started through Steam.
+func (l *Launcher) resolveGameMode(viaSteam bool) profile.GameModeMethod {
+	if viaSteam || l.Profile == nil || !profile.IsTrue(l.Profile.GameMode.Enabled) {
+		return ""
+	}
+	if l.gameModeChecked {
+		return l.gameMode
+	}
+	l.gameModeChecked = true
+
+	switch l.Profile.GameMode.Method {
+	case profile.GameModeMethodDBus:
+		l.gameMode = profile.GameModeMethodDBus
+	case profile.GameModeMethodWrapper:
+		if gamemode.IsInstalled() {
+			l.gameMode = profile.GameModeMethodWrapper
+		} else {
+			log.Printf("Warning: %s not found; running without GameMode", gamemode.Wrapper)
+		}
+	default:
+		switch {
+		case gamemode.DaemonAvailable():
+			l.gameMode = profile.GameModeMethodDBus
+		case gamemode.IsInstalled():
+			l.gameMode = profile.GameModeMethodWrapper
+		default:
+			log.Printf("Warning: GameMode is not installed; running without it")
+		}
+	}
+	return l.gameMode
+}
+
+// registerGameMode registers the game's process with the GameMode daemon,
+// and unregisters it when the game exits, before the profile's own system
+// changes are reverted.
+func (l *Launcher) registerGameMode(pid int) {
+	if err := gamemode.Register(pid); err != nil {
+		log.Printf("Warning: failed to register with GameMode: %v", err)
+		return
+	}
+	l.OnCleanup(func() {
+		if err := gamemode.Unregister(pid); err != nil {
+			log.Printf("Warning: failed to unregister from GameMode: %v", err)
+		}
+	})
+}
+
+// systemChanges returns the profile's system changes. When the game runs in
+// GameMode, the changes GameMode is configured to make as well are marked
+// as conflicts, and deferred to GameMode unless the profile says to warn.
+func (l *Launcher) systemChanges(viaSteam bool) []systemChange {
+	var changes []systemChange
+	for _, change := range l.Profile.SystemChanges() {
+		changes = append(changes, systemChange{SystemChange: change})
+	}
+	if len(changes) == 0 || l.resolveGameMode(viaSteam) == "" {
+		return changes
+	}
+
+	cfg, err := gamemode.LoadConfig()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return changes
+	}
+	for i := range changes {
+		changes[i].conflict = gameModeConflict(cfg, l.Profile, changes[i].Key)
+		changes[i].deferred = changes[i].conflict != "" && l.Profile.GameMode.OnConflict != profile.GameModeConflictWarn
+	}
+	return changes
+}
+
+// gameModeConflict describes the GameMode setting that changes the same
+// thing as the profile setting key, or returns "" if there is none.
+func gameModeConflict(cfg *gamemode.Config, p *profile.Profile, key string) string {
+	switch key {
+	case "cpu.governor":
+		if governor := cfg.Governor(); governor != p.CPU.Governor {
+			return "desiredgov=" + governor
+		}
+	case "cpu.smt":
+		if cfg.ParksCores() {
+			return "park_cores=" + cfg.ParkCores
+		}
+	}
+	return ""
+}
@@ -1,27 +1,62 @@
 package launcher
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"golang.org/x/sys/unix"
+
+	"github.com/jgabor/spela/internal/doctor"
 	"github.com/jgabor/spela/internal/env"
 	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/hook"
 	"github.com/jgabor/spela/internal/ludusavi"
 	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/session"
+	"github.com/jgabor/spela/internal/state"
 )
 
 type Launcher struct {
-	Game        *game.Game
-	Profile     *profile.Profile
+	Game    *game.Game
+	Profile *profile.Profile
+	// Resolution explains which layer each profile setting came from. It is
+	// only used to describe launch plans.
+	Resolution  *profile.Resolution
 	Environment *env.Environment
 	Command     []string
-	cleanup     []func()
+	// Hooks are the global hooks from the config. They run before the
+	// profile's own hooks.
+	Hooks hook.Settings
+	// Log receives the output of hooks. It defaults to standard error.
+	Log io.Writer
+	// RecordSession saves the launch in the game's session history, with
+	// the logs the profile asks for.
+	RecordSession bool
+	// AutoRollback restores the profile and DLLs of the last clean session
+	// when a recorded session crashes or exits early.
+	AutoRollback bool
+	// ApplySystemChanges makes the profile's system changes, which are
+	// opt-in. See appliesSystemChanges.
+	ApplySystemChanges bool
+	cleanup            []func()
+	// gameMode is how the game is put in GameMode, once worked out.
+	gameMode        profile.GameModeMethod
+	gameModeChecked bool
+	// sessionLog also receives the output of hooks while the game's output
+	// is captured.
+	sessionLog io.Writer
 }
 
 type WrapperInvocation struct {
@@ -40,52 +75,444 @@ func (l *Launcher) OnCleanup(fn func()) {
 	l.cleanup = append(l.cleanup, fn)
 }
 
+// Launch runs args, or starts the game through Steam when args is empty,
+// with the profile's environment, system changes and wrappers applied.
+//
+// Pre-launch hooks run first; one that fails with the abort policy cancels
+// the launch. Post-exit hooks, and crash hooks when the game exits with an
+// error, run once the system changes are reverted. Steam returns as soon as
+// it has handed the game off, so they are skipped for Steam launches, and so
+// is recording the session: spela records it when Steam runs it as the
+// game's launch option.
 func (l *Launcher) Launch(args []string) error {
+	viaSteam := l.viaSteam(args)
+	for _, warning := range l.warnings(viaSteam) {
+		log.Printf("Warning: %s", warning)
+	}
+	args = l.command(args)
 	if len(args) == 0 {
 		return nil
 	}
 
-	l.runPreLaunchHooks()
+	// The session starts once pre-launch hooks have passed, so that an
+	// aborted launch is not recorded. Their output is kept until the game
+	// log is open.
+	var preLaunchOutput *bytes.Buffer
+	if l.RecordSession && !viaSteam {
+		preLaunchOutput = &bytes.Buffer{}
+		l.sessionLog = preLaunchOutput
+	}
+	err := l.runPreLaunchHooks()
+	l.sessionLog = nil
+	if err != nil {
+		l.runCleanup()
+		return err
+	}
+	l.applySystemChanges(viaSteam)
+
+	var sess *session.Session
+	var gameLog *session.Log
+	if l.RecordSession && !viaSteam {
+		sess = l.beginSession(args)
+		gameLog = l.openGameLog(sess)
+	}
 
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = gameOutput(os.Stdout, gameLog)
+	cmd.Stderr = gameOutput(os.Stderr, gameLog)
 	l.Environment.ApplyToCmd(cmd)
+	if gameLog != nil {
+		// Processes the game leaves behind may keep its output open;
+		// don't wait for them once it has exited.
+		cmd.WaitDelay = 2 * time.Second
+		if _, err := gameLog.Write(preLaunchOutput.Bytes()); err != nil {
+			log.Printf("Warning: failed to write game log: %v", err)
+		}
+		l.sessionLog = gameLog
+		defer func() {
+			l.sessionLog = nil
+			if err := gameLog.Close(); err != nil {
+				log.Printf("Warning: failed to close game log: %v", err)
+			}
+		}()
+	}
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Run()
-	}()
+	var setUp <-chan struct{}
+	if err := cmd.Start(); err != nil {
+		done <- err
+	} else {
+		exited := make(chan struct{})
+		go func() {
+			err := cmd.Wait()
+			close(exited)
+			done <- err
+		}()
+		setUp = l.started(cmd.Process.Pid, viaSteam, exited)
+	}
 
-	var err error
+	interrupted := false
 	select {
 	case sig := <-sigChan:
+		interrupted = true
 		if cmd.Process != nil {
 			_ = cmd.Process.Signal(sig)
 		}
 		err = <-done
 	case err = <-done:
 	}
+	signal.Stop(sigChan)
+	if setUp != nil {
+		// It registers cleanups of its own.
+		<-setUp
+	}
+
+	code, sig, ran := exitStatus(err)
+	if sess != nil {
+		l.finishSession(sess, err, code, sig, ran, interrupted)
+	}
 
 	l.runCleanup()
+
+	if sess != nil && sess.Result().Bad() {
+		l.reportFailure(sess)
+	}
+
+	if ran && !viaSteam {
+		l.runExitHooks(code, interrupted)
+	}
 	return err
 }
 
-func (l *Launcher) runPreLaunchHooks() {
-	if l.Profile == nil || l.Game == nil {
+// exitStatus returns the exit code of a finished command, or 128 plus the
+// signal number and the signal's name if it was killed. It reports false if
+// the command never ran.
+func exitStatus(err error) (int, string, bool) {
+	if err == nil {
+		return 0, "", true
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, "", false
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), unix.SignalName(status.Signal()), true
+	}
+	return exitErr.ExitCode(), "", true
+}
+
+// beginSession records the start of the game's session. A session that
+// cannot be recorded is skipped; the game still launches.
+func (l *Launcher) beginSession(args []string) *session.Session {
+	if l.Game == nil {
+		return nil
+	}
+
+	sess := session.New(l.Game, args)
+	sess.SetEnv(l.Environment.All(), l.Environment.Unsets())
+	if l.Profile != nil {
+		hash, err := session.Hash(l.Profile)
+		if err != nil {
+			log.Printf("Warning: failed to hash profile: %v", err)
+		}
+		sess.Profile.Hash = hash
+		if l.Resolution != nil {
+			sess.Profile.Layers = slices.Clone(l.Resolution.Layers)
+		}
+		revision, err := profile.CurrentRevision(l.Game.AppID)
+		if err != nil {
+			log.Printf("Warning: failed to read profile history: %v", err)
+		}
+		sess.Profile.Revision = revision
+	}
+
+	if err := sess.Begin(); err != nil {
+		log.Printf("Warning: failed to record session: %v", err)
+		return nil
+	}
+
+	if l.Profile != nil && l.Profile.Logging.Enabled() {
+		if err := session.PruneLogs(l.Game.AppID, l.Profile.Logging.Keep(), l.Profile.Logging.MaxSize()); err != nil {
+			log.Printf("Warning: failed to remove old session logs: %v", err)
+		}
+		if profile.IsTrue(l.Profile.Logging.Debug) {
+			l.enableDebugLogs(sess.Dir())
+			sess.SetEnv(l.Environment.All(), l.Environment.Unsets())
+			if err := sess.Save(); err != nil {
+				log.Printf("Warning: failed to record session: %v", err)
+			}
+		}
+	}
+	return sess
+}
+
+// reportFailure points out what changed since the last clean session when
+// a session went bad, and rolls it back if AutoRollback is set.
+func (l *Launcher) reportFailure(sess *session.Session) {
+	log.Printf("Warning: %s %s", l.Game.Name, sess.Status())
+
+	report, err := doctor.Diagnose(l.Game)
+	if err != nil {
+		log.Printf("Warning: failed to diagnose session: %v", err)
+		return
+	}
+	for _, change := range report.Changes {
+		log.Printf("  since session %d: %s", report.LastGood.Number, change.Description)
+	}
+
+	if l.AutoRollback && report.Rollback != nil {
+		if err := doctor.RollBack(l.Game, report.Rollback); err != nil {
+			log.Printf("Warning: rollback failed: %v", err)
+			return
+		}
+		log.Printf("Rolled back to %s, as used by session %d", report.Rollback.Describe(), report.Rollback.Session)
 		return
 	}
+	log.Printf("Run 'spela doctor %s' for suggestions", strconv.Quote(l.Game.Name))
+}
+
+// debugLogVars point Proton, DXVK and VKD3D logging at a directory.
+func debugLogVars(dir string) [][2]string {
+	return [][2]string{
+		{"PROTON_LOG", "1"},
+		{"PROTON_LOG_DIR", dir},
+		{"DXVK_LOG_PATH", dir},
+		{"VKD3D_LOG_FILE", filepath.Join(dir, "vkd3d.log")},
+	}
+}
+
+// enableDebugLogs writes the debug logs of Proton and the translation
+// layers to dir. Variables the profile sets itself are left alone.
+func (l *Launcher) enableDebugLogs(dir string) {
+	l.Environment.Annotate("logging.debug", func() {
+		for _, v := range debugLogVars(dir) {
+			if l.Environment.Get(v[0]) == "" {
+				l.Environment.Set(v[0], v[1])
+			}
+		}
+	})
+}
+
+// openGameLog opens the session's game log if the profile captures output.
+func (l *Launcher) openGameLog(sess *session.Session) *session.Log {
+	if sess == nil || l.Profile == nil || !profile.IsTrue(l.Profile.Logging.Capture) {
+		return nil
+	}
+	gameLog, err := sess.OpenLog(l.Profile.Logging.MaxSize())
+	if err != nil {
+		log.Printf("Warning: not capturing game output: %v", err)
+		return nil
+	}
+	return gameLog
+}
+
+// gameOutput returns where the game writes to instead of f: the game log,
+// and f too when it is a terminal.
+func gameOutput(f *os.File, gameLog *session.Log) io.Writer {
+	if gameLog == nil {
+		return f
+	}
+	if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return io.MultiWriter(f, gameLog)
+	}
+	return gameLog
+}
+
+func (l *Launcher) finishSession(sess *session.Session, err error, code int, sig string, ran, interrupted bool) {
+	if ran {
+		err = sess.Finish(code, sig, interrupted)
+	} else {
+		err = sess.Fail(err)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to record session: %v", err)
+	}
 
-	if l.Profile.Ludusavi.BackupOnLaunch && ludusavi.IsInstalled() {
+	if l.Profile != nil && profile.IsTrue(l.Profile.Logging.Debug) {
+		if err := sess.TrimLogs(l.Profile.Logging.MaxSize()); err != nil {
+			log.Printf("Warning: failed to trim debug logs: %v", err)
+		}
+	}
+}
+
+func (l *Launcher) runPreLaunchHooks() error {
+	if l.Profile != nil && l.Game != nil && profile.IsTrue(l.Profile.Ludusavi.BackupOnLaunch) && ludusavi.IsInstalled() {
 		log.Printf("Backing up saves for %s...", l.Game.Name)
 		if _, err := ludusavi.BackupGame(l.Game.Name); err != nil {
 			log.Printf("Warning: failed to backup saves: %v", err)
 		}
 	}
+
+	return l.runHooks(hook.EventPreLaunch, l.hookContext())
+}
+
+// runExitHooks runs the post-exit hooks and, if the game failed without
+// being interrupted, the crash hooks.
+func (l *Launcher) runExitHooks(code int, interrupted bool) {
+	c := l.hookContext()
+	c.ExitCode = code
+
+	_ = l.runHooks(hook.EventPostExit, c)
+	if code != 0 && !interrupted {
+		_ = l.runHooks(hook.EventOnCrash, c)
+	}
+}
+
+// runHooks runs the hooks for event in order. It stops at the first failing
+// pre-launch hook with the abort policy and returns its error; other
+// failures are logged.
+func (l *Launcher) runHooks(event hook.Event, c hook.Context) error {
+	for _, h := range l.hooks(event) {
+		if err := h.Validate(); err != nil {
+			log.Printf("Warning: skipping %s hook %q: %v", event, h.Command, err)
+			continue
+		}
+		if err := hook.Run(h, event, c, l.log()); err != nil {
+			if event == hook.EventPreLaunch && h.Aborts() {
+				return fmt.Errorf("%s hook %q failed: %w", event, h.Command, err)
+			}
+			log.Printf("Warning: %s hook %q failed: %v", event, h.Command, err)
+		}
+	}
+	return nil
+}
+
+// hooks returns the global hooks for event followed by the profile's.
+func (l *Launcher) hooks(event hook.Event) []hook.Hook {
+	hooks := slices.Clone(l.Hooks.For(event))
+	if l.Profile != nil {
+		hooks = append(hooks, l.Profile.Hooks.For(event)...)
+	}
+	return hooks
+}
+
+func (l *Launcher) hookContext() hook.Context {
+	if l.Game == nil {
+		return hook.Context{}
+	}
+	return hook.Context{
+		AppID:      l.Game.AppID,
+		GameName:   l.Game.Name,
+		InstallDir: l.Game.InstallDir,
+		Prefix:     l.Game.PrefixPath,
+	}
+}
+
+func (l *Launcher) log() io.Writer {
+	w := l.Log
+	if w == nil {
+		w = os.Stderr
+	}
+	if l.sessionLog != nil {
+		return io.MultiWriter(w, l.sessionLog)
+	}
+	return w
+}
+
+// started is called once the game's command is running as pid. exited is
+// closed when it exits. The game is registered with GameMode and gets the
+// profile's process settings once its own process shows up under pid; the
+// returned channel is closed when that is done.
+func (l *Launcher) started(pid int, viaSteam bool, exited <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	gameMode := l.resolveGameMode(viaSteam) == profile.GameModeMethodDBus
+	var changes []profile.ProcessChange
+	if l.Profile != nil && !viaSteam {
+		changes = l.Profile.ProcessChanges()
+	}
+	if !gameMode && len(changes) == 0 {
+		close(done)
+		return done
+	}
+
+	go func() {
+		defer close(done)
+		target, ok := l.gameProcess(pid, exited)
+		if !ok {
+			return
+		}
+		if gameMode {
+			l.registerGameMode(target)
+		}
+		l.applyProcessChanges(changes, target)
+	}()
+	return done
+}
+
+// appliesSystemChanges reports whether a launch makes the profile's system
+// changes. Besides being opt-in, they are never made for launches through
+// Steam: Steam returns as soon as it has handed the game off, so they would
+// be reverted right away.
+func (l *Launcher) appliesSystemChanges(viaSteam bool) bool {
+	return l.ApplySystemChanges && !viaSteam
+}
+
+// applySystemChanges applies the profile's system changes and registers
+// their reversal. Each reversal is written to a journal before its change is
+// made, so `spela restore` can still revert it if this process dies. A
+// change that fails is skipped; the game still launches, and so it does
+// when a change is left to GameMode.
+func (l *Launcher) applySystemChanges(viaSteam bool) {
+	if l.Profile == nil || !l.appliesSystemChanges(viaSteam) {
+		return
+	}
+	changes := l.systemChanges(viaSteam)
+	if len(changes) == 0 {
+		return
+	}
+
+	var journal *state.Journal
+	if l.Game != nil {
+		journal = state.New(l.Game.Name, l.Game.AppID)
+	} else {
+		journal = state.New("", 0)
+	}
+	// Registered first, so it runs after every reversal.
+	l.OnCleanup(func() {
+		if err := journal.Close(); err != nil {
+			log.Printf("Warning: failed to remove session journal: %v", err)
+		}
+		if pending := journal.Pending(); len(pending) > 0 {
+			log.Printf("Warning: %d system changes were not reverted; run 'spela restore' to try again", len(pending))
+		}
+	})
+
+	for _, change := range changes {
+		if change.deferred {
+			log.Printf("Leaving %q to GameMode (%s)", change.Description, change.conflict)
+			continue
+		}
+		if change.conflict != "" {
+			log.Printf("Warning: %q conflicts with GameMode (%s)", change.Description, change.conflict)
+		}
+
+		reversal, err := change.Snapshot()
+		if err != nil {
+			log.Printf("Warning: %s: %v", change.Description, err)
+			continue
+		}
+		i, err := journal.Record(change.Key, change.Description, reversal)
+		if err != nil {
+			log.Printf("Warning: skipping %q: %v", change.Description, err)
+			continue
+		}
+		// A change that failed may have been made in part, such as a
+		// governor set on some CPUs only, so its reversal stays pending.
+		if err := change.Apply(); err != nil {
+			log.Printf("Warning: %s: %v", change.Description, err)
+		}
+
+		description := change.Description
+		l.OnCleanup(func() {
+			if err := journal.Revert(i); err != nil {
+				log.Printf("Warning: failed to revert %q: %v", description, err)
+			}
+		})
+	}
 }
 
 func (l *Launcher) runCleanup() {
@@ -0,0 +1,464 @@
+package launcher
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/gamemode"
+	"github.com/jgabor/spela/internal/hook"
+	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/session"
+)
+
+// Environment variable sources reported in a Plan.
+const (
+	SourceProfile   = "profile"
+	SourceWrapper   = "wrapper"
+	SourceInherited = "inherited"
+)
+
+// OriginWrapper is the env.Environment origin of variables that were passed
+// as KEY=value arguments in wrapper mode.
+const OriginWrapper = "wrapper"
+
+// relevantEnvPrefixes selects the inherited variables worth showing in a plan
+// unless all of them are requested.
+var relevantEnvPrefixes = []string{
+	"PROTON_", "DXVK_", "VKD3D_", "WINE", "__GL_", "__NV_", "VK_", "MESA_", "RADV_",
+	"MANGOHUD", "ENABLE_VKBASALT", "STEAM_COMPAT_", "SteamAppId", "SteamGameId", "LD_PRELOAD",
+}
+
+// PlannedEnvVar is a variable in the game's environment and where it came
+// from.
+type PlannedEnvVar struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	// Setting and Layer identify the profile setting for profile variables.
+	Setting string `json:"setting,omitempty"`
+	Layer   string `json:"layer,omitempty"`
+	// Overrides is the inherited value the variable replaces, if any.
+	Overrides *string `json:"overrides,omitempty"`
+	// Unset is true when the variable is removed rather than set.
+	Unset bool `json:"unset,omitempty"`
+}
+
+// PlannedChange is a system change the launch would make.
+type PlannedChange struct {
+	Setting     string `json:"setting"`
+	Description string `json:"description"`
+	Layer       string `json:"layer,omitempty"`
+	// Conflict is the GameMode setting that changes the same thing, and
+	// Deferred is set when the change is left to GameMode.
+	Conflict string `json:"gamemode_conflict,omitempty"`
+	Deferred bool   `json:"deferred,omitempty"`
+}
+
+// PlannedHook is a hook the launch would run.
+type PlannedHook struct {
+	Event     string `json:"event"`
+	Command   string `json:"command"`
+	Timeout   string `json:"timeout"`
+	OnFailure string `json:"on_failure"`
+	// Source is "config" for global hooks, or the profile layer.
+	Source string `json:"source"`
+}
+
+// PlannedCondition is a profile when block that matched.
+type PlannedCondition struct {
+	Conditions string   `json:"conditions"`
+	Layer      string   `json:"layer,omitempty"`
+	Settings   []string `json:"settings"`
+}
+
+// PlannedConditions describes how the profile's when blocks were evaluated.
+type PlannedConditions struct {
+	Context profile.RuntimeContext `json:"context"`
+	Matched []PlannedCondition     `json:"matched"`
+}
+
+// PlannedLogging describes the logs a launch would write to its session
+// directory.
+type PlannedLogging struct {
+	// Dir is the directory of the game's sessions; each launch gets a new
+	// numbered directory in it.
+	Dir     string   `json:"dir"`
+	Capture bool     `json:"capture"`
+	MaxSize int64    `json:"max_size,omitempty"`
+	Keep    int      `json:"keep_sessions"`
+	Debug   []string `json:"debug_env,omitempty"`
+}
+
+// Plan describes everything a launch would do without doing it.
+type Plan struct {
+	Game          string   `json:"game,omitempty"`
+	AppID         uint64   `json:"app_id,omitempty"`
+	ProfileLayers []string `json:"profile_layers"`
+	// Conditions is set when the profile has when blocks.
+	Conditions *PlannedConditions `json:"conditions,omitempty"`
+	// GameMode is how the game would be put in GameMode, if at all.
+	GameMode      profile.GameModeMethod `json:"gamemode,omitempty"`
+	Env           []PlannedEnvVar        `json:"env"`
+	SystemChanges []PlannedChange        `json:"system_changes"`
+	// Process lists the changes made to the game's main process once it
+	// is running.
+	Process []PlannedChange `json:"process,omitempty"`
+	Hooks   []PlannedHook   `json:"hooks"`
+	// Logging is set when the session would be recorded with logs.
+	Logging *PlannedLogging `json:"logging,omitempty"`
+	// Warnings lists what the launch would not do as the profile asks.
+	Warnings []string `json:"warnings,omitempty"`
+	Command  []string `json:"command"`
+}
+
+// Plan computes what Launch would do for args. Inherited variables are
+// limited to gaming related ones unless allEnv is set.
+func (l *Launcher) Plan(args []string, allEnv bool) *Plan {
+	plan := &Plan{
+		ProfileLayers: []string{},
+		Env:           []PlannedEnvVar{},
+		SystemChanges: []PlannedChange{},
+		Hooks:         []PlannedHook{},
+		Command:       l.command(args),
+	}
+	if l.Game != nil {
+		plan.Game = l.Game.Name
+		plan.AppID = l.Game.AppID
+	}
+	if l.Resolution != nil {
+		plan.ProfileLayers = append(plan.ProfileLayers, l.Resolution.Layers...)
+	}
+	if l.Profile != nil {
+		if result := l.Profile.Conditions(); result != nil {
+			plan.Conditions = plannedConditions(result)
+		}
+	}
+
+	inherited := l.Environment.InheritedAll()
+
+	set := l.Environment.All()
+	for _, key := range l.Environment.Unsets() {
+		set[key] = ""
+	}
+	for key, value := range set {
+		v := PlannedEnvVar{Key: key, Value: value, Source: SourceProfile}
+		if _, ok := l.Environment.Lookup(key); !ok {
+			v.Unset = true
+		}
+		if origin := l.Environment.Origin(key); origin == OriginWrapper {
+			v.Source = SourceWrapper
+		} else {
+			v.Setting = origin
+			v.Layer = l.layer(origin)
+		}
+		if previous, ok := inherited[key]; ok {
+			v.Overrides = &previous
+		}
+		plan.Env = append(plan.Env, v)
+	}
+
+	for key, value := range inherited {
+		if _, ok := set[key]; ok {
+			continue
+		}
+		if !allEnv && !isRelevantEnv(key) {
+			continue
+		}
+		plan.Env = append(plan.Env, PlannedEnvVar{Key: key, Value: value, Source: SourceInherited})
+	}
+
+	slices.SortFunc(plan.Env, func(a, b PlannedEnvVar) int { return strings.Compare(a.Key, b.Key) })
+
+	if l.Profile != nil {
+		plan.GameMode = l.resolveGameMode(l.viaSteam(args))
+		var changes []systemChange
+		if l.appliesSystemChanges(l.viaSteam(args)) {
+			changes = l.systemChanges(l.viaSteam(args))
+		}
+		for _, change := range changes {
+			plan.SystemChanges = append(plan.SystemChanges, PlannedChange{
+				Setting:     change.Key,
+				Description: change.Description,
+				Layer:       l.layer(change.Key),
+				Conflict:    change.conflict,
+				Deferred:    change.deferred,
+			})
+		}
+		if !l.viaSteam(args) {
+			for _, change := range l.Profile.ProcessChanges() {
+				plan.Process = append(plan.Process, PlannedChange{
+					Setting:     change.Key,
+					Description: change.Description,
+					Layer:       l.layer(change.Key),
+				})
+			}
+		}
+	}
+
+	for _, event := range hook.Events() {
+		if event != hook.EventPreLaunch && l.viaSteam(args) {
+			continue
+		}
+		plan.Hooks = append(plan.Hooks, plannedHooks(event, l.Hooks.For(event), "config")...)
+		if l.Profile != nil {
+			source := l.layer("hooks." + string(event))
+			if source == "" {
+				source = "profile"
+			}
+			plan.Hooks = append(plan.Hooks, plannedHooks(event, l.Profile.Hooks.For(event), source)...)
+		}
+	}
+
+	if l.RecordSession && !l.viaSteam(args) && l.Game != nil && l.Profile != nil && l.Profile.Logging.Enabled() {
+		plan.Logging = l.plannedLogging()
+	}
+
+	plan.Warnings = l.warnings(l.viaSteam(args))
+
+	return plan
+}
+
+// warnings returns what a launch would not do as the profile asks, for
+// Launch to log and Plan to show.
+func (l *Launcher) warnings(viaSteam bool) []string {
+	var warnings []string
+	if viaSteam {
+		if ignored := l.ignoredViaSteam(); len(ignored) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s ignored when starting the game through Steam; make spela the game's launch option (spela %%command%%) to apply them",
+				strings.Join(ignored, ", ")))
+		}
+	} else if l.Profile != nil {
+		if !l.ApplySystemChanges {
+			if keys := systemChangeKeys(l.Profile); len(keys) > 0 {
+				warnings = append(warnings, fmt.Sprintf("%s not applied; system settings are only changed at launch with apply_system_changes in config.yaml (spela config set apply_system_changes true)",
+					strings.Join(keys, ", ")))
+			}
+		}
+		for _, issue := range l.Profile.Warnings() {
+			warnings = append(warnings, issue.String())
+		}
+	}
+	return warnings
+}
+
+// systemChangeKeys returns the settings of p's system changes.
+func systemChangeKeys(p *profile.Profile) []string {
+	var keys []string
+	for _, change := range p.SystemChanges() {
+		keys = append(keys, change.Key)
+	}
+	return keys
+}
+
+// ignoredViaSteam returns the profile settings that are left out when the
+// game is started through Steam, since they would only apply to the Steam
+// client, or, for system changes, be reverted as soon as Steam returns.
+func (l *Launcher) ignoredViaSteam() []string {
+	if l.Profile == nil {
+		return nil
+	}
+	var settings []string
+	if len(l.Profile.Wrappers) > 0 {
+		settings = append(settings, "wrappers")
+	}
+	if profile.IsTrue(l.Profile.Gamescope.Enabled) {
+		settings = append(settings, "gamescope")
+	}
+	if l.Profile.CPU.Affinity != "" {
+		settings = append(settings, "cpu.affinity")
+	}
+	if profile.IsTrue(l.Profile.CPU.Scope.Enabled) {
+		settings = append(settings, "cpu.scope")
+	}
+	settings = append(settings, systemChangeKeys(l.Profile)...)
+	for _, change := range l.Profile.ProcessChanges() {
+		settings = append(settings, change.Key)
+	}
+	return settings
+}
+
+func (l *Launcher) plannedLogging() *PlannedLogging {
+	settings := l.Profile.Logging
+	planned := &PlannedLogging{
+		Dir:     session.GameDir(l.Game.AppID),
+		Capture: profile.IsTrue(settings.Capture),
+		Keep:    settings.Keep(),
+	}
+	if profile.IsTrue(settings.Capture) {
+		planned.MaxSize = settings.MaxSize()
+	}
+	if profile.IsTrue(settings.Debug) {
+		for _, v := range debugLogVars(filepath.Join(planned.Dir, "<session>")) {
+			if l.Environment.Get(v[0]) == "" {
+				planned.Debug = append(planned.Debug, v[0]+"="+v[1])
+			}
+		}
+	}
+	return planned
+}
+
+func plannedConditions(result *profile.ConditionResult) *PlannedConditions {
+	planned := &PlannedConditions{Context: result.Context, Matched: []PlannedCondition{}}
+	for _, w := range result.Matched {
+		planned.Matched = append(planned.Matched, PlannedCondition{
+			Conditions: w.Conditions.String(),
+			Layer:      w.Layer,
+			Settings:   w.Set.Keys(),
+		})
+	}
+	return planned
+}
+
+func plannedHooks(event hook.Event, hooks []hook.Hook, source string) []PlannedHook {
+	planned := make([]PlannedHook, len(hooks))
+	for i, h := range hooks {
+		policy := h.OnFailure
+		if policy == "" {
+			policy = hook.FailWarn
+		}
+		planned[i] = PlannedHook{
+			Event:     string(event),
+			Command:   h.Command,
+			Timeout:   h.EffectiveTimeout().String(),
+			OnFailure: string(policy),
+			Source:    source,
+		}
+	}
+	return planned
+}
+
+// layer returns the profile layer, or the matched when block, that
+// supplied a setting. Origins that combine several settings are attributed
+// to the first one.
+func (l *Launcher) layer(setting string) string {
+	if setting == "" {
+		return ""
+	}
+	first, _, _ := strings.Cut(setting, ",")
+	if l.Profile != nil {
+		if source, ok := l.Profile.Conditions().Source(first); ok {
+			return source
+		}
+	}
+	if l.Resolution == nil {
+		return ""
+	}
+	return l.Resolution.Source(first)
+}
+
+func isRelevantEnv(key string) bool {
+	for _, prefix := range relevantEnvPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// command returns the full command line for args: the profile's game
+// arguments are inserted around the game's own arguments and the result is
+// wrapped in the profile's wrappers, outermost first, then in gamemoderun
+// when GameMode is used that way, and then in gamescope, so that the
+// wrappers run inside its session. Affinity, with symbolic values resolved
+// against the CPU topology, and the systemd scope apply to all of it.
+//
+// Without args the game is started through Steam. Wrappers, gamescope,
+// affinity and the scope are left out then, since they would only apply to
+// the Steam client.
+func (l *Launcher) command(args []string) []string {
+	if l.viaSteam(args) {
+		return SteamCommand(l.Game.AppID, l.Profile)
+	}
+	if l.Profile == nil {
+		return slices.Clone(args)
+	}
+
+	command := l.insertGameArgs(args)
+
+	wrappers, err := l.Profile.WrapperCommands()
+	if err != nil {
+		log.Printf("Warning: ignoring wrappers: %v", err)
+		wrappers = nil
+	}
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		command = append(slices.Clone(wrappers[i]), command...)
+	}
+	if l.resolveGameMode(false) == profile.GameModeMethodWrapper && !slices.ContainsFunc(wrappers, isGameModeWrapper) {
+		command = append([]string{gamemode.Wrapper}, command...)
+	}
+
+	if gamescope := l.Profile.GamescopeCommand(); gamescope != nil {
+		command = append(gamescope, command...)
+	}
+
+	if l.Profile.CPU.Affinity != "" {
+		if affinity, err := cpu.ResolveAffinity(l.Profile.CPU.Affinity); err != nil {
+			log.Printf("Warning: ignoring CPU affinity: %v", err)
+		} else {
+			command = append([]string{"taskset", "-c", affinity}, command...)
+		}
+	}
+
+	var description string
+	if l.Game != nil {
+		description = "spela: " + l.Game.Name
+	}
+	if scope := l.Profile.ScopeCommand(description); scope != nil {
+		command = append(scope, command...)
+	}
+	return command
+}
+
+func isGameModeWrapper(wrapper []string) bool {
+	return filepath.Base(wrapper[0]) == gamemode.Wrapper
+}
+
+// viaSteam reports whether launching args starts the game through Steam.
+func (l *Launcher) viaSteam(args []string) bool {
+	return len(args) == 0 && l.Game != nil
+}
+
+// insertGameArgs adds the profile's game arguments. Prepended arguments go
+// right after the game executable, found as the first argument that is a
+// path inside the game's install directory; when it cannot be found they
+// are added at the end, before the appended ones.
+func (l *Launcher) insertGameArgs(args []string) []string {
+	extra := l.Profile.Args
+	command := slices.Clone(args)
+	if len(extra.Prepend) == 0 && len(extra.Append) == 0 {
+		return command
+	}
+
+	position := len(command)
+	if l.Game != nil && l.Game.InstallDir != "" {
+		dir := filepath.Clean(l.Game.InstallDir)
+		for i, arg := range command {
+			if path := filepath.Clean(arg); path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				position = i + 1
+				break
+			}
+		}
+	}
+
+	command = slices.Insert(command, position, extra.Prepend...)
+	return append(command, extra.Append...)
+}
+
+// SteamCommand returns the command that asks Steam to start a game. Steam
+// only passes extra arguments to the game when launched with -applaunch, so
+// that form is used when the profile has any.
+func SteamCommand(appID uint64, p *profile.Profile) []string {
+	id := strconv.FormatUint(appID, 10)
+	if p == nil || len(p.Args.Prepend)+len(p.Args.Append) == 0 {
+		return []string{"steam", "steam://rungameid/" + id}
+	}
+
+	command := []string{"steam", "-applaunch", id}
+	command = append(command, p.Args.Prepend...)
+	return append(command, p.Args.Append...)
+}
@@ -0,0 +1,412 @@
+package launcher
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/env"
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/hook"
+	"github.com/jgabor/spela/internal/profile"
+	"github.com/jgabor/spela/internal/session"
+)
+
+func TestCommand(t *testing.T) {
+	g := &game.Game{AppID: 10, Name: "Game", InstallDir: "/games/Game"}
+	p := &profile.Profile{
+		Args:     profile.ArgsSettings{Prepend: []string{"-dx12"}, Append: []string{"-skipintro"}},
+		Wrappers: []string{"gamemoderun", "mangohud --dlsym"},
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "wrapper mode",
+			args: []string{"/proton", "waitforexitandrun", "/games/Game/game.exe", "-windowed"},
+			want: []string{"gamemoderun", "mangohud", "--dlsym", "/proton", "waitforexitandrun", "/games/Game/game.exe", "-dx12", "-windowed", "-skipintro"},
+		},
+		{
+			name: "sibling directory",
+			args: []string{"/games/GameLauncher/run", "/games/Game/./game.exe"},
+			want: []string{"gamemoderun", "mangohud", "--dlsym", "/games/GameLauncher/run", "/games/Game/./game.exe", "-dx12", "-skipintro"},
+		},
+		{
+			name: "executable not found",
+			args: []string{"/bin/run"},
+			want: []string{"gamemoderun", "mangohud", "--dlsym", "/bin/run", "-dx12", "-skipintro"},
+		},
+		{
+			name: "steam",
+			args: nil,
+			want: []string{"steam", "-applaunch", "10", "-dx12", "-skipintro"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(g)
+			l.Profile = p
+			if got := l.command(tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("command() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGamescopeCommand(t *testing.T) {
+	sharpness := 5
+	p := &profile.Profile{
+		Proton: profile.ProtonSettings{EnableHDR: profile.Bool(true)},
+		Gamescope: profile.GamescopeSettings{
+			Enabled:      profile.Bool(true),
+			OutputWidth:  3840,
+			OutputHeight: 2160,
+			NestedWidth:  2560,
+			NestedHeight: 1440,
+			Upscaler:     profile.GamescopeUpscalerFSR,
+			Sharpness:    &sharpness,
+			HDR:          profile.Bool(true),
+			Mode:         profile.GamescopeModeFullscreen,
+			FrameLimit:   60,
+			ExtraFlags:   []string{"--mangoapp"},
+		},
+		CPU:      profile.CPUSettings{Affinity: "0-7"},
+		Wrappers: []string{"mangohud"},
+	}
+
+	l := New(&game.Game{AppID: 10})
+	l.Profile = p
+	want := []string{
+		"taskset", "-c", "0-7",
+		"gamescope", "-W", "3840", "-H", "2160", "-w", "2560", "-h", "1440", "-F", "fsr", "--sharpness", "5",
+		"--hdr-enabled", "-f", "--framerate-limit", "60", "--mangoapp", "--",
+		"mangohud", "/bin/game",
+	}
+	if got := l.command([]string{"/bin/game"}); !slices.Equal(got, want) {
+		t.Errorf("command() = %q, want %q", got, want)
+	}
+	if got := l.command(nil); slices.Contains(got, "gamescope") {
+		t.Errorf("command() through Steam = %q, want no gamescope", got)
+	}
+
+	if warnings := l.Plan([]string{"/bin/game"}, false).Warnings; len(warnings) != 0 {
+		t.Errorf("Plan() warnings = %q, want none", warnings)
+	}
+	warnings := l.Plan(nil, false).Warnings
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "wrappers, gamescope, cpu.affinity ignored") {
+		t.Errorf("Plan() warnings through Steam = %q, want the ignored settings", warnings)
+	}
+}
+
+func TestPlanRealtimeWarning(t *testing.T) {
+	l := New(&game.Game{AppID: 10})
+	l.Profile = &profile.Profile{CPU: profile.CPUSettings{SchedPolicy: cpu.SchedRR}}
+
+	warnings := l.Plan([]string{"/bin/game"}, false).Warnings
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "cpu.sched_policy: rr is a realtime policy") {
+		t.Errorf("Plan() warnings = %q, want the realtime risk", warnings)
+	}
+	// Through Steam the policy is not applied, which is the warning then.
+	warnings = l.Plan(nil, false).Warnings
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "cpu.sched_policy ignored") {
+		t.Errorf("Plan() warnings through Steam = %q", warnings)
+	}
+}
+
+func TestSystemChangesOptIn(t *testing.T) {
+	l := New(&game.Game{AppID: 10})
+	l.Profile = &profile.Profile{CPU: profile.CPUSettings{Governor: "performance"}}
+
+	plan := l.Plan([]string{"/bin/game"}, false)
+	if len(plan.SystemChanges) != 0 {
+		t.Errorf("SystemChanges = %+v without apply_system_changes, want none", plan.SystemChanges)
+	}
+	if len(plan.Warnings) != 1 || !strings.HasPrefix(plan.Warnings[0], "cpu.governor not applied") {
+		t.Errorf("Warnings = %q, want the governor left alone", plan.Warnings)
+	}
+
+	l.ApplySystemChanges = true
+	if plan := l.Plan([]string{"/bin/game"}, false); len(plan.SystemChanges) != 1 || len(plan.Warnings) != 0 {
+		t.Errorf("Plan() with apply_system_changes = %+v, %q", plan.SystemChanges, plan.Warnings)
+	}
+
+	// Steam returns right away, so the changes would be undone at once.
+	plan = l.Plan(nil, false)
+	if len(plan.SystemChanges) != 0 {
+		t.Errorf("SystemChanges through Steam = %+v, want none", plan.SystemChanges)
+	}
+	if len(plan.Warnings) != 1 || !strings.HasPrefix(plan.Warnings[0], "cpu.governor ignored") {
+		t.Errorf("Warnings through Steam = %q", plan.Warnings)
+	}
+}
+
+func TestGameModeWrapper(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "gamemoderun"), []byte("#!/bin/sh\nexec \"$@\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	if err := os.WriteFile(filepath.Join(config, "gamemode.ini"), []byte("[general]\ndesiredgov=powersave\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	smt := false
+	p := &profile.Profile{
+		GameMode: profile.GameModeSettings{Enabled: profile.Bool(true), Method: profile.GameModeMethodWrapper},
+		CPU:      profile.CPUSettings{Governor: "performance", SMT: &smt},
+		Wrappers: []string{"mangohud"},
+	}
+	l := New(&game.Game{AppID: 10})
+	l.Profile = p
+	l.ApplySystemChanges = true
+
+	plan := l.Plan([]string{"/bin/game"}, false)
+	if want := []string{"gamemoderun", "mangohud", "/bin/game"}; !slices.Equal(plan.Command, want) {
+		t.Errorf("Command = %q, want %q", plan.Command, want)
+	}
+	if plan.GameMode != profile.GameModeMethodWrapper {
+		t.Errorf("GameMode = %q, want wrapper", plan.GameMode)
+	}
+
+	changes := make(map[string]PlannedChange)
+	for _, c := range plan.SystemChanges {
+		changes[c.Setting] = c
+	}
+	if c := changes["cpu.governor"]; !c.Deferred || c.Conflict != "desiredgov=powersave" {
+		t.Errorf("cpu.governor = %+v, want deferred to desiredgov=powersave", c)
+	}
+	if c := changes["cpu.smt"]; c.Deferred || c.Conflict != "" {
+		t.Errorf("cpu.smt = %+v, want no conflict", c)
+	}
+
+	p.GameMode.OnConflict = profile.GameModeConflictWarn
+	l = New(&game.Game{AppID: 10})
+	l.Profile = p
+	l.ApplySystemChanges = true
+	for _, c := range l.Plan([]string{"/bin/game"}, false).SystemChanges {
+		if c.Deferred {
+			t.Errorf("%s deferred with on_conflict warn", c.Setting)
+		}
+	}
+
+	if got := l.command(nil); slices.Contains(got, "gamemoderun") {
+		t.Errorf("command() through Steam = %q, want no gamemoderun", got)
+	}
+}
+
+func TestCommandWithoutProfile(t *testing.T) {
+	l := New(&game.Game{AppID: 10})
+	if got, want := l.command(nil), []string{"steam", "steam://rungameid/10"}; !slices.Equal(got, want) {
+		t.Errorf("command() = %q, want %q", got, want)
+	}
+	if got, want := l.command([]string{"/bin/game"}), []string{"/bin/game"}; !slices.Equal(got, want) {
+		t.Errorf("command() = %q, want %q", got, want)
+	}
+}
+
+func TestPreLaunchHookAbort(t *testing.T) {
+	l := New(&game.Game{AppID: 10, Name: "Game"})
+	l.Log = io.Discard
+	l.Hooks = hook.Settings{PreLaunch: []hook.Hook{{Command: "exit 1"}}}
+	l.Profile = &profile.Profile{Hooks: hook.Settings{PreLaunch: []hook.Hook{{Command: "exit 1", OnFailure: hook.FailAbort}}}}
+
+	cleaned := false
+	l.OnCleanup(func() { cleaned = true })
+
+	if err := l.Launch([]string{"true"}); err == nil {
+		t.Fatal("Launch() error = nil, want the aborting hook's failure")
+	}
+	if !cleaned {
+		t.Error("cleanups did not run after the launch was aborted")
+	}
+}
+
+func TestExitHooks(t *testing.T) {
+	dir := t.TempDir()
+	l := New(&game.Game{AppID: 10, Name: "Game"})
+	l.Log = io.Discard
+	l.Hooks = hook.Settings{
+		PostExit: []hook.Hook{{Command: "echo $SPELA_EXIT_CODE > " + dir + "/post_exit"}},
+		OnCrash:  []hook.Hook{{Command: "touch " + dir + "/on_crash"}},
+	}
+
+	if err := l.Launch([]string{"true"}); err != nil {
+		t.Fatalf("Launch() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "on_crash")); err == nil {
+		t.Error("crash hook ran after a clean exit")
+	}
+
+	if err := l.Launch([]string{"sh", "-c", "exit 3"}); err == nil {
+		t.Fatal("Launch() error = nil for a failing game")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "post_exit"))
+	if err != nil || string(data) != "3\n" {
+		t.Errorf("post_exit hook saw exit code %q (%v), want 3", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "on_crash")); err != nil {
+		t.Error("crash hook did not run after a failing exit")
+	}
+}
+
+// TestEnvPrecedence checks that profile variables override inherited ones
+// and wrapper arguments override both, in the child environment and in the
+// plan.
+func TestEnvPrecedence(t *testing.T) {
+	e := env.NewFrom([]string{
+		"PROTON_ENABLE_HDR=0",
+		"DXVK_HUD=fps",
+		"WINEDLLOVERRIDES=d3d11=n",
+		"PROTON_LOG=",
+		"HOME=/home/me",
+	})
+	p := &profile.Profile{
+		Proton: profile.ProtonSettings{EnableHDR: profile.Bool(true), EnableWayland: profile.Bool(true)},
+		Env: map[string]profile.EnvVar{
+			"DXVK_HUD":         {Unset: true},
+			"WINEDLLOVERRIDES": {Append: "dxgi=n,b", Separator: ";"},
+			"PROTON_LOG":       {Value: "1"},
+		},
+	}
+	p.Apply(e)
+	e.Annotate(OriginWrapper, func() {
+		e.Set("PROTON_ENABLE_WAYLAND", "0")
+	})
+
+	want := []string{
+		"PROTON_ENABLE_HDR=1",
+		"WINEDLLOVERRIDES=d3d11=n;dxgi=n,b",
+		"PROTON_LOG=1",
+		"HOME=/home/me",
+		"PROTON_ENABLE_WAYLAND=0",
+		"__GL_THREADED_OPTIMIZATION=0",
+	}
+	if got := e.BuildEnv(); !slices.Equal(got, want) {
+		t.Errorf("BuildEnv() = %q, want %q", got, want)
+	}
+
+	l := New(nil)
+	l.Profile = p
+	l.Environment = e
+	plan := l.Plan([]string{"/bin/game"}, false)
+
+	vars := make(map[string]PlannedEnvVar)
+	for _, v := range plan.Env {
+		vars[v.Key] = v
+	}
+	tests := []struct {
+		key       string
+		source    string
+		setting   string
+		overrides string
+		unset     bool
+	}{
+		{key: "PROTON_ENABLE_HDR", source: SourceProfile, setting: "proton.enable_hdr", overrides: "0"},
+		{key: "PROTON_ENABLE_WAYLAND", source: SourceWrapper},
+		{key: "DXVK_HUD", source: SourceProfile, setting: "env.DXVK_HUD", overrides: "fps", unset: true},
+		{key: "WINEDLLOVERRIDES", source: SourceProfile, setting: "env.WINEDLLOVERRIDES", overrides: "d3d11=n"},
+		{key: "PROTON_LOG", source: SourceProfile, setting: "env.PROTON_LOG", overrides: ""},
+	}
+	for _, tt := range tests {
+		v, ok := vars[tt.key]
+		if !ok {
+			t.Errorf("%s missing from plan", tt.key)
+			continue
+		}
+		if v.Source != tt.source || v.Setting != tt.setting || v.Unset != tt.unset {
+			t.Errorf("%s = %+v, want source %s, setting %q, unset %v", tt.key, v, tt.source, tt.setting, tt.unset)
+		}
+		if tt.source == SourceProfile && (v.Overrides == nil || *v.Overrides != tt.overrides) {
+			t.Errorf("%s overrides %v, want %q", tt.key, v.Overrides, tt.overrides)
+		}
+	}
+	if _, ok := vars["HOME"]; ok {
+		t.Error("unrelated inherited variable HOME shown without allEnv")
+	}
+}
+
+func TestRecordSession(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	g := &game.Game{AppID: 10, Name: "Game", DLLs: []game.DetectedDLL{{Name: "nvngx_dlss.dll", Type: game.DLLTypeDLSS, Version: "3.7.10"}}}
+	l := New(g)
+	l.Log = io.Discard
+	l.Profile = &profile.Profile{}
+	l.Environment = env.NewFrom(nil)
+	l.Environment.Set("PROTON_LOG", "1")
+	l.RecordSession = true
+
+	if err := l.Launch([]string{"true"}); err != nil {
+		t.Fatalf("Launch() error = %v", err)
+	}
+	_ = l.Launch([]string{"sh", "-c", "kill -TERM $$"})
+	_ = l.Launch([]string{"/nonexistent/game"})
+
+	sessions, err := session.List(10)
+	if err != nil {
+		t.Fatalf("session.List() error = %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("recorded %d sessions, want 3", len(sessions))
+	}
+
+	first := sessions[0]
+	if first.Number != 1 || first.ExitCode == nil || *first.ExitCode != 0 || !first.Finished() {
+		t.Errorf("first session = %+v, want a clean exit", first)
+	}
+	if first.Profile.Hash == "" || first.Env["PROTON_LOG"] != "1" || len(first.DLLs) != 1 || first.DLLs[0].Version != "3.7.10" {
+		t.Errorf("first session did not record its settings: %+v", first)
+	}
+	if got := sessions[1].Status(); got != "killed by SIGTERM" {
+		t.Errorf("second session status = %q, want killed by SIGTERM", got)
+	}
+	if got := sessions[2]; got.Error == "" || got.Duration() != 0 {
+		t.Errorf("third session = %+v, want a failure to start", got)
+	}
+}
+
+func TestCaptureGameOutput(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	l := New(&game.Game{AppID: 10, Name: "Game"})
+	l.Log = io.Discard
+	l.Profile = &profile.Profile{
+		Logging: profile.LoggingSettings{Capture: profile.Bool(true), Debug: profile.Bool(true)},
+		Env:     map[string]profile.EnvVar{"DXVK_LOG_PATH": {Value: "/elsewhere"}},
+		Hooks: hook.Settings{
+			PreLaunch: []hook.Hook{{Command: "echo ready"}},
+			PostExit:  []hook.Hook{{Command: "echo done"}},
+		},
+	}
+	l.Environment = env.NewFrom(nil)
+	l.Profile.Apply(l.Environment)
+	l.RecordSession = true
+
+	if err := l.Launch([]string{"sh", "-c", "echo out; echo err >&2; echo $PROTON_LOG_DIR $DXVK_LOG_PATH"}); err != nil {
+		t.Fatalf("Launch() error = %v", err)
+	}
+
+	sessions, err := session.List(10)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("session.List() = %v, %v; want one session", sessions, err)
+	}
+	dir := sessions[0].Dir()
+	data, err := os.ReadFile(filepath.Join(dir, session.LogName))
+	if err != nil {
+		t.Fatalf("game log not written: %v", err)
+	}
+	want := "[pre_launch] ready\nout\nerr\n" + dir + " /elsewhere\n[post_exit] done\n"
+	if string(data) != want {
+		t.Errorf("game log = %q, want %q", data, want)
+	}
+}
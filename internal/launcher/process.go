@@ -0,0 +1,163 @@
+package launcher
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgabor/spela/internal/profile"
+)
+
+// gameProcessTimeout is how long to look for the game's own process among
+// the processes of the launched command, such as Steam's reaper and Proton.
+const gameProcessTimeout = 2 * time.Minute
+
+var gameProcessPoll = 500 * time.Millisecond
+
+var windowsDrive = regexp.MustCompile(`^[A-Za-z]:`)
+
+// gameProcess waits for the game's main process to appear under pid. When
+// the process cannot be told apart, it returns pid itself. It reports false
+// if the command exited first.
+func (l *Launcher) gameProcess(pid int, exited <-chan struct{}) (int, bool) {
+	if l.Game == nil || l.Game.InstallDir == "" {
+		return pid, true
+	}
+	found, ok := waitForGameProcess(pid, l.Game.InstallDir, exited)
+	switch {
+	case ok:
+		return found, true
+	case isClosed(exited):
+		return 0, false
+	}
+	log.Printf("Warning: could not find the game's process within %s; using the launched command, process %d (%s), instead",
+		gameProcessTimeout, pid, processName(pid))
+	return pid, true
+}
+
+// processName returns the command name of process pid, or "unknown" when it
+// cannot be read.
+func processName(pid int) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// applyProcessChanges applies the profile's process changes to the game's
+// process.
+func (l *Launcher) applyProcessChanges(changes []profile.ProcessChange, target int) {
+	for _, change := range changes {
+		if err := change.Apply(target); err != nil {
+			log.Printf("Warning: %s: %v", change.Description, err)
+			continue
+		}
+		log.Printf("%s for process %d", change.Description, target)
+	}
+}
+
+// waitForGameProcess polls for the game's process until it shows up, the
+// command exits, or gameProcessTimeout passes.
+func waitForGameProcess(root int, installDir string, exited <-chan struct{}) (int, bool) {
+	timeout := time.After(gameProcessTimeout)
+	for {
+		if pid, ok := findGameProcess(root, installDir); ok {
+			return pid, true
+		}
+		select {
+		case <-exited:
+			return 0, false
+		case <-timeout:
+			return 0, false
+		case <-time.After(gameProcessPoll):
+		}
+	}
+}
+
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// findGameProcess returns the first process, searching root and then its
+// descendants breadth first, whose executable lies in installDir. Wine
+// processes are matched by the Windows path of the program they run.
+func findGameProcess(root int, installDir string) (int, bool) {
+	installDir = filepath.Clean(installDir) + "/"
+	children := processChildren()
+
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if runsFrom(pid, installDir) {
+			return pid, true
+		}
+		queue = append(queue, children[pid]...)
+	}
+	return 0, false
+}
+
+// processChildren maps every running process to its children.
+func processChildren() map[int][]int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if ppid, ok := parentPID(pid); ok {
+			children[ppid] = append(children[ppid], pid)
+		}
+	}
+	return children
+}
+
+func parentPID(pid int) (int, bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+	// The command name in parentheses may contain spaces; the fields after
+	// it are state and parent PID.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	return ppid, err == nil
+}
+
+func runsFrom(pid int, installDir string) bool {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil && strings.HasPrefix(exe, installDir) {
+		return true
+	}
+
+	cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return false
+	}
+	program, _, _ := bytes.Cut(cmdline, []byte{0})
+	path := strings.ReplaceAll(string(program), `\`, "/")
+	path = windowsDrive.ReplaceAllString(path, "")
+	return strings.HasPrefix(path, installDir)
+}
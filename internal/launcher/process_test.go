@@ -0,0 +1,49 @@
+package launcher
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindGameProcess(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	installDir := t.TempDir()
+	game := filepath.Join(installDir, "game")
+	if err := os.Symlink(sleep, game); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cmd  *exec.Cmd
+	}{
+		{"native", exec.Command("sh", "-c", game+" 10; true")},
+		{"wine", exec.Command("bash", "-c", `(exec -a "Z:`+strings.ReplaceAll(installDir, "/", `\`)+`\game.exe" sleep 10); true`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cmd.Start(); err != nil {
+				t.Skipf("cannot start %s: %v", tt.cmd.Path, err)
+			}
+			t.Cleanup(func() {
+				_ = tt.cmd.Process.Kill()
+				_ = tt.cmd.Wait()
+			})
+
+			exited := make(chan struct{})
+			pid, ok := waitForGameProcess(tt.cmd.Process.Pid, installDir, exited)
+			if !ok {
+				t.Fatal("game process not found")
+			}
+			if pid == tt.cmd.Process.Pid {
+				t.Errorf("found the launched shell %d instead of the game", pid)
+			}
+		})
+	}
+}
@@ -2,92 +2,132 @@ package profile
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/jgabor/spela/internal/env"
 	"github.com/jgabor/spela/internal/xdg"
 )
 
-func (p *Profile) Apply(e *env.Environment) []func() {
-	var cleanup []func()
+// Apply sets the profile's environment in e. The profile's when blocks are
+// evaluated against the running system first, and the ones that match are
+// merged into p, so later uses of p see the same settings.
+func (p *Profile) Apply(e *env.Environment) {
+	p.evaluateConditions()
 
-	cleanup = append(cleanup, p.applyProton(e)...)
-	cleanup = append(cleanup, p.applyDLSS(e)...)
-	cleanup = append(cleanup, p.applyGPU(e)...)
+	p.applyProton(e)
+	p.applyDLSS(e)
+	p.applyGPU(e)
+	p.applyEnv(e)
+}
 
-	return cleanup
+// applyEnv applies the custom variables last, so they can override the ones
+// spela derives from other settings.
+func (p *Profile) applyEnv(e *env.Environment) {
+	for _, key := range slices.Sorted(maps.Keys(p.Env)) {
+		e.Annotate("env."+key, func() {
+			current, _ := e.Lookup(key)
+			if value, ok := p.Env[key].Resolve(current); ok {
+				e.Set(key, value)
+			} else {
+				e.Unset(key)
+			}
+		})
+	}
 }
 
-func (p *Profile) applyProton(e *env.Environment) []func() {
-	if p.Proton.EnableWayland {
-		e.EnableWayland()
+func (p *Profile) applyProton(e *env.Environment) {
+	if IsTrue(p.Proton.EnableWayland) {
+		e.Annotate("proton.enable_wayland", e.EnableWayland)
 	}
-	if p.Proton.EnableHDR {
-		e.EnableHDR()
+	if IsTrue(p.Proton.EnableHDR) {
+		e.Annotate("proton.enable_hdr", e.EnableHDR)
 	}
-	if p.Proton.EnableNGXUpdater {
-		e.EnableNGXUpdater()
+	if IsTrue(p.Proton.EnableNGXUpdater) {
+		e.Annotate("proton.enable_ngx_updater", e.EnableNGXUpdater)
 	}
-	return nil
 }
 
-func (p *Profile) applyDLSS(e *env.Environment) []func() {
-	if p.DLSS.SROverride {
-		e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE", "on")
+func (p *Profile) applyDLSS(e *env.Environment) {
+	if IsTrue(p.DLSS.SROverride) {
+		e.Annotate("dlss.sr_override", func() {
+			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE", "on")
+		})
 		if p.DLSS.SRMode != "" {
-			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_MODE", dlssModeToEnv(p.DLSS.SRMode))
+			e.Annotate("dlss.sr_mode", func() {
+				e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_MODE", dlssModeToEnv(p.DLSS.SRMode))
+			})
 		}
-		if p.DLSS.SRModelPreset != "" {
+		if p.DLSS.SRModelPreset != "" && p.DLSS.SRModelPreset != DLSSModelPresetOff {
 			preset := resolveModelPreset(p.DLSS.SRModelPreset, p.DLSS.SRMode)
-			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE_RENDER_PRESET_SELECTION", dlssModelPresetToEnv(preset))
+			e.Annotate("dlss.sr_model_preset", func() {
+				e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE_RENDER_PRESET_SELECTION", dlssModelPresetToEnv(preset))
+			})
 		} else if p.DLSS.SRPreset != "" {
-			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE_RENDER_PRESET_SELECTION", dlssPresetToEnv(p.DLSS.SRPreset))
+			e.Annotate("dlss.sr_preset", func() {
+				e.Set("DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE_RENDER_PRESET_SELECTION", dlssPresetToEnv(p.DLSS.SRPreset))
+			})
 		}
 	}
 
-	if p.DLSS.RROverride {
-		e.Set("DXVK_NVAPI_DRS_NGX_DLSS_RR_OVERRIDE", "on")
+	if IsTrue(p.DLSS.RROverride) {
+		e.Annotate("dlss.rr_override", func() {
+			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_RR_OVERRIDE", "on")
+		})
 		if p.DLSS.RRMode != "" {
-			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_RR_MODE", dlssModeToEnv(p.DLSS.RRMode))
+			e.Annotate("dlss.rr_mode", func() {
+				e.Set("DXVK_NVAPI_DRS_NGX_DLSS_RR_MODE", dlssModeToEnv(p.DLSS.RRMode))
+			})
 		}
 		if p.DLSS.RRPreset != "" {
-			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_RR_OVERRIDE_RENDER_PRESET_SELECTION", dlssPresetToEnv(p.DLSS.RRPreset))
+			e.Annotate("dlss.rr_preset", func() {
+				e.Set("DXVK_NVAPI_DRS_NGX_DLSS_RR_OVERRIDE_RENDER_PRESET_SELECTION", dlssPresetToEnv(p.DLSS.RRPreset))
+			})
 		}
 	}
 
-	if p.DLSS.FGOverride {
-		e.Set("DXVK_NVAPI_DRS_NGX_DLSS_FG_OVERRIDE", "on")
-		if p.DLSS.FGEnabled {
-			e.Set("DXVK_NVAPI_DRS_NGX_DLSSG_MULTI_FRAME_COUNT", fmt.Sprintf("%d", p.DLSS.MultiFrame))
+	if IsTrue(p.DLSS.FGOverride) {
+		e.Annotate("dlss.fg_override", func() {
+			e.Set("DXVK_NVAPI_DRS_NGX_DLSS_FG_OVERRIDE", "on")
+		})
+		if IsTrue(p.DLSS.FGEnabled) {
+			e.Annotate("dlss.multi_frame", func() {
+				e.Set("DXVK_NVAPI_DRS_NGX_DLSSG_MULTI_FRAME_COUNT", fmt.Sprintf("%d", IntValue(p.DLSS.MultiFrame)))
+			})
 		}
 	}
 
-	var debugOpts []string
-	if p.DLSS.Indicator {
+	var debugOpts, debugKeys []string
+	if IsTrue(p.DLSS.Indicator) {
 		debugOpts = append(debugOpts, "DLSSIndicator=1024")
+		debugKeys = append(debugKeys, "dlss.indicator")
 	}
-	if p.DLSS.FGIndicator {
+	if IsTrue(p.DLSS.FGIndicator) {
 		debugOpts = append(debugOpts, "DLSSGIndicator=2")
+		debugKeys = append(debugKeys, "dlss.fg_indicator")
 	}
 	if len(debugOpts) > 0 {
-		e.Set("DXVK_NVAPI_SET_NGX_DEBUG_OPTIONS", strings.Join(debugOpts, ","))
+		e.Annotate(strings.Join(debugKeys, ","), func() {
+			e.Set("DXVK_NVAPI_SET_NGX_DEBUG_OPTIONS", strings.Join(debugOpts, ","))
+		})
 	}
-
-	return nil
 }
 
-func (p *Profile) applyGPU(e *env.Environment) []func() {
-	if p.GPU.ShaderCache {
+func (p *Profile) applyGPU(e *env.Environment) {
+	if IsTrue(p.GPU.ShaderCache) {
 		cachePath := p.GPU.ShaderCachePath
 		if cachePath == "" {
 			cachePath = xdg.CachePath("nvidia")
 		}
-		e.SetShaderCache(cachePath)
+		e.Annotate("gpu.shader_cache", func() {
+			e.SetShaderCache(cachePath)
+		})
 	}
 
-	e.SetThreadedOptimization(p.GPU.ThreadedOptimization)
-
-	return nil
+	e.Annotate("gpu.threaded_optimization", func() {
+		e.SetThreadedOptimization(IsTrue(p.GPU.ThreadedOptimization))
+	})
 }
 
 func dlssModeToEnv(mode DLSSMode) string {
@@ -3,6 +3,7 @@ package profile_test
 import (
 	"testing"
 
+	"github.com/jgabor/spela/internal/cpu"
 	"github.com/jgabor/spela/internal/env"
 	"github.com/jgabor/spela/internal/profile"
 )
@@ -30,7 +31,7 @@ func TestModelPresetSelection(t *testing.T) {
 				DLSS: profile.DLSSSettings{
 					SRMode:        tt.mode,
 					SRModelPreset: tt.model,
-					SROverride:    true,
+					SROverride:    profile.Bool(true),
 				},
 			}
 			e := env.New()
@@ -42,3 +43,70 @@ func TestModelPresetSelection(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyRecordsOrigins(t *testing.T) {
+	p := &profile.Profile{
+		DLSS: profile.DLSSSettings{
+			SROverride:  profile.Bool(true),
+			SRMode:      profile.DLSSModeQuality,
+			Indicator:   profile.Bool(true),
+			FGIndicator: profile.Bool(true),
+		},
+		Proton: profile.ProtonSettings{EnableHDR: profile.Bool(true)},
+	}
+	e := env.New()
+	p.Apply(e)
+
+	tests := map[string]string{
+		"DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE": "dlss.sr_override",
+		"DXVK_NVAPI_DRS_NGX_DLSS_SR_MODE":     "dlss.sr_mode",
+		"DXVK_NVAPI_SET_NGX_DEBUG_OPTIONS":    "dlss.indicator,dlss.fg_indicator",
+		"PROTON_ENABLE_HDR":                   "proton.enable_hdr",
+		"__GL_THREADED_OPTIMIZATION":          "gpu.threaded_optimization",
+	}
+	for key, want := range tests {
+		if got := e.Origin(key); got != want {
+			t.Errorf("Origin(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSystemChanges(t *testing.T) {
+	smt := false
+	p := &profile.Profile{
+		CPU: profile.CPUSettings{Governor: "performance", SMT: &smt, Scheduler: "lavd"},
+		GPU: profile.GPUSettings{ClockOffset: profile.Int(150), PowerMizer: "auto"},
+	}
+
+	var keys []string
+	for _, change := range p.SystemChanges() {
+		keys = append(keys, change.Key)
+	}
+	want := []string{"cpu.governor", "cpu.smt", "cpu.scheduler", "gpu.clock_offset"}
+	if len(keys) != len(want) {
+		t.Fatalf("SystemChanges keys = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("SystemChanges keys = %v, want %v", keys, want)
+		}
+	}
+}
+
+func TestSchedulerChange(t *testing.T) {
+	tests := []struct {
+		settings profile.CPUSettings
+		want     string
+	}{
+		{profile.CPUSettings{Scheduler: "lavd"}, "Switch sched_ext scheduler to scx_lavd"},
+		{profile.CPUSettings{Scheduler: "scx_bpfland", SchedulerMode: cpu.SCXModeGaming}, "Switch sched_ext scheduler to scx_bpfland (gaming mode)"},
+		{profile.CPUSettings{Scheduler: profile.SchedulerNone}, "Stop the sched_ext scheduler"},
+	}
+	for _, tt := range tests {
+		p := &profile.Profile{CPU: tt.settings}
+		changes := p.SystemChanges()
+		if len(changes) != 1 || changes[0].Description != tt.want {
+			t.Errorf("SystemChanges() for %+v = %+v, want %q", tt.settings, changes, tt.want)
+		}
+	}
+}
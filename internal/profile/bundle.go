@@ -0,0 +1,424 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/hook"
+)
+
+// BundleFormatVersion is the version of the bundle file layout, independent
+// of the profile schema version of the profiles inside it.
+const BundleFormatVersion = 1
+
+// Bundle is a portable collection of profiles that can be moved between
+// machines. Profiles are keyed by Steam app ID, which is the same everywhere.
+type Bundle struct {
+	Format       int                 `yaml:"format"`
+	SpelaVersion string              `yaml:"spela_version,omitempty"`
+	ExportedAt   time.Time           `yaml:"exported_at"`
+	Default      *Profile            `yaml:"default,omitempty"`
+	Bases        map[string]*Profile `yaml:"bases,omitempty"`
+	Profiles     []BundleProfile     `yaml:"profiles,omitempty"`
+}
+
+type BundleProfile struct {
+	AppID   uint64   `yaml:"app_id"`
+	Game    string   `yaml:"game"`
+	Profile *Profile `yaml:"profile"`
+	// DLLs pins the DLL version per type (dlss, dlssg, ...) that the
+	// profile was tuned with.
+	DLLs map[string]string `yaml:"dlls,omitempty"`
+}
+
+type ConflictMode string
+
+const (
+	ConflictSkip      ConflictMode = "skip"
+	ConflictOverwrite ConflictMode = "overwrite"
+	ConflictMerge     ConflictMode = "merge"
+)
+
+// Import actions reported in ImportResult.
+const (
+	ImportCreated     = "created"
+	ImportSkipped     = "skipped"
+	ImportOverwritten = "overwritten"
+	ImportMerged      = "merged"
+	ImportUnchanged   = "unchanged"
+)
+
+type ImportResult struct {
+	// Target is "default", "base:<name>" or "game:<appid>".
+	Target string
+	Game   string
+	Action string
+}
+
+func NewBundle(spelaVersion string) *Bundle {
+	return &Bundle{
+		Format:       BundleFormatVersion,
+		SpelaVersion: spelaVersion,
+		ExportedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// Portable returns a copy of p without settings that only make sense on the
+// machine it was created on.
+func (p *Profile) Portable() *Profile {
+	portable := *p
+	portable.GPU.ShaderCachePath = ""
+	if !slices.Contains(cpu.AffinityPresets, cpu.AffinityPreset(p.CPU.Affinity)) {
+		portable.CPU.Affinity = ""
+	}
+	return &portable
+}
+
+// codeLoadingEnvVars make the dynamic loader, the Vulkan loader or Wine load
+// libraries, layers or DLLs from the paths they name.
+var codeLoadingEnvVars = []string{
+	"LD_PRELOAD", "LD_AUDIT", "LD_LIBRARY_PATH",
+	"VK_LAYER_PATH", "VK_ADD_LAYER_PATH", "VK_INSTANCE_LAYERS",
+	"VK_DRIVER_FILES", "VK_ADD_DRIVER_FILES", "VK_ICD_FILENAMES",
+	"WINEDLLOVERRIDES", "WINEDLLPATH",
+}
+
+// BundleCommand is a command that a profile in a bundle runs at launch.
+type BundleCommand struct {
+	// Target is "default", "base:<name>" or "game:<appid>", as in
+	// ImportResult.
+	Target string
+	Game   string
+	// Key is the setting holding the command, such as "hooks.pre_launch"
+	// or "when[1].wrappers".
+	Key     string
+	Command string
+}
+
+// Commands lists the hooks, wrappers and code-loading environment variables
+// of the profiles in b, including those in when blocks. Importing a bundle
+// makes them run with the user's privileges at the next launch, so they
+// should be reviewed first.
+func (b *Bundle) Commands() ([]BundleCommand, error) {
+	var commands []BundleCommand
+	add := func(target, gameName string, p *Profile) error {
+		found, err := p.commands()
+		if err != nil {
+			return fmt.Errorf("%s: %w", target, err)
+		}
+		for _, c := range found {
+			c.Target, c.Game = target, gameName
+			commands = append(commands, c)
+		}
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(b.Bases)) {
+		if err := add(baseLayer(name), "", b.Bases[name]); err != nil {
+			return nil, err
+		}
+	}
+	if b.Default != nil {
+		if err := add(LayerDefault, "", b.Default); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range b.Profiles {
+		if err := add(GameLayer(entry.AppID), entry.Game, entry.Profile); err != nil {
+			return nil, err
+		}
+	}
+	return commands, nil
+}
+
+func (p *Profile) commands() ([]BundleCommand, error) {
+	var commands []BundleCommand
+	var collect func(prefix string, p *Profile)
+	collect = func(prefix string, p *Profile) {
+		for _, event := range hook.Events() {
+			for _, h := range p.Hooks.For(event) {
+				commands = append(commands, BundleCommand{Key: prefix + "hooks." + string(event), Command: h.Command})
+			}
+		}
+		for _, w := range p.Wrappers {
+			commands = append(commands, BundleCommand{Key: prefix + "wrappers", Command: w})
+		}
+		for _, name := range codeLoadingEnvVars {
+			if v, ok := p.Env[name]; ok && !v.Unset {
+				commands = append(commands, BundleCommand{Key: prefix + "env." + name, Command: v.String()})
+			}
+		}
+	}
+
+	collect("", p)
+	for i, w := range p.When {
+		settings, err := w.Set.Profile()
+		if err != nil {
+			return nil, fmt.Errorf("when block %d: %w", i+1, err)
+		}
+		collect(fmt.Sprintf("when[%d].", i+1), settings)
+	}
+	return commands, nil
+}
+
+// SetDefault adds the default profile and the bases it extends.
+func (b *Bundle) SetDefault(p *Profile) error {
+	b.Default = p.Portable()
+	return b.addBases(p.Extends)
+}
+
+// Add adds a game profile and the bases it extends.
+func (b *Bundle) Add(appID uint64, gameName string, p *Profile, dlls map[string]string) error {
+	b.Profiles = append(b.Profiles, BundleProfile{
+		AppID:   appID,
+		Game:    gameName,
+		Profile: p.Portable(),
+		DLLs:    dlls,
+	})
+	return b.addBases(p.Extends)
+}
+
+func (b *Bundle) addBases(names []string) error {
+	for _, name := range names {
+		if _, ok := b.Bases[name]; ok {
+			continue
+		}
+
+		base, err := LoadBase(name)
+		if err != nil {
+			return err
+		}
+		if base == nil {
+			return fmt.Errorf("base profile not found: %s", name)
+		}
+
+		if b.Bases == nil {
+			b.Bases = make(map[string]*Profile)
+		}
+		b.Bases[name] = base.Portable()
+
+		if err := b.addBases(base.Extends); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteBundle(path string, b *Bundle) error {
+	for _, p := range b.allProfiles() {
+		p.SchemaVersion = CurrentSchemaVersion
+	}
+
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (b *Bundle) allProfiles() []*Profile {
+	var profiles []*Profile
+	if b.Default != nil {
+		profiles = append(profiles, b.Default)
+	}
+	for _, base := range b.Bases {
+		profiles = append(profiles, base)
+	}
+	for _, entry := range b.Profiles {
+		profiles = append(profiles, entry.Profile)
+	}
+	return profiles
+}
+
+// ReadBundle reads a bundle and decodes every profile in it with the same
+// strict rules and migrations as profile files.
+func ReadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Format       int                  `yaml:"format"`
+		SpelaVersion string               `yaml:"spela_version"`
+		ExportedAt   time.Time            `yaml:"exported_at"`
+		Default      yaml.Node            `yaml:"default"`
+		Bases        map[string]yaml.Node `yaml:"bases"`
+		Profiles     []struct {
+			AppID   uint64            `yaml:"app_id"`
+			Game    string            `yaml:"game"`
+			Profile yaml.Node         `yaml:"profile"`
+			DLLs    map[string]string `yaml:"dlls"`
+		} `yaml:"profiles"`
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if raw.Format == 0 {
+		return nil, fmt.Errorf("%s: not a spela profile bundle", path)
+	}
+	if raw.Format > BundleFormatVersion {
+		return nil, fmt.Errorf("%s: bundle format %d is newer than this version of spela supports (%d)", path, raw.Format, BundleFormatVersion)
+	}
+
+	b := &Bundle{Format: raw.Format, SpelaVersion: raw.SpelaVersion, ExportedAt: raw.ExportedAt}
+
+	if !raw.Default.IsZero() {
+		if b.Default, err = decodeBundleProfile(path+": default", &raw.Default); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, node := range raw.Bases {
+		if !baseNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s: invalid base profile name %q", path, name)
+		}
+		p, err := decodeBundleProfile(path+": base "+name, &node)
+		if err != nil {
+			return nil, err
+		}
+		if b.Bases == nil {
+			b.Bases = make(map[string]*Profile)
+		}
+		b.Bases[name] = p
+	}
+
+	for _, entry := range raw.Profiles {
+		if entry.AppID == 0 {
+			return nil, fmt.Errorf("%s: profile for %q has no app_id", path, entry.Game)
+		}
+		p, err := decodeBundleProfile(fmt.Sprintf("%s: %s (%d)", path, entry.Game, entry.AppID), &entry.Profile)
+		if err != nil {
+			return nil, err
+		}
+		b.Profiles = append(b.Profiles, BundleProfile{AppID: entry.AppID, Game: entry.Game, Profile: p, DLLs: entry.DLLs})
+	}
+
+	return b, nil
+}
+
+func decodeBundleProfile(name string, node *yaml.Node) (*Profile, error) {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	p, _, err := parseProfile(name, data)
+	return p, err
+}
+
+// Import writes the profiles of b to disk. Existing profiles are handled
+// according to mode: skip keeps them, overwrite replaces them, and merge
+// overlays the settings from the bundle onto them field by field.
+func (b *Bundle) Import(mode ConflictMode) ([]ImportResult, error) {
+	switch mode {
+	case ConflictSkip, ConflictOverwrite, ConflictMerge:
+	default:
+		return nil, fmt.Errorf("invalid conflict mode: %s (expected skip, overwrite or merge)", mode)
+	}
+
+	var results []ImportResult
+	importOne := func(target, gameName, path string, incoming *Profile, save func(*Profile) error) error {
+		action, err := importProfile(path, incoming, mode, save)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", target, err)
+		}
+		results = append(results, ImportResult{Target: target, Game: gameName, Action: action})
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(b.Bases)) {
+		save := func(p *Profile) error { return SaveBase(name, p) }
+		if err := importOne(baseLayer(name), "", basePath(name), b.Bases[name], save); err != nil {
+			return results, err
+		}
+	}
+	if b.Default != nil {
+		if err := importOne(LayerDefault, "", defaultProfilePath(), b.Default, SaveDefault); err != nil {
+			return results, err
+		}
+	}
+	for _, entry := range b.Profiles {
+		save := func(p *Profile) error { return Save(entry.AppID, p) }
+		if err := importOne(GameLayer(entry.AppID), entry.Game, profilePath(entry.AppID), entry.Profile, save); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func importProfile(path string, incoming *Profile, mode ConflictMode, save func(*Profile) error) (string, error) {
+	existing, err := loadFile(path)
+	if err != nil {
+		// An unreadable profile can still be replaced wholesale.
+		if mode != ConflictOverwrite {
+			return "", err
+		}
+		return ImportOverwritten, save(incoming)
+	}
+
+	switch {
+	case existing == nil:
+		return ImportCreated, save(incoming)
+	case existing != nil && reflect.DeepEqual(existing, incoming):
+		return ImportUnchanged, nil
+	case mode == ConflictSkip:
+		return ImportSkipped, nil
+	case mode == ConflictOverwrite:
+		return ImportOverwritten, save(incoming)
+	}
+
+	merged, err := mergeProfiles(existing, incoming)
+	if err != nil {
+		return "", err
+	}
+	return ImportMerged, save(merged)
+}
+
+// mergeProfiles overlays the fields set in overlay onto base.
+func mergeProfiles(base, overlay *Profile) (*Profile, error) {
+	values, err := profileValues(base)
+	if err != nil {
+		return nil, err
+	}
+	overlayValues, err := profileValues(overlay)
+	if err != nil {
+		return nil, err
+	}
+	mergeValues(values, overlayValues, "", "", make(map[string]string))
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var merged Profile
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	return &merged, nil
+}
+
+func profileValues(p *Profile) (map[string]any, error) {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
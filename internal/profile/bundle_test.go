@@ -0,0 +1,253 @@
+package profile_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"bases/nvidia.yaml": `
+dlss:
+  sr_override: true
+`,
+		"10.yaml": `
+name: Game
+extends: nvidia
+dlss:
+  sr_mode: quality
+gpu:
+  shader_cache: true
+  shader_cache_path: /home/me/cache
+cpu:
+  affinity: 0-7
+`,
+	})
+
+	p, err := profile.Load(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := profile.NewBundle("1.2.3")
+	if err := b.SetDefault(&profile.Profile{Preset: "balanced"}); err != nil {
+		t.Fatalf("SetDefault: %v", err)
+	}
+	if err := b.Add(10, "Game", p, map[string]string{"dlss": "310.2.1"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	if err := profile.WriteBundle(path, b); err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "/home/me/cache") || strings.Contains(string(data), "affinity") {
+		t.Errorf("bundle contains machine-specific settings:\n%s", data)
+	}
+
+	got, err := profile.ReadBundle(path)
+	if err != nil {
+		t.Fatalf("ReadBundle: %v", err)
+	}
+	if got.SpelaVersion != "1.2.3" {
+		t.Errorf("SpelaVersion = %q, want 1.2.3", got.SpelaVersion)
+	}
+	if got.Default == nil || got.Default.Preset != "balanced" {
+		t.Errorf("Default = %+v, want preset balanced", got.Default)
+	}
+	if got.Bases["nvidia"] == nil || !profile.IsTrue(got.Bases["nvidia"].DLSS.SROverride) {
+		t.Errorf("extended base profile not included: %+v", got.Bases)
+	}
+	if len(got.Profiles) != 1 {
+		t.Fatalf("got %d profiles, want 1", len(got.Profiles))
+	}
+	entry := got.Profiles[0]
+	if entry.AppID != 10 || entry.Game != "Game" || entry.DLLs["dlss"] != "310.2.1" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.Profile.DLSS.SRMode != profile.DLSSModeQuality || !profile.IsTrue(entry.Profile.GPU.ShaderCache) {
+		t.Errorf("profile settings lost: %+v", entry.Profile)
+	}
+}
+
+func TestReadBundleRejectsInvalidProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	err := os.WriteFile(path, []byte(`
+format: 1
+profiles:
+  - app_id: 10
+    game: Game
+    profile:
+      dlss:
+        sr_mod: quality
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := profile.ReadBundle(path); err == nil || !strings.Contains(err.Error(), "sr_mod") {
+		t.Errorf("ReadBundle error = %v, want unknown key sr_mod", err)
+	}
+}
+
+func TestBundleImportConflicts(t *testing.T) {
+	tests := []struct {
+		mode       profile.ConflictMode
+		wantAction string
+		wantMode   profile.DLSSMode
+		wantCache  bool
+	}{
+		{profile.ConflictSkip, profile.ImportSkipped, profile.DLSSModeBalanced, true},
+		{profile.ConflictOverwrite, profile.ImportOverwritten, profile.DLSSModePerformance, false},
+		{profile.ConflictMerge, profile.ImportMerged, profile.DLSSModePerformance, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			writeProfiles(t, map[string]string{
+				"10.yaml": `
+dlss:
+  sr_mode: balanced
+gpu:
+  shader_cache: true
+`,
+			})
+
+			b := profile.NewBundle("dev")
+			incoming := &profile.Profile{DLSS: profile.DLSSSettings{SRMode: profile.DLSSModePerformance}}
+			if err := b.Add(10, "Game", incoming, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Add(20, "Other", incoming, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := b.Import(tt.mode)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if len(results) != 2 || results[0].Action != tt.wantAction || results[1].Action != profile.ImportCreated {
+				t.Errorf("results = %+v", results)
+			}
+
+			p, err := profile.Load(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.DLSS.SRMode != tt.wantMode || profile.IsTrue(p.GPU.ShaderCache) != tt.wantCache {
+				t.Errorf("profile = %+v, want sr_mode %s and shader_cache %v", p, tt.wantMode, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestBundleImportInvalidMode(t *testing.T) {
+	b := profile.NewBundle("dev")
+	if _, err := b.Import("replace"); err == nil {
+		t.Error("expected error for unknown conflict mode")
+	}
+}
+
+func TestPortableKeepsAffinityPresets(t *testing.T) {
+	p := &profile.Profile{CPU: profile.CPUSettings{Affinity: "performance-cores"}}
+	if got := p.Portable().CPU.Affinity; got != "performance-cores" {
+		t.Errorf("Portable() affinity = %q, want performance-cores", got)
+	}
+	p.CPU.Affinity = "0-7"
+	if got := p.Portable().CPU.Affinity; got != "" {
+		t.Errorf("Portable() affinity = %q, want it dropped", got)
+	}
+}
+
+func TestBundleCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	err := os.WriteFile(path, []byte(`
+format: 1
+default:
+  dlss:
+    sr_override: true
+profiles:
+  - app_id: 10
+    game: Game
+    profile:
+      hooks:
+        pre_launch: [./prepare.sh]
+      wrappers: [mangohud]
+      env:
+        LD_PRELOAD: /tmp/evil.so
+        DXVK_HUD: fps
+      when:
+        - power: battery
+          set:
+            hooks:
+              post_exit:
+                - command: curl example.com
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := profile.ReadBundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands, err := b.Commands()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range commands {
+		if c.Target != "game:10" || c.Game != "Game" {
+			t.Errorf("command %+v, want it attributed to game:10", c)
+		}
+		got = append(got, c.Key+"="+c.Command)
+	}
+	want := "hooks.pre_launch=./prepare.sh wrappers=mangohud env.LD_PRELOAD=/tmp/evil.so when[1].hooks.post_exit=curl example.com"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Commands() = %v, want %s", got, want)
+	}
+}
+
+func TestBundleCommandsFlagCodeLoading(t *testing.T) {
+	tests := map[string]*profile.Profile{
+		"wrappers": {Wrappers: []string{"/tmp/wrap.sh"}},
+	}
+	for _, name := range []string{
+		"LD_PRELOAD", "LD_AUDIT", "LD_LIBRARY_PATH",
+		"VK_LAYER_PATH", "VK_ADD_LAYER_PATH", "VK_INSTANCE_LAYERS",
+		"VK_DRIVER_FILES", "VK_ADD_DRIVER_FILES", "VK_ICD_FILENAMES",
+		"WINEDLLOVERRIDES", "WINEDLLPATH",
+	} {
+		tests["env."+name] = &profile.Profile{Env: map[string]profile.EnvVar{name: {Value: "/tmp/payload"}}}
+	}
+
+	for key, p := range tests {
+		t.Run(key, func(t *testing.T) {
+			b := &profile.Bundle{Profiles: []profile.BundleProfile{{AppID: 10, Game: "Game", Profile: p}}}
+			commands, err := b.Commands()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(commands) != 1 || commands[0].Key != key {
+				t.Errorf("Commands() = %+v, want %s", commands, key)
+			}
+		})
+	}
+
+	harmless := &profile.Bundle{Profiles: []profile.BundleProfile{{AppID: 10, Profile: &profile.Profile{
+		Env: map[string]profile.EnvVar{"DXVK_HUD": {Value: "fps"}, "VK_LAYER_PATH": {Unset: true}},
+	}}}}
+	if commands, err := harmless.Commands(); err != nil || len(commands) != 0 {
+		t.Errorf("Commands() = %+v, %v, want none for harmless and unset variables", commands, err)
+	}
+}
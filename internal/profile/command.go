@@ -0,0 +1,203 @@
+package profile
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultPathSeparator = ":"
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVar is a custom environment variable. In YAML a plain string sets the
+// variable; a mapping can instead unset it, or add to a list such as PATH
+// or WINEDLLOVERRIDES (with separator ";") while keeping its current value.
+type EnvVar struct {
+	Value     string `yaml:"value,omitempty"`
+	Unset     bool   `yaml:"unset,omitempty"`
+	Prepend   string `yaml:"prepend,omitempty"`
+	Append    string `yaml:"append,omitempty"`
+	Separator string `yaml:"separator,omitempty"`
+}
+
+type envVarFields EnvVar
+
+var envVarKeys = []string{"value", "unset", "prepend", "append", "separator"}
+
+func (v *EnvVar) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*v = EnvVar{Value: node.Value}
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a value or a mapping with %s", node.Line, strings.Join(envVarKeys, ", "))
+	}
+
+	// Decoding a node does not inherit the strict mode of the outer
+	// decoder, so unknown keys are rejected here.
+	for i := 0; i < len(node.Content); i += 2 {
+		if key := node.Content[i]; !slices.Contains(envVarKeys, key.Value) {
+			return fmt.Errorf("line %d: field %s not found in type profile.EnvVar", key.Line, key.Value)
+		}
+	}
+
+	var fields envVarFields
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	*v = EnvVar(fields)
+	return nil
+}
+
+func (v EnvVar) MarshalYAML() (any, error) {
+	if v == (EnvVar{Value: v.Value}) {
+		return v.Value, nil
+	}
+	return envVarFields(v), nil
+}
+
+func (v EnvVar) String() string {
+	switch {
+	case v.Unset:
+		return "(unset)"
+	case v.Prepend != "" || v.Append != "":
+		var parts []string
+		if v.Value != "" {
+			parts = append(parts, v.Value)
+		}
+		if v.Prepend != "" {
+			parts = append(parts, "prepend "+v.Prepend)
+		}
+		if v.Append != "" {
+			parts = append(parts, "append "+v.Append)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return v.Value
+	}
+}
+
+// Resolve returns the value of the variable given its current value, and
+// false if the variable should be unset.
+func (v EnvVar) Resolve(current string) (string, bool) {
+	if v.Unset {
+		return "", false
+	}
+
+	value := current
+	if v.Value != "" {
+		value = v.Value
+	}
+
+	separator := v.Separator
+	if separator == "" {
+		separator = defaultPathSeparator
+	}
+	if v.Prepend != "" {
+		value = joinNonEmpty(separator, v.Prepend, value)
+	}
+	if v.Append != "" {
+		value = joinNonEmpty(separator, value, v.Append)
+	}
+	return value, true
+}
+
+func joinNonEmpty(separator string, parts ...string) string {
+	return strings.Join(slices.DeleteFunc(parts, func(s string) bool { return s == "" }), separator)
+}
+
+// ArgsSettings are extra arguments for the game itself. Prepend goes right
+// after the game executable, before any arguments it already has.
+type ArgsSettings struct {
+	Prepend []string `yaml:"prepend,omitempty"`
+	Append  []string `yaml:"append,omitempty"`
+}
+
+// WrapperCommands splits the profile's wrappers into arguments, outermost
+// wrapper first.
+func (p *Profile) WrapperCommands() ([][]string, error) {
+	commands := make([][]string, 0, len(p.Wrappers))
+	for _, wrapper := range p.Wrappers {
+		args, err := splitCommandLine(wrapper)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wrapper %q: %w", wrapper, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("empty wrapper command")
+		}
+		commands = append(commands, args)
+	}
+	return commands, nil
+}
+
+// splitCommandLine splits a command line into arguments the way a POSIX
+// shell would, honouring single quotes, double quotes and backslashes, but
+// without any expansion.
+func splitCommandLine(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// FormatCommandLine joins args into a command line that a shell, or the
+// wrappers setting, splits back into the same arguments. Arguments are only
+// quoted where needed.
+func FormatCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;~#!") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
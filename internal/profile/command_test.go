@@ -0,0 +1,185 @@
+package profile_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/env"
+	"github.com/jgabor/spela/internal/hook"
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestEnvSection(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"default.yaml": `
+env:
+  PROTON_LOG: "1"
+  DXVK_HUD: fps
+`,
+		"10.yaml": `
+env:
+  VKD3D_CONFIG: dxr11
+  DXVK_HUD:
+    unset: true
+  PATH:
+    prepend: /opt/tools/bin
+  WINEDLLOVERRIDES:
+    append: dxgi=n,b
+    separator: ";"
+`,
+	})
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("WINEDLLOVERRIDES", "winemenubuilder.exe=d")
+	t.Setenv("DXVK_HUD", "full")
+
+	p, err := profile.LoadEffective(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := env.New()
+	p.Apply(e)
+
+	want := map[string]string{
+		"PROTON_LOG":       "1",
+		"VKD3D_CONFIG":     "dxr11",
+		"PATH":             "/opt/tools/bin:/usr/bin",
+		"WINEDLLOVERRIDES": "winemenubuilder.exe=d;dxgi=n,b",
+	}
+	for key, value := range want {
+		if got, _ := e.Lookup(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if _, ok := e.Lookup("DXVK_HUD"); ok {
+		t.Error("DXVK_HUD should be unset")
+	}
+	for _, entry := range e.BuildEnv() {
+		if strings.HasPrefix(entry, "DXVK_HUD=") {
+			t.Errorf("unset variable in child environment: %s", entry)
+		}
+	}
+	if got := e.Origin("VKD3D_CONFIG"); got != "env.VKD3D_CONFIG" {
+		t.Errorf("Origin(VKD3D_CONFIG) = %q", got)
+	}
+}
+
+func TestEnvSectionRejectsUnknownFields(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"10.yaml": `
+env:
+  PATH:
+    apend: /opt/bin
+`,
+	})
+
+	if _, err := profile.Load(10); err == nil || !strings.Contains(err.Error(), "apend") {
+		t.Errorf("Load error = %v, want unknown field apend", err)
+	}
+}
+
+func TestEnvVarRoundTrip(t *testing.T) {
+	writeProfiles(t, map[string]string{})
+
+	p := &profile.Profile{Env: map[string]profile.EnvVar{
+		"PROTON_LOG": {Value: "1"},
+		"DXVK_HUD":   {Unset: true},
+	}}
+	if err := profile.Save(10, p); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := profile.Load(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Env["PROTON_LOG"].Value != "1" || !loaded.Env["DXVK_HUD"].Unset {
+		t.Errorf("Env = %+v", loaded.Env)
+	}
+}
+
+func TestWrapperCommands(t *testing.T) {
+	p := &profile.Profile{Wrappers: []string{"gamemoderun", `mangohud --dlsym`, `env "A B=1" 'it''s'`}}
+	got, err := p.WrapperCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"gamemoderun"}, {"mangohud", "--dlsym"}, {"env", "A B=1", "its"}}
+	if !slices.EqualFunc(got, want, slices.Equal[[]string]) {
+		t.Errorf("WrapperCommands() = %q, want %q", got, want)
+	}
+
+	p.Wrappers = []string{`mangohud "--dlsym`}
+	if _, err := p.WrapperCommands(); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
+
+func TestFormatCommandLine(t *testing.T) {
+	got := profile.FormatCommandLine([]string{"mangohud", "--dlsym", "it's here", ""})
+	if want := `mangohud --dlsym 'it'\''s here' ''`; got != want {
+		t.Errorf("FormatCommandLine() = %s, want %s", got, want)
+	}
+}
+
+func TestValidateEnvAndWrappers(t *testing.T) {
+	p := &profile.Profile{
+		Env:      map[string]profile.EnvVar{"1BAD": {Value: "x"}, "GOOD": {Unset: true, Value: "x"}},
+		Wrappers: []string{""},
+	}
+
+	var keys []string
+	for _, issue := range p.Validate(0) {
+		keys = append(keys, issue.Key)
+	}
+	want := []string{"env.1BAD", "env.GOOD", "wrappers"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("issues for %v, want %v", keys, want)
+	}
+}
+
+func TestSetEnvAndArgs(t *testing.T) {
+	p := &profile.Profile{}
+	if err := p.Set("env.PROTON_LOG", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Set("args.append", `-skipintro "--mode=dx 12"`); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := p.Get("env.PROTON_LOG"); got != "1" {
+		t.Errorf("env.PROTON_LOG = %q", got)
+	}
+	if !slices.Equal(p.Args.Append, []string{"-skipintro", "--mode=dx 12"}) {
+		t.Errorf("args.append = %q", p.Args.Append)
+	}
+	if err := p.Unset("env.PROTON_LOG"); err != nil || len(p.Env) != 0 {
+		t.Errorf("Unset env.PROTON_LOG: %v, %v", err, p.Env)
+	}
+}
+
+func TestResolveHooks(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"default.yaml": "hooks:\n  pre_launch: [notify-send starting]\n  post_exit: [notify-send done]\n",
+		"20.yaml":      "hooks:\n  pre_launch:\n    - command: ./mount-saves.sh\n      on_failure: abort\n",
+	})
+
+	r, err := profile.Resolve(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := r.Profile.Hooks
+	if len(hooks.PreLaunch) != 1 || hooks.PreLaunch[0].Command != "./mount-saves.sh" || !hooks.PreLaunch[0].Aborts() {
+		t.Errorf("pre_launch = %+v, want only the game's hook", hooks.PreLaunch)
+	}
+	if len(hooks.PostExit) != 1 || hooks.PostExit[0].Command != "notify-send done" {
+		t.Errorf("post_exit = %+v, want the default profile's hook", hooks.PostExit)
+	}
+	if got := r.Source("hooks.pre_launch"); got != "game:20" {
+		t.Errorf("Source(hooks.pre_launch) = %s, want game:20", got)
+	}
+
+	p := &profile.Profile{Hooks: hook.Settings{OnCrash: []hook.Hook{{Command: "x", OnFailure: "ignore"}}}}
+	if issues := p.Validate(0); len(issues) != 1 || issues[0].Key != "hooks.on_crash" {
+		t.Errorf("Validate() = %v, want one hooks.on_crash issue", issues)
+	}
+}
@@ -0,0 +1,103 @@
+package profile
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+const maxGamescopeSharpness = 20
+
+var (
+	validGamescopeUpscalers = []GamescopeUpscaler{
+		GamescopeUpscalerFSR, GamescopeUpscalerNIS, GamescopeUpscalerLinear,
+		GamescopeUpscalerNearest, GamescopeUpscalerPixel,
+	}
+	validGamescopeModes = []GamescopeMode{
+		GamescopeModeWindowed, GamescopeModeFullscreen, GamescopeModeBorderless,
+	}
+)
+
+// GamescopeCommand returns the gamescope command the game is wrapped in, up
+// to and including the "--" that separates it from the game's command, or
+// nil when gamescope is not enabled.
+func (p *Profile) GamescopeCommand() []string {
+	s := p.Gamescope
+	if !IsTrue(s.Enabled) {
+		return nil
+	}
+
+	command := []string{"gamescope"}
+	number := func(flag string, n int) {
+		if n > 0 {
+			command = append(command, flag, strconv.Itoa(n))
+		}
+	}
+	number("-W", s.OutputWidth)
+	number("-H", s.OutputHeight)
+	number("-w", s.NestedWidth)
+	number("-h", s.NestedHeight)
+	number("-r", s.RefreshRate)
+	if s.Upscaler != "" {
+		command = append(command, "-F", string(s.Upscaler))
+	}
+	if s.Sharpness != nil {
+		command = append(command, "--sharpness", strconv.Itoa(*s.Sharpness))
+	}
+	if IsTrue(s.HDR) {
+		command = append(command, "--hdr-enabled")
+	}
+	switch s.Mode {
+	case GamescopeModeFullscreen:
+		command = append(command, "-f")
+	case GamescopeModeBorderless:
+		command = append(command, "-b")
+	}
+	number("--framerate-limit", s.FrameLimit)
+	command = append(command, s.ExtraFlags...)
+	return append(command, "--")
+}
+
+func (s GamescopeSettings) validate(proton ProtonSettings) []Issue {
+	var issues []Issue
+	issues = checkEnum(issues, "gamescope.upscaler", s.Upscaler, validGamescopeUpscalers)
+	issues = checkEnum(issues, "gamescope.mode", s.Mode, validGamescopeModes)
+
+	for _, field := range []struct {
+		key   string
+		value int
+	}{
+		{"gamescope.output_width", s.OutputWidth},
+		{"gamescope.output_height", s.OutputHeight},
+		{"gamescope.nested_width", s.NestedWidth},
+		{"gamescope.nested_height", s.NestedHeight},
+		{"gamescope.refresh_rate", s.RefreshRate},
+		{"gamescope.frame_limit", s.FrameLimit},
+	} {
+		if field.value < 0 {
+			issues = append(issues, Issue{Key: field.key, Message: fmt.Sprintf("must not be negative, got %d", field.value)})
+		}
+	}
+
+	if s.Sharpness != nil && (*s.Sharpness < 0 || *s.Sharpness > maxGamescopeSharpness) {
+		issues = append(issues, Issue{Key: "gamescope.sharpness", Message: fmt.Sprintf("must be between 0 and %d, got %d", maxGamescopeSharpness, *s.Sharpness)})
+	}
+
+	if slices.Contains(s.ExtraFlags, "--") {
+		issues = append(issues, Issue{Key: "gamescope.extra_flags", Message: `must not contain "--"; spela adds it before the game's command`})
+	}
+
+	// Both sides have to agree: gamescope only passes HDR through to games
+	// that Proton lets output it, and Proton's HDR needs an HDR compositor.
+	// The issue is reported on gamescope.hdr either way, so either setting
+	// can be changed first.
+	if IsTrue(s.Enabled) {
+		switch {
+		case IsTrue(s.HDR) && !IsTrue(proton.EnableHDR):
+			issues = append(issues, Issue{Key: "gamescope.hdr", Message: "needs proton.enable_hdr to be on as well"})
+		case !IsTrue(s.HDR) && IsTrue(proton.EnableHDR):
+			issues = append(issues, Issue{Key: "gamescope.hdr", Message: "must be on when proton.enable_hdr is, or the game cannot output HDR"})
+		}
+	}
+	return issues
+}
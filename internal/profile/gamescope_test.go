@@ -0,0 +1,88 @@
+package profile_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestValidateGamescope(t *testing.T) {
+	sharpness := 30
+	tests := []struct {
+		name    string
+		profile profile.Profile
+		want    []string
+	}{
+		{
+			name: "valid",
+			profile: profile.Profile{
+				Proton:    profile.ProtonSettings{EnableHDR: profile.Bool(true)},
+				Gamescope: profile.GamescopeSettings{Enabled: profile.Bool(true), HDR: profile.Bool(true), Upscaler: profile.GamescopeUpscalerNIS, Mode: profile.GamescopeModeBorderless},
+			},
+		},
+		{
+			name: "invalid values",
+			profile: profile.Profile{
+				Gamescope: profile.GamescopeSettings{
+					Upscaler:    "bicubic",
+					Mode:        "maximized",
+					NestedWidth: -1,
+					Sharpness:   &sharpness,
+					ExtraFlags:  []string{"--", "gamemoderun"},
+				},
+			},
+			want: []string{"gamescope.upscaler", "gamescope.mode", "gamescope.nested_width", "gamescope.sharpness", "gamescope.extra_flags"},
+		},
+		{
+			name: "gamescope hdr without proton hdr",
+			profile: profile.Profile{
+				Gamescope: profile.GamescopeSettings{Enabled: profile.Bool(true), HDR: profile.Bool(true)},
+			},
+			want: []string{"gamescope.hdr"},
+		},
+		{
+			name: "proton hdr without gamescope hdr",
+			profile: profile.Profile{
+				Proton:    profile.ProtonSettings{EnableHDR: profile.Bool(true)},
+				Gamescope: profile.GamescopeSettings{Enabled: profile.Bool(true)},
+			},
+			want: []string{"gamescope.hdr"},
+		},
+		{
+			name: "proton hdr without gamescope",
+			profile: profile.Profile{
+				Proton: profile.ProtonSettings{EnableHDR: profile.Bool(true)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			for _, issue := range tt.profile.Validate(gpu.GPUGenerationUnknown) {
+				keys = append(keys, issue.Key)
+			}
+			if !slices.Equal(keys, tt.want) {
+				t.Errorf("Validate() keys = %q, want %q", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetGamescopeSharpness(t *testing.T) {
+	p := &profile.Profile{}
+	if err := p.Set("gamescope.sharpness", "0"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if p.Gamescope.Sharpness == nil || *p.Gamescope.Sharpness != 0 {
+		t.Errorf("sharpness = %v, want 0", p.Gamescope.Sharpness)
+	}
+	if err := p.Set("gamescope.sharpness", "21"); err == nil {
+		t.Error("Set() accepted sharpness 21")
+	}
+	if got, _ := p.Get("gamescope.sharpness"); got != "0" {
+		t.Errorf("Get() = %q, want the previous value 0", got)
+	}
+}
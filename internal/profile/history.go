@@ -0,0 +1,245 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgabor/spela/internal/xdg"
+)
+
+// maxRevisions is how many revisions are kept per profile. Older ones are
+// pruned when a new revision is recorded.
+const maxRevisions = 50
+
+const revisionTimeFormat = "20060102T150405Z"
+
+// Revision is a snapshot of a game profile as it was saved at some point.
+// Numbers increase with every save and are never reused.
+type Revision struct {
+	Number int
+	Time   time.Time
+	Path   string
+}
+
+func historyDir(appID uint64) string {
+	return xdg.DataPath("history", strconv.FormatUint(appID, 10))
+}
+
+// History lists the recorded revisions of a game profile, oldest first.
+func History(appID uint64) ([]Revision, error) {
+	dir := historyDir(appID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var revisions []Revision
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".yaml")
+		if entry.IsDir() || name == entry.Name() {
+			continue
+		}
+
+		number, stamp, ok := strings.Cut(name, "-")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		t, err := time.Parse(revisionTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		revisions = append(revisions, Revision{Number: n, Time: t, Path: filepath.Join(dir, entry.Name())})
+	}
+
+	slices.SortFunc(revisions, func(a, b Revision) int { return a.Number - b.Number })
+	return revisions, nil
+}
+
+func findRevision(appID uint64, number int) (*Revision, error) {
+	revisions, err := History(appID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range revisions {
+		if revisions[i].Number == number {
+			return &revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not found", number)
+}
+
+// LoadRevision returns a game profile as it was at the given revision.
+func LoadRevision(appID uint64, number int) (*Profile, error) {
+	rev, err := findRevision(appID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(rev.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, _, err := parseProfile(rev.Path, data)
+	return p, err
+}
+
+// Revert restores a game profile to the given revision. The restored profile
+// is saved as a new revision, so a revert can itself be reverted.
+func Revert(appID uint64, number int) (*Profile, error) {
+	p, err := LoadRevision(appID, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := Save(appID, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// IsCurrent reports whether rev matches the game profile on disk.
+func (rev Revision) IsCurrent(appID uint64) bool {
+	current, err := os.ReadFile(profilePath(appID))
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(rev.Path)
+	return err == nil && bytes.Equal(current, data)
+}
+
+// CurrentRevision returns the number of the latest revision that matches the
+// game profile on disk, or 0 if there is none.
+func CurrentRevision(appID uint64) (int, error) {
+	revisions, err := History(appID)
+	if err != nil {
+		return 0, err
+	}
+	for _, rev := range slices.Backward(revisions) {
+		if rev.IsCurrent(appID) {
+			return rev.Number, nil
+		}
+	}
+	return 0, nil
+}
+
+// recordRevision snapshots the profile file of a game unless it is
+// unchanged since the latest revision.
+func recordRevision(appID uint64) error {
+	data, err := os.ReadFile(profilePath(appID))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	revisions, err := History(appID)
+	if err != nil {
+		return err
+	}
+
+	next := 1
+	if len(revisions) > 0 {
+		latest := revisions[len(revisions)-1]
+		previous, err := os.ReadFile(latest.Path)
+		if err == nil && bytes.Equal(previous, data) {
+			return nil
+		}
+		next = latest.Number + 1
+	}
+
+	name := fmt.Sprintf("%04d-%s.yaml", next, time.Now().UTC().Format(revisionTimeFormat))
+	if err := xdg.WriteFile(filepath.Join(historyDir(appID), name), data); err != nil {
+		return err
+	}
+
+	for _, old := range revisions[:max(len(revisions)+1-maxRevisions, 0)] {
+		if err := os.Remove(old.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordHistory records a revision and only logs failures, since losing a
+// history entry must never prevent a profile from being saved.
+func recordHistory(appID uint64) {
+	if err := recordRevision(appID); err != nil {
+		slog.Warn("failed to record profile revision", "app_id", appID, "error", err)
+	}
+}
+
+// FieldChange is a setting that differs between two profiles.
+type FieldChange struct {
+	Key string
+	Old string
+	New string
+}
+
+func formatWhen(blocks []Conditional) string {
+	parts := make([]string, len(blocks))
+	for i, w := range blocks {
+		parts[i] = w.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Diff lists the settings that differ between two profiles, in declaration
+// order. A nil profile is treated as empty.
+func Diff(old, new *Profile) []FieldChange {
+	if old == nil {
+		old = &Profile{}
+	}
+	if new == nil {
+		new = &Profile{}
+	}
+
+	var changes []FieldChange
+	if oldExtends, newExtends := strings.Join(old.Extends, ", "), strings.Join(new.Extends, ", "); oldExtends != newExtends {
+		changes = append(changes, FieldChange{Key: "extends", Old: oldExtends, New: newExtends})
+	}
+	if oldWhen, newWhen := formatWhen(old.When), formatWhen(new.When); oldWhen != newWhen {
+		changes = append(changes, FieldChange{Key: "when", Old: oldWhen, New: newWhen})
+	}
+
+	var oldKeys []string
+	oldValues := make(map[string]string)
+	walkFields(reflect.ValueOf(old).Elem(), "", func(key string, v reflect.Value) {
+		oldKeys = append(oldKeys, key)
+		oldValues[key] = formatField(v)
+	})
+	seen := make(map[string]bool)
+	walkFields(reflect.ValueOf(new).Elem(), "", func(key string, v reflect.Value) {
+		seen[key] = true
+		if value := formatField(v); value != oldValues[key] {
+			changes = append(changes, FieldChange{Key: key, Old: oldValues[key], New: value})
+		}
+	})
+
+	// Map entries, such as env variables, that only the old profile has
+	// were removed.
+	for _, key := range oldKeys {
+		if !seen[key] {
+			changes = append(changes, FieldChange{Key: key, Old: oldValues[key]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,77 @@
+package profile_test
+
+import (
+	"testing"
+
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestSaveRecordsHistory(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"10.yaml": "dlss:\n  sr_mode: quality\n",
+	})
+
+	p := &profile.Profile{DLSS: profile.DLSSSettings{SRMode: profile.DLSSModePerformance}}
+	if err := profile.Save(10, p); err != nil {
+		t.Fatal(err)
+	}
+	// Saving the same settings again must not add a revision.
+	if err := profile.Save(10, p); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := profile.History(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2 (hand-written file and first save)", len(revisions))
+	}
+	if revisions[0].Number != 1 || revisions[1].Number != 2 {
+		t.Errorf("revision numbers = %d, %d", revisions[0].Number, revisions[1].Number)
+	}
+	if revisions[0].IsCurrent(10) || !revisions[1].IsCurrent(10) {
+		t.Error("expected only the latest revision to be current")
+	}
+
+	first, err := profile.LoadRevision(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := profile.Diff(first, p)
+	if len(changes) != 1 || changes[0] != (profile.FieldChange{Key: "dlss.sr_mode", Old: "quality", New: "performance"}) {
+		t.Errorf("Diff = %+v", changes)
+	}
+
+	reverted, err := profile.Revert(10, 1)
+	if err != nil {
+		t.Fatalf("Revert: %v", err)
+	}
+	if reverted.DLSS.SRMode != profile.DLSSModeQuality {
+		t.Errorf("reverted sr_mode = %s", reverted.DLSS.SRMode)
+	}
+
+	revisions, _ = profile.History(10)
+	if len(revisions) != 3 || !revisions[2].IsCurrent(10) {
+		t.Errorf("revert should record a new current revision, got %+v", revisions)
+	}
+
+	if _, err := profile.Revert(10, 42); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+}
+
+func TestDiffExtends(t *testing.T) {
+	changes := profile.Diff(nil, &profile.Profile{Extends: profile.BaseList{"nvidia"}})
+	if len(changes) != 1 || changes[0].Key != "extends" || changes[0].New != "nvidia" {
+		t.Errorf("Diff = %+v", changes)
+	}
+}
+
+func TestDiffRemovedEnv(t *testing.T) {
+	old := &profile.Profile{Env: map[string]profile.EnvVar{"DXVK_HUD": {Value: "fps"}, "PROTON_LOG": {Value: "1"}}}
+	changes := profile.Diff(old, &profile.Profile{Env: map[string]profile.EnvVar{"PROTON_LOG": {Value: "1"}}})
+	if len(changes) != 1 || changes[0].Key != "env.DXVK_HUD" || changes[0].Old != "fps" || changes[0].New != "" {
+		t.Errorf("Diff = %+v, want the removed env.DXVK_HUD", changes)
+	}
+}
@@ -0,0 +1,281 @@
+package profile
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/gpu"
+)
+
+// Keys lists every setting that can be addressed by a dotted key path such as
+// "gpu.clock_offset", in declaration order. Settings Set cannot parse, such
+// as hooks, are left out; they are edited in the profile file.
+func Keys() []string {
+	var keys []string
+	walkFields(reflect.ValueOf(&Profile{}).Elem(), "", func(key string, v reflect.Value) {
+		if settable(v.Type()) {
+			keys = append(keys, key)
+		}
+	})
+	return keys
+}
+
+// settable reports whether Set can parse a value for a setting of type t.
+// Booleans must be pointers: a plain bool cannot store an explicit false
+// that overrides an inherited true.
+func settable(t reflect.Type) bool {
+	switch {
+	case t.Kind() == reflect.String, t.Kind() == reflect.Int, t == reflect.TypeFor[[]string]():
+		return true
+	case t.Kind() == reflect.Pointer:
+		return t.Elem().Kind() == reflect.Bool || t.Elem().Kind() == reflect.Int
+	}
+	return false
+}
+
+// KeyValues suggests values for a key: the allowed values of enums and
+// true/false for booleans. It returns nil for free-form settings.
+func KeyValues(key string) []string {
+	switch key {
+	case "dlss.sr_mode", "dlss.rr_mode":
+		return enumNames(validDLSSModes)
+	case "dlss.sr_preset", "dlss.rr_preset":
+		return enumNames(validDLSSPresets)
+	case "dlss.sr_model_preset":
+		return enumNames(validModelPresets)
+	case "gpu.power_mizer":
+		return validPowerMizerModes
+	case "cpu.governor":
+		return validGovernors
+	case "cpu.affinity":
+		return enumNames(cpu.AffinityPresets)
+	case "cpu.scheduler":
+		schedulers, err := cpu.GetSchedulers()
+		if err != nil {
+			return nil
+		}
+		return append(schedulers, SchedulerNone)
+	case "cpu.scheduler_mode":
+		return enumNames(cpu.SCXModes)
+	case "cpu.io_class":
+		return enumNames(validIOClasses)
+	case "cpu.sched_policy":
+		return enumNames(validSchedPolicies)
+	case "overlay.position":
+		return validOverlayPositions
+	case "gamescope.upscaler":
+		return enumNames(validGamescopeUpscalers)
+	case "gamescope.mode":
+		return enumNames(validGamescopeModes)
+	case "gamemode.method":
+		return enumNames(validGameModeMethods)
+	case "gamemode.on_conflict":
+		return enumNames(validGameModeConflicts)
+	case "preset":
+		presets, err := ListPresets()
+		if err != nil {
+			return nil
+		}
+		names := make([]string, len(presets))
+		for i, preset := range presets {
+			names[i] = preset.Name
+		}
+		return names
+	}
+
+	field, err := lookupField(&Profile{}, key)
+	if err != nil {
+		return nil
+	}
+	if field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Bool {
+		return []string{"true", "false"}
+	}
+	return nil
+}
+
+func enumNames[T ~string](values []T) []string {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = string(v)
+	}
+	return names
+}
+
+func lookupField(p *Profile, key string) (reflect.Value, error) {
+	var field reflect.Value
+	found := false
+	walkFields(reflect.ValueOf(p).Elem(), "", func(k string, v reflect.Value) {
+		if k == key {
+			field = v
+			found = true
+		}
+	})
+	if !found {
+		return reflect.Value{}, fmt.Errorf("unknown profile key: %s", key)
+	}
+	return field, nil
+}
+
+// envKey returns the variable name for keys of the form "env.NAME".
+func envKey(key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, "env.")
+	return name, ok
+}
+
+// Get returns the value of a setting, or "" when it is unset.
+func (p *Profile) Get(key string) (string, error) {
+	if name, ok := envKey(key); ok {
+		return p.Env[name].String(), nil
+	}
+
+	field, err := lookupField(p, key)
+	if err != nil {
+		return "", err
+	}
+	return formatField(field), nil
+}
+
+// Set parses value according to the type of the setting and stores it. Enum
+// values and ranges are checked the same way Validate checks them. Setting
+// "preset" applies the named preset, tuned to the installed GPU, rather than
+// only storing its name.
+func (p *Profile) Set(key, value string) error {
+	if key == "preset" {
+		preset, err := GetPreset(value)
+		if err != nil {
+			return err
+		}
+		gen, _ := gpu.GetGPUGeneration()
+		return p.ApplyPreset(preset, gen)
+	}
+
+	if name, ok := envKey(key); ok {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name: %s", name)
+		}
+		if p.Env == nil {
+			p.Env = make(map[string]EnvVar)
+		}
+		p.Env[name] = EnvVar{Value: value}
+		return nil
+	}
+
+	field, err := lookupField(p, key)
+	if err != nil {
+		return err
+	}
+	if !settable(field.Type()) {
+		return fmt.Errorf("%s: cannot be set from the command line, edit the profile file instead", key)
+	}
+
+	previous := reflect.New(field.Type()).Elem()
+	previous.Set(field)
+
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected a number, got %q", key, value)
+		}
+		if n == 0 {
+			// A plain 0 is dropped when saved, so it cannot override an
+			// inherited value.
+			return fmt.Errorf("%s: 0 means unset here; use \"spela profile unset\" to inherit the default", key)
+		}
+		field.SetInt(int64(n))
+	case field.Type() == reflect.TypeFor[[]string]():
+		args, err := splitCommandLine(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		field.Set(reflect.ValueOf(args))
+	case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected true or false, got %q", key, value)
+		}
+		field.Set(reflect.ValueOf(&b))
+	case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected a number, got %q", key, value)
+		}
+		field.Set(reflect.ValueOf(&n))
+	}
+
+	for _, issue := range p.Validate(gpu.GPUGenerationUnknown) {
+		if issue.Key == key {
+			field.Set(previous)
+			return fmt.Errorf("%s: %s", key, issue.Message)
+		}
+	}
+	return nil
+}
+
+// Unset clears a setting so that it is inherited from the default profile
+// and base profiles again.
+func (p *Profile) Unset(key string) error {
+	if name, ok := envKey(key); ok {
+		delete(p.Env, name)
+		return nil
+	}
+
+	field, err := lookupField(p, key)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.Zero(field.Type()))
+	return nil
+}
+
+// ChangedKeys returns the settings, among Keys, whose values differ between
+// a and b. Editors use it to find what was edited in a resolved profile.
+func ChangedKeys(a, b *Profile) []string {
+	var changed []string
+	for _, key := range Keys() {
+		before, _ := a.Get(key)
+		after, _ := b.Get(key)
+		if before != after {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// CopySettings copies the settings named by keys from src to p, including
+// unset ones, so that p inherits them again.
+func (p *Profile) CopySettings(src *Profile, keys []string) error {
+	for _, key := range keys {
+		from, err := lookupField(src, key)
+		if err != nil {
+			return err
+		}
+		to, err := lookupField(p, key)
+		if err != nil {
+			return err
+		}
+		switch {
+		case from.Kind() == reflect.Pointer && !from.IsNil():
+			v := reflect.New(from.Type().Elem())
+			v.Elem().Set(from.Elem())
+			to.Set(v)
+		case from.Kind() == reflect.Slice && !from.IsNil():
+			to.Set(reflect.AppendSlice(reflect.MakeSlice(from.Type(), 0, from.Len()), from))
+		default:
+			to.Set(from)
+		}
+	}
+	return nil
+}
+
+// ApplyEdits writes the settings that differ between shown and edited to
+// layer, the profile file they were shown from. shown is the resolved profile
+// an editor started from; settings left alone stay inherited in layer.
+func ApplyEdits(layer, shown, edited *Profile) error {
+	return layer.CopySettings(edited, ChangedKeys(shown, edited))
+}
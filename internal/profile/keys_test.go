@@ -0,0 +1,213 @@
+package profile_test
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestKeys(t *testing.T) {
+	keys := profile.Keys()
+	for _, want := range []string{"name", "dlss.sr_mode", "gpu.clock_offset", "cpu.smt", "overlay.position"} {
+		if !slices.Contains(keys, want) {
+			t.Errorf("Keys() missing %s", want)
+		}
+	}
+	if slices.Contains(keys, "extends") || slices.Contains(keys, "schema_version") {
+		t.Error("Keys() should not include extends or schema_version")
+	}
+	if slices.Contains(keys, "hooks.pre_launch") {
+		t.Error("Keys() should not include hooks, which Set cannot parse")
+	}
+}
+
+func TestProfileSetGetUnset(t *testing.T) {
+	p := &profile.Profile{}
+
+	tests := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{"gpu.clock_offset", "100", "100"},
+		{"gpu.shader_cache", "true", "true"},
+		{"cpu.smt", "false", "false"},
+		{"dlss.sr_mode", "quality", "quality"},
+		{"proton.enable_hdr", "1", "true"},
+		{"cpu.affinity", "vcache-ccd", "vcache-ccd"},
+	}
+	for _, tt := range tests {
+		if err := p.Set(tt.key, tt.value); err != nil {
+			t.Fatalf("Set(%s, %s): %v", tt.key, tt.value, err)
+		}
+		got, err := p.Get(tt.key)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", tt.key, err)
+		}
+		if got != tt.want {
+			t.Errorf("Get(%s) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+	if profile.IntValue(p.GPU.ClockOffset) != 100 || p.CPU.SMT == nil || *p.CPU.SMT {
+		t.Errorf("fields not set: %+v", p)
+	}
+
+	if err := p.Unset("cpu.smt"); err != nil {
+		t.Fatal(err)
+	}
+	if p.CPU.SMT != nil {
+		t.Error("Unset did not clear cpu.smt")
+	}
+}
+
+func TestProfileSetFalseOverridesInherited(t *testing.T) {
+	writeProfiles(t, map[string]string{"default.yaml": "dlss:\n  fg_override: true\n  fg_enabled: true\n"})
+
+	p := &profile.Profile{}
+	if err := p.Set("dlss.fg_enabled", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if err := profile.Save(50, p); err != nil {
+		t.Fatal(err)
+	}
+	effective, err := profile.LoadEffective(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := effective.Get("dlss.fg_enabled"); got != "false" {
+		t.Errorf("fg_enabled = %q after setting false, want false over the inherited true", got)
+	}
+
+	if err := p.Unset("dlss.fg_enabled"); err != nil {
+		t.Fatal(err)
+	}
+	if err := profile.Save(50, p); err != nil {
+		t.Fatal(err)
+	}
+	if effective, err = profile.LoadEffective(50); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := effective.Get("dlss.fg_enabled"); got != "true" {
+		t.Errorf("fg_enabled = %q after unset, want the inherited true", got)
+	}
+}
+
+func TestProfileSetZeroOverridesInherited(t *testing.T) {
+	writeProfiles(t, map[string]string{"default.yaml": "gpu:\n  clock_offset: 150\n"})
+
+	p := &profile.Profile{}
+	if err := p.Set("gpu.clock_offset", "0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := profile.Save(50, p); err != nil {
+		t.Fatal(err)
+	}
+	effective, err := profile.LoadEffective(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := effective.Get("gpu.clock_offset"); got != "0" {
+		t.Errorf("clock_offset = %q after setting 0, want 0 over the inherited 150", got)
+	}
+}
+
+func TestApplyEditsKeepsUntouchedSettingsInherited(t *testing.T) {
+	writeProfiles(t, map[string]string{"default.yaml": "gpu:\n  shader_cache: true\nproton:\n  enable_hdr: true\n"})
+
+	r, err := profile.Resolve(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := &profile.Profile{}
+	if err := edited.CopySettings(r.Profile, profile.Keys()); err != nil {
+		t.Fatal(err)
+	}
+	edited.DLSS.SRMode = profile.DLSSModeQuality
+	edited.Proton.EnableHDR = profile.Bool(false)
+
+	layer := &profile.Profile{}
+	if err := profile.ApplyEdits(layer, r.Profile, edited); err != nil {
+		t.Fatal(err)
+	}
+	if layer.GPU.ShaderCache != nil {
+		t.Errorf("untouched gpu.shader_cache written to the game layer as %v", *layer.GPU.ShaderCache)
+	}
+	if layer.DLSS.SRMode != profile.DLSSModeQuality || layer.Proton.EnableHDR == nil || *layer.Proton.EnableHDR {
+		t.Errorf("edited settings not written: %+v %+v", layer.DLSS, layer.Proton)
+	}
+
+	if err := profile.Save(60, layer); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(profile.DefaultPath(), []byte("gpu:\n  shader_cache: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	effective, err := profile.LoadEffective(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := effective.Get("gpu.shader_cache"); got != "false" {
+		t.Errorf("gpu.shader_cache = %q, want the default profile's new value", got)
+	}
+}
+
+func TestProfileSetRejectsInvalidValues(t *testing.T) {
+	p := &profile.Profile{DLSS: profile.DLSSSettings{SRMode: profile.DLSSModeQuality}}
+
+	tests := []struct {
+		key, value, want string
+	}{
+		{"dlss.sr_mode", "ultra", "expected one of"},
+		{"dlss.multi_frame", "9", "between 0 and 3"},
+		{"gpu.clock_offset", "fast", "expected a number"},
+		{"gpu.shader_cache", "maybe", "expected true or false"},
+		{"gpu.clocks", "1", "unknown profile key"},
+		{"cpu.affinity", "fast-cores", "invalid CPU"},
+		{"hooks.pre_launch", "echo hi", "cannot be set"},
+		{"gamescope.refresh_rate", "0", "spela profile unset"},
+	}
+	for _, tt := range tests {
+		err := p.Set(tt.key, tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Set(%s, %s) error = %v, want %q", tt.key, tt.value, err, tt.want)
+		}
+	}
+
+	if p.DLSS.SRMode != profile.DLSSModeQuality || p.DLSS.MultiFrame != nil {
+		t.Errorf("rejected values were kept: %+v", p.DLSS)
+	}
+}
+
+func TestProfileSetPresetAppliesSettings(t *testing.T) {
+	writeProfiles(t, nil)
+
+	p := &profile.Profile{}
+	if err := p.Set("preset", "Quality"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Preset != "quality" || p.DLSS.SRMode != profile.DLSSModeQuality || !profile.IsTrue(p.DLSS.SROverride) {
+		t.Errorf("Set(preset, Quality) = %q with %+v, want the quality preset's settings", p.Preset, p.DLSS)
+	}
+
+	if err := p.Set("preset", "turbo"); err == nil || !strings.Contains(err.Error(), "unknown preset") {
+		t.Errorf("Set(preset, turbo) error = %v, want an unknown preset", err)
+	}
+	if p.Preset != "quality" {
+		t.Errorf("Preset = %q after a rejected preset, want quality", p.Preset)
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	if values := profile.KeyValues("gpu.power_mizer"); !slices.Equal(values, []string{"auto", "adaptive", "max"}) {
+		t.Errorf("KeyValues(gpu.power_mizer) = %v", values)
+	}
+	if values := profile.KeyValues("cpu.smt"); !slices.Equal(values, []string{"true", "false"}) {
+		t.Errorf("KeyValues(cpu.smt) = %v", values)
+	}
+	if values := profile.KeyValues("gpu.clock_offset"); values != nil {
+		t.Errorf("KeyValues(gpu.clock_offset) = %v, want nil", values)
+	}
+}
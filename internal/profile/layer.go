@@ -0,0 +1,356 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layer names recorded as the source of resolved values. Base and game
+// layers are named "base:<name>" and "game:<appid>".
+const (
+	LayerBuiltin = "builtin"
+	LayerDefault = "default"
+)
+
+const basesDirName = "bases"
+
+var baseNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// BaseList holds the names of the base profiles a profile extends. In YAML it
+// may be written as a single name or a list.
+type BaseList []string
+
+func (b *BaseList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		if node.Value == "" {
+			*b = nil
+		} else {
+			*b = BaseList{node.Value}
+		}
+		return nil
+	}
+
+	var names []string
+	if err := node.Decode(&names); err != nil {
+		return err
+	}
+	*b = names
+	return nil
+}
+
+func basesDir() string {
+	return filepath.Join(profilesDir(), basesDirName)
+}
+
+func basePath(name string) string {
+	return filepath.Join(basesDir(), name+".yaml")
+}
+
+func baseLayer(name string) string {
+	return "base:" + name
+}
+
+// GameLayer returns the layer name of a game's own profile.
+func GameLayer(appID uint64) string {
+	return "game:" + strconv.FormatUint(appID, 10)
+}
+
+// LoadBase reads the named base profile from the bases directory.
+func LoadBase(name string) (*Profile, error) {
+	if !baseNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid base profile name: %q", name)
+	}
+	return loadFile(basePath(name))
+}
+
+// SaveBase writes p as the named base profile.
+func SaveBase(name string, p *Profile) error {
+	if !baseNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid base profile name: %q", name)
+	}
+	return writeProfile(basePath(name), p)
+}
+
+// ListBases returns the names of all base profiles, sorted.
+func ListBases() ([]string, error) {
+	entries, err := os.ReadDir(basesDir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".yaml" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(name, ".yaml"))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Resolution is a profile assembled from its layers, together with the layer
+// that supplied each value.
+type Resolution struct {
+	Profile *Profile
+	// Layers lists the layers that contributed, lowest precedence first.
+	Layers []string
+	// Sources maps dotted YAML keys such as "dlss.sr_mode" to the layer that
+	// set them. Keys without an entry keep their built-in default.
+	Sources map[string]string
+}
+
+// Source returns the layer that supplied key.
+func (r *Resolution) Source(key string) string {
+	if source, ok := r.Sources[key]; ok {
+		return source
+	}
+	// Values given as mappings, such as env entries, are recorded per
+	// nested key; report the layer of the last one to be merged.
+	source := LayerBuiltin
+	for k, layer := range r.Sources {
+		if strings.HasPrefix(k, key+".") && r.layerIndex(layer) > r.layerIndex(source) {
+			source = layer
+		}
+	}
+	return source
+}
+
+func (r *Resolution) layerIndex(layer string) int {
+	return slices.Index(r.Layers, layer)
+}
+
+// ResolvedField is one leaf setting of a resolved profile.
+type ResolvedField struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Fields lists every setting of the resolved profile in declaration order.
+func (r *Resolution) Fields() []ResolvedField {
+	var fields []ResolvedField
+	walkFields(reflect.ValueOf(r.Profile).Elem(), "", func(key string, v reflect.Value) {
+		fields = append(fields, ResolvedField{Key: key, Value: formatField(v), Source: r.Source(key)})
+	})
+	return fields
+}
+
+func walkFields(v reflect.Value, prefix string, fn func(string, reflect.Value)) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" || tag == "extends" || tag == "schema_version" || tag == "when" {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			walkFields(v.Field(i), key, fn)
+		case reflect.Map:
+			m := v.Field(i)
+			keys := m.MapKeys()
+			slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+			for _, k := range keys {
+				fn(key+"."+k.String(), m.MapIndex(k))
+			}
+		default:
+			fn(key, v.Field(i))
+		}
+	}
+}
+
+func formatField(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return ""
+		}
+		if args, ok := v.Interface().([]string); ok {
+			return FormatCommandLine(args)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Resolve builds the effective profile for a game by merging, field by field,
+// the built-in defaults, the global default profile, the base profiles each
+// of those extends, and finally the game's own profile. A key that is absent
+// from a layer is inherited; an explicit value, including false or 0,
+// overrides it, and null resets it to the built-in default. The when blocks
+// of all layers are kept, in the same order, to be evaluated at launch.
+func Resolve(appID uint64) (*Resolution, error) {
+	r := newResolver()
+	if err := r.applyFile(defaultProfilePath(), LayerDefault, false); err != nil {
+		return nil, err
+	}
+	if err := r.applyFile(profilePath(appID), GameLayer(appID), false); err != nil {
+		return nil, err
+	}
+	return r.resolution()
+}
+
+// ResolveDefault resolves the global default profile and its bases.
+func ResolveDefault() (*Resolution, error) {
+	r := newResolver()
+	if err := r.applyFile(defaultProfilePath(), LayerDefault, false); err != nil {
+		return nil, err
+	}
+	return r.resolution()
+}
+
+type resolver struct {
+	values  map[string]any
+	sources map[string]string
+	layers  []string
+	stack   []string
+	// when collects the when blocks of every layer, lowest first.
+	when []Conditional
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		values:  make(map[string]any),
+		sources: make(map[string]string),
+	}
+}
+
+func (r *resolver) applyFile(path, layer string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			return nil
+		}
+		return err
+	}
+
+	p, data, err := parseProfile(path, data)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	delete(values, "extends")
+	delete(values, "schema_version")
+	delete(values, "when")
+
+	for _, base := range p.Extends {
+		if err := r.applyBase(base); err != nil {
+			return err
+		}
+	}
+
+	mergeValues(r.values, values, "", layer, r.sources)
+	r.layers = append(r.layers, layer)
+
+	for _, w := range p.When {
+		w.Layer = layer
+		r.when = append(r.when, w)
+	}
+	return nil
+}
+
+func (r *resolver) applyBase(name string) error {
+	if !baseNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid base profile name: %q", name)
+	}
+	if slices.Contains(r.stack, name) {
+		return fmt.Errorf("profile inheritance cycle: %s", strings.Join(append(r.stack, name), " -> "))
+	}
+
+	r.stack = append(r.stack, name)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	err := r.applyFile(basePath(name), baseLayer(name), true)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("base profile not found: %s", name)
+	}
+	return err
+}
+
+func (r *resolver) resolution() (*Resolution, error) {
+	data, err := yaml.Marshal(r.values)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Profile
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode resolved profile: %w", err)
+	}
+	p.When = r.when
+
+	return &Resolution{Profile: &p, Layers: r.layers, Sources: r.sources}, nil
+}
+
+// mergeValues overlays src onto dst. Nested mappings are merged key by key;
+// any other value replaces the previous one, and null removes it.
+func mergeValues(dst, src map[string]any, prefix, layer string, sources map[string]string) {
+	for key, value := range src {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if value == nil {
+			delete(dst, key)
+			for k := range sources {
+				if k == path || strings.HasPrefix(k, path+".") {
+					delete(sources, k)
+				}
+			}
+			continue
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			existing, ok := dst[key].(map[string]any)
+			if !ok {
+				existing = make(map[string]any)
+				dst[key] = existing
+			}
+			mergeValues(existing, nested, path, layer, sources)
+			continue
+		}
+
+		dst[key] = value
+		for k := range sources {
+			if strings.HasPrefix(k, path+".") {
+				delete(sources, k)
+			}
+		}
+		sources[path] = layer
+	}
+}
@@ -0,0 +1,181 @@
+package profile_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func writeProfiles(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	for name, content := range files {
+		path := filepath.Join(config, "spela", "profiles", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveLayers(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"default.yaml": `
+dlss:
+  sr_override: true
+  sr_mode: quality
+  indicator: true
+gpu:
+  shader_cache: true
+`,
+		"bases/nvidia.yaml": `
+dlss:
+  sr_mode: balanced
+  fg_override: true
+  fg_enabled: true
+  multi_frame: 3
+`,
+		"10.yaml": `
+extends: nvidia
+dlss:
+  sr_mode: performance
+  indicator: false
+gpu:
+  shader_cache: null
+`,
+	})
+
+	r, err := profile.Resolve(10)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	if got := strings.Join(r.Layers, ","); got != "default,base:nvidia,game:10" {
+		t.Errorf("Layers = %s", got)
+	}
+
+	p := r.Profile
+	if !profile.IsTrue(p.DLSS.SROverride) || p.DLSS.SRMode != profile.DLSSModePerformance {
+		t.Errorf("SR = %v %s, want inherited override with performance", p.DLSS.SROverride, p.DLSS.SRMode)
+	}
+	if !profile.IsTrue(p.DLSS.FGEnabled) || profile.IntValue(p.DLSS.MultiFrame) != 3 {
+		t.Errorf("FG = %v x%d, want base values", p.DLSS.FGEnabled, profile.IntValue(p.DLSS.MultiFrame))
+	}
+	if profile.IsTrue(p.DLSS.Indicator) {
+		t.Error("explicit false did not override the default profile")
+	}
+	if profile.IsTrue(p.GPU.ShaderCache) {
+		t.Error("null did not reset shader_cache")
+	}
+
+	sources := map[string]string{
+		"dlss.sr_override": "default",
+		"dlss.sr_mode":     "game:10",
+		"dlss.multi_frame": "base:nvidia",
+		"dlss.indicator":   "game:10",
+		"gpu.shader_cache": "builtin",
+		"cpu.governor":     "builtin",
+	}
+	for key, want := range sources {
+		if got := r.Source(key); got != want {
+			t.Errorf("Source(%s) = %s, want %s", key, got, want)
+		}
+	}
+}
+
+func TestResolveGameOnlyKeepsDefaults(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"default.yaml": "proton:\n  enable_hdr: true\n",
+		"20.yaml":      "dlss:\n  rr_override: true\n",
+	})
+
+	p, err := profile.LoadEffective(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !profile.IsTrue(p.Proton.EnableHDR) || !profile.IsTrue(p.DLSS.RROverride) {
+		t.Errorf("got hdr=%v rr=%v, want both from their layers", profile.IsTrue(p.Proton.EnableHDR), profile.IsTrue(p.DLSS.RROverride))
+	}
+
+	p, err = profile.LoadEffective(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || !profile.IsTrue(p.Proton.EnableHDR) {
+		t.Error("game without a profile should resolve to the default profile")
+	}
+}
+
+func TestResolveSavedFalseOverridesDefault(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"default.yaml": "dlss:\n  fg_override: true\n  fg_enabled: true\ngamescope:\n  enabled: true\n",
+	})
+
+	if err := profile.Save(40, &profile.Profile{
+		DLSS:      profile.DLSSSettings{FGEnabled: profile.Bool(false)},
+		Gamescope: profile.GamescopeSettings{Enabled: profile.Bool(false)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := profile.LoadEffective(40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.DLSS.FGEnabled == nil || *p.DLSS.FGEnabled {
+		t.Error("fg_enabled is not false, want the saved false to override the default")
+	}
+	if profile.IsTrue(p.Gamescope.Enabled) {
+		t.Error("gamescope.enabled = true, want the saved false to override the default")
+	}
+	if !profile.IsTrue(p.DLSS.FGOverride) {
+		t.Error("fg_override = false, want it inherited from the default")
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"missing base", map[string]string{"1.yaml": "extends: nope\n"}, "base profile not found: nope"},
+		{"cycle", map[string]string{
+			"1.yaml":       "extends: [a]\n",
+			"bases/a.yaml": "extends: b\n",
+			"bases/b.yaml": "extends: a\n",
+		}, "a -> b -> a"},
+		{"invalid name", map[string]string{"1.yaml": "extends: ../1\n"}, "invalid base profile name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeProfiles(t, tt.files)
+			_, err := profile.Resolve(1)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeepsExtends(t *testing.T) {
+	writeProfiles(t, map[string]string{"5.yaml": "extends: nvidia\nname: Game\n"})
+
+	p, err := profile.Load(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Extends) != 1 || p.Extends[0] != "nvidia" {
+		t.Errorf("Extends = %v", p.Extends)
+	}
+}
@@ -0,0 +1,199 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jgabor/spela/internal/gpu"
+)
+
+const presetsDirName = "presets"
+
+// Preset is a named set of profile settings. Built-in presets adapt to the
+// GPU generation; user presets are static YAML profiles.
+type Preset struct {
+	Name        string
+	Description string
+	Builtin     bool
+
+	build    func(gen gpu.GPUGeneration) *Profile
+	settings *Profile
+}
+
+// Settings returns the profile settings the preset applies on gen.
+func (p *Preset) Settings(gen gpu.GPUGeneration) *Profile {
+	if p.build != nil {
+		return p.build(gen)
+	}
+	settings := *p.settings
+	return &settings
+}
+
+var builtinPresets = []Preset{
+	{
+		Name:        "performance",
+		Description: "Highest frame rate: DLSS performance mode with frame generation where supported",
+		Builtin:     true,
+		build:       func(gen gpu.GPUGeneration) *Profile { return dlssPreset(gen, DLSSModePerformance, gen.MaxMultiFrame()) },
+	},
+	{
+		Name:        "balanced",
+		Description: "DLSS balanced mode with single frame generation where supported",
+		Builtin:     true,
+		build: func(gen gpu.GPUGeneration) *Profile {
+			return dlssPreset(gen, DLSSModeBalanced, min(gen.MaxMultiFrame(), 1))
+		},
+	},
+	{
+		Name:        "quality",
+		Description: "Best image quality: DLSS quality mode without frame generation",
+		Builtin:     true,
+		build:       func(gen gpu.GPUGeneration) *Profile { return dlssPreset(gen, DLSSModeQuality, 0) },
+	},
+}
+
+// dlssPreset overrides super resolution with mode. Transformer models are
+// only selected on GPUs with FP8 support, where they run at full speed;
+// older GPUs get the CNN preset E. Frame generation is overridden and
+// enabled with multiFrame generated frames when it is above zero and the GPU
+// supports it, and explicitly disabled otherwise, so that neither an
+// inherited model nor inherited frame generation outlives the preset.
+func dlssPreset(gen gpu.GPUGeneration, mode DLSSMode, multiFrame int) *Profile {
+	p := &Profile{
+		DLSS: DLSSSettings{
+			SROverride: Bool(true),
+			SRMode:     mode,
+		},
+	}
+
+	if gen.SupportsFP8() {
+		p.DLSS.SRModelPreset = DLSSModelPresetAuto
+	} else {
+		p.DLSS.SRModelPreset = DLSSModelPresetOff
+		p.DLSS.SRPreset = DLSSPresetE
+	}
+
+	frameGen := multiFrame > 0 && gen.SupportsFrameGeneration()
+	p.DLSS.FGEnabled = Bool(frameGen)
+	if frameGen {
+		p.DLSS.FGOverride = Bool(true)
+		p.DLSS.MultiFrame = Int(multiFrame)
+	}
+
+	return p
+}
+
+func presetsDir() string {
+	return filepath.Join(profilesDir(), presetsDirName)
+}
+
+func presetPath(name string) string {
+	return filepath.Join(presetsDir(), name+".yaml")
+}
+
+// ListPresets returns the built-in presets followed by user presets. A user
+// preset with the name of a built-in one replaces it.
+func ListPresets() ([]Preset, error) {
+	user, err := loadUserPresets()
+	if err != nil {
+		return nil, err
+	}
+
+	presets := make([]Preset, 0, len(builtinPresets)+len(user))
+	for _, preset := range builtinPresets {
+		if override, ok := user[preset.Name]; ok {
+			presets = append(presets, override)
+			delete(user, preset.Name)
+			continue
+		}
+		presets = append(presets, preset)
+	}
+
+	names := make([]string, 0, len(user))
+	for name := range user {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		presets = append(presets, user[name])
+	}
+
+	return presets, nil
+}
+
+// GetPreset looks up a preset by name, case-insensitively.
+func GetPreset(name string) (*Preset, error) {
+	presets, err := ListPresets()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range presets {
+		if strings.EqualFold(presets[i].Name, name) {
+			return &presets[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown preset: %s", name)
+}
+
+// SavePreset stores p as a user preset.
+func SavePreset(name string, p *Profile) error {
+	if !baseNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid preset name: %q", name)
+	}
+	return writeProfile(presetPath(name), p)
+}
+
+func loadUserPresets() (map[string]Preset, error) {
+	entries, err := os.ReadDir(presetsDir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]Preset{}, nil
+		}
+		return nil, err
+	}
+
+	presets := make(map[string]Preset)
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || filepath.Ext(filename) != ".yaml" {
+			continue
+		}
+
+		p, err := loadFile(filepath.Join(presetsDir(), filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load preset %s: %w", filename, err)
+		}
+
+		name := strings.TrimSuffix(filename, ".yaml")
+		description := p.Name
+		if description == "" {
+			description = "User preset"
+		}
+		presets[name] = Preset{Name: name, Description: description, settings: p}
+	}
+
+	return presets, nil
+}
+
+// ApplyPreset copies the settings of preset into p. Each setting the preset
+// defines replaces the one in p; settings it leaves unset are kept.
+func (p *Profile) ApplyPreset(preset *Preset, gen gpu.GPUGeneration) error {
+	settings := preset.Settings(gen)
+	settings.SchemaVersion, settings.Name, settings.Extends, settings.Preset = 0, "", nil, ""
+
+	merged, err := mergeProfiles(p, settings)
+	if err != nil {
+		return fmt.Errorf("failed to apply preset %s: %w", preset.Name, err)
+	}
+	merged.conditions = p.conditions
+	*p = *merged
+	p.Preset = preset.Name
+	return nil
+}
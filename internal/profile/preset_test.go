@@ -0,0 +1,171 @@
+package profile_test
+
+import (
+	"testing"
+
+	"github.com/jgabor/spela/internal/env"
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestBuiltinPresetsAdaptToGPU(t *testing.T) {
+	writeProfiles(t, nil)
+
+	tests := []struct {
+		preset     string
+		gen        gpu.GPUGeneration
+		mode       profile.DLSSMode
+		model      profile.DLSSModelPreset
+		cnn        profile.DLSSPreset
+		fg         bool
+		multiFrame int
+	}{
+		{"performance", gpu.GPUGenerationBlackwell, profile.DLSSModePerformance, profile.DLSSModelPresetAuto, "", true, 3},
+		{"performance", gpu.GPUGenerationAdaLovelace, profile.DLSSModePerformance, profile.DLSSModelPresetAuto, "", true, 1},
+		{"performance", gpu.GPUGenerationAmpere, profile.DLSSModePerformance, profile.DLSSModelPresetOff, profile.DLSSPresetE, false, 0},
+		{"balanced", gpu.GPUGenerationBlackwell, profile.DLSSModeBalanced, profile.DLSSModelPresetAuto, "", true, 1},
+		{"balanced", gpu.GPUGenerationTuring, profile.DLSSModeBalanced, profile.DLSSModelPresetOff, profile.DLSSPresetE, false, 0},
+		{"quality", gpu.GPUGenerationAdaLovelace, profile.DLSSModeQuality, profile.DLSSModelPresetAuto, "", false, 0},
+		{"quality", gpu.GPUGenerationUnknown, profile.DLSSModeQuality, profile.DLSSModelPresetOff, profile.DLSSPresetE, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset+"/"+tt.gen.String(), func(t *testing.T) {
+			preset, err := profile.GetPreset(tt.preset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dlss := preset.Settings(tt.gen).DLSS
+
+			if !profile.IsTrue(dlss.SROverride) || dlss.SRMode != tt.mode {
+				t.Errorf("SR = %v %s, want override %s", dlss.SROverride, dlss.SRMode, tt.mode)
+			}
+			if dlss.SRModelPreset != tt.model || dlss.SRPreset != tt.cnn {
+				t.Errorf("model = %q preset = %q, want %q %q", dlss.SRModelPreset, dlss.SRPreset, tt.model, tt.cnn)
+			}
+			if profile.IsTrue(dlss.FGEnabled) != tt.fg || profile.IntValue(dlss.MultiFrame) != tt.multiFrame {
+				t.Errorf("FG = %v x%d, want %v x%d", dlss.FGEnabled, profile.IntValue(dlss.MultiFrame), tt.fg, tt.multiFrame)
+			}
+			if (dlss.FGOverride != nil) != tt.fg {
+				t.Errorf("FGOverride = %v, want it set only with frame generation on", dlss.FGOverride)
+			}
+		})
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"presets/streaming.yaml": "name: Low latency for streaming\ngpu:\n  power_mizer: max\n",
+	})
+
+	p := &profile.Profile{
+		Name:   "Game",
+		DLSS:   profile.DLSSSettings{FGOverride: profile.Bool(true), FGEnabled: profile.Bool(true), MultiFrame: profile.Int(3), RROverride: profile.Bool(true), Indicator: profile.Bool(true)},
+		Proton: profile.ProtonSettings{EnableHDR: profile.Bool(true)},
+	}
+
+	quality, err := profile.GetPreset("Quality")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ApplyPreset(quality, gpu.GPUGenerationBlackwell); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.DLSS.FGEnabled == nil || *p.DLSS.FGEnabled || p.DLSS.SRMode != profile.DLSSModeQuality {
+		t.Errorf("DLSS = %+v, want quality with frame generation explicitly off", p.DLSS)
+	}
+	if !profile.IsTrue(p.DLSS.RROverride) || !profile.IsTrue(p.DLSS.Indicator) {
+		t.Error("DLSS settings the preset does not define should be kept")
+	}
+	if !profile.IsTrue(p.Proton.EnableHDR) || p.Name != "Game" {
+		t.Error("sections the preset does not define should be kept")
+	}
+	if p.Preset != "quality" {
+		t.Errorf("Preset = %q", p.Preset)
+	}
+
+	streaming, err := profile.GetPreset("streaming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streaming.Builtin || streaming.Description != "Low latency for streaming" {
+		t.Errorf("user preset = %+v", streaming)
+	}
+	if err := p.ApplyPreset(streaming, gpu.GPUGenerationBlackwell); err != nil {
+		t.Fatal(err)
+	}
+	if p.GPU.PowerMizer != "max" || p.DLSS.SRMode != profile.DLSSModeQuality {
+		t.Errorf("got power=%q mode=%q, want user GPU settings on top of quality", p.GPU.PowerMizer, p.DLSS.SRMode)
+	}
+	if p.Name != "Game" {
+		t.Errorf("Name = %q, want the game name rather than the preset description", p.Name)
+	}
+}
+
+func TestPresetOverridesInheritedDLSS(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"default.yaml": "dlss:\n  sr_model_preset: k\n  fg_override: true\n  fg_enabled: true\n  multi_frame: 3\n",
+	})
+
+	quality, err := profile.GetPreset("quality")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &profile.Profile{}
+	if err := p.ApplyPreset(quality, gpu.GPUGenerationAmpere); err != nil {
+		t.Fatal(err)
+	}
+	if err := profile.Save(60, p); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := profile.Resolve(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := env.New()
+	r.Profile.Apply(e)
+
+	if got := e.Get("DXVK_NVAPI_DRS_NGX_DLSSG_MULTI_FRAME_COUNT"); got != "" {
+		t.Error("frame generation inherited from the default profile is still on")
+	}
+	if got := e.Get("DXVK_NVAPI_DRS_NGX_DLSS_SR_OVERRIDE_RENDER_PRESET_SELECTION"); got != "render_preset_e" {
+		t.Errorf("render preset = %q, want the CNN preset E over the inherited model", got)
+	}
+}
+
+func TestPresetWithoutFrameGenerationSkipsOverride(t *testing.T) {
+	writeProfiles(t, nil)
+
+	performance, err := profile.GetPreset("performance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := env.New()
+	performance.Settings(gpu.GPUGenerationAmpere).Apply(e)
+
+	if _, ok := e.Lookup("DXVK_NVAPI_DRS_NGX_DLSS_FG_OVERRIDE"); ok {
+		t.Error("the performance preset exports the frame generation override on a GPU without frame generation")
+	}
+}
+
+func TestUserPresetOverridesBuiltin(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"presets/performance.yaml": "dlss:\n  sr_mode: ultra_performance\n",
+	})
+
+	presets, err := profile.ListPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(presets) != 3 {
+		t.Fatalf("got %d presets, want 3", len(presets))
+	}
+	if presets[0].Name != "performance" || presets[0].Builtin {
+		t.Errorf("first preset = %+v, want user performance preset", presets[0])
+	}
+	if mode := presets[0].Settings(gpu.GPUGenerationAmpere).DLSS.SRMode; mode != profile.DLSSModeUltraPerformance {
+		t.Errorf("SRMode = %s", mode)
+	}
+}
@@ -0,0 +1,174 @@
+package profile
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/jgabor/spela/internal/cpu"
+)
+
+const (
+	maxScopeWeight = 10000
+	// defaultIOPriority is the kernel's level for a process at nice 0.
+	defaultIOPriority = 4
+)
+
+var (
+	validIOClasses     = []cpu.IOClass{cpu.IOClassRealtime, cpu.IOClassBestEffort, cpu.IOClassIdle}
+	validSchedPolicies = []cpu.SchedPolicy{cpu.SchedOther, cpu.SchedBatch, cpu.SchedIdle, cpu.SchedFIFO, cpu.SchedRR}
+	memorySizePattern  = regexp.MustCompile(`^(\d+(\.\d+)?[KMGT]?|\d+(\.\d+)?%|infinity)$`)
+)
+
+// ProcessChange is a scheduling setting applied to the game's main process
+// once it is running. It ends with the process, so nothing is reverted.
+type ProcessChange struct {
+	// Key is the profile setting the change comes from, e.g. "cpu.nice".
+	Key         string
+	Description string
+	apply       func(pid int) error
+}
+
+// Apply makes the change to the process pid and all of its threads.
+func (c ProcessChange) Apply(pid int) error {
+	return c.apply(pid)
+}
+
+// ProcessChanges lists the changes the profile makes to the game's main
+// process, in the order they are applied.
+func (p *Profile) ProcessChanges() []ProcessChange {
+	var changes []ProcessChange
+	s := p.CPU
+
+	if s.SchedPolicy != "" {
+		policy, priority := s.SchedPolicy, max(IntValue(s.SchedPriority), cpu.MinSchedPriority)
+		description := fmt.Sprintf("Set scheduling policy to %s", policy)
+		if policy.IsRealtime() {
+			description += fmt.Sprintf(" with priority %d", priority)
+		}
+		changes = append(changes, ProcessChange{
+			Key:         "cpu.sched_policy",
+			Description: description,
+			apply: func(pid int) error {
+				if err := cpu.SetSchedPolicy(pid, policy, priority); err != nil {
+					return fmt.Errorf("failed to set scheduling policy: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if s.Nice != nil {
+		nice := *s.Nice
+		changes = append(changes, ProcessChange{
+			Key:         "cpu.nice",
+			Description: fmt.Sprintf("Set niceness to %d", nice),
+			apply: func(pid int) error {
+				if err := cpu.SetNice(pid, nice); err != nil {
+					return fmt.Errorf("failed to set niceness: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if s.IOClass != "" {
+		class, level := s.IOClass, defaultIOPriority
+		if s.IOPriority != nil {
+			level = *s.IOPriority
+		}
+		description := fmt.Sprintf("Set I/O class to %s", class)
+		if class != cpu.IOClassIdle {
+			description += fmt.Sprintf(" with priority %d", level)
+		}
+		changes = append(changes, ProcessChange{
+			Key:         "cpu.io_class",
+			Description: description,
+			apply: func(pid int) error {
+				if err := cpu.SetIOPriority(pid, class, level); err != nil {
+					return fmt.Errorf("failed to set I/O priority: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	return changes
+}
+
+// ScopeCommand returns the systemd-run command that starts the game in its
+// own user scope, up to and including the "--" before the game's command,
+// or nil when the scope is not enabled.
+func (p *Profile) ScopeCommand(description string) []string {
+	s := p.CPU.Scope
+	if !IsTrue(s.Enabled) {
+		return nil
+	}
+
+	command := []string{"systemd-run", "--user", "--scope", "--quiet", "--collect"}
+	if description != "" {
+		command = append(command, "--description="+description)
+	}
+	property := func(name, value string) {
+		command = append(command, "-p", name+"="+value)
+	}
+	if s.CPUWeight > 0 {
+		property("CPUWeight", strconv.Itoa(s.CPUWeight))
+	}
+	if s.IOWeight > 0 {
+		property("IOWeight", strconv.Itoa(s.IOWeight))
+	}
+	if s.MemoryHigh != "" {
+		property("MemoryHigh", s.MemoryHigh)
+	}
+	if s.MemoryMax != "" {
+		property("MemoryMax", s.MemoryMax)
+	}
+	return append(command, "--")
+}
+
+// warnProcess returns the process settings that are valid but risky.
+func (s CPUSettings) warnProcess() []Issue {
+	var issues []Issue
+	if s.SchedPolicy.IsRealtime() {
+		issues = append(issues, Issue{Key: "cpu.sched_policy", Message: fmt.Sprintf("%s is a realtime policy; a game that spins on a core can starve the desktop and input, and leave the system unresponsive", s.SchedPolicy)})
+	}
+	return issues
+}
+
+func (s CPUSettings) validateProcess() []Issue {
+	var issues []Issue
+	issues = checkEnum(issues, "cpu.io_class", s.IOClass, validIOClasses)
+	issues = checkEnum(issues, "cpu.sched_policy", s.SchedPolicy, validSchedPolicies)
+
+	if s.Nice != nil && (*s.Nice < cpu.MinNice || *s.Nice > cpu.MaxNice) {
+		issues = append(issues, Issue{Key: "cpu.nice", Message: fmt.Sprintf("must be between %d and %d, got %d", cpu.MinNice, cpu.MaxNice, *s.Nice)})
+	}
+	if s.IOPriority != nil && (*s.IOPriority < 0 || *s.IOPriority > cpu.MaxIOPriority) {
+		issues = append(issues, Issue{Key: "cpu.io_priority", Message: fmt.Sprintf("must be between 0 and %d, got %d", cpu.MaxIOPriority, *s.IOPriority)})
+	}
+	if s.SchedPriority != nil && (*s.SchedPriority < cpu.MinSchedPriority || *s.SchedPriority > cpu.MaxSchedPriority) {
+		issues = append(issues, Issue{Key: "cpu.sched_priority", Message: fmt.Sprintf("must be between %d and %d, got %d", cpu.MinSchedPriority, cpu.MaxSchedPriority, *s.SchedPriority)})
+	}
+
+	for _, weight := range []struct {
+		key   string
+		value int
+	}{
+		{"cpu.scope.cpu_weight", s.Scope.CPUWeight},
+		{"cpu.scope.io_weight", s.Scope.IOWeight},
+	} {
+		if weight.value < 0 || weight.value > maxScopeWeight {
+			issues = append(issues, Issue{Key: weight.key, Message: fmt.Sprintf("must be between 1 and %d, got %d", maxScopeWeight, weight.value)})
+		}
+	}
+	for _, limit := range []struct{ key, value string }{
+		{"cpu.scope.memory_high", s.Scope.MemoryHigh},
+		{"cpu.scope.memory_max", s.Scope.MemoryMax},
+	} {
+		if limit.value != "" && !memorySizePattern.MatchString(limit.value) {
+			issues = append(issues, Issue{Key: limit.key, Message: fmt.Sprintf("invalid size %q (expected bytes with an optional K, M, G or T suffix, a percentage, or infinity)", limit.value)})
+		}
+	}
+	return issues
+}
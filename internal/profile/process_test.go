@@ -0,0 +1,89 @@
+package profile_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestProcessChanges(t *testing.T) {
+	nice := -5
+	p := &profile.Profile{CPU: profile.CPUSettings{
+		Nice:        &nice,
+		IOClass:     cpu.IOClassBestEffort,
+		SchedPolicy: cpu.SchedFIFO,
+	}}
+
+	var descriptions []string
+	for _, c := range p.ProcessChanges() {
+		descriptions = append(descriptions, c.Key+": "+c.Description)
+	}
+	want := []string{
+		"cpu.sched_policy: Set scheduling policy to fifo with priority 1",
+		"cpu.nice: Set niceness to -5",
+		"cpu.io_class: Set I/O class to best-effort with priority 4",
+	}
+	if !slices.Equal(descriptions, want) {
+		t.Errorf("ProcessChanges() = %q, want %q", descriptions, want)
+	}
+}
+
+func TestScopeCommand(t *testing.T) {
+	p := &profile.Profile{}
+	if got := p.ScopeCommand("spela: Foo"); got != nil {
+		t.Errorf("ScopeCommand() = %q, want nil when disabled", got)
+	}
+
+	p.CPU.Scope = profile.ScopeSettings{Enabled: profile.Bool(true), CPUWeight: 1000, MemoryMax: "16G"}
+	want := []string{
+		"systemd-run", "--user", "--scope", "--quiet", "--collect", "--description=spela: Foo",
+		"-p", "CPUWeight=1000", "-p", "MemoryMax=16G", "--",
+	}
+	if got := p.ScopeCommand("spela: Foo"); !slices.Equal(got, want) {
+		t.Errorf("ScopeCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateProcessSettings(t *testing.T) {
+	nice, ioPriority := 20, 8
+	p := &profile.Profile{CPU: profile.CPUSettings{
+		Nice:          &nice,
+		IOClass:       "rt",
+		IOPriority:    &ioPriority,
+		SchedPolicy:   "deadline",
+		SchedPriority: profile.Int(100),
+		Scope:         profile.ScopeSettings{CPUWeight: 20000, MemoryHigh: "8GB", MemoryMax: "50%"},
+	}}
+
+	var keys []string
+	for _, issue := range p.Validate(gpu.GPUGenerationUnknown) {
+		keys = append(keys, issue.Key)
+	}
+	want := []string{
+		"cpu.io_class", "cpu.sched_policy", "cpu.nice", "cpu.io_priority", "cpu.sched_priority",
+		"cpu.scope.cpu_weight", "cpu.scope.memory_high",
+	}
+	if !slices.Equal(keys, want) {
+		t.Errorf("Validate() keys = %q, want %q", keys, want)
+	}
+}
+
+func TestRealtimeSchedPolicyWarning(t *testing.T) {
+	for _, policy := range []cpu.SchedPolicy{cpu.SchedFIFO, cpu.SchedRR} {
+		p := &profile.Profile{CPU: profile.CPUSettings{SchedPolicy: policy}}
+		if issues := p.Validate(gpu.GPUGenerationUnknown); len(issues) != 0 {
+			t.Errorf("Validate() with %s = %v, want valid", policy, issues)
+		}
+		if warnings := p.Warnings(); len(warnings) != 1 || warnings[0].Key != "cpu.sched_policy" {
+			t.Errorf("Warnings() with %s = %v, want a cpu.sched_policy warning", policy, warnings)
+		}
+	}
+
+	p := &profile.Profile{CPU: profile.CPUSettings{SchedPolicy: cpu.SchedBatch}}
+	if warnings := p.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() with batch = %v, want none", warnings)
+	}
+}
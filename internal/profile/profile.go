@@ -1,5 +1,10 @@
 package profile
 
+import (
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/hook"
+)
+
 type DLSSMode string
 
 const (
@@ -53,32 +58,122 @@ const (
 	DLSSModelPresetK    DLSSModelPreset = "k"
 	DLSSModelPresetL    DLSSModelPreset = "l"
 	DLSSModelPresetM    DLSSModelPreset = "m"
+	// DLSSModelPresetOff selects the render preset in sr_preset instead of
+	// a transformer model, also when one is inherited.
+	DLSSModelPresetOff DLSSModelPreset = "off"
 )
 
+// Bool returns a pointer to v. Boolean settings are pointers so that an
+// unset value, which is inherited from the layers below, differs from an
+// explicit false.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// IsTrue reports whether a boolean setting is set to true.
+func IsTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// Int returns a pointer to v, for integer settings where 0 is a value that
+// overrides an inherited one.
+func Int(v int) *int {
+	return &v
+}
+
+// IntValue returns the value of an integer setting, or 0 when it is unset.
+func IntValue(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
 type Profile struct {
-	Name string `yaml:"name,omitempty"`
+	SchemaVersion int `yaml:"schema_version,omitempty"`
+
+	Name    string   `yaml:"name,omitempty"`
+	Extends BaseList `yaml:"extends,omitempty"`
+	Preset  string   `yaml:"preset,omitempty"`
+
+	DLSS      DLSSSettings      `yaml:"dlss,omitempty"`
+	GPU       GPUSettings       `yaml:"gpu,omitempty"`
+	CPU       CPUSettings       `yaml:"cpu,omitempty"`
+	Proton    ProtonSettings    `yaml:"proton,omitempty"`
+	Gamescope GamescopeSettings `yaml:"gamescope,omitempty"`
+	GameMode  GameModeSettings  `yaml:"gamemode,omitempty"`
+	Ludusavi  LudusaviSettings  `yaml:"ludusavi,omitempty"`
+	Overlay   OverlaySettings   `yaml:"overlay,omitempty"`
+	Logging   LoggingSettings   `yaml:"logging,omitempty"`
+
+	Env      map[string]EnvVar `yaml:"env,omitempty"`
+	Args     ArgsSettings      `yaml:"args,omitempty"`
+	Wrappers []string          `yaml:"wrappers,omitempty"`
+	Hooks    hook.Settings     `yaml:"hooks,omitempty"`
 
-	DLSS     DLSSSettings     `yaml:"dlss,omitempty"`
-	GPU      GPUSettings      `yaml:"gpu,omitempty"`
-	CPU      CPUSettings      `yaml:"cpu,omitempty"`
-	Proton   ProtonSettings   `yaml:"proton,omitempty"`
-	Ludusavi LudusaviSettings `yaml:"ludusavi,omitempty"`
-	Overlay  OverlaySettings  `yaml:"overlay,omitempty"`
+	When []Conditional `yaml:"when,omitempty"`
+
+	conditions *ConditionResult
 }
 
 type LudusaviSettings struct {
-	BackupOnLaunch  bool `yaml:"backup_on_launch,omitempty"`
-	RestoreOnLaunch bool `yaml:"restore_on_launch,omitempty"`
+	BackupOnLaunch  *bool `yaml:"backup_on_launch,omitempty"`
+	RestoreOnLaunch *bool `yaml:"restore_on_launch,omitempty"`
+}
+
+// Defaults for the logging settings left at zero.
+const (
+	DefaultLogMaxSizeMB    = 10
+	DefaultLogKeepSessions = 10
+)
+
+// LoggingSettings control the logs written to the directory of each recorded
+// session.
+type LoggingSettings struct {
+	// Capture writes the game's output to game.log, and still shows it when
+	// spela runs in a terminal.
+	Capture *bool `yaml:"capture,omitempty"`
+	// Debug turns on Proton, DXVK and VKD3D logging into the session
+	// directory.
+	Debug *bool `yaml:"debug,omitempty"`
+	// MaxSizeMB caps game.log. When full it is rotated to game.log.1,
+	// replacing the previous one. Debug logs, which spela does not write
+	// itself, are cut to their last MaxSizeMB when the session ends.
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+	// KeepSessions is how many of the game's latest sessions keep their
+	// logs.
+	KeepSessions int `yaml:"keep_sessions,omitempty"`
+}
+
+// Enabled reports whether the session directory gets any logs.
+func (s LoggingSettings) Enabled() bool {
+	return IsTrue(s.Capture) || IsTrue(s.Debug)
+}
+
+// MaxSize returns the cap on game.log in bytes.
+func (s LoggingSettings) MaxSize() int64 {
+	if s.MaxSizeMB == 0 {
+		return DefaultLogMaxSizeMB << 20
+	}
+	return int64(s.MaxSizeMB) << 20
+}
+
+// Keep returns how many sessions keep their logs.
+func (s LoggingSettings) Keep() int {
+	if s.KeepSessions == 0 {
+		return DefaultLogKeepSessions
+	}
+	return s.KeepSessions
 }
 
 type OverlaySettings struct {
-	Enabled       bool   `yaml:"enabled,omitempty"`
+	Enabled       *bool  `yaml:"enabled,omitempty"`
 	Position      string `yaml:"position,omitempty"`
-	ShowFPS       bool   `yaml:"show_fps,omitempty"`
-	ShowFrametime bool   `yaml:"show_frametime,omitempty"`
-	ShowCPU       bool   `yaml:"show_cpu,omitempty"`
-	ShowGPU       bool   `yaml:"show_gpu,omitempty"`
-	ShowVRAM      bool   `yaml:"show_vram,omitempty"`
+	ShowFPS       *bool  `yaml:"show_fps,omitempty"`
+	ShowFrametime *bool  `yaml:"show_frametime,omitempty"`
+	ShowCPU       *bool  `yaml:"show_cpu,omitempty"`
+	ShowGPU       *bool  `yaml:"show_gpu,omitempty"`
+	ShowVRAM      *bool  `yaml:"show_vram,omitempty"`
 	ToggleKey     string `yaml:"toggle_key,omitempty"`
 }
 
@@ -86,34 +181,138 @@ type DLSSSettings struct {
 	SRMode        DLSSMode        `yaml:"sr_mode,omitempty"`
 	SRPreset      DLSSPreset      `yaml:"sr_preset,omitempty"`
 	SRModelPreset DLSSModelPreset `yaml:"sr_model_preset,omitempty"`
-	SROverride    bool            `yaml:"sr_override,omitempty"`
+	SROverride    *bool           `yaml:"sr_override,omitempty"`
 	RRMode        DLSSMode        `yaml:"rr_mode,omitempty"`
 	RRPreset      DLSSPreset      `yaml:"rr_preset,omitempty"`
-	RROverride    bool            `yaml:"rr_override,omitempty"`
-	FGEnabled     bool            `yaml:"fg_enabled,omitempty"`
-	FGOverride    bool            `yaml:"fg_override,omitempty"`
-	MultiFrame    int             `yaml:"multi_frame,omitempty"`
-	Indicator     bool            `yaml:"indicator,omitempty"`
-	FGIndicator   bool            `yaml:"fg_indicator,omitempty"`
+	RROverride    *bool           `yaml:"rr_override,omitempty"`
+	FGEnabled     *bool           `yaml:"fg_enabled,omitempty"`
+	FGOverride    *bool           `yaml:"fg_override,omitempty"`
+	MultiFrame    *int            `yaml:"multi_frame,omitempty"`
+	Indicator     *bool           `yaml:"indicator,omitempty"`
+	FGIndicator   *bool           `yaml:"fg_indicator,omitempty"`
 }
 
 type GPUSettings struct {
-	ShaderCache          bool   `yaml:"shader_cache,omitempty"`
+	ShaderCache          *bool  `yaml:"shader_cache,omitempty"`
 	ShaderCachePath      string `yaml:"shader_cache_path,omitempty"`
-	ThreadedOptimization bool   `yaml:"threaded_optimization,omitempty"`
-	ClockOffset          int    `yaml:"clock_offset,omitempty"`
-	MemoryOffset         int    `yaml:"memory_offset,omitempty"`
+	ThreadedOptimization *bool  `yaml:"threaded_optimization,omitempty"`
+	ClockOffset          *int   `yaml:"clock_offset,omitempty"`
+	MemoryOffset         *int   `yaml:"memory_offset,omitempty"`
 	PowerMizer           string `yaml:"power_mizer,omitempty"`
 }
 
+// SchedulerNone as cpu.scheduler stops the sched_ext scheduler for the
+// session.
+const SchedulerNone = "none"
+
 type CPUSettings struct {
 	Governor string `yaml:"governor,omitempty"`
 	SMT      *bool  `yaml:"smt,omitempty"`
+	// Affinity is a CPU list for taskset, such as "0-7", or one of
+	// cpu.AffinityPresets, resolved against the CPU topology at launch.
 	Affinity string `yaml:"affinity,omitempty"`
+	// Scheduler is the sched_ext scheduler to run during the session, such
+	// as "lavd" or "scx_lavd", or "none" to stop the one that is running.
+	// SchedulerMode is the scx_loader mode to run it in.
+	Scheduler     string      `yaml:"scheduler,omitempty"`
+	SchedulerMode cpu.SCXMode `yaml:"scheduler_mode,omitempty"`
+
+	// Nice, IOClass and IOPriority, and SchedPolicy and SchedPriority are
+	// applied to the game's main process once it is running. IOPriority
+	// defaults to 4, and SchedPriority, which only the fifo and rr policies
+	// use, to 1.
+	Nice          *int            `yaml:"nice,omitempty"`
+	IOClass       cpu.IOClass     `yaml:"io_class,omitempty"`
+	IOPriority    *int            `yaml:"io_priority,omitempty"`
+	SchedPolicy   cpu.SchedPolicy `yaml:"sched_policy,omitempty"`
+	SchedPriority *int            `yaml:"sched_priority,omitempty"`
+	// Scope runs the game in its own systemd user scope.
+	Scope ScopeSettings `yaml:"scope,omitempty"`
+}
+
+// ScopeSettings are the resource controls of the systemd scope the game runs
+// in. Weights range from 1 to 10000, with 100 as the default for other
+// units; memory limits take systemd sizes such as 8G or 75%.
+type ScopeSettings struct {
+	Enabled    *bool  `yaml:"enabled,omitempty"`
+	CPUWeight  int    `yaml:"cpu_weight,omitempty"`
+	IOWeight   int    `yaml:"io_weight,omitempty"`
+	MemoryHigh string `yaml:"memory_high,omitempty"`
+	MemoryMax  string `yaml:"memory_max,omitempty"`
+}
+
+// GameModeSettings run the game under Feral GameMode.
+type GameModeSettings struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// Method is how the game is put in GameMode: dbus registers it with the
+	// daemon, wrapper runs it through gamemoderun, and auto, the default,
+	// uses D-Bus when the daemon can be reached.
+	Method GameModeMethod `yaml:"method,omitempty"`
+	// OnConflict is what happens to the cpu settings GameMode is configured
+	// to change as well: defer, the default, leaves them to GameMode, and
+	// warn applies them anyway.
+	OnConflict GameModeConflict `yaml:"on_conflict,omitempty"`
 }
 
+type GameModeMethod string
+
+const (
+	GameModeMethodAuto    GameModeMethod = "auto"
+	GameModeMethodDBus    GameModeMethod = "dbus"
+	GameModeMethodWrapper GameModeMethod = "wrapper"
+)
+
+type GameModeConflict string
+
+const (
+	GameModeConflictDefer GameModeConflict = "defer"
+	GameModeConflictWarn  GameModeConflict = "warn"
+)
+
 type ProtonSettings struct {
-	EnableWayland    bool `yaml:"enable_wayland,omitempty"`
-	EnableHDR        bool `yaml:"enable_hdr,omitempty"`
-	EnableNGXUpdater bool `yaml:"enable_ngx_updater,omitempty"`
+	EnableWayland    *bool `yaml:"enable_wayland,omitempty"`
+	EnableHDR        *bool `yaml:"enable_hdr,omitempty"`
+	EnableNGXUpdater *bool `yaml:"enable_ngx_updater,omitempty"`
+}
+
+// GamescopeSettings run the game in a nested gamescope session. Sizes and
+// rates left at zero are gamescope's own defaults.
+type GamescopeSettings struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// OutputWidth and OutputHeight are the size of the gamescope window;
+	// NestedWidth and NestedHeight the resolution the game renders at.
+	OutputWidth  int `yaml:"output_width,omitempty"`
+	OutputHeight int `yaml:"output_height,omitempty"`
+	NestedWidth  int `yaml:"nested_width,omitempty"`
+	NestedHeight int `yaml:"nested_height,omitempty"`
+	RefreshRate  int `yaml:"refresh_rate,omitempty"`
+	// Upscaler is the filter used to scale the game up to the output size.
+	Upscaler GamescopeUpscaler `yaml:"upscaler,omitempty"`
+	// Sharpness applies to the fsr and nis upscalers, from 0 (sharpest) to
+	// 20.
+	Sharpness *int          `yaml:"sharpness,omitempty"`
+	HDR       *bool         `yaml:"hdr,omitempty"`
+	Mode      GamescopeMode `yaml:"mode,omitempty"`
+	// FrameLimit caps the game's frame rate.
+	FrameLimit int `yaml:"frame_limit,omitempty"`
+	// ExtraFlags are passed to gamescope after the other options.
+	ExtraFlags []string `yaml:"extra_flags,omitempty"`
 }
+
+type GamescopeUpscaler string
+
+const (
+	GamescopeUpscalerFSR     GamescopeUpscaler = "fsr"
+	GamescopeUpscalerNIS     GamescopeUpscaler = "nis"
+	GamescopeUpscalerLinear  GamescopeUpscaler = "linear"
+	GamescopeUpscalerNearest GamescopeUpscaler = "nearest"
+	GamescopeUpscalerPixel   GamescopeUpscaler = "pixel"
+)
+
+type GamescopeMode string
+
+const (
+	GamescopeModeWindowed   GamescopeMode = "windowed"
+	GamescopeModeFullscreen GamescopeMode = "fullscreen"
+	GamescopeModeBorderless GamescopeMode = "borderless"
+)
@@ -0,0 +1,163 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentSchemaVersion is written to every saved profile. Files with an
+// older version are migrated when they are read; newer ones are rejected.
+const CurrentSchemaVersion = 1
+
+// migrations[v] upgrades a document from schema version v to v+1.
+var migrations = []func(doc *yaml.Node) error{
+	// Version 0 files predate schema_version and need no other changes.
+	0: func(*yaml.Node) error { return nil },
+}
+
+// Issue is a single problem found in a profile file.
+type Issue struct {
+	Line    int
+	Key     string
+	Message string
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", i.Line)
+	}
+	if i.Key != "" {
+		b.WriteString(i.Key + ": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// ValidationError lists every issue found in one profile file.
+type ValidationError struct {
+	Path   string
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		lines[i] = issue.String()
+	}
+	if len(lines) == 1 {
+		return fmt.Sprintf("%s: %s", e.Path, lines[0])
+	}
+	return fmt.Sprintf("%s:\n  %s", e.Path, strings.Join(lines, "\n  "))
+}
+
+var (
+	yamlLinePattern     = regexp.MustCompile(`^line (\d+): (.*)$`)
+	unknownFieldPattern = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+// parseProfile migrates and strictly decodes a profile document. Unknown keys
+// and values of the wrong type are reported with their line numbers. The
+// returned data is the document after migration.
+func parseProfile(path string, data []byte) (*Profile, []byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return &Profile{SchemaVersion: CurrentSchemaVersion}, nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, &ValidationError{Path: path, Issues: []Issue{{Line: root.Line, Message: "profile must be a mapping"}}}
+	}
+
+	version, err := schemaVersion(root)
+	if err != nil {
+		return nil, nil, &ValidationError{Path: path, Issues: []Issue{{Line: root.Line, Key: "schema_version", Message: err.Error()}}}
+	}
+	if version > CurrentSchemaVersion {
+		return nil, nil, fmt.Errorf("%s: schema_version %d is newer than this version of spela supports (%d)", path, version, CurrentSchemaVersion)
+	}
+
+	if version < CurrentSchemaVersion {
+		for v := version; v < CurrentSchemaVersion; v++ {
+			if err := migrations[v](root); err != nil {
+				return nil, nil, fmt.Errorf("%s: failed to migrate from schema version %d: %w", path, v, err)
+			}
+		}
+		if data, err = yaml.Marshal(&doc); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var p Profile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, nil, &ValidationError{Path: path, Issues: decodeIssues(typeErr)}
+		}
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.SchemaVersion = CurrentSchemaVersion
+
+	return &p, data, nil
+}
+
+func schemaVersion(root *yaml.Node) (int, error) {
+	node := lookupNode(root, "schema_version")
+	if node == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(node.Value)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid version %q", node.Value)
+	}
+	return version, nil
+}
+
+func decodeIssues(err *yaml.TypeError) []Issue {
+	issues := make([]Issue, 0, len(err.Errors))
+	for _, msg := range err.Errors {
+		var issue Issue
+		if m := yamlLinePattern.FindStringSubmatch(msg); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+			msg = m[2]
+		}
+		if m := unknownFieldPattern.FindStringSubmatch(msg); m != nil {
+			issue.Key = m[1]
+			msg = "unknown field"
+		}
+		issue.Message = msg
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// lookupNode returns the value node at a dotted key path below a mapping.
+func lookupNode(node *yaml.Node, path string) *yaml.Node {
+	for _, key := range strings.Split(path, ".") {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+	return node
+}
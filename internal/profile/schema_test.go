@@ -0,0 +1,136 @@
+package profile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	writeProfiles(t, map[string]string{"1.yaml": "dlss:\n  sr_mode: quality\n  sr_mod: balanced\n"})
+
+	_, err := profile.Load(1)
+	var verr *profile.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want ValidationError", err)
+	}
+	if len(verr.Issues) != 1 || verr.Issues[0].Line != 3 || verr.Issues[0].Key != "sr_mod" {
+		t.Errorf("issues = %+v, want unknown sr_mod on line 3", verr.Issues)
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"1.yaml": "dlss:\n  sr_mode: quality\n",
+		"2.yaml": "schema_version: 99\n",
+	})
+
+	p, err := profile.Load(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SchemaVersion != profile.CurrentSchemaVersion || p.DLSS.SRMode != profile.DLSSModeQuality {
+		t.Errorf("unversioned profile loaded as %+v", p)
+	}
+
+	if err := profile.Save(1, p); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(profile.Path(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "schema_version: 1\n") {
+		t.Errorf("saved profile does not start with schema_version:\n%s", data)
+	}
+
+	if _, err := profile.Load(2); err == nil || !strings.Contains(err.Error(), "newer than this version") {
+		t.Errorf("got %v, want newer schema error", err)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"1.yaml": `schema_version: 1
+dlss:
+  sr_preset: Z
+  sr_mode: quality
+  multi_frame: 9
+cpu:
+  governor: turbo
+  affinity: 0-3,x
+`,
+		"2.yaml": "dlss:\n  fg_override: true\n  fg_enabled: true\n  multi_frame: 3\n",
+		"3.yaml": "dlss:\n  sr_mode: dlaa\ncpu:\n  affinity: 0-7:2,12\n",
+	})
+
+	var verr *profile.ValidationError
+	err := profile.ValidateFile(profile.Path(1), gpu.GPUGenerationUnknown)
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want ValidationError", err)
+	}
+	lines := map[string]int{}
+	for _, issue := range verr.Issues {
+		lines[issue.Key] = issue.Line
+	}
+	want := map[string]int{"dlss.sr_preset": 3, "dlss.multi_frame": 5, "cpu.governor": 7, "cpu.affinity": 8}
+	for key, line := range want {
+		if lines[key] != line {
+			t.Errorf("%s reported on line %d, want %d", key, lines[key], line)
+		}
+	}
+	if len(verr.Issues) != len(want) {
+		t.Errorf("issues = %+v", verr.Issues)
+	}
+
+	tests := []struct {
+		gen  gpu.GPUGeneration
+		want string
+	}{
+		{gpu.GPUGenerationUnknown, ""},
+		{gpu.GPUGenerationBlackwell, ""},
+		{gpu.GPUGenerationAdaLovelace, "must be at most 1 on"},
+		{gpu.GPUGenerationAmpere, "frame generation needs an RTX 40"},
+	}
+	for _, tt := range tests {
+		err := profile.ValidateFile(profile.Path(2), tt.gen)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.gen, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.gen, err, tt.want)
+		}
+	}
+
+	if err := profile.ValidateFile(profile.Path(3), gpu.GPUGenerationAmpere); err != nil {
+		t.Errorf("valid profile: %v", err)
+	}
+}
+
+func TestListSkipsInvalidProfiles(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"1.yaml": "name: good\n",
+		"2.yaml": "dlss: [broken\n",
+	})
+
+	profiles, err := profile.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || profiles[1] == nil {
+		t.Errorf("profiles = %v, want only app 1", profiles)
+	}
+
+	files, err := profile.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "1.yaml" {
+		t.Errorf("Files = %v", files)
+	}
+}
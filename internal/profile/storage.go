@@ -3,6 +3,7 @@ package profile
 import (
 	"errors"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -26,12 +27,29 @@ func profilePath(appID uint64) string {
 	return filepath.Join(profilesDir(), strconv.FormatUint(appID, 10)+".yaml")
 }
 
+// Path returns the file a game's profile is stored in.
+func Path(appID uint64) string {
+	return profilePath(appID)
+}
+
+// DefaultPath returns the file the default profile is stored in.
+func DefaultPath() string {
+	return defaultProfilePath()
+}
+
 func EnsureProfilesDir() error {
 	return os.MkdirAll(profilesDir(), 0o755)
 }
 
 func Load(appID uint64) (*Profile, error) {
-	path := profilePath(appID)
+	return loadFile(profilePath(appID))
+}
+
+func LoadDefault() (*Profile, error) {
+	return loadFile(defaultProfilePath())
+}
+
+func loadFile(path string) (*Profile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
@@ -40,69 +58,55 @@ func Load(appID uint64) (*Profile, error) {
 		return nil, err
 	}
 
-	var p Profile
-	if err := yaml.Unmarshal(data, &p); err != nil {
-		return nil, err
-	}
-
-	return &p, nil
+	p, _, err := parseProfile(path, data)
+	return p, err
 }
 
-func LoadDefault() (*Profile, error) {
-	data, err := os.ReadFile(defaultProfilePath())
-	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return nil, nil
-		}
-		return nil, err
+func writeProfile(path string, p *Profile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
 	}
 
-	var p Profile
-	if err := yaml.Unmarshal(data, &p); err != nil {
-		return nil, err
+	p.SchemaVersion = CurrentSchemaVersion
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
 	}
 
-	return &p, nil
+	return os.WriteFile(path, data, 0o644)
 }
 
+// LoadEffective returns the layered profile for a game (see Resolve), or nil
+// if neither a game nor a default profile exists.
 func LoadEffective(appID uint64) (*Profile, error) {
-	p, err := Load(appID)
+	r, err := Resolve(appID)
 	if err != nil {
 		return nil, err
 	}
-	if p != nil {
-		return p, nil
+	if len(r.Layers) == 0 {
+		return nil, nil
 	}
-	return LoadDefault()
+	return r.Profile, nil
 }
 
+// Save writes a game profile and records it in the profile's history. The
+// file on disk is recorded first as well, so hand edits made since the last
+// save are not lost.
 func Save(appID uint64, p *Profile) error {
-	if err := EnsureProfilesDir(); err != nil {
-		return err
-	}
-
-	data, err := yaml.Marshal(p)
-	if err != nil {
+	recordHistory(appID)
+	if err := writeProfile(profilePath(appID), p); err != nil {
 		return err
 	}
-
-	return os.WriteFile(profilePath(appID), data, 0o644)
+	recordHistory(appID)
+	return nil
 }
 
 func SaveDefault(p *Profile) error {
-	if err := EnsureProfilesDir(); err != nil {
-		return err
-	}
-
-	data, err := yaml.Marshal(p)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(defaultProfilePath(), data, 0o644)
+	return writeProfile(defaultProfilePath(), p)
 }
 
 func Delete(appID uint64) error {
+	recordHistory(appID)
 	path := profilePath(appID)
 	err := os.Remove(path)
 	if errors.Is(err, fs.ErrNotExist) {
@@ -149,7 +153,11 @@ func List() (map[uint64]*Profile, error) {
 		}
 
 		p, err := Load(appID)
-		if err != nil || p == nil {
+		if err != nil {
+			slog.Warn("skipping invalid profile", "app_id", appID, "error", err)
+			continue
+		}
+		if p == nil {
 			continue
 		}
 
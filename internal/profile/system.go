@@ -0,0 +1,212 @@
+package profile
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/state"
+)
+
+// nvidia-settings GPUPowerMizerMode values.
+const (
+	powerMizerAdaptive = 0
+	powerMizerMax      = 1
+)
+
+// SystemChange is a machine-wide setting that a profile changes for the
+// duration of a game session. Unlike environment variables it outlives the
+// game process, so it has to be reverted when the session ends.
+type SystemChange struct {
+	// Key is the profile setting the change comes from, e.g. "cpu.governor".
+	Key         string
+	Description string
+	snapshot    func() (state.Reversal, error)
+	apply       func() error
+}
+
+// Snapshot reads the current state and returns the reversal that brings it
+// back. It is taken before the change is made, so that the reversal can be
+// journaled first.
+func (c SystemChange) Snapshot() (state.Reversal, error) {
+	return c.snapshot()
+}
+
+// Apply makes the change.
+func (c SystemChange) Apply() error {
+	return c.apply()
+}
+
+// SystemChanges lists the system changes the profile makes when a game is
+// launched, in the order they are applied.
+func (p *Profile) SystemChanges() []SystemChange {
+	var changes []SystemChange
+
+	if p.CPU.Governor != "" {
+		governor := cpu.Governor(p.CPU.Governor)
+		changes = append(changes, SystemChange{
+			Key:         "cpu.governor",
+			Description: fmt.Sprintf("Set CPU governor to %s", governor),
+			snapshot: func() (state.Reversal, error) {
+				previous, err := cpu.GetCurrentGovernor()
+				if err != nil {
+					return state.Reversal{}, fmt.Errorf("failed to read CPU governor: %w", err)
+				}
+				return state.Reversal{Kind: state.ReverseGovernor, Value: string(previous)}, nil
+			},
+			apply: func() error { return cpu.SetGovernor(governor) },
+		})
+	}
+
+	if p.CPU.SMT != nil {
+		enabled := *p.CPU.SMT
+		status := "off"
+		if enabled {
+			status = "on"
+		}
+		changes = append(changes, SystemChange{
+			Key:         "cpu.smt",
+			Description: fmt.Sprintf("Turn SMT %s", status),
+			snapshot:    snapshotSMT,
+			apply: func() error {
+				if err := cpu.SetSMT(enabled); err != nil {
+					return fmt.Errorf("failed to set SMT: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if p.CPU.Scheduler != "" {
+		changes = append(changes, p.schedulerChange())
+	}
+
+	if p.GPU.ClockOffset != nil {
+		offset := *p.GPU.ClockOffset
+		changes = append(changes, SystemChange{
+			Key:         "gpu.clock_offset",
+			Description: fmt.Sprintf("Set GPU clock offset to %+d MHz", offset),
+			snapshot:    snapshotGPUClocks,
+			apply: func() error {
+				if err := gpu.SetGraphicsClockOffset(offset); err != nil {
+					return fmt.Errorf("failed to set GPU clock offset: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if p.GPU.MemoryOffset != nil {
+		offset := *p.GPU.MemoryOffset
+		changes = append(changes, SystemChange{
+			Key:         "gpu.memory_offset",
+			Description: fmt.Sprintf("Set GPU memory clock offset to %+d MHz", offset),
+			snapshot:    snapshotGPUClocks,
+			apply: func() error {
+				if err := gpu.SetMemoryClockOffset(offset); err != nil {
+					return fmt.Errorf("failed to set GPU memory clock offset: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if mode, ok := powerMizerMode(p.GPU.PowerMizer); ok {
+		changes = append(changes, SystemChange{
+			Key:         "gpu.power_mizer",
+			Description: fmt.Sprintf("Set GPU PowerMizer mode to %s", p.GPU.PowerMizer),
+			snapshot: func() (state.Reversal, error) {
+				settings, err := gpu.GetNVIDIASettings()
+				if err != nil {
+					return state.Reversal{}, fmt.Errorf("failed to read PowerMizer mode: %w", err)
+				}
+				return state.Reversal{Kind: state.ReversePowerMizer, Value: strconv.Itoa(settings.PowerMizerMode)}, nil
+			},
+			apply: func() error {
+				if err := gpu.SetPowerMizerMode(mode); err != nil {
+					return fmt.Errorf("failed to set PowerMizer mode: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	return changes
+}
+
+// schedulerChange switches the sched_ext scheduler, or stops it for
+// SchedulerNone. The scheduler that ran before is restored afterwards.
+func (p *Profile) schedulerChange() SystemChange {
+	scheduler, mode := cpu.SCXSchedulerName(p.CPU.Scheduler), p.CPU.SchedulerMode
+	description := fmt.Sprintf("Switch sched_ext scheduler to %s", scheduler)
+	if mode != "" {
+		description += fmt.Sprintf(" (%s mode)", mode)
+	}
+	if p.CPU.Scheduler == SchedulerNone {
+		scheduler, description = "", "Stop the sched_ext scheduler"
+	}
+
+	return SystemChange{
+		Key:         "cpu.scheduler",
+		Description: description,
+		snapshot: func() (state.Reversal, error) {
+			current, err := cpu.SCXStatus()
+			if err != nil {
+				return state.Reversal{}, fmt.Errorf("failed to read sched_ext scheduler: %w", err)
+			}
+			return state.SCXReversal(current), nil
+		},
+		apply: func() error {
+			if current, err := cpu.SCXStatus(); err == nil && schedulerRunning(current, scheduler, mode) {
+				return nil
+			}
+			if scheduler == "" {
+				if err := cpu.SCXStop(); err != nil {
+					return fmt.Errorf("failed to stop sched_ext scheduler: %w", err)
+				}
+				return nil
+			}
+			if err := cpu.SCXStart(scheduler, mode); err != nil {
+				return fmt.Errorf("failed to switch sched_ext scheduler: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// schedulerRunning reports whether scheduler already runs in mode, or no
+// scheduler runs when scheduler is "". An empty mode matches any.
+func schedulerRunning(current cpu.SCXState, scheduler string, mode cpu.SCXMode) bool {
+	if scheduler == "" {
+		return !current.Running
+	}
+	return current.Running && current.Scheduler == scheduler && (mode == "" || current.Mode == "" || current.Mode == mode)
+}
+
+func snapshotSMT() (state.Reversal, error) {
+	previous, err := cpu.GetSMTStatus()
+	if err != nil {
+		return state.Reversal{}, fmt.Errorf("failed to read SMT status: %w", err)
+	}
+	return state.Reversal{Kind: state.ReverseSMT, Value: strconv.FormatBool(previous)}, nil
+}
+
+// snapshotGPUClocks returns a reset of the clock offsets; the driver has no
+// offset to read back.
+func snapshotGPUClocks() (state.Reversal, error) {
+	return state.Reversal{Kind: state.ReverseGPUClocks}, nil
+}
+
+// powerMizerMode maps a power_mizer setting to the driver value. "auto"
+// leaves the driver's choice alone.
+func powerMizerMode(setting string) (int, bool) {
+	switch setting {
+	case "adaptive":
+		return powerMizerAdaptive, true
+	case "max":
+		return powerMizerMax, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,204 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/hook"
+)
+
+// maxMultiFrame is the most frames any GPU generation can generate per
+// rendered frame. Validate checks the limit of the detected generation when
+// it is known.
+var maxMultiFrame = gpu.GPUGenerationBlackwell.MaxMultiFrame()
+
+var (
+	validDLSSModes = []DLSSMode{
+		DLSSModeOff, DLSSModeUltraPerformance, DLSSModePerformance,
+		DLSSModeBalanced, DLSSModeQuality, DLSSModeDLAA,
+	}
+	validDLSSPresets = []DLSSPreset{
+		DLSSPresetDefault, DLSSPresetA, DLSSPresetB, DLSSPresetC, DLSSPresetD, DLSSPresetE,
+		DLSSPresetF, DLSSPresetJ, DLSSPresetK, DLSSPresetL, DLSSPresetM,
+	}
+	validModelPresets = []DLSSModelPreset{
+		DLSSModelPresetAuto, DLSSModelPresetK, DLSSModelPresetL, DLSSModelPresetM, DLSSModelPresetOff,
+	}
+	validPowerMizerModes   = []string{"auto", "adaptive", "max"}
+	validGovernors         = []string{"performance", "powersave", "ondemand", "conservative", "schedutil", "userspace"}
+	validGameModeMethods   = []GameModeMethod{GameModeMethodAuto, GameModeMethodDBus, GameModeMethodWrapper}
+	validGameModeConflicts = []GameModeConflict{GameModeConflictDefer, GameModeConflictWarn}
+	schedulerNamePattern   = regexp.MustCompile(`^[a-z0-9_]+$`)
+	validOverlayPositions  = []string{
+		"top-left", "top-center", "top-right", "middle-left", "middle-right",
+		"bottom-left", "bottom-center", "bottom-right",
+	}
+)
+
+func checkEnum[T ~string](issues []Issue, key string, value T, valid []T) []Issue {
+	if value == "" || slices.Contains(valid, value) {
+		return issues
+	}
+	names := make([]string, len(valid))
+	for i, v := range valid {
+		names[i] = string(v)
+	}
+	return append(issues, Issue{Key: key, Message: fmt.Sprintf("invalid value %q (expected one of %s)", value, strings.Join(names, ", "))})
+}
+
+// Validate reports values that decode but cannot take effect: unknown enum
+// values, malformed CPU lists, and frame generation settings the GPU cannot
+// run. Hardware checks are skipped when gen is GPUGenerationUnknown.
+func (p *Profile) Validate(gen gpu.GPUGeneration) []Issue {
+	var issues []Issue
+
+	for _, base := range p.Extends {
+		if !baseNamePattern.MatchString(base) {
+			issues = append(issues, Issue{Key: "extends", Message: fmt.Sprintf("invalid base profile name %q", base)})
+		}
+	}
+
+	issues = checkEnum(issues, "dlss.sr_mode", p.DLSS.SRMode, validDLSSModes)
+	issues = checkEnum(issues, "dlss.rr_mode", p.DLSS.RRMode, validDLSSModes)
+	issues = checkEnum(issues, "dlss.sr_preset", p.DLSS.SRPreset, validDLSSPresets)
+	issues = checkEnum(issues, "dlss.rr_preset", p.DLSS.RRPreset, validDLSSPresets)
+	issues = checkEnum(issues, "dlss.sr_model_preset", p.DLSS.SRModelPreset, validModelPresets)
+	issues = checkEnum(issues, "gpu.power_mizer", p.GPU.PowerMizer, validPowerMizerModes)
+	issues = checkEnum(issues, "cpu.governor", p.CPU.Governor, validGovernors)
+	issues = checkEnum(issues, "cpu.scheduler_mode", p.CPU.SchedulerMode, cpu.SCXModes)
+	if p.CPU.Scheduler != "" && p.CPU.Scheduler != SchedulerNone && !schedulerNamePattern.MatchString(p.CPU.Scheduler) {
+		issues = append(issues, Issue{Key: "cpu.scheduler", Message: fmt.Sprintf("invalid scheduler name %q (expected a name like lavd or scx_lavd, or none)", p.CPU.Scheduler)})
+	}
+	issues = checkEnum(issues, "overlay.position", p.Overlay.Position, validOverlayPositions)
+	issues = checkEnum(issues, "gamemode.method", p.GameMode.Method, validGameModeMethods)
+	issues = checkEnum(issues, "gamemode.on_conflict", p.GameMode.OnConflict, validGameModeConflicts)
+
+	if multiFrame := IntValue(p.DLSS.MultiFrame); multiFrame < 0 || multiFrame > maxMultiFrame {
+		issues = append(issues, Issue{Key: "dlss.multi_frame", Message: fmt.Sprintf("must be between 0 and %d, got %d", maxMultiFrame, multiFrame)})
+	}
+
+	if p.Logging.MaxSizeMB < 0 {
+		issues = append(issues, Issue{Key: "logging.max_size_mb", Message: fmt.Sprintf("must not be negative, got %d", p.Logging.MaxSizeMB)})
+	}
+	if p.Logging.KeepSessions < 0 {
+		issues = append(issues, Issue{Key: "logging.keep_sessions", Message: fmt.Sprintf("must not be negative, got %d", p.Logging.KeepSessions)})
+	}
+
+	issues = append(issues, p.CPU.validateProcess()...)
+	issues = append(issues, p.Gamescope.validate(p.Proton)...)
+
+	if gen != gpu.GPUGenerationUnknown && IsTrue(p.DLSS.FGOverride) && IsTrue(p.DLSS.FGEnabled) {
+		switch {
+		case !gen.SupportsFrameGeneration():
+			issues = append(issues, Issue{Key: "dlss.fg_enabled", Message: fmt.Sprintf("frame generation needs an RTX 40 series GPU or newer, detected %s", gen)})
+		case IntValue(p.DLSS.MultiFrame) > gen.MaxMultiFrame():
+			issues = append(issues, Issue{Key: "dlss.multi_frame", Message: fmt.Sprintf("must be at most %d on %s, got %d", gen.MaxMultiFrame(), gen, *p.DLSS.MultiFrame)})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(p.Env)) {
+		key := "env." + name
+		v := p.Env[name]
+		switch {
+		case !envNamePattern.MatchString(name):
+			issues = append(issues, Issue{Key: key, Message: fmt.Sprintf("invalid environment variable name %q", name)})
+		case v.Unset && v != (EnvVar{Unset: true}):
+			issues = append(issues, Issue{Key: key, Message: "unset cannot be combined with other fields"})
+		}
+	}
+
+	if _, err := p.WrapperCommands(); err != nil {
+		issues = append(issues, Issue{Key: "wrappers", Message: err.Error()})
+	}
+
+	for _, event := range hook.Events() {
+		key := "hooks." + string(event)
+		for i, h := range p.Hooks.For(event) {
+			if err := h.Validate(); err != nil {
+				issues = append(issues, Issue{Key: key, Message: fmt.Sprintf("hook %d: %v", i+1, err)})
+			}
+		}
+	}
+
+	for i, w := range p.When {
+		issues = append(issues, w.validate(i)...)
+	}
+
+	if p.CPU.Affinity != "" && !slices.Contains(cpu.AffinityPresets, cpu.AffinityPreset(p.CPU.Affinity)) {
+		if _, err := cpu.ParseCPUList(p.CPU.Affinity); err != nil {
+			issues = append(issues, Issue{Key: "cpu.affinity", Message: err.Error()})
+		}
+	}
+
+	return issues
+}
+
+// Warnings returns the settings that are valid but risky. Unlike the issues
+// Validate returns, they do not make the profile invalid.
+func (p *Profile) Warnings() []Issue {
+	return p.CPU.warnProcess()
+}
+
+// ValidateFile strictly decodes the profile at path and validates it,
+// returning a *ValidationError with line numbers when anything is wrong.
+func ValidateFile(path string, gen gpu.GPUGeneration) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	p, migrated, err := parseProfile(path, data)
+	if err != nil {
+		return err
+	}
+
+	issues := p.Validate(gen)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(migrated, &doc); err == nil && len(doc.Content) > 0 {
+		for i := range issues {
+			if node := lookupNode(doc.Content[0], issues[i].Key); node != nil {
+				issues[i].Line = node.Line
+			}
+		}
+	}
+
+	return &ValidationError{Path: path, Issues: issues}
+}
+
+// Files lists every profile file: the default profile, per-game profiles,
+// base profiles and user presets.
+func Files() ([]string, error) {
+	var files []string
+	for _, dir := range []string{profilesDir(), basesDir(), presetsDir()} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
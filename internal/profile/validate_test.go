@@ -0,0 +1,49 @@
+package profile_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestValidateMultiFrameByGeneration(t *testing.T) {
+	tests := []struct {
+		gen        gpu.GPUGeneration
+		multiFrame int
+		valid      bool
+	}{
+		{gpu.GPUGenerationAdaLovelace, 1, true},
+		{gpu.GPUGenerationAdaLovelace, 2, false},
+		{gpu.GPUGenerationBlackwell, 3, true},
+		{gpu.GPUGenerationBlackwell, 4, false},
+		{gpu.GPUGenerationUnknown, 3, true},
+		{gpu.GPUGenerationUnknown, 4, false},
+	}
+	for _, tt := range tests {
+		p := &profile.Profile{DLSS: profile.DLSSSettings{
+			FGOverride: profile.Bool(true),
+			FGEnabled:  profile.Bool(true),
+			MultiFrame: profile.Int(tt.multiFrame),
+		}}
+		var found bool
+		for _, issue := range p.Validate(tt.gen) {
+			found = found || issue.Key == "dlss.multi_frame"
+		}
+		if found == tt.valid {
+			t.Errorf("%s with multi_frame %d: valid = %v, want %v", tt.gen, tt.multiFrame, !found, tt.valid)
+		}
+	}
+}
+
+func TestValidateScheduler(t *testing.T) {
+	p := &profile.Profile{CPU: profile.CPUSettings{Scheduler: "scx-lavd", SchedulerMode: "turbo"}}
+	var keys []string
+	for _, issue := range p.Validate(gpu.GPUGenerationUnknown) {
+		keys = append(keys, issue.Key)
+	}
+	if want := []string{"cpu.scheduler_mode", "cpu.scheduler"}; !slices.Equal(keys, want) {
+		t.Errorf("Validate() keys = %v, want %v", keys, want)
+	}
+}
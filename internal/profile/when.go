@@ -0,0 +1,374 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jgabor/spela/internal/display"
+)
+
+// Power sources and session types a when block can match.
+const (
+	PowerAC      = "ac"
+	PowerBattery = "battery"
+
+	SessionWayland   = "wayland"
+	SessionX11       = "x11"
+	SessionGamescope = "gamescope"
+)
+
+var (
+	validPowerSources = []string{PowerAC, PowerBattery}
+	validSessions     = []string{SessionWayland, SessionX11, SessionGamescope}
+	resolutionPattern = regexp.MustCompile(`^[0-9]+x[0-9]+$`)
+)
+
+// Keys that only make sense at the top level of a profile.
+var unconditionalKeys = []string{"schema_version", "name", "extends", "preset", "when"}
+
+// Conditional is a when block: settings that only apply when every condition
+// it sets matches the machine the game is launched on.
+type Conditional struct {
+	Conditions `yaml:",inline"`
+	Set        ConditionalSettings `yaml:"set"`
+	// Layer is the profile layer the block was read from, when resolved.
+	Layer string `yaml:"-"`
+}
+
+// Conditions are matched against a RuntimeContext. Empty conditions match
+// anything.
+type Conditions struct {
+	// Power is ac or battery.
+	Power string `yaml:"power,omitempty"`
+	// Resolution is WIDTHxHEIGHT; it matches if any display currently runs
+	// at that mode.
+	Resolution string `yaml:"resolution,omitempty"`
+	// Session is wayland, x11 or gamescope.
+	Session string `yaml:"session,omitempty"`
+	// Hostname may contain shell wildcards.
+	Hostname string `yaml:"hostname,omitempty"`
+}
+
+// Matches reports whether every condition that is set holds for c.
+func (c Conditions) Matches(rc RuntimeContext) bool {
+	if c.Power != "" && c.Power != rc.Power {
+		return false
+	}
+	if c.Resolution != "" && !slices.Contains(rc.Resolutions, c.Resolution) {
+		return false
+	}
+	if c.Session != "" && c.Session != rc.Session {
+		return false
+	}
+	if c.Hostname != "" {
+		if ok, _ := path.Match(c.Hostname, rc.Hostname); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Conditions) String() string {
+	var parts []string
+	for _, cond := range []struct{ key, value string }{
+		{"power", c.Power},
+		{"resolution", c.Resolution},
+		{"session", c.Session},
+		{"hostname", c.Hostname},
+	} {
+		if cond.value != "" {
+			parts = append(parts, cond.key+"="+cond.value)
+		}
+	}
+	if len(parts) == 0 {
+		return "always"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (w Conditional) String() string {
+	var settings []string
+	for _, key := range w.Set.Keys() {
+		settings = append(settings, fmt.Sprintf("%s=%v", key, w.Set.value(key)))
+	}
+	return fmt.Sprintf("when %s: %s", w.Conditions, strings.Join(settings, ", "))
+}
+
+// ConditionalSettings are the settings of a when block. They are kept as
+// written, so a block can set a value back to false or 0 and null resets a
+// setting to its built-in default, the same as in a profile layer.
+type ConditionalSettings struct {
+	values map[string]any
+}
+
+func (s *ConditionalSettings) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: set must be a mapping of profile settings", node.Line)
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if key := node.Content[i]; slices.Contains(unconditionalKeys, key.Value) {
+			return fmt.Errorf("line %d: %s cannot be set in a when block", key.Line, key.Value)
+		}
+	}
+
+	// Decoding a node does not inherit the strict mode of the outer
+	// decoder, so the settings are checked against Profile here.
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&Profile{}); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("line %d: invalid when settings: %w", node.Line, err)
+	}
+
+	return node.Decode(&s.values)
+}
+
+func (s ConditionalSettings) MarshalYAML() (any, error) {
+	if s.values == nil {
+		return map[string]any{}, nil
+	}
+	return s.values, nil
+}
+
+// Keys lists the dotted keys of the settings, sorted.
+func (s ConditionalSettings) Keys() []string {
+	var keys []string
+	var walk func(map[string]any, string)
+	walk = func(values map[string]any, prefix string) {
+		for key, value := range values {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+				walk(nested, key)
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	walk(s.values, "")
+	slices.Sort(keys)
+	return keys
+}
+
+func (s ConditionalSettings) value(key string) any {
+	var current any = s.values
+	for part := range strings.SplitSeq(key, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+// Profile decodes the settings into a profile of their own.
+func (s ConditionalSettings) Profile() (*Profile, error) {
+	data, err := yaml.Marshal(s.values)
+	if err != nil {
+		return nil, err
+	}
+	var p Profile
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (w Conditional) validate(i int) []Issue {
+	var issues []Issue
+	add := func(format string, args ...any) {
+		issues = append(issues, Issue{Key: "when", Message: fmt.Sprintf("block %d: ", i+1) + fmt.Sprintf(format, args...)})
+	}
+
+	if w.Power != "" && !slices.Contains(validPowerSources, w.Power) {
+		add("invalid power %q (expected one of %s)", w.Power, strings.Join(validPowerSources, ", "))
+	}
+	if w.Resolution != "" && !resolutionPattern.MatchString(w.Resolution) {
+		add("invalid resolution %q (expected WIDTHxHEIGHT)", w.Resolution)
+	}
+	if w.Session != "" && !slices.Contains(validSessions, w.Session) {
+		add("invalid session %q (expected one of %s)", w.Session, strings.Join(validSessions, ", "))
+	}
+	if _, err := path.Match(w.Hostname, ""); err != nil {
+		add("invalid hostname pattern %q", w.Hostname)
+	}
+	if len(w.Set.values) == 0 {
+		add("set has no settings")
+	}
+
+	settings, err := w.Set.Profile()
+	if err != nil {
+		add("%v", err)
+		return issues
+	}
+	for _, issue := range settings.Validate(0) {
+		add("%s: %s", issue.Key, issue.Message)
+	}
+	return issues
+}
+
+// RuntimeContext describes the machine a game is launched on.
+type RuntimeContext struct {
+	Power string `json:"power,omitempty"`
+	// Resolutions are the modes the active displays currently run at.
+	Resolutions []string `json:"resolutions,omitempty"`
+	Session     string   `json:"session,omitempty"`
+	Hostname    string   `json:"hostname,omitempty"`
+}
+
+// DetectContext inspects the running system.
+func DetectContext() RuntimeContext {
+	hostname, _ := os.Hostname()
+	return RuntimeContext{
+		Power:       detectPower("/sys/class/power_supply"),
+		Resolutions: display.Resolutions(),
+		Session:     detectSession(),
+		Hostname:    hostname,
+	}
+}
+
+// detectPower reports ac when any external power supply is online, battery
+// when there is a battery but no online supply, and ac on machines without
+// a battery.
+func detectPower(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return PowerAC
+	}
+
+	hasBattery := false
+	for _, entry := range entries {
+		supply := filepath.Join(dir, entry.Name())
+		if readSysfs(filepath.Join(supply, "type")) == "Battery" {
+			if readSysfs(filepath.Join(supply, "scope")) != "Device" {
+				hasBattery = true
+			}
+			continue
+		}
+		if readSysfs(filepath.Join(supply, "online")) == "1" {
+			return PowerAC
+		}
+	}
+	if hasBattery {
+		return PowerBattery
+	}
+	return PowerAC
+}
+
+func detectSession() string {
+	switch {
+	case os.Getenv("GAMESCOPE_WAYLAND_DISPLAY") != "" || strings.EqualFold(os.Getenv("XDG_CURRENT_DESKTOP"), "gamescope"):
+		return SessionGamescope
+	case os.Getenv("WAYLAND_DISPLAY") != "" || os.Getenv("XDG_SESSION_TYPE") == "wayland":
+		return SessionWayland
+	case os.Getenv("DISPLAY") != "" || os.Getenv("XDG_SESSION_TYPE") == "x11":
+		return SessionX11
+	}
+	return ""
+}
+
+func readSysfs(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ConditionResult records how a profile's when blocks were evaluated.
+type ConditionResult struct {
+	Context RuntimeContext
+	Matched []Conditional
+	sources map[string]string
+}
+
+// Source returns the matched block that set key, described with its
+// conditions and layer.
+func (r *ConditionResult) Source(key string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	if source, ok := r.sources[key]; ok {
+		return source, true
+	}
+	for k, source := range r.sources {
+		if strings.HasPrefix(k, key+".") {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// Conditions returns the result of evaluating the profile's when blocks, or
+// nil if they have not been evaluated.
+func (p *Profile) Conditions() *ConditionResult {
+	return p.conditions
+}
+
+// EvaluateConditions merges the settings of every when block that matches
+// rc into p, in order, so later blocks win. Blocks resolved from several
+// layers are ordered lowest layer first.
+func (p *Profile) EvaluateConditions(rc RuntimeContext) error {
+	result := &ConditionResult{Context: rc, sources: make(map[string]string)}
+	for _, w := range p.When {
+		if w.Matches(rc) {
+			result.Matched = append(result.Matched, w)
+		}
+	}
+	if len(result.Matched) == 0 {
+		p.conditions = result
+		return nil
+	}
+
+	values, err := profileValues(p)
+	if err != nil {
+		return err
+	}
+	for _, w := range result.Matched {
+		source := "when " + w.Conditions.String()
+		if w.Layer != "" {
+			source += " in " + w.Layer
+		}
+		mergeValues(values, w.Set.values, "", source, result.sources)
+	}
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	var merged Profile
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return fmt.Errorf("failed to apply when blocks: %w", err)
+	}
+	merged.When = p.When
+	merged.conditions = result
+	*p = merged
+	return nil
+}
+
+// evaluateConditions evaluates the when blocks against the running system
+// once; a profile without when blocks is left alone.
+func (p *Profile) evaluateConditions() {
+	if len(p.When) == 0 || p.conditions != nil {
+		return
+	}
+	if err := p.EvaluateConditions(DetectContext()); err != nil {
+		slog.Warn("ignoring when blocks", "error", err)
+	}
+}
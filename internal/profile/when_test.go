@@ -0,0 +1,125 @@
+package profile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jgabor/spela/internal/profile"
+)
+
+func TestConditionsMatch(t *testing.T) {
+	rc := profile.RuntimeContext{
+		Power:       profile.PowerBattery,
+		Resolutions: []string{"1920x1200", "3840x2160"},
+		Session:     profile.SessionWayland,
+		Hostname:    "deck-laptop",
+	}
+
+	tests := []struct {
+		conditions profile.Conditions
+		want       bool
+	}{
+		{profile.Conditions{}, true},
+		{profile.Conditions{Power: "battery"}, true},
+		{profile.Conditions{Power: "ac"}, false},
+		{profile.Conditions{Resolution: "3840x2160"}, true},
+		{profile.Conditions{Resolution: "2560x1440"}, false},
+		{profile.Conditions{Session: "gamescope"}, false},
+		{profile.Conditions{Hostname: "deck-*"}, true},
+		{profile.Conditions{Hostname: "desktop"}, false},
+		{profile.Conditions{Power: "battery", Session: "x11"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.conditions.Matches(rc); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.conditions, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateConditions(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"default.yaml": `
+gpu:
+  power_mizer: max
+when:
+  - power: battery
+    set:
+      gpu:
+        power_mizer: adaptive
+`,
+		"20.yaml": `
+dlss:
+  sr_mode: quality
+  sr_override: true
+when:
+  - resolution: 3840x2160
+    set:
+      dlss:
+        sr_mode: performance
+  - power: battery
+    set:
+      dlss:
+        sr_override: false
+`,
+	})
+
+	r, err := profile.Resolve(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := r.Profile
+	if len(p.When) != 3 || p.When[0].Layer != "default" || p.When[2].Layer != "game:20" {
+		t.Fatalf("When = %+v, want the blocks of both layers in order", p.When)
+	}
+
+	if err := p.EvaluateConditions(profile.RuntimeContext{Power: profile.PowerBattery}); err != nil {
+		t.Fatal(err)
+	}
+	if p.GPU.PowerMizer != "adaptive" || profile.IsTrue(p.DLSS.SROverride) || p.DLSS.SRMode != profile.DLSSModeQuality {
+		t.Errorf("got power_mizer=%s sr_override=%v sr_mode=%s, want the battery blocks only",
+			p.GPU.PowerMizer, p.DLSS.SROverride, p.DLSS.SRMode)
+	}
+
+	result := p.Conditions()
+	if len(result.Matched) != 2 {
+		t.Fatalf("Matched = %+v, want 2 blocks", result.Matched)
+	}
+	if source, ok := result.Source("dlss.sr_override"); !ok || source != "when power=battery in game:20" {
+		t.Errorf("Source(dlss.sr_override) = %q, %v", source, ok)
+	}
+	if _, ok := result.Source("dlss.sr_mode"); ok {
+		t.Error("Source(dlss.sr_mode) reported a block that did not match")
+	}
+}
+
+func TestWhenValidation(t *testing.T) {
+	writeProfiles(t, map[string]string{
+		"1.yaml": "when:\n  - power: battery\n    set:\n      dlss:\n        sr_mod: performance\n",
+		"2.yaml": "when:\n  - power: battery\n    set:\n      extends: laptop\n",
+		"3.yaml": "when:\n  - power: mains\n    resolution: 4k\n    set:\n      dlss:\n        multi_frame: 9\n",
+		"4.yaml": "when:\n  - powr: battery\n    set:\n      gpu:\n        clock_offset: 0\n",
+	})
+
+	for _, id := range []uint64{1, 2, 4} {
+		if _, err := profile.Load(id); err == nil {
+			t.Errorf("Load(%d) accepted an invalid when block", id)
+		}
+	}
+
+	p, err := profile.Load(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, issue := range p.Validate(0) {
+		if issue.Key == "when" {
+			messages = append(messages, issue.Message)
+		}
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{`invalid power "mains"`, `invalid resolution "4k"`, "dlss.multi_frame"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("issues %q do not mention %s", joined, want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// LogName is the file the game's output is captured to.
+const LogName = "game.log"
+
+// Log is a size-capped log file. When a write would take it past its cap it
+// is renamed with a .1 suffix, replacing the previous one, and a new file is
+// started, so it never takes up more than twice the cap.
+type Log struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	size    int64
+	file    *os.File
+}
+
+// OpenLog opens the session's game log for appending.
+func (s *Session) OpenLog(maxSize int64) (*Log, error) {
+	l := &Log{path: filepath.Join(s.Dir(), LogName), maxSize: maxSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+func (l *Log) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate log: %w", err)
+	}
+	return l.open()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Logs lists the log files in the session directory, sorted by name.
+func (s *Session) Logs() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var logs []string
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == fileName {
+			continue
+		}
+		logs = append(logs, entry.Name())
+	}
+	return logs, nil
+}
+
+// TrimLogs cuts every log in the session directory that is larger than
+// maxSize down to its last maxSize bytes. Proton, DXVK and VKD3D write their
+// debug logs themselves, so unlike game.log they cannot be rotated as they
+// grow; the end of a log, where a crash shows up, is what is kept.
+func (s *Session) TrimLogs(maxSize int64) error {
+	logs, err := s.Logs()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range logs {
+		if err := trimLog(filepath.Join(s.Dir(), name), maxSize); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func trimLog(path string, maxSize int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if maxSize <= 0 || info.Size() <= maxSize {
+		return nil
+	}
+	if _, err := f.Seek(-maxSize, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to trim %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".trim-*")
+	if err != nil {
+		return fmt.Errorf("failed to trim %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := io.Copy(tmp, f); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to trim %s: %w", path, err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to trim %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to trim %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to trim %s: %w", path, err)
+	}
+	return nil
+}
+
+// PruneLogs removes the logs of all but the keep latest sessions of a game,
+// and trims the logs of those it keeps to maxSize, in case a session ended
+// without trimming its own. The session records themselves are kept, so
+// their playtime still counts.
+func PruneLogs(appID uint64, keep int, maxSize int64) error {
+	dir := GameDir(appID)
+	numbers, err := sessionNumbers(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var errs []error
+	pruned := max(len(numbers)-keep, 0)
+	for _, n := range numbers[pruned:] {
+		s := &Session{path: filepath.Join(dir, strconv.Itoa(n), fileName)}
+		if err := s.TrimLogs(maxSize); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, n := range numbers[:pruned] {
+		s := &Session{path: filepath.Join(dir, strconv.Itoa(n), fileName)}
+		logs, err := s.Logs()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, name := range logs {
+			if err := os.RemoveAll(filepath.Join(s.Dir(), name)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jgabor/spela/internal/game"
+)
+
+func TestLogRotation(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	s := New(&game.Game{AppID: 1, Name: "A"}, nil)
+	if err := s.Begin(); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	l, err := s.OpenLog(10)
+	if err != nil {
+		t.Fatalf("OpenLog() error = %v", err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for name, want := range map[string]string{LogName: "third\n", LogName + ".1": "second\n"} {
+		data, err := os.ReadFile(filepath.Join(s.Dir(), name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q (%v), want %q", name, data, err, want)
+		}
+	}
+}
+
+func TestPruneLogs(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	var sessions []*Session
+	for range 3 {
+		s := New(&game.Game{AppID: 1, Name: "A"}, nil)
+		if err := s.Begin(); err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(s.Dir(), LogName), []byte("log\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err := PruneLogs(1, 2, 1<<20); err != nil {
+		t.Fatalf("PruneLogs() error = %v", err)
+	}
+
+	for i, want := range [][]string{nil, {LogName}, {LogName}} {
+		logs, err := sessions[i].Logs()
+		if err != nil || !slices.Equal(logs, want) {
+			t.Errorf("session %d logs = %v (%v), want %v", i+1, logs, err, want)
+		}
+	}
+	if listed, _ := List(1); len(listed) != 3 {
+		t.Errorf("PruneLogs() removed session records, %d left", len(listed))
+	}
+}
+
+func TestTrimLogs(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	s := New(&game.Game{AppID: 1, Name: "A"}, nil)
+	if err := s.Begin(); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	big := filepath.Join(s.Dir(), "steam-1.log")
+	if err := os.WriteFile(big, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	small := filepath.Join(s.Dir(), "vkd3d.log")
+	if err := os.WriteFile(small, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TrimLogs(4); err != nil {
+		t.Fatalf("TrimLogs() error = %v", err)
+	}
+	if data, _ := os.ReadFile(big); string(data) != "6789" {
+		t.Errorf("trimmed log = %q, want the last 4 bytes", data)
+	}
+	if data, _ := os.ReadFile(small); string(data) != "abc" {
+		t.Errorf("log under the cap = %q, want it unchanged", data)
+	}
+	if logs, _ := s.Logs(); !slices.Equal(logs, []string{"steam-1.log", "vkd3d.log"}) {
+		t.Errorf("session logs after trimming = %v", logs)
+	}
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"slices"
+	"time"
+)
+
+// Outcome classifies how a session ended.
+type Outcome string
+
+const (
+	// OutcomeClean is a game that exited with code 0 after running for a
+	// while.
+	OutcomeClean Outcome = "clean"
+	// OutcomeCrash is a game that exited with an error code or was killed
+	// by a fault such as SIGSEGV.
+	OutcomeCrash Outcome = "crash"
+	// OutcomeEarlyExit is a game that exited with code 0 within
+	// EarlyExitThreshold, which usually means it failed to get going.
+	OutcomeEarlyExit Outcome = "early_exit"
+	// OutcomeKilled is a game stopped by the user or the system.
+	OutcomeKilled Outcome = "killed"
+	// OutcomeFailed is a game that could not be started at all.
+	OutcomeFailed Outcome = "failed"
+	// OutcomeInterrupted is a session whose spela process went away before
+	// the game exited.
+	OutcomeInterrupted Outcome = "interrupted"
+	// OutcomeRunning is a session that has not ended yet.
+	OutcomeRunning Outcome = "running"
+)
+
+// EarlyExitThreshold is how long a game must run for a clean exit not to
+// count as an early one.
+const EarlyExitThreshold = 15 * time.Second
+
+// stopSignals are the signals used to ask a game to stop, as opposed to
+// faults.
+var stopSignals = []string{"SIGINT", "SIGTERM", "SIGHUP", "SIGKILL", "SIGQUIT"}
+
+// Bad reports whether the outcome points at a problem with the game's
+// settings.
+func (o Outcome) Bad() bool {
+	return o == OutcomeCrash || o == OutcomeEarlyExit || o == OutcomeFailed
+}
+
+// Result returns the recorded outcome of a finished session, and whether an
+// unfinished one is running or was interrupted.
+func (s *Session) Result() Outcome {
+	switch {
+	case s.Outcome != "":
+		return s.Outcome
+	case s.Finished():
+		return s.classify()
+	case s.Running():
+		return OutcomeRunning
+	}
+	return OutcomeInterrupted
+}
+
+func (s *Session) classify() Outcome {
+	switch {
+	case s.Error != "":
+		return OutcomeFailed
+	case s.Interrupted:
+		return OutcomeKilled
+	case s.Signal != "":
+		if slices.Contains(stopSignals, s.Signal) {
+			return OutcomeKilled
+		}
+		return OutcomeCrash
+	case s.ExitCode != nil && *s.ExitCode != 0:
+		return OutcomeCrash
+	case s.Duration() < EarlyExitThreshold:
+		return OutcomeEarlyExit
+	}
+	return OutcomeClean
+}
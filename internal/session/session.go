@@ -0,0 +1,409 @@
+// Package session records every game launch: when it started and ended, how
+// the game exited, and the settings it ran with.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jgabor/spela/internal/dll"
+	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/xdg"
+)
+
+const fileName = "session.yaml"
+
+// Session is one launch of a game. It is written when the game starts and
+// again when it exits; a session without an end time was cut short, or is
+// still running.
+type Session struct {
+	Number  int       `yaml:"-" json:"number"`
+	AppID   uint64    `yaml:"app_id" json:"app_id"`
+	Game    string    `yaml:"game,omitempty" json:"game,omitempty"`
+	PID     int       `yaml:"pid" json:"-"`
+	Start   time.Time `yaml:"start" json:"start"`
+	End     time.Time `yaml:"end,omitempty" json:"end,omitzero"`
+	Command []string  `yaml:"command,omitempty" json:"command,omitempty"`
+	// ExitCode is set once the game has exited on its own; Signal names the
+	// signal that killed it otherwise.
+	ExitCode *int   `yaml:"exit_code,omitempty" json:"exit_code,omitempty"`
+	Signal   string `yaml:"signal,omitempty" json:"signal,omitempty"`
+	// Interrupted is set when spela passed on a signal to stop the game.
+	Interrupted bool `yaml:"interrupted,omitempty" json:"interrupted,omitempty"`
+	// Error is why the game could not be started.
+	Error   string            `yaml:"error,omitempty" json:"error,omitempty"`
+	Outcome Outcome           `yaml:"outcome,omitempty" json:"outcome,omitempty"`
+	Profile ProfileSnapshot   `yaml:"profile,omitempty" json:"profile"`
+	DLLs    []DLL             `yaml:"dlls,omitempty" json:"dlls,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Unset   []string          `yaml:"unset,omitempty" json:"unset,omitempty"`
+
+	path string
+}
+
+// ProfileSnapshot identifies the resolved profile a session ran with. Two
+// sessions with the same hash ran with the same settings.
+type ProfileSnapshot struct {
+	Hash   string   `yaml:"hash,omitempty" json:"hash,omitempty"`
+	Layers []string `yaml:"layers,omitempty" json:"layers,omitempty"`
+	// Revision is the history revision of the game's own profile, if it
+	// had one.
+	Revision int `yaml:"revision,omitempty" json:"revision,omitempty"`
+}
+
+// DLL is an upscaler DLL found in the game when it was launched.
+type DLL struct {
+	Name    string       `yaml:"name" json:"name"`
+	Type    game.DLLType `yaml:"type" json:"type"`
+	Version string       `yaml:"version,omitempty" json:"version,omitempty"`
+	Path    string       `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// Hash returns a short digest of a profile's settings, as marshalled.
+func Hash(v any) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6]), nil
+}
+
+// DLLs returns the DLLs of g found when it was last scanned, with the
+// version of the file now in place, since it may have been swapped since.
+func DLLs(g *game.Game) []DLL {
+	var dlls []DLL
+	for _, d := range g.DLLs {
+		version := d.Version
+		if v, err := dll.GetDLLVersion(d.Path); err == nil && v != "" {
+			version = v
+		}
+		dlls = append(dlls, DLL{Name: d.Name, Type: d.Type, Version: version, Path: d.Path})
+	}
+	return dlls
+}
+
+// GameDir returns the directory the sessions of a game are stored in, one
+// numbered directory each.
+func GameDir(appID uint64) string {
+	return xdg.DataPath("sessions", strconv.FormatUint(appID, 10))
+}
+
+// New starts a session for g in this process. It is numbered after the
+// game's last session; the caller fills in the settings before calling
+// Begin, and calls Save after changing them later.
+func New(g *game.Game, command []string) *Session {
+	return &Session{
+		AppID:   g.AppID,
+		Game:    g.Name,
+		PID:     os.Getpid(),
+		Command: slices.Clone(command),
+		DLLs:    DLLs(g),
+	}
+}
+
+// SetEnv records the variables set and unset for the game.
+func (s *Session) SetEnv(vars map[string]string, unset []string) {
+	s.Env = maps.Clone(vars)
+	s.Unset = slices.Clone(unset)
+}
+
+// Begin marks the session as started now and writes it to a new session
+// directory.
+func (s *Session) Begin() error {
+	s.Start = time.Now().UTC()
+
+	dir := GameDir(s.AppID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	numbers, err := sessionNumbers(dir)
+	if err != nil {
+		return err
+	}
+	number := 0
+	if len(numbers) > 0 {
+		number = numbers[len(numbers)-1]
+	}
+
+	// Another launch of the same game may take a number at the same time;
+	// creating the directory claims it.
+	for {
+		number++
+		err := os.Mkdir(filepath.Join(dir, strconv.Itoa(number)), 0o755)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create session directory: %w", err)
+		}
+		break
+	}
+
+	s.Number = number
+	s.path = filepath.Join(dir, strconv.Itoa(number), fileName)
+	return s.write()
+}
+
+// Finish records how the game exited: its exit code, or the signal that
+// killed it when signal is not empty, and whether spela stopped it.
+func (s *Session) Finish(code int, signal string, interrupted bool) error {
+	s.End = time.Now().UTC()
+	if signal != "" {
+		s.Signal = signal
+	} else {
+		s.ExitCode = &code
+	}
+	s.Interrupted = interrupted
+	s.Outcome = s.classify()
+	return s.write()
+}
+
+// Fail records that the game could not be started.
+func (s *Session) Fail(err error) error {
+	s.End = time.Now().UTC()
+	s.Error = err.Error()
+	s.Outcome = OutcomeFailed
+	return s.write()
+}
+
+// Dir returns the directory the session is stored in.
+func (s *Session) Dir() string {
+	return filepath.Dir(s.path)
+}
+
+// Finished reports whether the session's end was recorded.
+func (s *Session) Finished() bool {
+	return !s.End.IsZero()
+}
+
+// Running reports whether the session has not ended and the spela process
+// that launched it is still alive.
+func (s *Session) Running() bool {
+	if s.Finished() || s.PID <= 0 {
+		return false
+	}
+	if s.PID == os.Getpid() {
+		return true
+	}
+	err := syscall.Kill(s.PID, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Duration returns how long the game ran. Sessions that never finished, or
+// never started the game, count as zero.
+func (s *Session) Duration() time.Duration {
+	if !s.Finished() || s.Error != "" {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+// Status describes how the session ended.
+func (s *Session) Status() string {
+	var exit string
+	switch {
+	case s.Signal != "":
+		exit = s.Signal
+	case s.ExitCode != nil:
+		exit = "code " + strconv.Itoa(*s.ExitCode)
+	}
+
+	switch s.Result() {
+	case OutcomeFailed:
+		return "failed to start"
+	case OutcomeClean:
+		return "exited with " + exit
+	case OutcomeCrash:
+		return "crashed with " + exit
+	case OutcomeEarlyExit:
+		return fmt.Sprintf("exited with %s after %s", exit, FormatDuration(s.Duration()))
+	case OutcomeKilled:
+		if s.Signal != "" {
+			return "killed by " + s.Signal
+		}
+		return "stopped, " + exit
+	case OutcomeRunning:
+		return "running"
+	}
+	return "interrupted"
+}
+
+// Save writes the session again, after its settings changed.
+func (s *Session) Save() error {
+	return s.write()
+}
+
+// write replaces the session file through a temporary file, so that a crash
+// mid-write leaves the previous version instead of a truncated file.
+func (s *Session) write() error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".session-*")
+	if err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// sessionNumbers returns the numbers of the session directories in dir,
+// sorted.
+func sessionNumbers(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var numbers []int
+	for _, entry := range entries {
+		if n, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() {
+			numbers = append(numbers, n)
+		}
+	}
+	slices.Sort(numbers)
+	return numbers, nil
+}
+
+// List returns the recorded sessions of a game, oldest first.
+func List(appID uint64) ([]*Session, error) {
+	dir := GameDir(appID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		number, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name(), fileName)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		s := &Session{Number: number, path: path}
+		if err := yaml.Unmarshal(data, s); err != nil {
+			// One damaged file should not hide every other session.
+			log.Printf("Warning: skipping session %s: %v", path, err)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+
+	slices.SortFunc(sessions, func(a, b *Session) int { return a.Number - b.Number })
+	return sessions, nil
+}
+
+// All returns the recorded sessions of every game, oldest first.
+func All() ([]*Session, error) {
+	entries, err := os.ReadDir(xdg.DataPath("sessions"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		appID, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		game, err := List(appID)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, game...)
+	}
+
+	slices.SortFunc(sessions, func(a, b *Session) int { return a.Start.Compare(b.Start) })
+	return sessions, nil
+}
+
+// Playtime returns the total duration of a game's sessions.
+func Playtime(appID uint64) (time.Duration, error) {
+	sessions, err := List(appID)
+	if err != nil {
+		return 0, err
+	}
+	return Total(sessions), nil
+}
+
+// Playtimes returns the total playtime of every game with sessions.
+func Playtimes() (map[uint64]time.Duration, error) {
+	sessions, err := All()
+	if err != nil {
+		return nil, err
+	}
+	playtimes := make(map[uint64]time.Duration)
+	for _, s := range sessions {
+		playtimes[s.AppID] += s.Duration()
+	}
+	return playtimes, nil
+}
+
+// Total sums the durations of sessions.
+func Total(sessions []*Session) time.Duration {
+	var total time.Duration
+	for _, s := range sessions {
+		total += s.Duration()
+	}
+	return total
+}
+
+// FormatDuration formats d as hours and minutes, or seconds for short
+// sessions.
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
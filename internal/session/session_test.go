@@ -0,0 +1,118 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jgabor/spela/internal/game"
+)
+
+func TestSessionsAreNumberedPerGame(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	a := &game.Game{AppID: 1, Name: "A"}
+	b := &game.Game{AppID: 2, Name: "B"}
+	for _, g := range []*game.Game{a, a, b} {
+		s := New(g, []string{"game.exe"})
+		if err := s.Begin(); err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+		if err := s.Finish(0, "", false); err != nil {
+			t.Fatalf("Finish() error = %v", err)
+		}
+	}
+
+	sessions, err := List(1)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Number != 1 || sessions[1].Number != 2 {
+		t.Fatalf("List(1) = %+v, want sessions 1 and 2", sessions)
+	}
+
+	all, err := All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("All() returned %d sessions, want 3", len(all))
+	}
+}
+
+func TestListSkipsDamagedSessions(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	g := &game.Game{AppID: 1, Name: "A"}
+	for range 2 {
+		s := New(g, []string{"game.exe"})
+		if err := s.Begin(); err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+	}
+
+	sessions, err := List(1)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("List() = %d sessions, %v, want 2", len(sessions), err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(sessions[0].path))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("session directory holds %d entries, want only %s", len(entries), fileName)
+	}
+	if err := os.WriteFile(sessions[0].path, []byte("start: [not a time\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err = List(1)
+	if err != nil {
+		t.Fatalf("List() error = %v, want the damaged session skipped", err)
+	}
+	if len(sessions) != 1 || sessions[0].Number != 2 {
+		t.Errorf("List() = %+v, want only session 2", sessions)
+	}
+}
+
+func TestStatusAndPlaytime(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	start := time.Date(2026, 1, 2, 20, 0, 0, 0, time.UTC)
+	zero, crash := 0, 1
+	sessions := []*Session{
+		{Start: start, End: start.Add(90 * time.Minute), ExitCode: &zero},
+		{Start: start, End: start.Add(time.Hour), ExitCode: &crash},
+		{Start: start, End: start.Add(5 * time.Second), ExitCode: &zero},
+		{Start: start, End: start.Add(time.Minute), Signal: "SIGKILL"},
+		{Start: start, End: start.Add(time.Minute), Signal: "SIGSEGV"},
+		{Start: start, End: start.Add(time.Minute), Error: "exec: not found"},
+		// Left behind by a spela process that is gone.
+		{Start: start, PID: 1 << 30},
+		{Start: start, PID: os.Getpid()},
+	}
+
+	want := []struct {
+		status   string
+		duration time.Duration
+	}{
+		{"exited with code 0", 90 * time.Minute},
+		{"crashed with code 1", time.Hour},
+		{"exited with code 0 after 5s", 5 * time.Second},
+		{"killed by SIGKILL", time.Minute},
+		{"crashed with SIGSEGV", time.Minute},
+		{"failed to start", 0},
+		{"interrupted", 0},
+		{"running", 0},
+	}
+	for i, s := range sessions {
+		if got := s.Status(); got != want[i].status {
+			t.Errorf("session %d Status() = %q, want %q", i, got, want[i].status)
+		}
+		if got := s.Duration(); got != want[i].duration {
+			t.Errorf("session %d Duration() = %s, want %s", i, got, want[i].duration)
+		}
+	}
+
+	if got := FormatDuration(Total(sessions)); got != "2h 32m" {
+		t.Errorf("FormatDuration(Total()) = %q, want 2h 32m", got)
+	}
+}
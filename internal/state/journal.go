@@ -0,0 +1,414 @@
+// Package state keeps a journal of the system changes made for a game
+// session, so they can be reverted even when spela is killed, or the
+// terminal closed, before the session ends.
+//
+// Journals live in the runtime directory, which does not survive a reboot;
+// neither do the changes they record.
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jgabor/spela/internal/cpu"
+	"github.com/jgabor/spela/internal/gpu"
+	"github.com/jgabor/spela/internal/xdg"
+)
+
+// Reversal kinds.
+const (
+	ReverseGovernor   = "cpu.governor"
+	ReverseSMT        = "cpu.smt"
+	ReverseGPUClocks  = "gpu.clocks"
+	ReversePowerMizer = "gpu.power_mizer"
+	// ReverseSCXLoader restores the scheduler of scx_loader. Its value is
+	// "<scheduler>:<mode>", or empty when no scheduler ran.
+	ReverseSCXLoader = "cpu.scheduler"
+	// ReverseSCXService restores the scheduler configured for scx.service
+	// and whether it ran. Its value is "<scheduler>:running" or
+	// "<scheduler>:stopped".
+	ReverseSCXService = "cpu.scheduler.service"
+)
+
+// SCXReversal returns the reversal that brings back the scheduler state s.
+func SCXReversal(s cpu.SCXState) Reversal {
+	if s.Backend == cpu.SCXBackendService {
+		running := "stopped"
+		if s.Running {
+			running = "running"
+		}
+		return Reversal{Kind: ReverseSCXService, Value: s.Scheduler + ":" + running}
+	}
+	if !s.Running {
+		return Reversal{Kind: ReverseSCXLoader}
+	}
+	return Reversal{Kind: ReverseSCXLoader, Value: s.Scheduler + ":" + string(s.Mode)}
+}
+
+// Reversal undoes a system change. Unlike a closure it can be written to a
+// journal and replayed by another spela process.
+type Reversal struct {
+	Kind  string `yaml:"kind"`
+	Value string `yaml:"value,omitempty"`
+}
+
+// reverters apply a reversal of each kind given its value.
+var reverters = map[string]func(value string) error{
+	ReverseGovernor: func(value string) error {
+		return cpu.SetGovernor(cpu.Governor(value))
+	},
+	ReverseSMT: func(value string) error {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid SMT state %q: %w", value, err)
+		}
+		return cpu.SetSMT(enabled)
+	},
+	ReverseGPUClocks: func(string) error {
+		return gpu.ResetClocks()
+	},
+	ReversePowerMizer: func(value string) error {
+		mode, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid PowerMizer mode %q: %w", value, err)
+		}
+		return gpu.SetPowerMizerMode(mode)
+	},
+	ReverseSCXLoader: func(value string) error {
+		scheduler, mode, _ := strings.Cut(value, ":")
+		return cpu.SCXRestoreLoader(scheduler, cpu.SCXMode(mode))
+	},
+	ReverseSCXService: func(value string) error {
+		scheduler, running, _ := strings.Cut(value, ":")
+		return cpu.SCXRestoreService(scheduler, running == "running")
+	},
+}
+
+// Apply reverts the change.
+func (r Reversal) Apply() error {
+	revert, ok := reverters[r.Kind]
+	if !ok {
+		return fmt.Errorf("unknown reversal: %s", r.Kind)
+	}
+	return revert(r.Value)
+}
+
+func (r Reversal) String() string {
+	switch r.Kind {
+	case ReverseGovernor:
+		return "Restore CPU governor to " + r.Value
+	case ReverseSMT:
+		if r.Value == "true" {
+			return "Turn SMT back on"
+		}
+		return "Turn SMT back off"
+	case ReverseGPUClocks:
+		return "Reset GPU clocks"
+	case ReversePowerMizer:
+		return "Restore GPU PowerMizer mode " + r.Value
+	case ReverseSCXLoader:
+		scheduler, mode, _ := strings.Cut(r.Value, ":")
+		switch {
+		case scheduler == "":
+			return "Stop the sched_ext scheduler"
+		case mode != "":
+			return fmt.Sprintf("Switch back to scheduler %s (%s)", scheduler, mode)
+		}
+		return "Switch back to scheduler " + scheduler
+	case ReverseSCXService:
+		scheduler, running, _ := strings.Cut(r.Value, ":")
+		switch {
+		case running == "running":
+			return "Switch scx.service back to " + scheduler
+		case scheduler == "":
+			return "Stop scx.service"
+		}
+		return "Stop scx.service and set its scheduler back to " + scheduler
+	}
+	return r.Kind
+}
+
+// Entry is a system change and how to revert it.
+type Entry struct {
+	// Key is the profile setting the change came from.
+	Key         string   `yaml:"key"`
+	Description string   `yaml:"description"`
+	Reversal    Reversal `yaml:"reversal"`
+	Reverted    bool     `yaml:"reverted,omitempty"`
+}
+
+// Journal records the system changes of one game session.
+type Journal struct {
+	PID int `yaml:"pid"`
+	// StartTime is when the process with PID started, in clock ticks since
+	// boot, so that a reused PID is not taken for the session's process.
+	StartTime uint64    `yaml:"start_time,omitempty"`
+	Game      string    `yaml:"game,omitempty"`
+	AppID     uint64    `yaml:"app_id,omitempty"`
+	Started   time.Time `yaml:"started"`
+	Entries   []Entry   `yaml:"entries"`
+	// RecoveryFailed is set when reverting the journal automatically at
+	// start failed. It is then left for `spela restore`.
+	RecoveryFailed bool `yaml:"recovery_failed,omitempty"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// journalSeq numbers the journals started by this process.
+var journalSeq atomic.Uint64
+
+// Dir returns the directory journals are kept in.
+func Dir() string {
+	return filepath.Join(xdg.RuntimeDir(), "state")
+}
+
+// New starts a journal for a session run by this process. Nothing is
+// written until the first change is recorded.
+//
+// The file name combines the PID with the session's start and a counter, so
+// neither a reused PID nor another session of the same process overwrites a
+// journal that is still pending.
+func New(game string, appID uint64) *Journal {
+	pid := os.Getpid()
+	start, _ := processStartTime(pid)
+	started := time.Now().UTC()
+	name := fmt.Sprintf("%d-%d-%d.yaml", pid, started.UnixNano(), journalSeq.Add(1))
+	return &Journal{
+		PID:       pid,
+		StartTime: start,
+		Game:      game,
+		AppID:     appID,
+		Started:   started,
+		path:      filepath.Join(Dir(), name),
+	}
+}
+
+// Record adds the reversal of a change and writes the journal. The change
+// must only be made once Record succeeds. It returns the entry's index.
+func (j *Journal) Record(key, description string, r Reversal) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.Entries = append(j.Entries, Entry{Key: key, Description: description, Reversal: r})
+	if err := j.write(); err != nil {
+		j.Entries = j.Entries[:len(j.Entries)-1]
+		return 0, err
+	}
+	return len(j.Entries) - 1, nil
+}
+
+// Revert applies the reversal of entry i and marks it done. A failed
+// reversal stays pending.
+func (j *Journal) Revert(i int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := &j.Entries[i]
+	if entry.Reverted {
+		return nil
+	}
+	if err := entry.Reversal.Apply(); err != nil {
+		return err
+	}
+	entry.Reverted = true
+	return j.write()
+}
+
+// Replay reverts every pending entry, newest first, and closes the journal.
+func (j *Journal) Replay() error {
+	var errs []error
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		if err := j.Revert(i); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", j.Entries[i].Reversal, err))
+		}
+	}
+	errs = append(errs, j.Close())
+	return errors.Join(errs...)
+}
+
+// MarkRecoveryFailed records that an automatic replay failed, so that later
+// starts of spela do not try again.
+func (j *Journal) MarkRecoveryFailed() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.RecoveryFailed = true
+	return j.write()
+}
+
+// Pending returns the entries that have not been reverted.
+func (j *Journal) Pending() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return slices.DeleteFunc(slices.Clone(j.Entries), func(e Entry) bool { return e.Reverted })
+}
+
+// Close removes the journal if nothing is left to revert. Otherwise it is
+// kept, so `spela restore` can try again.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, entry := range j.Entries {
+		if !entry.Reverted {
+			return nil
+		}
+	}
+	return j.remove()
+}
+
+// Discard removes the journal without reverting anything.
+func (j *Journal) Discard() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.remove()
+}
+
+func (j *Journal) remove() error {
+	err := os.Remove(j.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Running reports whether the process that owns the journal is still alive.
+// A process that has the journal's PID but started at another time has
+// reused the PID, and does not count.
+func (j *Journal) Running() bool {
+	if j.PID <= 0 {
+		return false
+	}
+	if j.StartTime != 0 {
+		start, err := processStartTime(j.PID)
+		return err == nil && start == j.StartTime
+	}
+	if j.PID == os.Getpid() {
+		return true
+	}
+	err := syscall.Kill(j.PID, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// processStartTime returns when process pid started, in clock ticks since
+// boot, from field 22 of /proc/<pid>/stat.
+func processStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	return parseStartTime(string(data))
+}
+
+// parseStartTime reads the start time from the contents of /proc/<pid>/stat.
+// The command name in field 2 may hold spaces and parentheses, so fields are
+// counted from the last ')'.
+func parseStartTime(stat string) (uint64, error) {
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, errors.New("malformed process stat")
+	}
+	// The fields after the command name start at field 3.
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 22-2 {
+		return 0, errors.New("malformed process stat")
+	}
+	return strconv.ParseUint(fields[22-3], 10, 64)
+}
+
+// write saves the journal atomically, so a crash while writing never
+// leaves a truncated file behind.
+func (j *Journal) write() error {
+	if len(j.Entries) == 0 {
+		return j.remove()
+	}
+
+	data, err := yaml.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(j.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".journal-*")
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// Load returns every journal in the state directory, oldest first.
+func Load() ([]*Journal, error) {
+	entries, err := os.ReadDir(Dir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var journals []*Journal
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".yaml" {
+			continue
+		}
+
+		path := filepath.Join(Dir(), name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		j := &Journal{path: path}
+		if err := yaml.Unmarshal(data, j); err != nil {
+			return nil, fmt.Errorf("failed to parse journal %s: %w", path, err)
+		}
+		journals = append(journals, j)
+	}
+
+	slices.SortFunc(journals, func(a, b *Journal) int { return a.Started.Compare(b.Started) })
+	return journals, nil
+}
+
+// Stale returns the journals left behind by sessions whose spela process is
+// gone.
+func Stale() ([]*Journal, error) {
+	journals, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(journals, (*Journal).Running), nil
+}
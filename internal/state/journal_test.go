@@ -0,0 +1,220 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jgabor/spela/internal/cpu"
+)
+
+// fakeReverters records the reversals applied instead of touching the
+// system; a reversal with the value "fail" fails.
+func fakeReverters(t *testing.T) *[]string {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	var applied []string
+	saved := reverters
+	reverters = map[string]func(string) error{
+		ReverseGovernor: func(value string) error {
+			if value == "fail" {
+				return errors.New("permission denied")
+			}
+			applied = append(applied, ReverseGovernor+"="+value)
+			return nil
+		},
+		ReverseGPUClocks: func(string) error {
+			applied = append(applied, ReverseGPUClocks)
+			return nil
+		},
+	}
+	t.Cleanup(func() { reverters = saved })
+	return &applied
+}
+
+func TestJournalLifecycle(t *testing.T) {
+	applied := fakeReverters(t)
+
+	j := New("Game", 10)
+	if _, err := os.Stat(j.path); !os.IsNotExist(err) {
+		t.Fatal("journal written before any change was recorded")
+	}
+
+	governor, err := j.Record("cpu.governor", "Set CPU governor to performance", Reversal{Kind: ReverseGovernor, Value: "powersave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clocks, err := j.Record("gpu.clock_offset", "Set GPU clock offset", Reversal{Kind: ReverseGPUClocks})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	journals, err := Load()
+	if err != nil || len(journals) != 1 {
+		t.Fatalf("Load() = %v, %v, want the recorded journal", journals, err)
+	}
+	if got := journals[0]; got.Game != "Game" || got.AppID != 10 || len(got.Entries) != 2 || !got.Running() {
+		t.Errorf("loaded journal = %+v", got)
+	}
+	if stale, _ := Stale(); len(stale) != 0 {
+		t.Errorf("Stale() = %v, want none while the session runs", stale)
+	}
+
+	if err := j.Revert(clocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Revert(governor); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(j.path); !os.IsNotExist(err) {
+		t.Error("journal kept after every change was reverted")
+	}
+	if want := []string{"gpu.clocks", "cpu.governor=powersave"}; !slices.Equal(*applied, want) {
+		t.Errorf("applied %v, want %v", *applied, want)
+	}
+}
+
+func TestReplayStaleJournal(t *testing.T) {
+	applied := fakeReverters(t)
+
+	// A process that has exited stands in for a killed spela.
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("true not available")
+	}
+
+	j := New("Game", 10)
+	j.PID = cmd.Process.Pid
+	if _, err := j.Record("cpu.governor", "", Reversal{Kind: ReverseGovernor, Value: "fail"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Record("gpu.clock_offset", "", Reversal{Kind: ReverseGPUClocks}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := Stale()
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("Stale() = %v, %v, want the dead session's journal", stale, err)
+	}
+	if err := stale[0].Replay(); err == nil {
+		t.Fatal("Replay() error = nil, want the failed governor reversal")
+	}
+	if err := stale[0].MarkRecoveryFailed(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gpu.clocks"}; !slices.Equal(*applied, want) {
+		t.Errorf("applied %v, want %v", *applied, want)
+	}
+
+	// The failed reversal stays pending for the next attempt.
+	stale, _ = Stale()
+	if len(stale) != 1 || len(stale[0].Pending()) != 1 {
+		t.Fatalf("after a failed replay Stale() = %v, want one pending entry", stale)
+	}
+	if !stale[0].RecoveryFailed {
+		t.Error("the failed automatic recovery was not recorded")
+	}
+	stale[0].Entries[0].Reversal.Value = "performance"
+	if err := stale[0].Replay(); err != nil {
+		t.Fatal(err)
+	}
+	if stale, _ := Stale(); len(stale) != 0 {
+		t.Errorf("Stale() = %v after a successful replay", stale)
+	}
+}
+
+func TestRunningReusedPID(t *testing.T) {
+	j := New("Game", 10)
+	if j.StartTime == 0 || !j.Running() {
+		t.Fatalf("new journal = %+v, want this process's start time and running", j)
+	}
+
+	// Another process that started later under the same PID.
+	reused := &Journal{PID: j.PID, StartTime: j.StartTime + 1}
+	if reused.Running() {
+		t.Error("Running() = true for a journal whose PID was reused")
+	}
+}
+
+func TestJournalsDoNotShareFiles(t *testing.T) {
+	fakeReverters(t)
+
+	// A journal left behind by an earlier process with the same PID.
+	older := New("Older", 10)
+	older.Started = older.Started.Add(-time.Hour)
+	older.RecoveryFailed = true
+	if _, err := older.Record("cpu.governor", "", Reversal{Kind: ReverseGovernor, Value: "powersave"}); err != nil {
+		t.Fatal(err)
+	}
+
+	j := New("Game", 20)
+	if j.path == older.path {
+		t.Fatalf("both journals use %s", j.path)
+	}
+	if _, err := j.Record("gpu.clock_offset", "", Reversal{Kind: ReverseGPUClocks}); err != nil {
+		t.Fatal(err)
+	}
+
+	journals, err := Load()
+	if err != nil || len(journals) != 2 {
+		t.Fatalf("Load() = %v, %v, want both journals", journals, err)
+	}
+	if journals[0].Game != "Older" || !journals[0].RecoveryFailed || len(journals[0].Pending()) != 1 {
+		t.Errorf("older journal = %+v, want its pending reversal kept", journals[0])
+	}
+}
+
+func TestParseStartTime(t *testing.T) {
+	stat := "4242 (a) game (x)) S 1 4242 4242 0 -1 4194560 100 0 0 0 5 3 0 0 20 0 8 0 987654 0 0"
+	if got, err := parseStartTime(stat); err != nil || got != 987654 {
+		t.Errorf("parseStartTime() = %d, %v, want 987654", got, err)
+	}
+	if _, err := parseStartTime("4242 (game) S 1"); err == nil {
+		t.Error("parseStartTime() of a short stat succeeded")
+	}
+}
+
+func TestSCXReversal(t *testing.T) {
+	tests := []struct {
+		state cpu.SCXState
+		want  Reversal
+		text  string
+	}{
+		{
+			cpu.SCXState{Backend: cpu.SCXBackendLoader, Scheduler: "scx_bpfland", Running: true, Mode: cpu.SCXModeAuto},
+			Reversal{Kind: ReverseSCXLoader, Value: "scx_bpfland:auto"},
+			"Switch back to scheduler scx_bpfland (auto)",
+		},
+		{
+			cpu.SCXState{Backend: cpu.SCXBackendLoader},
+			Reversal{Kind: ReverseSCXLoader},
+			"Stop the sched_ext scheduler",
+		},
+		{
+			cpu.SCXState{Backend: cpu.SCXBackendService, Scheduler: "scx_rusty"},
+			Reversal{Kind: ReverseSCXService, Value: "scx_rusty:stopped"},
+			"Stop scx.service and set its scheduler back to scx_rusty",
+		},
+		{
+			cpu.SCXState{Backend: cpu.SCXBackendService, Scheduler: "scx_rusty", Running: true},
+			Reversal{Kind: ReverseSCXService, Value: "scx_rusty:running"},
+			"Switch scx.service back to scx_rusty",
+		},
+	}
+	for _, tt := range tests {
+		got := SCXReversal(tt.state)
+		if got != tt.want {
+			t.Errorf("SCXReversal(%+v) = %+v, want %+v", tt.state, got, tt.want)
+		}
+		if got.String() != tt.text {
+			t.Errorf("%+v.String() = %q, want %q", got, got.String(), tt.text)
+		}
+	}
+}
@@ -120,14 +120,7 @@ func ScanLibrary(lib Library) ([]*game.Game, error) {
 		}
 
 		dlls, _ := dll.ScanDirectory(manifest.FullInstallDir)
-		for _, d := range dlls {
-			g.DLLs = append(g.DLLs, game.DetectedDLL{
-				Path:    d.Path,
-				Name:    d.Name,
-				Type:    d.Type,
-				Version: d.Version,
-			})
-		}
+		g.DLLs = append(g.DLLs, dlls...)
 
 		games = append(games, g)
 	}
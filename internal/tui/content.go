@@ -12,6 +12,7 @@ import (
 	"github.com/jgabor/spela/internal/dll"
 	"github.com/jgabor/spela/internal/env"
 	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/launcher"
 	"github.com/jgabor/spela/internal/profile"
 )
 
@@ -36,6 +37,8 @@ type ContentModel struct {
 	profile             *profile.Profile
 	profileWidget       ProfileWidgetModel
 	dlssPresetModal     DLSSPresetModalModel
+	historyModal        ProfileHistoryModalModel
+	sessionsModal       SessionsModalModel
 	width               int
 	height              int
 	profileHeight       int
@@ -87,6 +90,8 @@ type dllTypesLoadedMsg struct {
 func NewContent() ContentModel {
 	return ContentModel{
 		dlssPresetModal: NewDLSSPresetModal(),
+		historyModal:    NewProfileHistoryModal(),
+		sessionsModal:   NewSessionsModal(),
 	}
 }
 
@@ -124,7 +129,7 @@ func (m ContentModel) SetDefaultProfile() ContentModel {
 	m.usingDefaultProfile = false
 	m.launching = false
 
-	p, _ := profile.LoadDefault()
+	p := loadDefaultProfile()
 	m.profile = p
 	m.profileWidget = NewDefaultProfileWidget(p)
 	if m.profile == nil {
@@ -163,6 +168,18 @@ func (m ContentModel) Update(msg tea.Msg) (ContentModel, tea.Cmd) {
 		return m, tea.Batch(cmds...)
 	}
 
+	if m.historyModal.Visible() {
+		var cmd tea.Cmd
+		m.historyModal, cmd = m.historyModal.Update(msg)
+		return m, cmd
+	}
+
+	if m.sessionsModal.Visible() {
+		var cmd tea.Cmd
+		m.sessionsModal, cmd = m.sessionsModal.Update(msg)
+		return m, cmd
+	}
+
 	if m.dllInstallState != DLLInstallNone {
 		return m.updateDLLInstall(msg)
 	}
@@ -180,6 +197,19 @@ func (m ContentModel) Update(msg tea.Msg) (ContentModel, tea.Cmd) {
 	case dlssPresetCancelledMsg:
 		return m, nil
 
+	case profileRevertRequestMsg:
+		return m, m.revertProfile(msg.revision)
+
+	case profileRevertMsg:
+		if msg.err == nil && m.game != nil {
+			p, inherited := loadEffectiveProfile(m.game.AppID)
+			m.profile = p
+			m.usingDefaultProfile = inherited
+			m.profileWidget = NewProfileWidget(m.game, p)
+			m.profileHeight = m.profileSectionHeight()
+		}
+		return m, nil
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "l":
@@ -203,13 +233,25 @@ func (m ContentModel) Update(msg tea.Msg) (ContentModel, tea.Cmd) {
 				m.dllOperating = true
 				return m, m.restoreDLLs()
 			}
+		case "H":
+			if m.game != nil && !m.defaultProfile && !m.profileWidget.Editing() {
+				m.historyModal.SetSize(m.width, m.height)
+				m.historyModal.Open(m.game.AppID, m.game.Name)
+				return m, nil
+			}
+		case "S":
+			if m.game != nil && !m.profileWidget.Editing() {
+				m.sessionsModal.SetSize(m.width, m.height)
+				m.sessionsModal.Open(m.game.AppID, m.game.Name)
+				return m, nil
+			}
 		}
 
 	case profileSaveMsg:
 		if msg.success {
+			m.profileWidget = m.profileWidget.Saved()
 			if m.defaultProfile {
-				p, _ := profile.LoadDefault()
-				m.profile = p
+				m.profile = loadDefaultProfile()
 			} else if m.game != nil {
 				p, inherited := loadEffectiveProfile(m.game.AppID)
 				m.profile = p
@@ -332,8 +374,16 @@ func (m ContentModel) restoreDLLs() tea.Cmd {
 	}
 }
 
+func (m ContentModel) revertProfile(revision int) tea.Cmd {
+	appID := m.game.AppID
+	return func() tea.Msg {
+		_, err := profile.Revert(appID, revision)
+		return profileRevertMsg{revision: revision, err: err}
+	}
+}
+
 func (m ContentModel) HasModalOpen() bool {
-	return m.dlssPresetModal.Visible() || m.dllInstallState != DLLInstallNone || m.profileWidget.Editing()
+	return m.dlssPresetModal.Visible() || m.historyModal.Visible() || m.sessionsModal.Visible() || m.dllInstallState != DLLInstallNone || m.profileWidget.Editing()
 }
 
 func (m ContentModel) HasGameSelection() bool {
@@ -345,6 +395,14 @@ func (m ContentModel) View() string {
 		return m.dlssPresetModal.View()
 	}
 
+	if m.historyModal.Visible() {
+		return m.historyModal.View()
+	}
+
+	if m.sessionsModal.Visible() {
+		return m.sessionsModal.View()
+	}
+
 	if m.dllInstallState != DLLInstallNone {
 		return m.renderDLLInstallDialog()
 	}
@@ -558,16 +616,23 @@ func (m ContentModel) renderProfile() string {
 	return b.String()
 }
 
+// loadEffectiveProfile returns the resolved profile of a game, and whether
+// all of it is inherited because the game has no profile of its own.
 func loadEffectiveProfile(appID uint64) (*profile.Profile, bool) {
-	p, _ := profile.Load(appID)
-	if p != nil {
-		return p, false
-	}
-	defaultProfile, _ := profile.LoadDefault()
-	if defaultProfile == nil {
+	r, err := profile.Resolve(appID)
+	if err != nil || len(r.Layers) == 0 {
 		return nil, false
 	}
-	return defaultProfile, true
+	return r.Profile, !profile.Exists(appID)
+}
+
+// loadDefaultProfile returns the resolved default profile.
+func loadDefaultProfile() *profile.Profile {
+	r, err := profile.ResolveDefault()
+	if err != nil || len(r.Layers) == 0 {
+		return nil
+	}
+	return r.Profile
 }
 
 func (m ContentModel) loadDLLTypes() tea.Cmd {
@@ -719,7 +784,8 @@ func (m ContentModel) launchGame() tea.Cmd {
 			p.Apply(environment)
 		}
 
-		cmd := exec.Command("steam", fmt.Sprintf("steam://rungameid/%d", g.AppID))
+		steam := launcher.SteamCommand(g.AppID, p)
+		cmd := exec.Command(steam[0], steam[1:]...)
 		environment.ApplyToCmd(cmd)
 		if err := cmd.Start(); err != nil {
 			return launchGameMsg{err: fmt.Errorf("failed to launch game: %w", err)}
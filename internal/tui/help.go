@@ -59,6 +59,8 @@ func NewHelp() HelpModel {
 					{"i", "Install DLL"},
 					{"u", "Update DLLs"},
 					{"R", "Restore DLLs"},
+					{"H", "Profile history"},
+					{"S", "Sessions and playtime"},
 				},
 			},
 			{
@@ -316,6 +316,16 @@ func (m LayoutModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.content, _ = m.content.Update(msg)
 		return m, cmd
 
+	case profileRevertMsg:
+		var cmd tea.Cmd
+		if msg.err != nil {
+			cmd = m.messageBar.SetMessage(fmt.Sprintf("Revert failed: %v", msg.err), MessageError)
+		} else {
+			cmd = m.messageBar.SetMessage(fmt.Sprintf("Profile reverted to revision %d", msg.revision), MessageSuccess)
+		}
+		m.content, _ = m.content.Update(msg)
+		return m, cmd
+
 	case optionsSavedMsg:
 		m.config = msg.config
 		cmd := m.messageBar.SetMessage("Options saved!", MessageSuccess)
@@ -140,6 +140,13 @@ func buildOptionsSections() []OptionsSection {
 					Type:        OptionTypeEnum,
 					Options:     []string{"techpowerup", "github"},
 				},
+				{
+					Key:         "dll_signature_policy",
+					Label:       "Signature check",
+					Description: "Verify vendor signatures before swapping DLLs",
+					Type:        OptionTypeEnum,
+					Options:     []string{"enforce", "warn", "off"},
+				},
 			},
 		},
 		{
@@ -394,6 +401,11 @@ func (m OptionsModalModel) getConfigValue(key string) string {
 			return "techpowerup"
 		}
 		return m.config.PreferredDLLSource
+	case "dll_signature_policy":
+		if m.config.DLLSignaturePolicy == "" {
+			return "enforce"
+		}
+		return m.config.DLLSignaturePolicy
 	case "show_hints":
 		return boolStr(m.config.ShowHints)
 	case "theme":
@@ -447,6 +459,8 @@ func (m *OptionsModalModel) setConfigValue(key, value string) {
 		m.config.ManifestRefreshHours = v
 	case "preferred_dll_source":
 		m.config.PreferredDLLSource = value
+	case "dll_signature_policy":
+		m.config.DLLSignaturePolicy = value
 	case "show_hints":
 		m.config.ShowHints = value == "true"
 		SetShowHints(m.config.ShowHints)
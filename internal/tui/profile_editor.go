@@ -11,8 +11,11 @@ import (
 )
 
 type ProfileEditorModel struct {
-	game       *game.Game
-	profile    *profile.Profile
+	game    *game.Game
+	profile *profile.Profile
+	// shown is the profile as first shown, to tell edited settings from
+	// inherited ones on save.
+	shown      *profile.Profile
 	saveTarget ProfileSaveTarget
 	cursor     int
 	fields     []profileField
@@ -29,10 +32,10 @@ type profileField struct {
 }
 
 func frameGenerationValue(p *profile.Profile) string {
-	if !p.DLSS.FGOverride {
+	if !profile.IsTrue(p.DLSS.FGOverride) {
 		return "(default)"
 	}
-	if p.DLSS.FGEnabled {
+	if profile.IsTrue(p.DLSS.FGEnabled) {
 		return "true"
 	}
 	return "false"
@@ -61,7 +64,7 @@ func NewProfileEditor(g *game.Game, p *profile.Profile) ProfileEditorModel {
 		{
 			label:       "DLSS-SR Override",
 			key:         "sr_override",
-			value:       boolStr(p.DLSS.SROverride),
+			value:       boolStr(profile.IsTrue(p.DLSS.SROverride)),
 			options:     []string{"true", "false"},
 			description: "Force DLSS super resolution even if game doesn't natively support it",
 		},
@@ -75,28 +78,28 @@ func NewProfileEditor(g *game.Game, p *profile.Profile) ProfileEditorModel {
 		{
 			label:       "Multi-Frame",
 			key:         "multi_frame",
-			value:       intStr(p.DLSS.MultiFrame),
+			value:       intStr(profile.IntValue(p.DLSS.MultiFrame)),
 			options:     []string{"0", "1", "2", "3", "4"},
 			description: "Number of extra frames to generate (0=disabled, 1-4=frame multiplier)",
 		},
 		{
 			label:       "DLSS Indicator",
 			key:         "indicator",
-			value:       boolStr(p.DLSS.Indicator),
+			value:       boolStr(profile.IsTrue(p.DLSS.Indicator)),
 			options:     []string{"true", "false"},
 			description: "Show on-screen indicator when DLSS is active",
 		},
 		{
 			label:       "Shader Cache",
 			key:         "shader_cache",
-			value:       boolStr(p.GPU.ShaderCache),
+			value:       boolStr(profile.IsTrue(p.GPU.ShaderCache)),
 			options:     []string{"true", "false"},
 			description: "Enable GPU shader caching for faster load times after first run",
 		},
 		{
 			label:       "Threaded Opt",
 			key:         "threaded_opt",
-			value:       boolStr(p.GPU.ThreadedOptimization),
+			value:       boolStr(profile.IsTrue(p.GPU.ThreadedOptimization)),
 			options:     []string{"true", "false"},
 			description: "Enable NVIDIA threaded optimization for multi-core performance",
 		},
@@ -110,39 +113,34 @@ func NewProfileEditor(g *game.Game, p *profile.Profile) ProfileEditorModel {
 		{
 			label:       "HDR",
 			key:         "hdr",
-			value:       boolStr(p.Proton.EnableHDR),
+			value:       boolStr(profile.IsTrue(p.Proton.EnableHDR)),
 			options:     []string{"true", "false"},
 			description: "Enable high dynamic range output for compatible displays",
 		},
 		{
 			label:       "Wayland",
 			key:         "wayland",
-			value:       boolStr(p.Proton.EnableWayland),
+			value:       boolStr(profile.IsTrue(p.Proton.EnableWayland)),
 			options:     []string{"true", "false"},
 			description: "Use native Wayland instead of XWayland. May improve latency",
 		},
 		{
 			label:       "NGX Updater",
 			key:         "ngx_updater",
-			value:       boolStr(p.Proton.EnableNGXUpdater),
+			value:       boolStr(profile.IsTrue(p.Proton.EnableNGXUpdater)),
 			options:     []string{"true", "false"},
 			description: "Let Proton automatically update DLSS DLLs to latest version",
 		},
 		{
 			label:       "Save Backup",
 			key:         "backup_on_launch",
-			value:       boolStr(p.Ludusavi.BackupOnLaunch),
+			value:       boolStr(profile.IsTrue(p.Ludusavi.BackupOnLaunch)),
 			options:     []string{"true", "false"},
 			description: "Automatically backup save games when launching via Ludusavi",
 		},
 	}
 
-	return ProfileEditorModel{
-		game:       g,
-		profile:    p,
-		saveTarget: NewGameProfileSaveTarget(g.AppID),
-		fields:     fields,
-	}
+	return newProfileEditor(g, p, NewGameProfileSaveTarget(g.AppID), fields)
 }
 
 func NewDefaultProfileEditor(p *profile.Profile) ProfileEditorModel {
@@ -168,7 +166,7 @@ func NewDefaultProfileEditor(p *profile.Profile) ProfileEditorModel {
 		{
 			label:       "DLSS-SR Override",
 			key:         "sr_override",
-			value:       boolStr(p.DLSS.SROverride),
+			value:       boolStr(profile.IsTrue(p.DLSS.SROverride)),
 			options:     []string{"true", "false"},
 			description: "Force DLSS super resolution even if game doesn't natively support it",
 		},
@@ -182,28 +180,28 @@ func NewDefaultProfileEditor(p *profile.Profile) ProfileEditorModel {
 		{
 			label:       "Multi-Frame",
 			key:         "multi_frame",
-			value:       intStr(p.DLSS.MultiFrame),
+			value:       intStr(profile.IntValue(p.DLSS.MultiFrame)),
 			options:     []string{"0", "1", "2", "3", "4"},
 			description: "Number of extra frames to generate (0=disabled, 1-4=frame multiplier)",
 		},
 		{
 			label:       "DLSS Indicator",
 			key:         "indicator",
-			value:       boolStr(p.DLSS.Indicator),
+			value:       boolStr(profile.IsTrue(p.DLSS.Indicator)),
 			options:     []string{"true", "false"},
 			description: "Show on-screen indicator when DLSS is active",
 		},
 		{
 			label:       "Shader Cache",
 			key:         "shader_cache",
-			value:       boolStr(p.GPU.ShaderCache),
+			value:       boolStr(profile.IsTrue(p.GPU.ShaderCache)),
 			options:     []string{"true", "false"},
 			description: "Enable GPU shader caching for faster load times after first run",
 		},
 		{
 			label:       "Threaded Opt",
 			key:         "threaded_opt",
-			value:       boolStr(p.GPU.ThreadedOptimization),
+			value:       boolStr(profile.IsTrue(p.GPU.ThreadedOptimization)),
 			options:     []string{"true", "false"},
 			description: "Enable NVIDIA threaded optimization for multi-core performance",
 		},
@@ -217,38 +215,49 @@ func NewDefaultProfileEditor(p *profile.Profile) ProfileEditorModel {
 		{
 			label:       "HDR",
 			key:         "hdr",
-			value:       boolStr(p.Proton.EnableHDR),
+			value:       boolStr(profile.IsTrue(p.Proton.EnableHDR)),
 			options:     []string{"true", "false"},
 			description: "Enable high dynamic range output for compatible displays",
 		},
 		{
 			label:       "Wayland",
 			key:         "wayland",
-			value:       boolStr(p.Proton.EnableWayland),
+			value:       boolStr(profile.IsTrue(p.Proton.EnableWayland)),
 			options:     []string{"true", "false"},
 			description: "Use native Wayland instead of XWayland. May improve latency",
 		},
 		{
 			label:       "NGX Updater",
 			key:         "ngx_updater",
-			value:       boolStr(p.Proton.EnableNGXUpdater),
+			value:       boolStr(profile.IsTrue(p.Proton.EnableNGXUpdater)),
 			options:     []string{"true", "false"},
 			description: "Let Proton automatically update DLSS DLLs to latest version",
 		},
 		{
 			label:       "Save Backup",
 			key:         "backup_on_launch",
-			value:       boolStr(p.Ludusavi.BackupOnLaunch),
+			value:       boolStr(profile.IsTrue(p.Ludusavi.BackupOnLaunch)),
 			options:     []string{"true", "false"},
 			description: "Backup saves when launching game",
 		},
 	}
 
-	return ProfileEditorModel{
-		profile:    p,
-		saveTarget: DefaultProfileSaveTarget(),
+	return newProfileEditor(nil, p, DefaultProfileSaveTarget(), fields)
+}
+
+// newProfileEditor edits a copy of p, the resolved profile. Values are
+// normalized to what the fields can show first, so that only the fields the
+// user changes differ from shown.
+func newProfileEditor(g *game.Game, p *profile.Profile, saveTarget ProfileSaveTarget, fields []profileField) ProfileEditorModel {
+	m := ProfileEditorModel{
+		game:       g,
+		profile:    cloneSettings(p),
+		saveTarget: saveTarget,
 		fields:     fields,
 	}
+	m.applyToProfile()
+	m.shown = cloneSettings(m.profile)
+	return m
 }
 
 func boolStr(b bool) string {
@@ -331,25 +340,25 @@ func (m *ProfileEditorModel) applyToProfile() {
 		case "sr_preset":
 			m.profile.DLSS.SRPreset = profile.DLSSPreset(f.value)
 		case "sr_override":
-			m.profile.DLSS.SROverride = f.value == "true"
+			m.profile.DLSS.SROverride = profile.Bool(f.value == "true")
 		case "fg_enabled":
 			if f.value == "(default)" {
-				m.profile.DLSS.FGEnabled = false
-				m.profile.DLSS.FGOverride = false
+				m.profile.DLSS.FGEnabled = nil
+				m.profile.DLSS.FGOverride = nil
 			} else {
-				m.profile.DLSS.FGEnabled = f.value == "true"
-				m.profile.DLSS.FGOverride = true
+				m.profile.DLSS.FGEnabled = profile.Bool(f.value == "true")
+				m.profile.DLSS.FGOverride = profile.Bool(true)
 			}
 		case "multi_frame":
 			var v int
 			_, _ = fmt.Sscanf(f.value, "%d", &v)
-			m.profile.DLSS.MultiFrame = v
+			m.profile.DLSS.MultiFrame = profile.Int(v)
 		case "indicator":
-			m.profile.DLSS.Indicator = f.value == "true"
+			m.profile.DLSS.Indicator = profile.Bool(f.value == "true")
 		case "shader_cache":
-			m.profile.GPU.ShaderCache = f.value == "true"
+			m.profile.GPU.ShaderCache = profile.Bool(f.value == "true")
 		case "threaded_opt":
-			m.profile.GPU.ThreadedOptimization = f.value == "true"
+			m.profile.GPU.ThreadedOptimization = profile.Bool(f.value == "true")
 		case "power_mizer":
 			if f.value == "auto" {
 				m.profile.GPU.PowerMizer = ""
@@ -357,20 +366,20 @@ func (m *ProfileEditorModel) applyToProfile() {
 				m.profile.GPU.PowerMizer = f.value
 			}
 		case "hdr":
-			m.profile.Proton.EnableHDR = f.value == "true"
+			m.profile.Proton.EnableHDR = profile.Bool(f.value == "true")
 		case "wayland":
-			m.profile.Proton.EnableWayland = f.value == "true"
+			m.profile.Proton.EnableWayland = profile.Bool(f.value == "true")
 		case "ngx_updater":
-			m.profile.Proton.EnableNGXUpdater = f.value == "true"
+			m.profile.Proton.EnableNGXUpdater = profile.Bool(f.value == "true")
 		case "backup_on_launch":
-			m.profile.Ludusavi.BackupOnLaunch = f.value == "true"
+			m.profile.Ludusavi.BackupOnLaunch = profile.Bool(f.value == "true")
 		}
 	}
 }
 
 func (m ProfileEditorModel) save() tea.Cmd {
 	return func() tea.Msg {
-		if err := m.saveTarget.SaveProfile(m.profile); err != nil {
+		if err := m.saveTarget.SaveEdits(m.shown, m.profile); err != nil {
 			return profileSaveMsg{err: err}
 		}
 		return profileSaveMsg{success: true}
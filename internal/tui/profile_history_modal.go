@@ -0,0 +1,220 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/jgabor/spela/internal/profile"
+)
+
+const historyVisibleRevisions = 8
+
+type ProfileHistoryModalModel struct {
+	visible   bool
+	appID     uint64
+	gameName  string
+	revisions []profile.Revision
+	changes   [][]profile.FieldChange
+	current   int
+	cursor    int
+	offset    int
+	err       error
+	width     int
+	height    int
+}
+
+type profileRevertRequestMsg struct {
+	revision int
+}
+
+type profileRevertMsg struct {
+	revision int
+	err      error
+}
+
+func NewProfileHistoryModal() ProfileHistoryModalModel {
+	return ProfileHistoryModalModel{}
+}
+
+func (m *ProfileHistoryModalModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+// Open loads the revisions of a game profile, newest first, together with
+// what each one would change compared to the current profile.
+func (m *ProfileHistoryModalModel) Open(appID uint64, gameName string) {
+	m.visible = true
+	m.appID = appID
+	m.gameName = gameName
+	m.cursor = 0
+	m.offset = 0
+	m.current = -1
+	m.changes = nil
+
+	revisions, err := profile.History(appID)
+	slices.Reverse(revisions)
+	m.revisions = revisions
+	m.err = err
+	if err != nil {
+		return
+	}
+
+	current, err := profile.Load(appID)
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	for i, rev := range revisions {
+		if m.current < 0 && rev.IsCurrent(appID) {
+			m.current = i
+		}
+		p, err := profile.LoadRevision(appID, rev.Number)
+		if err != nil {
+			m.changes = append(m.changes, nil)
+			continue
+		}
+		m.changes = append(m.changes, profile.Diff(current, p))
+	}
+}
+
+func (m ProfileHistoryModalModel) Visible() bool {
+	return m.visible
+}
+
+func (m ProfileHistoryModalModel) Update(msg tea.Msg) (ProfileHistoryModalModel, tea.Cmd) {
+	if !m.visible {
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			if m.cursor < m.offset {
+				m.offset = m.cursor
+			}
+		case "down", "j":
+			if m.cursor < len(m.revisions)-1 {
+				m.cursor++
+			}
+			if m.cursor >= m.offset+historyVisibleRevisions {
+				m.offset = m.cursor - historyVisibleRevisions + 1
+			}
+		case "enter":
+			if len(m.revisions) == 0 || m.cursor == m.current {
+				return m, nil
+			}
+			m.visible = false
+			revision := m.revisions[m.cursor].Number
+			return m, func() tea.Msg {
+				return profileRevertRequestMsg{revision: revision}
+			}
+		case "esc", "q":
+			m.visible = false
+		}
+	}
+
+	return m, nil
+}
+
+func (m ProfileHistoryModalModel) View() string {
+	if !m.visible {
+		return ""
+	}
+
+	t := GetTheme()
+
+	modalWidth := 70
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(modalWidth).
+		Padding(1, 2)
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Profile history: " + m.gameName))
+	b.WriteString("\n\n")
+
+	switch {
+	case m.err != nil:
+		b.WriteString(errorStyle.Render(fmt.Sprintf("Failed to load history: %v", m.err)))
+		b.WriteString("\n")
+	case len(m.revisions) == 0:
+		b.WriteString(dimStyle.Render("No saved revisions yet"))
+		b.WriteString("\n")
+	default:
+		end := min(m.offset+historyVisibleRevisions, len(m.revisions))
+		for i := m.offset; i < end; i++ {
+			rev := m.revisions[i]
+			cursor := "  "
+			style := normalStyle
+			if i == m.cursor {
+				cursor = "> "
+				style = selectedStyle
+			}
+
+			b.WriteString(style.Render(fmt.Sprintf("%s%4d  %s", cursor, rev.Number, rev.Time.Local().Format("2006-01-02 15:04:05"))))
+			if i == m.current {
+				b.WriteString(successStyle.Render("  current"))
+			}
+			b.WriteString("\n")
+		}
+
+		b.WriteString("\n")
+		b.WriteString(m.renderChanges())
+	}
+
+	if hint := RenderHint("\n\n" + "↑↓:navigate • enter:revert • esc:close"); hint != "" {
+		b.WriteString(hint)
+	}
+
+	modal := boxStyle.Render(b.String())
+
+	centerX := max((m.width-modalWidth-4)/2, 0)
+	centerY := max((m.height-lipgloss.Height(modal))/2, 0)
+
+	positionedStyle := lipgloss.NewStyle().
+		MarginLeft(centerX).
+		MarginTop(centerY)
+
+	return positionedStyle.Render(modal)
+}
+
+func (m ProfileHistoryModalModel) renderChanges() string {
+	if m.cursor == m.current {
+		return dimStyle.Render("This is the current profile")
+	}
+
+	changes := m.changes[m.cursor]
+	if len(changes) == 0 {
+		return dimStyle.Render("Same settings as the current profile")
+	}
+
+	var b strings.Builder
+	b.WriteString(dimStyle.Render("Reverting changes:"))
+	for _, c := range changes {
+		b.WriteString("\n")
+		b.WriteString(normalStyle.Render(c.Key + ": "))
+		b.WriteString(dimStyle.Render(historyValue(c.Old) + " → "))
+		b.WriteString(dlssStyle.Render(historyValue(c.New)))
+	}
+	return b.String()
+}
+
+func historyValue(value string) string {
+	if value == "" {
+		return "(default)"
+	}
+	return value
+}
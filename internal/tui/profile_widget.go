@@ -8,6 +8,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/jgabor/spela/internal/game"
+	"github.com/jgabor/spela/internal/gpu"
 	"github.com/jgabor/spela/internal/profile"
 )
 
@@ -34,15 +35,41 @@ func DefaultProfileSaveTarget() ProfileSaveTarget {
 	return ProfileSaveTarget{kind: ProfileSaveTargetDefault}
 }
 
-func (target ProfileSaveTarget) SaveProfile(p *profile.Profile) error {
+// SaveEdits writes the settings that differ between shown, the resolved
+// profile an editor started from, and edited to the target's profile file.
+// Settings that were not changed stay inherited.
+func (target ProfileSaveTarget) SaveEdits(shown, edited *profile.Profile) error {
+	var layer *profile.Profile
+	var err error
 	switch target.kind {
 	case ProfileSaveTargetDefault:
-		return profile.SaveDefault(p)
+		layer, err = profile.LoadDefault()
 	case ProfileSaveTargetGame:
-		return profile.Save(target.appID, p)
+		layer, err = profile.Load(target.appID)
 	default:
 		return fmt.Errorf("unsupported profile save target")
 	}
+	if err != nil {
+		return err
+	}
+	if layer == nil {
+		layer = &profile.Profile{}
+	}
+	if err := profile.ApplyEdits(layer, shown, edited); err != nil {
+		return err
+	}
+
+	if target.kind == ProfileSaveTargetDefault {
+		return profile.SaveDefault(layer)
+	}
+	return profile.Save(target.appID, layer)
+}
+
+// cloneSettings copies the settings of p that editors work with.
+func cloneSettings(p *profile.Profile) *profile.Profile {
+	c := &profile.Profile{}
+	_ = c.CopySettings(p, profile.Keys())
+	return c
 }
 
 type WidgetField struct {
@@ -60,7 +87,10 @@ type WidgetGroup struct {
 }
 
 type ProfileWidgetModel struct {
-	profile      *profile.Profile
+	profile *profile.Profile
+	// shown is the profile as first shown, to tell edited settings from
+	// inherited ones on save.
+	shown        *profile.Profile
 	saveTarget   ProfileSaveTarget
 	groups       []WidgetGroup
 	focusedGroup int
@@ -114,15 +144,56 @@ func NewDefaultProfileWidget(p *profile.Profile) ProfileWidgetModel {
 	return newProfileWidget(DefaultProfileSaveTarget(), "Default profile", p)
 }
 
+// newProfileWidget edits a copy of p, the resolved profile. Values are
+// normalized to what the fields can show first, so that only the fields the
+// user changes differ from shown.
 func newProfileWidget(saveTarget ProfileSaveTarget, name string, p *profile.Profile) ProfileWidgetModel {
 	if p == nil {
 		p = &profile.Profile{Name: name}
 	}
+	p = cloneSettings(p)
+	groups := profileWidgetGroups(p)
+	applyWidgetFields(p, groups)
 
-	groups := []WidgetGroup{
+	return ProfileWidgetModel{
+		profile:      p,
+		shown:        cloneSettings(p),
+		saveTarget:   saveTarget,
+		groups:       groups,
+		focusedGroup: 0,
+		focusedField: 0,
+		editing:      false,
+	}
+}
+
+func presetOptions() []string {
+	options := []string{"(none)"}
+	presets, _ := profile.ListPresets()
+	for _, preset := range presets {
+		options = append(options, preset.Name)
+	}
+	return options
+}
+
+func presetValue(name string) string {
+	if name == "" {
+		return "(none)"
+	}
+	return name
+}
+
+func profileWidgetGroups(p *profile.Profile) []WidgetGroup {
+	return []WidgetGroup{
 		{
 			title: "DLSS settings",
 			fields: []WidgetField{
+				{
+					label:       "Preset",
+					key:         "preset",
+					value:       presetValue(p.Preset),
+					options:     presetOptions(),
+					description: "Apply a named preset, tuned to the detected GPU",
+				},
 				{
 					label:       "Quality mode",
 					key:         "sr_mode",
@@ -141,28 +212,28 @@ func newProfileWidget(saveTarget ProfileSaveTarget, name string, p *profile.Prof
 				{
 					label:       "Override",
 					key:         "sr_override",
-					value:       displayBool(p.DLSS.SROverride),
+					value:       displayBool(profile.IsTrue(p.DLSS.SROverride)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Force DLSS even if unsupported",
 				},
 				{
 					label:       "Indicator",
 					key:         "indicator",
-					value:       displayBool(p.DLSS.Indicator),
+					value:       displayBool(profile.IsTrue(p.DLSS.Indicator)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Show on-screen DLSS indicator",
 				},
 				{
 					label:       "Frame gen",
 					key:         "fg_enabled",
-					value:       displayFrameGeneration(p.DLSS.FGEnabled, p.DLSS.FGOverride),
+					value:       displayFrameGeneration(profile.IsTrue(p.DLSS.FGEnabled), profile.IsTrue(p.DLSS.FGOverride)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Enable AI frame generation",
 				},
 				{
 					label:       "Multi-frame",
 					key:         "multi_frame",
-					value:       displayInt(p.DLSS.MultiFrame),
+					value:       displayInt(profile.IntValue(p.DLSS.MultiFrame)),
 					options:     []string{"(default)", "1", "2", "3", "4"},
 					description: "Extra frames to generate (0=off)",
 				},
@@ -174,14 +245,14 @@ func newProfileWidget(saveTarget ProfileSaveTarget, name string, p *profile.Prof
 				{
 					label:       "Shader cache",
 					key:         "shader_cache",
-					value:       displayBool(p.GPU.ShaderCache),
+					value:       displayBool(profile.IsTrue(p.GPU.ShaderCache)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Enable GPU shader caching",
 				},
 				{
 					label:       "Threaded opt",
 					key:         "threaded_opt",
-					value:       displayBool(p.GPU.ThreadedOptimization),
+					value:       displayBool(profile.IsTrue(p.GPU.ThreadedOptimization)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Enable threaded optimization",
 				},
@@ -200,21 +271,21 @@ func newProfileWidget(saveTarget ProfileSaveTarget, name string, p *profile.Prof
 				{
 					label:       "HDR",
 					key:         "hdr",
-					value:       displayBool(p.Proton.EnableHDR),
+					value:       displayBool(profile.IsTrue(p.Proton.EnableHDR)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Enable high dynamic range",
 				},
 				{
 					label:       "Wayland",
 					key:         "wayland",
-					value:       displayBool(p.Proton.EnableWayland),
+					value:       displayBool(profile.IsTrue(p.Proton.EnableWayland)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Use native Wayland",
 				},
 				{
 					label:       "NGX updater",
 					key:         "ngx_updater",
-					value:       displayBool(p.Proton.EnableNGXUpdater),
+					value:       displayBool(profile.IsTrue(p.Proton.EnableNGXUpdater)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Auto-update DLSS DLLs",
 				},
@@ -226,22 +297,13 @@ func newProfileWidget(saveTarget ProfileSaveTarget, name string, p *profile.Prof
 				{
 					label:       "Save backup",
 					key:         "backup_on_launch",
-					value:       displayBool(p.Ludusavi.BackupOnLaunch),
+					value:       displayBool(profile.IsTrue(p.Ludusavi.BackupOnLaunch)),
 					options:     []string{"(default)", "true", "false"},
 					description: "Backup saves on launch",
 				},
 			},
 		},
 	}
-
-	return ProfileWidgetModel{
-		profile:      p,
-		saveTarget:   saveTarget,
-		groups:       groups,
-		focusedGroup: 0,
-		focusedField: 0,
-		editing:      false,
-	}
 }
 
 func (m *ProfileWidgetModel) SetSize(width, height int) {
@@ -346,11 +408,39 @@ func (m *ProfileWidgetModel) cycleFieldValue(direction int) {
 	newIndex := (currentIndex + direction + len(field.options)) % len(field.options)
 	field.value = field.options[newIndex]
 	m.modified = true
+
+	if field.key == "preset" {
+		m.applyPreset(field.value)
+		return
+	}
 	m.applyToProfile()
 }
 
+// applyPreset applies the named preset to the profile and refreshes every
+// field from the result. Choosing "(none)" only clears the preset name.
+func (m *ProfileWidgetModel) applyPreset(name string) {
+	if name == "(none)" {
+		m.profile.Preset = ""
+		return
+	}
+
+	preset, err := profile.GetPreset(name)
+	if err != nil {
+		return
+	}
+	gen, _ := gpu.GetGPUGeneration()
+	if err := m.profile.ApplyPreset(preset, gen); err != nil {
+		return
+	}
+	m.groups = profileWidgetGroups(m.profile)
+}
+
 func (m *ProfileWidgetModel) applyToProfile() {
-	for _, group := range m.groups {
+	applyWidgetFields(m.profile, m.groups)
+}
+
+func applyWidgetFields(p *profile.Profile, groups []WidgetGroup) {
+	for _, group := range groups {
 		for _, field := range group.fields {
 			value := field.value
 			isDefault := value == "(default)"
@@ -358,54 +448,54 @@ func (m *ProfileWidgetModel) applyToProfile() {
 			switch field.key {
 			case "sr_mode":
 				if isDefault {
-					m.profile.DLSS.SRMode = ""
+					p.DLSS.SRMode = ""
 				} else {
-					m.profile.DLSS.SRMode = profile.DLSSMode(value)
+					p.DLSS.SRMode = profile.DLSSMode(value)
 				}
 			case "sr_preset":
 				if isDefault {
-					m.profile.DLSS.SRPreset = ""
+					p.DLSS.SRPreset = ""
 				} else {
-					m.profile.DLSS.SRPreset = profile.DLSSPreset(value)
+					p.DLSS.SRPreset = profile.DLSSPreset(value)
 				}
 			case "sr_override":
-				m.profile.DLSS.SROverride = value == "true"
+				p.DLSS.SROverride = profile.Bool(value == "true")
 			case "fg_enabled":
 				if isDefault {
-					m.profile.DLSS.FGEnabled = false
-					m.profile.DLSS.FGOverride = false
+					p.DLSS.FGEnabled = nil
+					p.DLSS.FGOverride = nil
 				} else {
-					m.profile.DLSS.FGEnabled = value == "true"
-					m.profile.DLSS.FGOverride = true
+					p.DLSS.FGEnabled = profile.Bool(value == "true")
+					p.DLSS.FGOverride = profile.Bool(true)
 				}
 			case "multi_frame":
 				if isDefault {
-					m.profile.DLSS.MultiFrame = 0
+					p.DLSS.MultiFrame = nil
 				} else {
 					var v int
 					_, _ = fmt.Sscanf(value, "%d", &v)
-					m.profile.DLSS.MultiFrame = v
+					p.DLSS.MultiFrame = profile.Int(v)
 				}
 			case "indicator":
-				m.profile.DLSS.Indicator = value == "true"
+				p.DLSS.Indicator = profile.Bool(value == "true")
 			case "shader_cache":
-				m.profile.GPU.ShaderCache = value == "true"
+				p.GPU.ShaderCache = profile.Bool(value == "true")
 			case "threaded_opt":
-				m.profile.GPU.ThreadedOptimization = value == "true"
+				p.GPU.ThreadedOptimization = profile.Bool(value == "true")
 			case "power_mizer":
 				if isDefault {
-					m.profile.GPU.PowerMizer = ""
+					p.GPU.PowerMizer = ""
 				} else {
-					m.profile.GPU.PowerMizer = value
+					p.GPU.PowerMizer = value
 				}
 			case "hdr":
-				m.profile.Proton.EnableHDR = value == "true"
+				p.Proton.EnableHDR = profile.Bool(value == "true")
 			case "wayland":
-				m.profile.Proton.EnableWayland = value == "true"
+				p.Proton.EnableWayland = profile.Bool(value == "true")
 			case "ngx_updater":
-				m.profile.Proton.EnableNGXUpdater = value == "true"
+				p.Proton.EnableNGXUpdater = profile.Bool(value == "true")
 			case "backup_on_launch":
-				m.profile.Ludusavi.BackupOnLaunch = value == "true"
+				p.Ludusavi.BackupOnLaunch = profile.Bool(value == "true")
 			}
 		}
 	}
@@ -431,13 +521,20 @@ func (m *ProfileWidgetModel) SetDLSSPreset(preset profile.DLSSPreset) {
 
 func (m ProfileWidgetModel) save() tea.Cmd {
 	return func() tea.Msg {
-		if err := m.saveTarget.SaveProfile(m.profile); err != nil {
+		if err := m.saveTarget.SaveEdits(m.shown, m.profile); err != nil {
 			return profileSaveMsg{err: err}
 		}
 		return profileSaveMsg{success: true}
 	}
 }
 
+// Saved marks the edits as saved, so the next save starts from them.
+func (m ProfileWidgetModel) Saved() ProfileWidgetModel {
+	m.shown = cloneSettings(m.profile)
+	m.modified = false
+	return m
+}
+
 func (m ProfileWidgetModel) Modified() bool {
 	return m.modified
 }
@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/jgabor/spela/internal/session"
+)
+
+const sessionsVisible = 8
+
+type SessionsModalModel struct {
+	visible  bool
+	gameName string
+	sessions []*session.Session
+	playtime time.Duration
+	cursor   int
+	offset   int
+	err      error
+	width    int
+	height   int
+}
+
+func NewSessionsModal() SessionsModalModel {
+	return SessionsModalModel{}
+}
+
+func (m *SessionsModalModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+// Open loads the recorded sessions of a game, newest first.
+func (m *SessionsModalModel) Open(appID uint64, gameName string) {
+	m.visible = true
+	m.gameName = gameName
+	m.cursor = 0
+	m.offset = 0
+
+	sessions, err := session.List(appID)
+	slices.Reverse(sessions)
+	m.sessions = sessions
+	m.playtime = session.Total(sessions)
+	m.err = err
+}
+
+func (m SessionsModalModel) Visible() bool {
+	return m.visible
+}
+
+func (m SessionsModalModel) Update(msg tea.Msg) (SessionsModalModel, tea.Cmd) {
+	if !m.visible {
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			if m.cursor < m.offset {
+				m.offset = m.cursor
+			}
+		case "down", "j":
+			if m.cursor < len(m.sessions)-1 {
+				m.cursor++
+			}
+			if m.cursor >= m.offset+sessionsVisible {
+				m.offset = m.cursor - sessionsVisible + 1
+			}
+		case "esc", "q":
+			m.visible = false
+		}
+	}
+
+	return m, nil
+}
+
+func (m SessionsModalModel) View() string {
+	if !m.visible {
+		return ""
+	}
+
+	t := GetTheme()
+
+	modalWidth := 70
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(modalWidth).
+		Padding(1, 2)
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Sessions: " + m.gameName))
+	b.WriteString("\n\n")
+
+	switch {
+	case m.err != nil:
+		b.WriteString(errorStyle.Render(fmt.Sprintf("Failed to load sessions: %v", m.err)))
+		b.WriteString("\n")
+	case len(m.sessions) == 0:
+		b.WriteString(dimStyle.Render("No sessions recorded yet"))
+		b.WriteString("\n")
+	default:
+		b.WriteString(dimStyle.Render(fmt.Sprintf("Total playtime: %s over %d sessions", session.FormatDuration(m.playtime), len(m.sessions))))
+		b.WriteString("\n\n")
+
+		end := min(m.offset+sessionsVisible, len(m.sessions))
+		for i := m.offset; i < end; i++ {
+			s := m.sessions[i]
+			cursor := "  "
+			style := normalStyle
+			if i == m.cursor {
+				cursor = "> "
+				style = selectedStyle
+			}
+
+			b.WriteString(style.Render(fmt.Sprintf("%s%4d  %s  %8s  ", cursor, s.Number, s.Start.Local().Format("2006-01-02 15:04"), session.FormatDuration(s.Duration()))))
+			b.WriteString(m.renderStatus(s))
+			b.WriteString("\n")
+		}
+
+		b.WriteString("\n")
+		b.WriteString(m.renderDetails(m.sessions[m.cursor]))
+	}
+
+	if hint := RenderHint("\n\n" + "↑↓:navigate • esc:close"); hint != "" {
+		b.WriteString(hint)
+	}
+
+	modal := boxStyle.Render(b.String())
+
+	centerX := max((m.width-modalWidth-4)/2, 0)
+	centerY := max((m.height-lipgloss.Height(modal))/2, 0)
+
+	positionedStyle := lipgloss.NewStyle().
+		MarginLeft(centerX).
+		MarginTop(centerY)
+
+	return positionedStyle.Render(modal)
+}
+
+func (m SessionsModalModel) renderStatus(s *session.Session) string {
+	status := s.Status()
+	switch {
+	case s.Running():
+		return dlssStyle.Render(status)
+	case s.ExitCode != nil && *s.ExitCode == 0:
+		return successStyle.Render(status)
+	case s.Finished():
+		return errorStyle.Render(status)
+	}
+	return dimStyle.Render(status)
+}
+
+func (m SessionsModalModel) renderDetails(s *session.Session) string {
+	var b strings.Builder
+
+	profileHash := "none"
+	if s.Profile.Hash != "" {
+		profileHash = s.Profile.Hash
+	}
+	b.WriteString(normalStyle.Render("Profile: "))
+	b.WriteString(dlssStyle.Render(profileHash))
+
+	for _, d := range s.DLLs {
+		version := d.Version
+		if version == "" {
+			version = "unknown"
+		}
+		b.WriteString("\n")
+		b.WriteString(normalStyle.Render(d.Name + ": "))
+		b.WriteString(dlssStyle.Render(version))
+	}
+
+	if len(s.Env) > 0 {
+		b.WriteString("\n")
+		b.WriteString(normalStyle.Render("Env: "))
+		b.WriteString(dimStyle.Render(strings.Join(slices.Sorted(maps.Keys(s.Env)), ", ")))
+	}
+	if s.Error != "" {
+		b.WriteString("\n")
+		b.WriteString(errorStyle.Render(s.Error))
+	}
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jgabor/spela/internal/dll"
+)
+
+// Artifact is a downloaded DLL together with the metadata recorded in the
+// manifest.
+type Artifact struct {
+	Path   string
+	SHA256 string
+	Size   int64
+	Info   *dll.PEInfo
+}
+
+// fetchArtifact downloads a release, extracts the DLL if it is packaged in a
+// zip archive, and verifies it against the release before returning it.
+func fetchArtifact(client *http.Client, release *Release, outDir string, checkSignature bool) (*Artifact, error) {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	download := filepath.Join(outDir, release.Type+".download")
+	defer func() { _ = os.Remove(download) }()
+
+	if err := downloadFile(client, release.DownloadURL, download); err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", release.DownloadURL, err)
+	}
+
+	dest := filepath.Join(outDir, release.Filename)
+	if err := extractDLL(download, release.Filename, dest); err != nil {
+		return nil, err
+	}
+
+	artifact, err := inspectArtifact(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyArtifact(artifact, release, checkSignature); err != nil {
+		return nil, err
+	}
+
+	return artifact, nil
+}
+
+func downloadFile(client *http.Client, rawURL, dest string) error {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// extractDLL copies filename out of the zip archive at src to dest. Files
+// that are not zip archives are assumed to be the DLL itself.
+func extractDLL(src, filename, dest string) error {
+	magic := make([]byte, 4)
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	_, _ = io.ReadFull(f, magic)
+	_ = f.Close()
+
+	if !bytes.Equal(magic, []byte("PK\x03\x04")) {
+		return copyFile(src, dest)
+	}
+
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	for _, file := range zr.File {
+		if !strings.EqualFold(path.Base(file.Name), filename) {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rc.Close() }()
+
+		out, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, rc); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	}
+
+	return fmt.Errorf("could not find %s in archive", filename)
+}
+
+func copyFile(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0o644)
+}
+
+func inspectArtifact(path string) (*Artifact, error) {
+	info, err := dll.ReadPEInfo(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PE metadata: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Artifact{
+		Path:   path,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+		Size:   size,
+		Info:   info,
+	}, nil
+}
+
+// verifyArtifact checks that the DLL is the 64-bit build of the version the
+// source advertised and, optionally, that it carries a vendor signature.
+func verifyArtifact(a *Artifact, release *Release, checkSignature bool) error {
+	if a.Info.FileVersion == "" {
+		return fmt.Errorf("%s has no version resource", release.Filename)
+	}
+	if dll.CompareVersions(a.Info.FileVersion, release.Version) != 0 {
+		return fmt.Errorf("%s reports version %s, source advertised %s", release.Filename, a.Info.FileVersion, release.Version)
+	}
+	if a.Info.Arch != dll.ArchX64 {
+		return fmt.Errorf("%s is built for %s, expected %s", release.Filename, a.Info.Arch, dll.ArchX64)
+	}
+
+	if checkSignature {
+		if _, err := dll.VerifyDLLSignature(a.Path, release.Filename); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
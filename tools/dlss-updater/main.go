@@ -4,235 +4,172 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"os"
-	"regexp"
 	"strings"
+	"time"
+
+	"github.com/jgabor/spela/internal/dll"
 )
 
+// Exit codes. The workflow relies on exitUpToDate to skip the release steps.
 const (
-	userAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	exitUpdate   = 0
+	exitFailure  = 1
+	exitUpToDate = 2
 )
 
-type DLLSource struct {
-	Type         string
-	TechPowerUp  string
-	Filename     string
-	TitlePattern string
-}
-
-var dllSources = []DLLSource{
-	{
-		Type:         "dlss",
-		TechPowerUp:  "https://www.techpowerup.com/download/nvidia-dlss-dll/",
-		Filename:     "nvngx_dlss.dll",
-		TitlePattern: `<title>NVIDIA DLSS DLL (\d+\.\d+\.\d+) Download`,
-	},
-	{
-		Type:         "dlssg",
-		TechPowerUp:  "https://www.techpowerup.com/download/nvidia-dlss-3-frame-generation-dll/",
-		Filename:     "nvngx_dlssg.dll",
-		TitlePattern: `<title>NVIDIA DLSS Frame Generation DLL (\d+\.\d+\.\d+) Download`,
-	},
-	{
-		Type:         "dlssd",
-		TechPowerUp:  "https://www.techpowerup.com/download/nvidia-dlss-3-ray-reconstruction-dll/",
-		Filename:     "nvngx_dlssd.dll",
-		TitlePattern: `<title>NVIDIA DLSS Ray Reconstruction DLL (\d+\.\d+\.\d+) Download`,
-	},
-}
-
-type Manifest struct {
-	Version   string           `json:"version"`
-	UpdatedAt string           `json:"updated_at"`
-	DLLs      map[string][]DLL `json:"dlls"`
-}
-
-type DLL struct {
-	Version string `json:"version"`
+// UpdateResult is printed with -json after a successful -update.
+type UpdateResult struct {
+	Release
+	Tag    string `json:"tag"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	URL    string `json:"url"`
 }
 
-type LatestVersion struct {
-	Type        string `json:"type"`
-	Version     string `json:"version"`
-	DownloadURL string `json:"download_url"`
-	Filename    string `json:"filename"`
-	IsNew       bool   `json:"is_new"`
-	Source      string `json:"source"`
+type options struct {
+	manifestPath   string
+	outDir         string
+	repository     string
+	checkSignature bool
 }
 
 func main() {
 	manifestPath := flag.String("manifest", "data/manifest.json", "Path to manifest.json")
-	dllType := flag.String("type", "dlss", "DLL type to check (dlss, dlssg, dlssd)")
+	dllType := flag.String("type", "dlss", "DLL type to check (dlss, dlssg, dlssd, xess)")
 	outputJSON := flag.Bool("json", false, "Output as JSON")
+	update := flag.Bool("update", false, "Download, verify and add a new release to the manifest")
+	validate := flag.Bool("validate", false, "Validate the manifest and exit")
+	outDir := flag.String("out", "dist", "Directory for downloaded DLLs")
+	repository := flag.String("repository", "", "GitHub repository hosting release assets (default: manifest repository)")
+	skipSignature := flag.Bool("skip-signature", false, "Do not require a vendor Authenticode signature")
 	flag.Parse()
 
-	source := findSource(*dllType)
-	if source == nil {
+	if *validate {
+		manifest, err := loadManifest(*manifestPath)
+		if err == nil {
+			err = validateManifest(manifest)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Manifest is invalid:\n%v\n", err)
+			os.Exit(exitFailure)
+		}
+		fmt.Printf("%s is valid\n", *manifestPath)
+		return
+	}
+
+	target := findTarget(*dllType)
+	if target == nil {
 		fmt.Fprintf(os.Stderr, "Unknown DLL type: %s\n", *dllType)
-		os.Exit(1)
+		os.Exit(exitFailure)
 	}
 
-	latest, err := fetchLatestVersion(source)
+	client := &http.Client{Timeout: 5 * time.Minute}
+
+	latest, err := target.Latest(client)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to fetch latest version: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitFailure)
 	}
 
-	current, err := getCurrentVersion(*manifestPath, *dllType)
+	var current string
+	manifest, err := loadManifest(*manifestPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: could not read manifest: %v\n", err)
-	}
-
-	latest.IsNew = current == "" || latest.Version != current
-
-	if *outputJSON {
-		enc := json.NewEncoder(os.Stdout)
-		_ = enc.Encode(latest)
 	} else {
-		if latest.IsNew {
-			fmt.Printf("New %s version available: %s\n", strings.ToUpper(source.Type), latest.Version)
-			fmt.Printf("Download URL: %s\n", latest.DownloadURL)
-			fmt.Printf("Filename: %s\n", latest.Filename)
-			fmt.Printf("Source: %s\n", latest.Source)
-			if current != "" {
-				fmt.Printf("Current version: %s\n", current)
-			}
-		} else {
-			fmt.Printf("%s is up to date: %s\n", strings.ToUpper(source.Type), latest.Version)
-		}
+		current = currentVersion(manifest, target.Type)
 	}
 
-	if latest.IsNew {
-		os.Exit(0)
-	} else {
-		os.Exit(2)
-	}
-}
+	latest.IsNew = current == "" || dll.IsNewer(current, latest.Version)
 
-func findSource(dllType string) *DLLSource {
-	for i := range dllSources {
-		if dllSources[i].Type == dllType {
-			return &dllSources[i]
+	if !latest.IsNew || !*update {
+		report(latest, current, *outputJSON)
+		if latest.IsNew {
+			os.Exit(exitUpdate)
 		}
+		os.Exit(exitUpToDate)
 	}
-	return nil
-}
-
-func fetchLatestVersion(source *DLLSource) (*LatestVersion, error) {
-	return fetchFromTechPowerUp(source)
-}
-
-func fetchFromTechPowerUp(source *DLLSource) (*LatestVersion, error) {
-	client := &http.Client{}
-
-	req, err := http.NewRequest("GET", source.TechPowerUp, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", userAgent)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	if manifest == nil {
+		fmt.Fprintln(os.Stderr, "Cannot update without a readable manifest")
+		os.Exit(exitFailure)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	result, err := runUpdate(client, manifest, latest, options{
+		manifestPath:   *manifestPath,
+		outDir:         *outDir,
+		repository:     *repository,
+		checkSignature: !*skipSignature,
+	}, time.Now())
 	if err != nil {
-		return nil, err
+		fmt.Fprintf(os.Stderr, "Update failed: %v\n", err)
+		os.Exit(exitFailure)
 	}
 
-	html := string(body)
-
-	// Extract version from title
-	titleRegex := regexp.MustCompile(source.TitlePattern)
-	matches := titleRegex.FindStringSubmatch(html)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("could not find version in page title")
+	if *outputJSON {
+		_ = json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		fmt.Printf("Added %s %s to %s\n", strings.ToUpper(result.Type), result.Version, *manifestPath)
+		fmt.Printf("File: %s\n", result.Path)
+		fmt.Printf("SHA256: %s\n", result.SHA256)
+		fmt.Printf("Size: %d bytes\n", result.Size)
+		fmt.Printf("Release: %s\n", result.Tag)
 	}
-	version := matches[1]
+}
 
-	// Extract file ID
-	idRegex := regexp.MustCompile(`<input type="hidden" name="id" value="(\d+)"`)
-	idMatches := idRegex.FindStringSubmatch(html)
-	if len(idMatches) < 2 {
-		return nil, fmt.Errorf("could not find file ID")
+func report(latest *Release, current string, outputJSON bool) {
+	if outputJSON {
+		_ = json.NewEncoder(os.Stdout).Encode(latest)
+		return
 	}
-	fileID := idMatches[1]
 
-	// Get actual download URL
-	downloadURL, err := getTechPowerUpDownloadURL(source.TechPowerUp, fileID)
-	if err != nil {
-		return nil, fmt.Errorf("could not get download URL: %w", err)
+	if latest.IsNew {
+		fmt.Printf("New %s version available: %s\n", strings.ToUpper(latest.Type), latest.Version)
+		fmt.Printf("Download URL: %s\n", latest.DownloadURL)
+		fmt.Printf("Filename: %s\n", latest.Filename)
+		fmt.Printf("Source: %s\n", latest.Source)
+		if current != "" {
+			fmt.Printf("Current version: %s\n", current)
+		}
+	} else {
+		fmt.Printf("%s is up to date: %s\n", strings.ToUpper(latest.Type), latest.Version)
 	}
-
-	return &LatestVersion{
-		Type:        source.Type,
-		Version:     version,
-		DownloadURL: downloadURL,
-		Filename:    source.Filename,
-		Source:      "techpowerup.com",
-	}, nil
 }
 
-func getTechPowerUpDownloadURL(baseURL, fileID string) (string, error) {
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+// runUpdate downloads and verifies a release, records it in the manifest and
+// writes the manifest back only if the result still validates.
+func runUpdate(client *http.Client, manifest *dll.Manifest, release *Release, opts options, now time.Time) (*UpdateResult, error) {
+	repository := opts.repository
+	if repository == "" {
+		repository = manifest.Repository
 	}
-
-	data := url.Values{}
-	data.Set("id", fileID)
-	data.Set("server_id", "27") // TechPowerUp NL server
-
-	req, err := http.NewRequest("POST", baseURL, strings.NewReader(data.Encode()))
-	if err != nil {
-		return "", err
+	if repository == "" {
+		return nil, fmt.Errorf("no repository configured for release assets")
 	}
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
+	artifact, err := fetchArtifact(client, release, opts.outDir, opts.checkSignature)
 	if err != nil {
-		return "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusFound {
-		return "", fmt.Errorf("expected redirect, got %d", resp.StatusCode)
-	}
-
-	location := resp.Header.Get("Location")
-	if location == "" {
-		return "", fmt.Errorf("no redirect location")
+		return nil, err
 	}
 
-	return location, nil
-}
+	entry := manifestEntry(release, artifact, repository, now)
+	addEntry(manifest, release.Type, entry, now)
 
-func getCurrentVersion(manifestPath, dllType string) (string, error) {
-	data, err := os.ReadFile(manifestPath)
-	if err != nil {
-		return "", err
+	if err := validateManifest(manifest); err != nil {
+		return nil, fmt.Errorf("updated manifest is invalid: %w", err)
 	}
-
-	var manifest Manifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return "", err
+	if err := saveManifest(opts.manifestPath, manifest); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
 	}
 
-	dlls, ok := manifest.DLLs[dllType]
-	if !ok || len(dlls) == 0 {
-		return "", nil
-	}
-
-	return strings.TrimPrefix(dlls[0].Version, "v"), nil
+	return &UpdateResult{
+		Release: *release,
+		Tag:     releaseTag(release.Type, release.Version),
+		Path:    artifact.Path,
+		SHA256:  artifact.SHA256,
+		Size:    artifact.Size,
+		URL:     entry.URL,
+	}, nil
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgabor/spela/internal/dll"
+)
+
+func TestRunUpdate(t *testing.T) {
+	server := newFixtureServer(t)
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest.json")
+
+	manifest := &dll.Manifest{
+		Version:    "1",
+		UpdatedAt:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Repository: "jgabor/spela-dlls",
+		DLLs:       map[string][]dll.DLL{"dlss": {validEntry("310.4.0")}},
+	}
+	release := &Release{
+		Type:        "dlss",
+		Version:     "310.5.0",
+		DownloadURL: server.URL + "/files/dlss_310.5.0.zip",
+		Filename:    "nvngx_dlss.dll",
+		Source:      "techpowerup.com",
+	}
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	// The fixture is not Authenticode signed, so signature checks are covered
+	// by the dll package instead.
+	result, err := runUpdate(server.Client(), manifest, release, options{
+		manifestPath: manifestPath,
+		outDir:       filepath.Join(dir, "dist"),
+	}, now)
+	if err != nil {
+		t.Fatalf("runUpdate: %v", err)
+	}
+
+	if result.Tag != "dll-dlss-v310.5.0" {
+		t.Errorf("Tag = %q", result.Tag)
+	}
+	wantURL := "https://github.com/jgabor/spela-dlls/releases/download/dll-dlss-v310.5.0/nvngx_dlss.dll"
+	if result.URL != wantURL {
+		t.Errorf("URL = %q, want %q", result.URL, wantURL)
+	}
+
+	info, err := os.Stat(result.Path)
+	if err != nil {
+		t.Fatalf("extracted DLL: %v", err)
+	}
+	if info.Size() != result.Size {
+		t.Errorf("Size = %d, file has %d bytes", result.Size, info.Size())
+	}
+
+	saved, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := saved.GetLatestDLL("dlss")
+	if latest.Version != "310.5.0" || latest.SHA256 != result.SHA256 || latest.Notes != "From techpowerup.com" {
+		t.Errorf("latest entry = %+v", latest)
+	}
+	if len(saved.DLLs["dlss"]) != 2 {
+		t.Errorf("dlss entries = %d, want 2", len(saved.DLLs["dlss"]))
+	}
+}
+
+func TestRunUpdateRejectsVersionMismatch(t *testing.T) {
+	server := newFixtureServer(t)
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest.json")
+
+	manifest := &dll.Manifest{Version: "1", Repository: "jgabor/spela-dlls", DLLs: map[string][]dll.DLL{}}
+	release := &Release{
+		Type:        "dlss",
+		Version:     "310.6.0",
+		DownloadURL: server.URL + "/files/dlss_310.5.0.zip",
+		Filename:    "nvngx_dlss.dll",
+		Source:      "techpowerup.com",
+	}
+
+	_, err := runUpdate(server.Client(), manifest, release, options{manifestPath: manifestPath, outDir: dir}, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "source advertised 310.6.0") {
+		t.Fatalf("got %v, want version mismatch", err)
+	}
+	if _, err := os.Stat(manifestPath); !os.IsNotExist(err) {
+		t.Errorf("manifest written despite failed verification")
+	}
+}
+
+func TestRunUpdateRejectsNonPE(t *testing.T) {
+	server := newFixtureServer(t)
+	dir := t.TempDir()
+
+	manifest := &dll.Manifest{Version: "1", Repository: "jgabor/spela-dlls", DLLs: map[string][]dll.DLL{}}
+	release := &Release{
+		Type:        "dlss",
+		Version:     "310.5.0",
+		DownloadURL: server.URL + "/files/nvngx_dlss.dll",
+		Filename:    "nvngx_dlss.dll",
+	}
+
+	opts := options{manifestPath: filepath.Join(dir, "manifest.json"), outDir: dir}
+	if _, err := runUpdate(server.Client(), manifest, release, opts, time.Now()); err == nil {
+		t.Fatal("expected error for a download that is not a PE image")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jgabor/spela/internal/dll"
+)
+
+const releaseURLFormat = "https://github.com/%s/releases/download/%s/%s"
+
+// manifestFilenames lists the DLL types the manifest may contain and the
+// filename each type is published under.
+var manifestFilenames = map[string]string{
+	"dlss":  "nvngx_dlss.dll",
+	"dlssg": "nvngx_dlssg.dll",
+	"dlssd": "nvngx_dlssd.dll",
+	"xess":  "libxess.dll",
+	"fsr":   "amd_fidelityfx_dx12.dll",
+}
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func loadManifest(path string) (*dll.Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest dll.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.DLLs == nil {
+		manifest.DLLs = make(map[string][]dll.DLL)
+	}
+
+	return &manifest, nil
+}
+
+func saveManifest(path string, manifest *dll.Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func currentVersion(manifest *dll.Manifest, dllType string) string {
+	if latest := manifest.GetLatestDLL(dllType); latest != nil {
+		return strings.TrimPrefix(latest.Version, "v")
+	}
+	return ""
+}
+
+func releaseTag(dllType, version string) string {
+	return fmt.Sprintf("dll-%s-v%s", dllType, version)
+}
+
+// manifestEntry builds the manifest record for a verified artifact hosted as
+// a release asset of repository.
+func manifestEntry(release *Release, artifact *Artifact, repository string, now time.Time) dll.DLL {
+	return dll.DLL{
+		Version:     release.Version,
+		Filename:    release.Filename,
+		URL:         fmt.Sprintf(releaseURLFormat, repository, releaseTag(release.Type, release.Version), release.Filename),
+		SHA256:      artifact.SHA256,
+		Size:        artifact.Size,
+		ReleaseDate: now.UTC().Truncate(time.Second),
+		Notes:       "From " + release.Source,
+	}
+}
+
+// addEntry inserts entry and keeps the list for dllType ordered newest first,
+// which GetLatestDLL relies on.
+func addEntry(manifest *dll.Manifest, dllType string, entry dll.DLL, now time.Time) {
+	manifest.AddDLL(dllType, entry)
+	manifest.UpdatedAt = now.UTC().Truncate(time.Second)
+}
+
+// validateManifest checks the whole manifest and reports every problem found.
+func validateManifest(manifest *dll.Manifest) error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if manifest.Version == "" {
+		add("manifest version is missing")
+	}
+	if manifest.UpdatedAt.IsZero() {
+		add("updated_at is missing")
+	}
+	if manifest.DLLs == nil {
+		add("dlls is missing")
+	}
+
+	types := make([]string, 0, len(manifest.DLLs))
+	for dllType := range manifest.DLLs {
+		types = append(types, dllType)
+	}
+	sort.Strings(types)
+
+	for _, dllType := range types {
+		entries := manifest.DLLs[dllType]
+		filename, known := manifestFilenames[dllType]
+		if !known {
+			add("%s: unknown DLL type", dllType)
+		}
+
+		seen := make(map[string]bool)
+		for i, entry := range entries {
+			where := fmt.Sprintf("%s[%d]", dllType, i)
+
+			if entry.Version == "" {
+				add("%s: version is missing", where)
+			} else if seen[entry.Version] {
+				add("%s: duplicate version %s", where, entry.Version)
+			}
+			seen[entry.Version] = true
+
+			if i > 0 && dll.CompareVersions(entries[i-1].Version, entry.Version) <= 0 {
+				add("%s: %s is listed after %s; entries must be newest first", where, entry.Version, entries[i-1].Version)
+			}
+			if known && entry.Filename != filename {
+				add("%s: filename %q, expected %q", where, entry.Filename, filename)
+			}
+			if u, err := url.Parse(entry.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+				add("%s: url %q is not an https URL", where, entry.URL)
+			}
+			if !sha256Pattern.MatchString(entry.SHA256) {
+				add("%s: sha256 %q is not a lowercase hex SHA-256", where, entry.SHA256)
+			}
+			if entry.Size <= 0 {
+				add("%s: size must be positive", where)
+			}
+			if entry.ReleaseDate.IsZero() {
+				add("%s: release_date is missing", where)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
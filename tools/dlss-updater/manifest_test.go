@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgabor/spela/internal/dll"
+)
+
+func validEntry(version string) dll.DLL {
+	return dll.DLL{
+		Version:     version,
+		Filename:    "nvngx_dlss.dll",
+		URL:         "https://github.com/jgabor/spela-dlls/releases/download/dll-dlss-v" + version + "/nvngx_dlss.dll",
+		SHA256:      strings.Repeat("ab", 32),
+		Size:        1024,
+		ReleaseDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestAddEntryKeepsNewestFirst(t *testing.T) {
+	manifest := &dll.Manifest{Version: "1", DLLs: map[string][]dll.DLL{
+		"dlss": {validEntry("310.2.1"), validEntry("310.1.0")},
+	}}
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	addEntry(manifest, "dlss", validEntry("310.10.0"), now)
+	addEntry(manifest, "dlss", validEntry("310.2.0"), now)
+
+	var got []string
+	for _, entry := range manifest.DLLs["dlss"] {
+		got = append(got, entry.Version)
+	}
+	want := "310.10.0 310.2.1 310.2.0 310.1.0"
+	if strings.Join(got, " ") != want {
+		t.Errorf("order = %v, want %s", got, want)
+	}
+	if manifest.GetLatestDLL("dlss").Version != "310.10.0" {
+		t.Errorf("GetLatestDLL = %s", manifest.GetLatestDLL("dlss").Version)
+	}
+	if !manifest.UpdatedAt.Equal(now) {
+		t.Errorf("UpdatedAt = %v", manifest.UpdatedAt)
+	}
+	if err := validateManifest(manifest); err != nil {
+		t.Errorf("validateManifest: %v", err)
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	updated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		mutate func(m *dll.Manifest)
+		want   string
+	}{
+		{"valid", func(m *dll.Manifest) {}, ""},
+		{"missing version", func(m *dll.Manifest) { m.Version = "" }, "manifest version is missing"},
+		{"unknown type", func(m *dll.Manifest) { m.DLLs["nis"] = nil }, "nis: unknown DLL type"},
+		{"out of order", func(m *dll.Manifest) {
+			m.DLLs["dlss"] = []dll.DLL{validEntry("310.1.0"), validEntry("310.2.0")}
+		}, "entries must be newest first"},
+		{"duplicate", func(m *dll.Manifest) {
+			m.DLLs["dlss"] = []dll.DLL{validEntry("310.1.0"), validEntry("310.1.0")}
+		}, "duplicate version"},
+		{"bad hash", func(m *dll.Manifest) { m.DLLs["dlss"][0].SHA256 = "ABC" }, "not a lowercase hex SHA-256"},
+		{"http url", func(m *dll.Manifest) { m.DLLs["dlss"][0].URL = "http://example.com/x.dll" }, "not an https URL"},
+		{"wrong filename", func(m *dll.Manifest) { m.DLLs["dlss"][0].Filename = "nvngx_dlssg.dll" }, "expected \"nvngx_dlss.dll\""},
+		{"zero size", func(m *dll.Manifest) { m.DLLs["dlss"][0].Size = 0 }, "size must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &dll.Manifest{Version: "1", UpdatedAt: updated, DLLs: map[string][]dll.DLL{
+				"dlss": {validEntry("310.1.0")},
+				"xess": {},
+			}}
+			tt.mutate(manifest)
+
+			err := validateManifest(manifest)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepositoryManifestIsValid(t *testing.T) {
+	manifest, err := loadManifest(filepath.Join("..", "..", "data", "manifest.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("data/manifest.json not present")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateManifest(manifest); err != nil {
+		t.Errorf("data/manifest.json: %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	userAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+)
+
+// Release describes the newest upstream build of a DLL as reported by a source.
+type Release struct {
+	Type        string `json:"type"`
+	Version     string `json:"version"`
+	DownloadURL string `json:"download_url"`
+	Filename    string `json:"filename"`
+	IsNew       bool   `json:"is_new"`
+	Source      string `json:"source"`
+}
+
+// Source looks up the latest release of a target from one upstream.
+type Source interface {
+	Name() string
+	Latest(client *http.Client, target *Target) (*Release, error)
+}
+
+// Target is a DLL type tracked in the manifest and the sources that are
+// tried, in order, to find its latest release.
+type Target struct {
+	Type     string
+	Filename string
+	Sources  []Source
+}
+
+var targets = []Target{
+	{
+		Type:     "dlss",
+		Filename: "nvngx_dlss.dll",
+		Sources: []Source{&TechPowerUp{
+			Page:         "https://www.techpowerup.com/download/nvidia-dlss-dll/",
+			TitlePattern: `<title>NVIDIA DLSS DLL (\d+\.\d+\.\d+) Download`,
+		}},
+	},
+	{
+		Type:     "dlssg",
+		Filename: "nvngx_dlssg.dll",
+		Sources: []Source{&TechPowerUp{
+			Page:         "https://www.techpowerup.com/download/nvidia-dlss-3-frame-generation-dll/",
+			TitlePattern: `<title>NVIDIA DLSS Frame Generation DLL (\d+\.\d+\.\d+) Download`,
+		}},
+	},
+	{
+		Type:     "dlssd",
+		Filename: "nvngx_dlssd.dll",
+		Sources: []Source{&TechPowerUp{
+			Page:         "https://www.techpowerup.com/download/nvidia-dlss-3-ray-reconstruction-dll/",
+			TitlePattern: `<title>NVIDIA DLSS Ray Reconstruction DLL (\d+\.\d+\.\d+) Download`,
+		}},
+	},
+	{
+		Type:     "xess",
+		Filename: "libxess.dll",
+		Sources: []Source{&GitHubRelease{
+			Repo:         "intel/xess",
+			AssetPattern: `(?i)^xess_sdk_.*\.zip$`,
+		}},
+	},
+}
+
+func findTarget(dllType string) *Target {
+	for i := range targets {
+		if targets[i].Type == dllType {
+			return &targets[i]
+		}
+	}
+	return nil
+}
+
+// Latest returns the release reported by the first source that succeeds.
+func (t *Target) Latest(client *http.Client) (*Release, error) {
+	var errs []error
+	for _, source := range t.Sources {
+		release, err := source.Latest(client, t)
+		if err == nil {
+			return release, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no sources configured for %s", t.Type)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// TechPowerUp scrapes a TechPowerUp download page for the version in its
+// title and resolves the mirror redirect to a direct download URL.
+type TechPowerUp struct {
+	Page         string
+	TitlePattern string
+	ServerID     string
+}
+
+func (s *TechPowerUp) Name() string {
+	return "techpowerup.com"
+}
+
+func (s *TechPowerUp) Latest(client *http.Client, target *Target) (*Release, error) {
+	body, err := get(client, s.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	html := string(body)
+
+	// Extract version from title
+	titleRegex := regexp.MustCompile(s.TitlePattern)
+	matches := titleRegex.FindStringSubmatch(html)
+	if len(matches) < 2 {
+		return nil, fmt.Errorf("could not find version in page title")
+	}
+	version := matches[1]
+
+	// Extract file ID
+	idRegex := regexp.MustCompile(`<input type="hidden" name="id" value="(\d+)"`)
+	idMatches := idRegex.FindStringSubmatch(html)
+	if len(idMatches) < 2 {
+		return nil, fmt.Errorf("could not find file ID")
+	}
+	fileID := idMatches[1]
+
+	// Get actual download URL
+	downloadURL, err := s.downloadURL(client, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get download URL: %w", err)
+	}
+
+	return &Release{
+		Type:        target.Type,
+		Version:     version,
+		DownloadURL: downloadURL,
+		Filename:    target.Filename,
+		Source:      s.Name(),
+	}, nil
+}
+
+func (s *TechPowerUp) downloadURL(client *http.Client, fileID string) (string, error) {
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	serverID := s.ServerID
+	if serverID == "" {
+		serverID = "27" // TechPowerUp NL server
+	}
+
+	data := url.Values{}
+	data.Set("id", fileID)
+	data.Set("server_id", serverID)
+
+	req, err := http.NewRequest("POST", s.Page, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := noRedirect.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusFound {
+		return "", fmt.Errorf("expected redirect, got %d", resp.StatusCode)
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("no redirect location")
+	}
+
+	return location, nil
+}
+
+// GitHubRelease reads the latest release of a GitHub repository and picks
+// the first asset whose name matches AssetPattern.
+type GitHubRelease struct {
+	Repo         string
+	AssetPattern string
+	APIBase      string
+}
+
+func (s *GitHubRelease) Name() string {
+	return "github.com/" + s.Repo
+}
+
+func (s *GitHubRelease) Latest(client *http.Client, target *Target) (*Release, error) {
+	apiBase := s.APIBase
+	if apiBase == "" {
+		apiBase = "https://api.github.com"
+	}
+
+	body, err := get(client, fmt.Sprintf("%s/repos/%s/releases/latest", apiBase, s.Repo))
+	if err != nil {
+		return nil, err
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+
+	version := regexp.MustCompile(`\d+(\.\d+)+`).FindString(release.TagName)
+	if version == "" {
+		return nil, fmt.Errorf("could not find version in tag %q", release.TagName)
+	}
+
+	assetRegex := regexp.MustCompile(s.AssetPattern)
+	for _, asset := range release.Assets {
+		if assetRegex.MatchString(asset.Name) {
+			return &Release{
+				Type:        target.Type,
+				Version:     version,
+				DownloadURL: asset.BrowserDownloadURL,
+				Filename:    target.Filename,
+				Source:      s.Name(),
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no asset matching %s in release %s", s.AssetPattern, release.TagName)
+}
+
+func get(client *http.Client, rawURL string) ([]byte, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
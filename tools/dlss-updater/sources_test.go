@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFixtureServer serves the TechPowerUp page, its mirror redirect, the
+// GitHub release API and the zipped DLL from testdata.
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/download/nvidia-dlss-dll/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if err := r.ParseForm(); err != nil || r.Form.Get("id") != "3412" {
+				http.Error(w, "bad form", http.StatusBadRequest)
+				return
+			}
+			http.Redirect(w, r, server.URL+"/files/dlss_310.5.0.zip", http.StatusFound)
+			return
+		}
+		http.ServeFile(w, r, "testdata/techpowerup.html")
+	})
+	mux.HandleFunc("/repos/intel/xess/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/github_release.json")
+	})
+	mux.HandleFunc("/files/dlss_310.5.0.zip", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/dlss_310.5.0.zip")
+	})
+	mux.HandleFunc("/files/nvngx_dlss.dll", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not a PE image"))
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTechPowerUpLatest(t *testing.T) {
+	server := newFixtureServer(t)
+	target := &Target{Type: "dlss", Filename: "nvngx_dlss.dll"}
+	source := &TechPowerUp{
+		Page:         server.URL + "/download/nvidia-dlss-dll/",
+		TitlePattern: `<title>NVIDIA DLSS DLL (\d+\.\d+\.\d+) Download`,
+	}
+
+	release, err := source.Latest(server.Client(), target)
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if release.Version != "310.5.0" {
+		t.Errorf("Version = %q, want 310.5.0", release.Version)
+	}
+	if release.DownloadURL != server.URL+"/files/dlss_310.5.0.zip" {
+		t.Errorf("DownloadURL = %q", release.DownloadURL)
+	}
+	if release.Source != "techpowerup.com" {
+		t.Errorf("Source = %q", release.Source)
+	}
+}
+
+func TestGitHubReleaseLatest(t *testing.T) {
+	server := newFixtureServer(t)
+	target := &Target{Type: "xess", Filename: "libxess.dll"}
+	source := &GitHubRelease{Repo: "intel/xess", AssetPattern: `(?i)^xess_sdk_.*\.zip$`, APIBase: server.URL}
+
+	release, err := source.Latest(server.Client(), target)
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if release.Version != "2.1.0" {
+		t.Errorf("Version = %q, want 2.1.0", release.Version)
+	}
+	if release.DownloadURL != "https://github.com/intel/xess/releases/download/v2.1.0/XeSS_SDK_2.1.0.zip" {
+		t.Errorf("DownloadURL = %q", release.DownloadURL)
+	}
+}
+
+func TestTargetFallsBackToNextSource(t *testing.T) {
+	server := newFixtureServer(t)
+	target := &Target{
+		Type:     "dlss",
+		Filename: "nvngx_dlss.dll",
+		Sources: []Source{
+			&GitHubRelease{Repo: "missing/repo", AssetPattern: ".*", APIBase: server.URL},
+			&TechPowerUp{
+				Page:         server.URL + "/download/nvidia-dlss-dll/",
+				TitlePattern: `<title>NVIDIA DLSS DLL (\d+\.\d+\.\d+) Download`,
+			},
+		},
+	}
+
+	release, err := target.Latest(server.Client())
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if release.Source != "techpowerup.com" {
+		t.Errorf("Source = %q, want techpowerup.com", release.Source)
+	}
+}